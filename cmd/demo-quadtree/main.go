@@ -54,12 +54,8 @@ func main() {
 		panic(err)
 	}
 
-	var space plane.Space2D[uint32]
-	if config.World.WorldWrap {
-		space = plane.NewToroidal2D(worldRes.Side(), worldRes.Side())
-	} else {
-		space = plane.NewEuclidean2D(worldRes.Side(), worldRes.Side())
-	}
+	wrapX, wrapY := config.World.WrapAxes()
+	space := grid.NewWrapSpace(worldRes.Side(), worldRes.Side(), wrapX, wrapY)
 	manager := grid.NewMultiBucketGridManager(
 		space,
 		worldRes,
@@ -128,7 +124,7 @@ func handleEvent(event gfx.Event, ctx *Context) {
 		fmt.Println("Window exposed")
 	case gfx.KeyPress:
 		fmt.Printf("Key pressed [code=%d lable=%s]\n", e.Code, e.Label)
-		if e.Code == 65307 {
+		if e.Key == gfx.KeyEscape {
 			ctx.window.Stop()
 		}
 	case gfx.KeyRelease:
@@ -137,7 +133,7 @@ func handleEvent(event gfx.Event, ctx *Context) {
 		if e.Button == 1 {
 			fmt.Printf("Left Mouse Button pressed %d %d\n", e.X, e.Y)
 			ctx.lmbPressed = true
-			drawDots(e.X, e.Y, ctx)
+			drawDots(e, ctx)
 		}
 	case gfx.ButtonRelease:
 		if e.Button == 1 {
@@ -146,12 +142,12 @@ func handleEvent(event gfx.Event, ctx *Context) {
 		}
 	case gfx.MotionNotify:
 		if ctx.lmbPressed {
-			drawDots(e.X, e.Y, ctx)
+			drawDots(e, ctx)
 		}
 	case gfx.EnterNotify:
-		fmt.Println("Mouse enter notify")
+		fmt.Printf("Mouse enter notify [x=%d y=%d]\n", e.X, e.Y)
 	case gfx.LeaveNotify:
-		fmt.Println("Mouse leave notify")
+		fmt.Printf("Mouse leave notify [x=%d y=%d]\n", e.X, e.Y)
 	case gfx.CreateNotify:
 		fmt.Println("Window created")
 	case gfx.DestroyNotify:
@@ -166,11 +162,13 @@ func handleEvent(event gfx.Event, ctx *Context) {
 	}
 }
 
-func drawDots(wX, wY int, ctx *Context) {
+func drawDots(event gfx.Event, ctx *Context) {
 	pane := ctx.window.GetDefaultPane()
-	px, py := pane.WindowToWorldCoords(wX, wY)
+	vec, ok := pane.PointerWorld(event)
+	if !ok {
+		return
+	}
 	layer1 := pane.GetLayer(1)
-	vec := geom.NewVec(px, py)
 	planeBox := ctx.plane.WrapVec(vec)
 	drawable := &gfx.Drawable{
 		AABB:  planeBox,
@@ -0,0 +1,79 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"image/color"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+	"github.com/kjkrol/gokx/internal/renderer"
+	"github.com/kjkrol/gokx/pkg/gfx"
+	"github.com/kjkrol/gokx/pkg/grid"
+	"github.com/kjkrol/gokx/pkg/gridbridge"
+)
+
+//go:embed shader.glsl
+var shaderSource string
+
+func main() {
+	worldRes := spatial.Size512x512
+
+	config := gfx.WindowConfig{
+		Width:  int(worldRes.Side()),
+		Height: int(worldRes.Side()),
+		Title:  "Gradient Demo",
+		World: gfx.WorldConfig{
+			WorldResolution: worldRes,
+			WorldWrap:       true,
+		},
+	}
+
+	bridge := gridbridge.NewBridge()
+	window := gfx.NewWindow(config, renderer.NewAutoRendererFactory(renderer.RendererConfig{ShaderSource: shaderSource}, bridge))
+	window.SetDrawableEventsApplier(bridge)
+	defer window.Close()
+
+	pane := window.GetDefaultPane()
+	pane.AddLayer(1)
+
+	torus := plane.NewToroidal2D(worldRes.Side(), worldRes.Side())
+	manager := grid.NewMultiBucketGridManager(torus, worldRes, 2, spatial.Size64x64, 16)
+	bridge.AttachPane(pane, manager)
+
+	layer := pane.GetLayer(1)
+	layer.AddDrawable(&gfx.Drawable{
+		AABB: torus.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](40, 40), 200, 120)),
+		Style: gfx.SpatialStyle{
+			Stroke: color.White,
+			Gradient: &gfx.Gradient{
+				From: color.RGBA{255, 0, 0, 255},
+				To:   color.RGBA{0, 0, 255, 255},
+				Dir:  gfx.GradientHorizontal,
+			},
+		},
+	})
+	// Crosses the right/bottom world seam to show the ramp stays continuous.
+	layer.AddDrawable(&gfx.Drawable{
+		AABB: torus.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](400, 300), 200, 260)),
+		Style: gfx.SpatialStyle{
+			Gradient: &gfx.Gradient{
+				From: color.RGBA{255, 255, 0, 255},
+				To:   color.RGBA{0, 160, 0, 128},
+				Dir:  gfx.GradientVertical,
+			},
+		},
+	})
+
+	window.Show()
+	window.RefreshRate(30)
+	window.SetRenderOnDemand(true)
+	window.ListenEvents(func(event gfx.Event) {
+		if e, ok := event.(gfx.KeyPress); ok && e.Key == gfx.KeyEscape {
+			window.Stop()
+		}
+	})
+
+	fmt.Println("Program closed")
+}
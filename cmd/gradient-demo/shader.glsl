@@ -0,0 +1,127 @@
+#ifdef VERTEX
+#if defined(PASS_COLOR)
+layout(location = 0) in vec2 aPos;
+INSTANCE_ATTRIBUTES
+
+uniform vec2 uViewport;
+uniform vec2 uOrigin;
+uniform vec2 uWorld;
+uniform bool uWrap;
+
+out vec2 vLocal;
+out vec2 vSize;
+out vec4 vFill;
+out vec4 vStroke;
+out vec4 vFillTo;
+out vec4 vGradient;
+out vec4 vUV;
+
+void main() {
+	vec2 tl = iRect.xy;
+	vec2 br = iRect.zw;
+	if (uWrap && tl.x < uOrigin.x) {
+		tl.x += uWorld.x;
+		br.x += uWorld.x;
+	}
+	if (uWrap && tl.y < uOrigin.y) {
+		tl.y += uWorld.y;
+		br.y += uWorld.y;
+	}
+	vec2 size = br - tl;
+	vec2 pos = (tl - uOrigin) + aPos * size;
+	vec2 ndc = vec2(
+		(pos.x / uViewport.x) * 2.0 - 1.0,
+		1.0 - (pos.y / uViewport.y) * 2.0
+	);
+	gl_Position = vec4(ndc, 0.0, 1.0);
+	vLocal = aPos;
+	vSize = size;
+	vFill = iFill;
+	vStroke = iStroke;
+	vFillTo = iFillTo;
+	vGradient = iGradient;
+	vUV = iUV;
+}
+#elif defined(PASS_COMPOSITE)
+layout(location = 0) in vec2 aPos;
+
+uniform vec2 uViewport;
+uniform vec4 uRect;
+uniform vec4 uTexRect;
+
+out vec2 vUV;
+
+void main() {
+	vec2 pos = mix(uRect.xy, uRect.zw, aPos);
+	vec2 ndc = vec2(
+		(pos.x / uViewport.x) * 2.0 - 1.0,
+		1.0 - (pos.y / uViewport.y) * 2.0
+	);
+	gl_Position = vec4(ndc, 0.0, 1.0);
+	vec2 uv = mix(uTexRect.xy, uTexRect.zw, aPos);
+	vUV = vec2(uv.x, 1.0 - uv.y);
+}
+#endif
+#endif
+
+#ifdef FRAGMENT
+#if defined(PASS_COLOR)
+in vec2 vLocal;
+in vec2 vSize;
+in vec4 vFill;
+in vec4 vStroke;
+in vec4 vFillTo;
+in vec4 vGradient;
+in vec4 vUV;
+
+uniform sampler2D uSprite;
+uniform bool uPremultiply;
+
+out vec4 outColor;
+
+// finish premultiplies the color for blend modes that expect it.
+vec4 finish(vec4 color) {
+	if (uPremultiply) {
+		color.rgb *= color.a;
+	}
+	return color;
+}
+
+void main() {
+	float strokeWidth = 1.0;
+	if (vStroke.a > 0.0) {
+		vec2 dist = min(vLocal * vSize, (1.0 - vLocal) * vSize);
+		float edge = min(dist.x, dist.y);
+		if (edge < strokeWidth) {
+			outColor = finish(vStroke);
+			return;
+		}
+	}
+	vec4 fill = vFill;
+	if (vUV != vec4(0.0)) {
+		fill = texture(uSprite, mix(vUV.xy, vUV.zw, vLocal));
+	} else if (vGradient.x > 0.5) {
+		float along = vGradient.x < 1.5 ? vLocal.x : vLocal.y;
+		fill = mix(vFill, vFillTo, mix(vGradient.y, vGradient.z, along));
+	}
+	if (fill.a <= 0.0) {
+		discard;
+	}
+	outColor = finish(fill);
+}
+#elif defined(PASS_COMPOSITE)
+in vec2 vUV;
+
+uniform sampler2D uTex;
+uniform vec4 uTint;
+uniform float uTintStrength;
+
+out vec4 outColor;
+
+void main() {
+	vec4 color = texture(uTex, vUV);
+	color.rgb = mix(color.rgb, uTint.rgb, uTintStrength);
+	outColor = color;
+}
+#endif
+#endif
@@ -0,0 +1,151 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+	"github.com/kjkrol/gokx/internal/renderer"
+	"github.com/kjkrol/gokx/pkg/gfx"
+	"github.com/kjkrol/gokx/pkg/grid"
+	"github.com/kjkrol/gokx/pkg/gridbridge"
+)
+
+//go:embed shader.glsl
+var shaderSource string
+
+// The pane renders at 160x112 and is upscaled 4x into the 640x480 window,
+// leaving a 16 px letterbox above and below.
+const (
+	windowWidth    = 640
+	windowHeight   = 480
+	internalWidth  = 160
+	internalHeight = 112
+)
+
+func main() {
+	worldRes := spatial.Size256x256
+
+	config := gfx.WindowConfig{
+		Width:  windowWidth,
+		Height: windowHeight,
+		Title:  "Pixel Art Demo",
+		World: gfx.WorldConfig{
+			WorldResolution: worldRes,
+			WorldWrap:       true,
+		},
+	}
+
+	bridge := gridbridge.NewBridge()
+	window := gfx.NewWindow(config, renderer.NewAutoRendererFactory(renderer.RendererConfig{ShaderSource: shaderSource}, bridge))
+	window.SetDrawableEventsApplier(bridge)
+	defer window.Close()
+
+	pane := window.AddPane("pixels", &gfx.PaneConfig{
+		Width:          windowWidth,
+		Height:         windowHeight,
+		InternalWidth:  internalWidth,
+		InternalHeight: internalHeight,
+		World:          config.World,
+	})
+	pane.AddLayer(1)
+	pane.AddLayer(2)
+
+	torus := plane.NewToroidal2D(worldRes.Side(), worldRes.Side())
+	manager := grid.NewMultiBucketGridManager(torus, worldRes, 2, spatial.Size32x32, 16)
+	bridge.AttachPane(pane, manager)
+
+	layer := pane.GetLayer(1)
+	palette := []color.RGBA{
+		{255, 0, 77, 255},
+		{255, 163, 0, 255},
+		{255, 236, 39, 255},
+		{0, 228, 54, 255},
+		{41, 173, 255, 255},
+		{131, 118, 156, 255},
+	}
+	// A checker of one-unit tiles shows every world unit as a crisp 4x4 block.
+	for y := uint32(0); y < 16; y++ {
+		for x := uint32(0); x < 16; x++ {
+			if (x+y)%2 == 0 {
+				continue
+			}
+			layer.AddDrawable(&gfx.Drawable{
+				AABB:  torus.WrapAABB(geom.NewAABBAt(geom.NewVec(8+x, 8+y), 1, 1)),
+				Style: gfx.SpatialStyle{Fill: color.White},
+			})
+		}
+	}
+	for i, c := range palette {
+		layer.AddDrawable(&gfx.Drawable{
+			AABB: torus.WrapAABB(geom.NewAABBAt(geom.NewVec(uint32(32+i*20), 48), 12, 12)),
+			Style: gfx.SpatialStyle{
+				Fill:   c,
+				Stroke: color.Black,
+			},
+		})
+	}
+
+	window.Show()
+
+	// An 8x8 sprite drawn at 2x: each texel covers 2x2 world units.
+	heart, err := window.LoadTexture(heartImage())
+	if err != nil {
+		panic(err)
+	}
+	pane.GetLayer(2).AddDrawable(gfx.Sprite{
+		AABB:      torus.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](72, 72), 16, 16)),
+		TextureID: heart,
+	}.Drawable())
+
+	window.RefreshRate(30)
+	window.SetRenderOnDemand(true)
+	window.ListenEvents(func(event gfx.Event) {
+		e, ok := event.(gfx.KeyPress)
+		if !ok {
+			return
+		}
+		// Arrow keys scroll one world unit, i.e. one upscaled pixel block.
+		switch e.Key {
+		case gfx.KeyEscape:
+			window.Stop()
+		case gfx.KeyLeft:
+			pane.Viewport().Move(-1, 0)
+		case gfx.KeyRight:
+			pane.Viewport().Move(1, 0)
+		case gfx.KeyUp:
+			pane.Viewport().Move(0, -1)
+		case gfx.KeyDown:
+			pane.Viewport().Move(0, 1)
+		}
+	})
+
+	fmt.Println("Program closed")
+}
+
+// heartImage returns an 8x8 heart on a transparent background.
+func heartImage() image.Image {
+	rows := []string{
+		"........",
+		".##..##.",
+		"########",
+		"########",
+		".######.",
+		"..####..",
+		"...##...",
+		"........",
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				img.SetNRGBA(x, y, color.NRGBA{255, 0, 77, 255})
+			}
+		}
+	}
+	return img
+}
@@ -64,12 +64,8 @@ func main() {
 		panic(err)
 	}
 
-	var space plane.Space2D[uint32]
-	if config.World.WorldWrap {
-		space = plane.NewToroidal2D(worldRes.Side(), worldRes.Side())
-	} else {
-		space = plane.NewEuclidean2D(worldRes.Side(), worldRes.Side())
-	}
+	wrapX, wrapY := config.World.WrapAxes()
+	space := grid.NewWrapSpace(worldRes.Side(), worldRes.Side(), wrapX, wrapY)
 	manager := grid.NewMultiBucketGridManager(
 		space,
 		worldRes,
@@ -87,7 +83,6 @@ func main() {
 	polygon1Shape := torus.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](50, 50), 50, 50))
 
 	polygon1 := &gfx.Drawable{
-		ID:   gfx.NextDrawableID(),
 		AABB: polygon1Shape,
 		Style: gfx.SpatialStyle{
 			Fill:   color.RGBA{0, 255, 0, 255},
@@ -97,7 +92,6 @@ func main() {
 
 	rectShape := torus.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](150, 150), 100, 100))
 	polygon2 := &gfx.Drawable{
-		ID:   gfx.NextDrawableID(),
 		AABB: rectShape,
 		Style: gfx.SpatialStyle{
 			Fill:   color.RGBA{0, 255, 0, 255},
@@ -114,7 +108,6 @@ func main() {
 		vec := geom.NewVec(randX, randY)
 		planeBox := torus.WrapAABB(geom.NewAABBAt(vec, 1, 1))
 		drawable := &gfx.Drawable{
-			ID:    gfx.NextDrawableID(),
 			AABB:  planeBox,
 			Style: gfx.SpatialStyle{Stroke: color.White},
 		}
@@ -128,12 +121,16 @@ func main() {
 	window.Show()
 
 	// ------- Animations -------------------
-	simulation := NewSimulation(5*time.Millisecond, func() (gfx.DrawableSetAdded, gfx.DrawableSetRemoved, gfx.DrawableSetTranslated) {
+	// Polygon speeds in world units per second; fractional moves carry over
+	// to the next tick so speed does not depend on ticker accuracy.
+	poly1Motion := motion{vx: 200, vy: 200}
+	poly2Motion := motion{vy: -200}
+	simulation := NewSimulation(5*time.Millisecond, func(dt time.Duration) (gfx.DrawableSetAdded, gfx.DrawableSetRemoved, gfx.DrawableSetTranslated) {
 		var translated []gfx.DrawableTranslate
 
 		// move polygon1
 		oldPoly1 := polygon1.AABB
-		torus.Translate(&polygon1.AABB, signedVec(1, 1))
+		torus.Translate(&polygon1.AABB, poly1Motion.step(dt))
 		translated = append(translated, gfx.DrawableTranslate{
 			PaneID:     pane.IDValue(),
 			LayerID:    layer2.ID(),
@@ -144,7 +141,7 @@ func main() {
 
 		// move polygon2
 		oldPoly2 := polygon2.AABB
-		torus.Translate(&polygon2.AABB, signedVec(0, -1))
+		torus.Translate(&polygon2.AABB, poly2Motion.step(dt))
 		translated = append(translated, gfx.DrawableTranslate{
 			PaneID:     pane.IDValue(),
 			LayerID:    layer2.ID(),
@@ -199,7 +196,7 @@ func handleEvent(event gfx.Event, ctx *DemoContext) {
 		fmt.Println("Window exposed")
 	case gfx.KeyPress:
 		fmt.Printf("Key pressed [code=%d lable=%s]\n", e.Code, e.Label)
-		if e.Code == 65307 {
+		if e.Key == gfx.KeyEscape {
 			ctx.window.Stop()
 		}
 	case gfx.KeyRelease:
@@ -208,7 +205,7 @@ func handleEvent(event gfx.Event, ctx *DemoContext) {
 		if e.Button == 1 {
 			fmt.Printf("Left Mouse Button pressed %d %d\n", e.X, e.Y)
 			ctx.lmbPressed = true
-			drawDots(e.X, e.Y, ctx)
+			drawDots(e, ctx)
 		}
 	case gfx.ButtonRelease:
 		if e.Button == 1 {
@@ -217,12 +214,12 @@ func handleEvent(event gfx.Event, ctx *DemoContext) {
 		}
 	case gfx.MotionNotify:
 		if ctx.lmbPressed {
-			drawDots(e.X, e.Y, ctx)
+			drawDots(e, ctx)
 		}
 	case gfx.EnterNotify:
-		fmt.Println("Mouse enter notify")
+		fmt.Printf("Mouse enter notify [x=%d y=%d]\n", e.X, e.Y)
 	case gfx.LeaveNotify:
-		fmt.Println("Mouse leave notify")
+		fmt.Printf("Mouse leave notify [x=%d y=%d]\n", e.X, e.Y)
 	case gfx.CreateNotify:
 		fmt.Println("Window created")
 	case gfx.DestroyNotify:
@@ -237,14 +234,15 @@ func handleEvent(event gfx.Event, ctx *DemoContext) {
 	}
 }
 
-func drawDots(wX, wY int, ctx *DemoContext) {
+func drawDots(event gfx.Event, ctx *DemoContext) {
 	pane := ctx.window.GetDefaultPane()
-	wx, wy := pane.WindowToWorldCoords(wX, wY)
+	vec, ok := pane.PointerWorld(event)
+	if !ok {
+		return
+	}
 	layer1 := pane.GetLayer(1)
-	vec := geom.NewVec(wx, wy)
 	planeBox := ctx.plane.WrapAABB(geom.NewAABBAt(vec, 1, 1))
 	drawable := &gfx.Drawable{
-		ID:    gfx.NextDrawableID(),
 		AABB:  planeBox,
 		Style: gfx.SpatialStyle{Stroke: color.White},
 	}
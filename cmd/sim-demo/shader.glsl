@@ -1,27 +1,29 @@
 #ifdef VERTEX
 #if defined(PASS_COLOR)
 layout(location = 0) in vec2 aPos;
-layout(location = 1) in vec4 iRect;
-layout(location = 2) in vec4 iFill;
-layout(location = 3) in vec4 iStroke;
+INSTANCE_ATTRIBUTES
 
 uniform vec2 uViewport;
 uniform vec2 uOrigin;
 uniform vec2 uWorld;
+uniform bool uWrap;
 
 out vec2 vLocal;
 out vec2 vSize;
 out vec4 vFill;
 out vec4 vStroke;
+out vec4 vFillTo;
+out vec4 vGradient;
+out vec4 vUV;
 
 void main() {
 	vec2 tl = iRect.xy;
 	vec2 br = iRect.zw;
-	if (uWorld.x > 0.0 && tl.x < uOrigin.x) {
+	if (uWrap && tl.x < uOrigin.x) {
 		tl.x += uWorld.x;
 		br.x += uWorld.x;
 	}
-	if (uWorld.y > 0.0 && tl.y < uOrigin.y) {
+	if (uWrap && tl.y < uOrigin.y) {
 		tl.y += uWorld.y;
 		br.y += uWorld.y;
 	}
@@ -36,6 +38,9 @@ void main() {
 	vSize = size;
 	vFill = iFill;
 	vStroke = iStroke;
+	vFillTo = iFillTo;
+	vGradient = iGradient;
+	vUV = iUV;
 }
 #elif defined(PASS_COMPOSITE)
 layout(location = 0) in vec2 aPos;
@@ -65,33 +70,58 @@ in vec2 vLocal;
 in vec2 vSize;
 in vec4 vFill;
 in vec4 vStroke;
+in vec4 vFillTo;
+in vec4 vGradient;
+in vec4 vUV;
+
+uniform sampler2D uSprite;
+uniform bool uPremultiply;
 
 out vec4 outColor;
 
+// finish premultiplies the color for blend modes that expect it.
+vec4 finish(vec4 color) {
+	if (uPremultiply) {
+		color.rgb *= color.a;
+	}
+	return color;
+}
+
 void main() {
 	float strokeWidth = 1.0;
 	if (vStroke.a > 0.0) {
 		vec2 dist = min(vLocal * vSize, (1.0 - vLocal) * vSize);
 		float edge = min(dist.x, dist.y);
 		if (edge < strokeWidth) {
-			outColor = vStroke;
+			outColor = finish(vStroke);
 			return;
 		}
 	}
-	if (vFill.a <= 0.0) {
+	vec4 fill = vFill;
+	if (vUV != vec4(0.0)) {
+		fill = texture(uSprite, mix(vUV.xy, vUV.zw, vLocal));
+	} else if (vGradient.x > 0.5) {
+		float along = vGradient.x < 1.5 ? vLocal.x : vLocal.y;
+		fill = mix(vFill, vFillTo, mix(vGradient.y, vGradient.z, along));
+	}
+	if (fill.a <= 0.0) {
 		discard;
 	}
-	outColor = vFill;
+	outColor = finish(fill);
 }
 #elif defined(PASS_COMPOSITE)
 in vec2 vUV;
 
 uniform sampler2D uTex;
+uniform vec4 uTint;
+uniform float uTintStrength;
 
 out vec4 outColor;
 
 void main() {
-	outColor = texture(uTex, vUV);
+	vec4 color = texture(uTex, vUV);
+	color.rgb = mix(color.rgb, uTint.rgb, uTintStrength);
+	outColor = color;
 }
 #endif
 #endif
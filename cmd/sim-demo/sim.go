@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"math"
 	"time"
 
+	"github.com/kjkrol/gokg/pkg/geom"
 	"github.com/kjkrol/gokx/pkg/gfx"
 )
 
-// StepFunc produces bulk drawable events for a single simulation tick.
-type StepFunc func() (gfx.DrawableSetAdded, gfx.DrawableSetRemoved, gfx.DrawableSetTranslated)
+// StepFunc produces bulk drawable events for a single simulation tick. dt is
+// the wall time elapsed since the previous tick; ticker jitter makes it vary,
+// so moves should integrate velocities with it (distance = speed * dt) rather
+// than assume Duration.
+type StepFunc func(dt time.Duration) (gfx.DrawableSetAdded, gfx.DrawableSetRemoved, gfx.DrawableSetTranslated)
 
 type Simulation struct {
 	Duration time.Duration
@@ -37,12 +42,15 @@ func (s *Simulation) Run(ctx context.Context, emit func(gfx.Event)) {
 		ticker := time.NewTicker(s.Duration)
 		defer ticker.Stop()
 
+		last := time.Now()
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
-				added, removed, translated := s.Step()
+			case now := <-ticker.C:
+				dt := now.Sub(last)
+				last = now
+				added, removed, translated := s.Step(dt)
 				if len(added.Items) > 0 {
 					emit(added)
 				}
@@ -56,3 +64,21 @@ func (s *Simulation) Run(ctx context.Context, emit func(gfx.Event)) {
 		}
 	}()
 }
+
+// motion integrates a constant velocity (world units per second) over the
+// elapsed time of each tick. Drawables move in whole units, so the fractional
+// remainder is kept for the next step.
+type motion struct {
+	vx, vy       float64
+	restX, restY float64
+}
+
+func (m *motion) step(dt time.Duration) geom.Vec[uint32] {
+	m.restX += m.vx * dt.Seconds()
+	m.restY += m.vy * dt.Seconds()
+	dx := math.Trunc(m.restX)
+	dy := math.Trunc(m.restY)
+	m.restX -= dx
+	m.restY -= dy
+	return signedVec(int(dx), int(dy))
+}
@@ -5,28 +5,46 @@ type Event interface{}
 type Expose struct{}
 type KeyPress struct {
 	Code  uint64
+	Key   Key
 	Label string
 }
 type KeyRelease struct {
 	Code  uint64
+	Key   Key
 	Label string
 }
+
+// TextInput carries text typed with the keyboard layout, dead keys and input
+// method applied; it follows the KeyPress that produced it.
+type TextInput struct {
+	Text string
+}
 type ButtonPress struct {
-	Button uint32
-	X, Y   int
+	Button  uint32
+	Buttons uint32
+	X, Y    int
 }
 type ButtonRelease struct {
-	Button uint32
-	X, Y   int
+	Button  uint32
+	Buttons uint32
+	X, Y    int
 }
 type MotionNotify struct {
 	X, Y int
 }
-type EnterNotify struct{}
-type LeaveNotify struct{}
+type EnterNotify struct {
+	X, Y int
+}
+type LeaveNotify struct {
+	X, Y int
+}
 type CreateNotify struct{}
 type DestroyNotify struct{}
 type ClientMessage struct{}
+
+// CloseRequest is emitted when the user asks to close the window
+// (WM_DELETE_WINDOW on X11, SDL_QUIT on SDL).
+type CloseRequest struct{}
 type MouseWheel struct {
 	DeltaX float64
 	DeltaY float64
@@ -34,3 +52,12 @@ type MouseWheel struct {
 }
 type UnexpectedEvent struct{}
 type TimeoutEvent struct{}
+
+// buttonBit returns the Buttons mask bit of a 1-based button number
+// (bit 0 = button 1 = left, bit 1 = middle, bit 2 = right).
+func buttonBit(button uint32) uint32 {
+	if button == 0 || button > 32 {
+		return 0
+	}
+	return 1 << (button - 1)
+}
@@ -0,0 +1,88 @@
+package platform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GLAPI selects the client API of the GL context.
+type GLAPI int
+
+const (
+	// GLAPIOpenGL requests desktop OpenGL (default).
+	GLAPIOpenGL GLAPI = iota
+	// GLAPIOpenGLES requests OpenGL ES.
+	GLAPIOpenGLES
+)
+
+// GLContextConfig is the requested GL context. Zero Major/Minor select 3.3
+// for OpenGL and 3.0 for OpenGL ES, the minimum the renderer's shaders need.
+// The WASM backend always uses WebGL2 (OpenGL ES 3.0) and ignores it.
+type GLContextConfig struct {
+	API          GLAPI
+	Major, Minor int
+}
+
+// Version returns the requested version with defaults applied.
+func (c GLContextConfig) Version() (major, minor int) {
+	if c.Major == 0 && c.Minor == 0 {
+		if c.API == GLAPIOpenGLES {
+			return 3, 0
+		}
+		return 3, 3
+	}
+	return c.Major, c.Minor
+}
+
+// Validate reports versions the renderer cannot use: it needs GLSL 3.30
+// (explicit attribute locations, instancing) or GLSL ES 3.00.
+func (c GLContextConfig) Validate() error {
+	major, minor := c.Version()
+	switch c.API {
+	case GLAPIOpenGL:
+		if major < 3 || (major == 3 && minor < 3) {
+			return fmt.Errorf("platform: OpenGL %d.%d is below the required 3.3", major, minor)
+		}
+	case GLAPIOpenGLES:
+		if major < 3 {
+			return fmt.Errorf("platform: OpenGL ES %d.%d is below the required 3.0", major, minor)
+		}
+	default:
+		return fmt.Errorf("platform: unknown GL API %d", c.API)
+	}
+	return nil
+}
+
+// ShaderHeader returns the GLSL #version line (plus default precisions for
+// ES) matching the context: "#version 330 core" for OpenGL 3.3,
+// "#version 300 es" for OpenGL ES 3.0.
+func (c GLContextConfig) ShaderHeader() string {
+	major, minor := c.Version()
+	if c.API == GLAPIOpenGLES {
+		return fmt.Sprintf("#version %d%d0 es\nprecision highp float;\nprecision highp int;\n", major, minor)
+	}
+	return fmt.Sprintf("#version %d%d0 core\n", major, minor)
+}
+
+// StripShaderVersion blanks a #version directive in source, since renderers
+// prepend ShaderHeader themselves, and reports one that differs from the
+// header (e.g. "#version 330 core" on an OpenGL ES context). Line numbers in
+// compiler logs are kept.
+func (c GLContextConfig) StripShaderVersion(source string) (string, error) {
+	want := strings.SplitN(c.ShaderHeader(), "\n", 2)[0]
+	var sb strings.Builder
+	for line := range strings.Lines(source) {
+		directive := strings.TrimSpace(line)
+		if !strings.HasPrefix(directive, "#version") {
+			sb.WriteString(line)
+			continue
+		}
+		if strings.Join(strings.Fields(directive), " ") != want {
+			return "", fmt.Errorf("platform: shader declares %q but the context needs %q", directive, want)
+		}
+		if strings.HasSuffix(line, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,58 @@
+package platform
+
+import "testing"
+
+func TestGLContextConfig_DefaultsAndHeaders(t *testing.T) {
+	var gl GLContextConfig
+	if major, minor := gl.Version(); major != 3 || minor != 3 {
+		t.Fatalf("default GL version = %d.%d, want 3.3", major, minor)
+	}
+	if got := gl.ShaderHeader(); got != "#version 330 core\n" {
+		t.Fatalf("GL header = %q", got)
+	}
+
+	gles := GLContextConfig{API: GLAPIOpenGLES}
+	if major, minor := gles.Version(); major != 3 || minor != 0 {
+		t.Fatalf("default GLES version = %d.%d, want 3.0", major, minor)
+	}
+	if got := gles.ShaderHeader(); got != "#version 300 es\nprecision highp float;\nprecision highp int;\n" {
+		t.Fatalf("GLES header = %q", got)
+	}
+	if got := (GLContextConfig{Major: 4, Minor: 1}).ShaderHeader(); got != "#version 410 core\n" {
+		t.Fatalf("GL 4.1 header = %q", got)
+	}
+}
+
+func TestGLContextConfig_Validate(t *testing.T) {
+	valid := []GLContextConfig{{}, {Major: 4, Minor: 6}, {API: GLAPIOpenGLES}, {API: GLAPIOpenGLES, Major: 3, Minor: 2}}
+	for _, c := range valid {
+		if err := c.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", c, err)
+		}
+	}
+	invalid := []GLContextConfig{{Major: 3, Minor: 2}, {Major: 2, Minor: 1}, {API: GLAPIOpenGLES, Major: 2}, {API: 7}}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
+
+func TestGLContextConfig_StripShaderVersion(t *testing.T) {
+	gl := GLContextConfig{}
+	got, err := gl.StripShaderVersion("#version  330   core\nvoid main() {}\n")
+	if err != nil {
+		t.Fatalf("matching directive rejected: %v", err)
+	}
+	if got != "\nvoid main() {}\n" {
+		t.Fatalf("stripped source = %q", got)
+	}
+
+	if _, err := (GLContextConfig{API: GLAPIOpenGLES}).StripShaderVersion("#version 330 core\n"); err == nil {
+		t.Fatal("expected a mismatch error for a desktop shader on GLES")
+	}
+	src := "void main() {}\n"
+	if got, err := gl.StripShaderVersion(src); err != nil || got != src {
+		t.Fatalf("source without directive changed: %q, %v", got, err)
+	}
+}
@@ -0,0 +1,169 @@
+package platform
+
+// Key is a backend-independent key identifier derived from the raw code of
+// each backend (X11 keysym, SDL scancode, DOM KeyboardEvent.code). It names
+// the physical key, so KeyA is reported with or without Shift held.
+type Key uint16
+
+const (
+	KeyUnknown Key = iota
+	KeyA
+	KeyB
+	KeyC
+	KeyD
+	KeyE
+	KeyF
+	KeyG
+	KeyH
+	KeyI
+	KeyJ
+	KeyK
+	KeyL
+	KeyM
+	KeyN
+	KeyO
+	KeyP
+	KeyQ
+	KeyR
+	KeyS
+	KeyT
+	KeyU
+	KeyV
+	KeyW
+	KeyX
+	KeyY
+	KeyZ
+	Key0
+	Key1
+	Key2
+	Key3
+	Key4
+	Key5
+	Key6
+	Key7
+	Key8
+	Key9
+	KeyEscape
+	KeyEnter
+	KeySpace
+	KeyTab
+	KeyBackspace
+	KeyLeft
+	KeyRight
+	KeyUp
+	KeyDown
+	KeyLeftShift
+	KeyRightShift
+	KeyLeftCtrl
+	KeyRightCtrl
+	KeyLeftAlt
+	KeyRightAlt
+	KeyLeftSuper
+	KeyRightSuper
+)
+
+// x11Keysyms maps X11 keysyms (as returned by XLookupKeysym with index 0)
+// that are not letters or digits.
+var x11Keysyms = map[uint64]Key{
+	0xff1b: KeyEscape,
+	0xff0d: KeyEnter,
+	0xff8d: KeyEnter, // KP_Enter
+	0x0020: KeySpace,
+	0xff09: KeyTab,
+	0xff08: KeyBackspace,
+	0xff51: KeyLeft,
+	0xff52: KeyUp,
+	0xff53: KeyRight,
+	0xff54: KeyDown,
+	0xffe1: KeyLeftShift,
+	0xffe2: KeyRightShift,
+	0xffe3: KeyLeftCtrl,
+	0xffe4: KeyRightCtrl,
+	0xffe9: KeyLeftAlt,
+	0xffea: KeyRightAlt,
+	0xffeb: KeyLeftSuper,
+	0xffec: KeyRightSuper,
+}
+
+// x11Key converts an X11 keysym to a Key.
+func x11Key(keysym uint64) Key {
+	switch {
+	case keysym >= 'a' && keysym <= 'z':
+		return KeyA + Key(keysym-'a')
+	case keysym >= 'A' && keysym <= 'Z':
+		return KeyA + Key(keysym-'A')
+	case keysym >= '0' && keysym <= '9':
+		return Key0 + Key(keysym-'0')
+	}
+	return x11Keysyms[keysym]
+}
+
+// sdlScancodes maps SDL scancodes that are not letters or digits.
+var sdlScancodes = map[uint64]Key{
+	40:  KeyEnter,
+	41:  KeyEscape,
+	42:  KeyBackspace,
+	43:  KeyTab,
+	44:  KeySpace,
+	79:  KeyRight,
+	80:  KeyLeft,
+	81:  KeyDown,
+	82:  KeyUp,
+	88:  KeyEnter, // SDL_SCANCODE_KP_ENTER
+	224: KeyLeftCtrl,
+	225: KeyLeftShift,
+	226: KeyLeftAlt,
+	227: KeyLeftSuper,
+	228: KeyRightCtrl,
+	229: KeyRightShift,
+	230: KeyRightAlt,
+	231: KeyRightSuper,
+}
+
+// sdlKey converts an SDL scancode to a Key. Letters are SDL_SCANCODE_A (4)
+// to SDL_SCANCODE_Z (29); digits run SDL_SCANCODE_1 (30) to SDL_SCANCODE_0 (39).
+func sdlKey(scancode uint64) Key {
+	switch {
+	case scancode >= 4 && scancode <= 29:
+		return KeyA + Key(scancode-4)
+	case scancode >= 30 && scancode <= 38:
+		return Key1 + Key(scancode-30)
+	case scancode == 39:
+		return Key0
+	}
+	return sdlScancodes[scancode]
+}
+
+// domCodes maps DOM KeyboardEvent.code values that are not letters or digits.
+var domCodes = map[string]Key{
+	"Escape":       KeyEscape,
+	"Enter":        KeyEnter,
+	"NumpadEnter":  KeyEnter,
+	"Space":        KeySpace,
+	"Tab":          KeyTab,
+	"Backspace":    KeyBackspace,
+	"ArrowLeft":    KeyLeft,
+	"ArrowRight":   KeyRight,
+	"ArrowUp":      KeyUp,
+	"ArrowDown":    KeyDown,
+	"ShiftLeft":    KeyLeftShift,
+	"ShiftRight":   KeyRightShift,
+	"ControlLeft":  KeyLeftCtrl,
+	"ControlRight": KeyRightCtrl,
+	"AltLeft":      KeyLeftAlt,
+	"AltRight":     KeyRightAlt,
+	"MetaLeft":     KeyLeftSuper,
+	"MetaRight":    KeyRightSuper,
+}
+
+// domKey converts a DOM KeyboardEvent.code ("KeyA", "Digit1", "ArrowUp", ...)
+// to a Key.
+func domKey(code string) Key {
+	if len(code) == 4 && code[:3] == "Key" && code[3] >= 'A' && code[3] <= 'Z' {
+		return KeyA + Key(code[3]-'A')
+	}
+	if len(code) == 6 && code[:5] == "Digit" && code[5] >= '0' && code[5] <= '9' {
+		return Key0 + Key(code[5]-'0')
+	}
+	return domCodes[code]
+}
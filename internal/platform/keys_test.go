@@ -0,0 +1,69 @@
+package platform
+
+import "testing"
+
+func TestX11Key(t *testing.T) {
+	cases := map[uint64]Key{
+		'a':    KeyA,
+		'Z':    KeyZ,
+		'0':    Key0,
+		'7':    Key7,
+		0xff1b: KeyEscape,
+		0xff0d: KeyEnter,
+		0x20:   KeySpace,
+		0xff51: KeyLeft,
+		0xff54: KeyDown,
+		0xffe1: KeyLeftShift,
+		0xffe4: KeyRightCtrl,
+		0xffbe: KeyUnknown, // F1
+	}
+	for keysym, want := range cases {
+		if got := x11Key(keysym); got != want {
+			t.Errorf("x11Key(%#x) = %d, want %d", keysym, got, want)
+		}
+	}
+}
+
+func TestSDLKey(t *testing.T) {
+	cases := map[uint64]Key{
+		4:   KeyA,
+		29:  KeyZ,
+		30:  Key1,
+		38:  Key9,
+		39:  Key0,
+		41:  KeyEscape,
+		40:  KeyEnter,
+		44:  KeySpace,
+		82:  KeyUp,
+		225: KeyLeftShift,
+		230: KeyRightAlt,
+		58:  KeyUnknown, // F1
+	}
+	for scancode, want := range cases {
+		if got := sdlKey(scancode); got != want {
+			t.Errorf("sdlKey(%d) = %d, want %d", scancode, got, want)
+		}
+	}
+}
+
+func TestDOMKey(t *testing.T) {
+	cases := map[string]Key{
+		"KeyA":        KeyA,
+		"KeyZ":        KeyZ,
+		"Digit0":      Key0,
+		"Digit9":      Key9,
+		"Escape":      KeyEscape,
+		"NumpadEnter": KeyEnter,
+		"Space":       KeySpace,
+		"ArrowRight":  KeyRight,
+		"ControlLeft": KeyLeftCtrl,
+		"MetaRight":   KeyRightSuper,
+		"Keyboard":    KeyUnknown,
+		"F1":          KeyUnknown,
+	}
+	for code, want := range cases {
+		if got := domKey(code); got != want {
+			t.Errorf("domKey(%q) = %d, want %d", code, got, want)
+		}
+	}
+}
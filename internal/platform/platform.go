@@ -1,6 +1,9 @@
 package platform
 
-import "image"
+import (
+	"image"
+	"time"
+)
 
 type WindowConfig struct {
 	PositionX   int
@@ -9,6 +12,12 @@ type WindowConfig struct {
 	Height      int
 	BorderWidth int
 	Title       string
+	GL          GLContextConfig
+
+	// Queue settings for backends that buffer events themselves (WASM).
+	EventBufferSize   int
+	EventOverflow     OverflowPolicy
+	EventBlockTimeout time.Duration
 }
 
 type PlatformWindowWrapper interface {
@@ -20,7 +29,46 @@ type PlatformWindowWrapper interface {
 	GLContext() any
 }
 
+// CloseRequestHandlerSetter is implemented by wrappers that must answer close
+// requests synchronously (e.g. the browser's beforeunload) instead of emitting
+// a CloseRequest event. A handler returning false vetoes the close.
+type CloseRequestHandlerSetter interface {
+	SetCloseRequestHandler(fn func() bool)
+}
+
+// EventInjector is implemented by wrappers whose event queue can be fed
+// programmatically (the headless backend). InjectEvent reports whether the
+// event was queued.
+type EventInjector interface {
+	InjectEvent(e Event) bool
+}
+
+// DroppedEventsCounter is implemented by wrappers that buffer events and may
+// drop them on overflow.
+type DroppedEventsCounter interface {
+	DroppedEvents() uint64
+}
+
 type PlatformImageWrapper interface {
 	Update(rect image.Rectangle)
 	Delete()
 }
+
+// ImageWrapperFactory is implemented by wrappers that can present CPU-side
+// images (software rendering). img must stay alive until Delete.
+type ImageWrapperFactory interface {
+	NewPlatformImageWrapper(img *image.RGBA, offsetX, offsetY int) PlatformImageWrapper
+}
+
+// GPUProber is implemented by wrappers that can tell up front whether a GL
+// context can be created.
+type GPUProber interface {
+	GPUAvailable() bool
+}
+
+// GLContextCreator is implemented by wrappers that create their GL context
+// lazily in BeginFrame. CreateGLContext does the same and makes the context
+// current, but reports a failure instead of panicking.
+type GLContextCreator interface {
+	CreateGLContext() error
+}
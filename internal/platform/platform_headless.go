@@ -0,0 +1,100 @@
+//go:build !cgo && !js
+
+package platform
+
+import (
+	"image"
+	"sync/atomic"
+	"time"
+)
+
+// headlessWindowWrapper is the pure-Go backend used when cgo is disabled. It
+// has no display: events come only from InjectEvent and images are kept in
+// memory, which is enough to drive gfx, grid and gridbridge in tests and on
+// headless CI.
+type headlessWindowWrapper struct {
+	conf    WindowConfig
+	events  chan Event
+	dropped atomic.Uint64
+	closed  atomic.Bool
+}
+
+func NewPlatformWindowWrapper(conf WindowConfig) (PlatformWindowWrapper, error) {
+	return &headlessWindowWrapper{
+		conf:   conf,
+		events: make(chan Event, eventBufferSize(conf.EventBufferSize)),
+	}, nil
+}
+
+func (w *headlessWindowWrapper) Show() {
+	w.InjectEvent(Expose{})
+}
+
+func (w *headlessWindowWrapper) Close() {
+	if w.closed.Swap(true) {
+		return
+	}
+	w.InjectEvent(DestroyNotify{})
+}
+
+// InjectEvent queues e as if the platform had produced it, following the
+// configured overflow policy.
+func (w *headlessWindowWrapper) InjectEvent(e Event) bool {
+	if Enqueue(w.events, e, w.conf.EventOverflow, w.conf.EventBlockTimeout) {
+		w.dropped.Add(1)
+		return false
+	}
+	return true
+}
+
+func (w *headlessWindowWrapper) DroppedEvents() uint64 {
+	return w.dropped.Load()
+}
+
+func (w *headlessWindowWrapper) NextEventTimeout(timeoutMs int) Event {
+	select {
+	case e := <-w.events:
+		return e
+	default:
+	}
+	if timeoutMs <= 0 {
+		return TimeoutEvent{}
+	}
+	select {
+	case e := <-w.events:
+		return e
+	case <-time.After(time.Duration(timeoutMs) * time.Millisecond):
+		return TimeoutEvent{}
+	}
+}
+
+func (w *headlessWindowWrapper) BeginFrame() {}
+func (w *headlessWindowWrapper) EndFrame()   {}
+
+func (w *headlessWindowWrapper) GLContext() any {
+	return nil
+}
+
+func (w *headlessWindowWrapper) GPUAvailable() bool {
+	return false
+}
+
+func (w *headlessWindowWrapper) SurfaceFactory() SurfaceFactory {
+	return DefaultSurfaceFactory()
+}
+
+func (w *headlessWindowWrapper) NewPlatformImageWrapper(img *image.RGBA, offsetX, offsetY int) PlatformImageWrapper {
+	return &headlessImageWrapper{img: img}
+}
+
+// headlessImageWrapper presents nothing; the caller's image already is the
+// in-memory surface.
+type headlessImageWrapper struct {
+	img *image.RGBA
+}
+
+func (hw *headlessImageWrapper) Update(image.Rectangle) {}
+
+func (hw *headlessImageWrapper) Delete() {
+	hw.img = nil
+}
@@ -0,0 +1,39 @@
+//go:build !cgo && !js
+
+package platform
+
+import "testing"
+
+func TestHeadlessWindow_InjectEvent(t *testing.T) {
+	w, err := NewPlatformWindowWrapper(WindowConfig{Width: 32, Height: 32, EventBufferSize: 2})
+	if err != nil {
+		t.Fatalf("NewPlatformWindowWrapper: %v", err)
+	}
+	injector, ok := w.(EventInjector)
+	if !ok {
+		t.Fatal("headless wrapper must implement EventInjector")
+	}
+
+	if _, ok := w.NextEventTimeout(0).(TimeoutEvent); !ok {
+		t.Fatal("empty queue should time out")
+	}
+
+	injector.InjectEvent(KeyPress{Code: 41, Key: KeyEscape})
+	injector.InjectEvent(MotionNotify{X: 3, Y: 4})
+	if injector.InjectEvent(Expose{}) {
+		t.Error("third event should overflow a 2-slot queue")
+	}
+	if got := w.(DroppedEventsCounter).DroppedEvents(); got != 1 {
+		t.Errorf("DroppedEvents = %d, want 1", got)
+	}
+
+	if e, ok := w.NextEventTimeout(10).(KeyPress); !ok || e.Key != KeyEscape {
+		t.Errorf("first event = %#v, want KeyPress Escape", e)
+	}
+	if e, ok := w.NextEventTimeout(10).(MotionNotify); !ok || e.X != 3 || e.Y != 4 {
+		t.Errorf("second event = %#v, want MotionNotify(3,4)", e)
+	}
+	if w.(GPUProber).GPUAvailable() {
+		t.Error("headless wrapper must not report a GPU")
+	}
+}
@@ -70,12 +70,11 @@ const (
 
 // ----------------------------------------------------------------------------
 
-func NewPlatformWindowWrapper(conf WindowConfig) PlatformWindowWrapper {
+func NewPlatformWindowWrapper(conf WindowConfig) (PlatformWindowWrapper, error) {
 
 	conn, err := newXConnection()
 	if err != nil {
-		fmt.Println(err)
-		return nil
+		return nil, err
 	}
 
 	window := C.XCreateSimpleWindow(
@@ -98,7 +97,8 @@ func NewPlatformWindowWrapper(conf WindowConfig) PlatformWindowWrapper {
 		window:         window,
 		title:          title,
 		surfaceFactory: DefaultSurfaceFactory(),
-	}
+		glConfig:       conf.GL,
+	}, nil
 }
 
 // ----------------------------------------------------------------------------
@@ -126,8 +126,9 @@ func newXConnection() (*xConnection, error) {
 	}, nil
 }
 
+// Close closes the display. The root window belongs to the X server and is
+// never destroyed; application windows must be destroyed before.
 func (c *xConnection) Close() {
-	C.XDestroyWindow(c.display, c.rootWindow)
 	C.XCloseDisplay(c.display)
 }
 
@@ -248,24 +249,62 @@ type x11WindowWrapper struct {
 	eglConfig      C.EGLConfig
 	eglSurface     C.EGLSurface
 	eglContext     C.EGLContext
+	eglProbed      C.EGLDisplay // initialized by GPUAvailable
+	wmDeleteWindow C.Atom
+	glConfig       GLContextConfig
+	xi2            xi2Scroll
+	xim            xim
+	// pending holds events produced alongside the one convert returned,
+	// e.g. the TextInput of a KeyPress.
+	pending []Event
 }
 
 func (w *x11WindowWrapper) Show() {
 	C.XMapWindow(w.conn.display, w.window)
 
-	wmDeleteWindow := C.XInternAtom(w.conn.display, C.CString("WM_DELETE_WINDOW"), 0)
-	C.XSetWMProtocols(w.conn.display, w.window, &wmDeleteWindow, 1)
+	if w.wmDeleteWindow == 0 {
+		// The atom is interned by the server, so the name can go right away.
+		name := C.CString("WM_DELETE_WINDOW")
+		w.wmDeleteWindow = C.XInternAtom(w.conn.display, name, 0)
+		C.free(unsafe.Pointer(name))
+	}
+	C.XSetWMProtocols(w.conn.display, w.window, &w.wmDeleteWindow, 1)
 	C.XSelectInput(w.conn.display, w.window, DefaultMask)
+	if !w.xi2.enabled {
+		w.initXI2()
+	}
+	if w.xim.im == nil {
+		w.initXIM()
+	}
 }
+
+// Close tears down in dependency order: EGL surface and context (which
+// reference the window), then the window, then the display. Calling it again
+// is a no-op.
 func (w *x11WindowWrapper) Close() {
+	if w.conn == nil {
+		return
+	}
 	w.destroyEGL()
-	C.XDestroyWindow(w.conn.display, w.window)
-	C.free(unsafe.Pointer(w.title))
+	w.closeXIM()
+	if w.window != 0 {
+		C.XDestroyWindow(w.conn.display, w.window)
+		w.window = 0
+	}
+	if w.title != nil {
+		C.free(unsafe.Pointer(w.title))
+		w.title = nil
+	}
 	w.conn.Close()
 	w.conn = nil
 }
 
 func (w *x11WindowWrapper) NextEventTimeout(timeoutMs int) Event {
+	if len(w.pending) > 0 {
+		event := w.pending[0]
+		w.pending = w.pending[1:]
+		return event
+	}
 	if w.fd == 0 {
 		w.fd = int(C.getConnectionNumber(w.conn.display))
 	}
@@ -274,7 +313,7 @@ func (w *x11WindowWrapper) NextEventTimeout(timeoutMs int) Event {
 	if C.XPending(w.conn.display) > 0 {
 		var ev C.XEvent
 		C.XNextEvent(w.conn.display, &ev)
-		return convert(ev)
+		return w.convert(ev)
 	}
 
 	if timeoutMs < 0 {
@@ -297,11 +336,46 @@ func (w *x11WindowWrapper) NextEventTimeout(timeoutMs int) Event {
 	var ev C.XEvent
 	if C.XPending(w.conn.display) > 0 {
 		C.XNextEvent(w.conn.display, &ev)
-		return convert(ev)
+		return w.convert(ev)
 	}
 	return TimeoutEvent{}
 }
 
+// convert maps WM_DELETE_WINDOW client messages to CloseRequest and XInput2
+// events to motion and smooth scrolling, queues the TextInput of key presses,
+// and delegates everything else to the stateless conversion. While XInput2
+// scrolls, the core wheel buttons are emulated duplicates and are dropped.
+// Events consumed by the input method are dropped as well.
+func (w *x11WindowWrapper) convert(ev C.XEvent) Event {
+	if w.filterXIM(&ev) {
+		return UnexpectedEvent{}
+	}
+	switch (*C.XAnyEvent)(unsafe.Pointer(&ev))._type {
+	case C.KeyPress:
+		if text, ok := w.lookupText(&ev); ok {
+			w.pending = append(w.pending, TextInput{Text: text})
+		}
+	case C.ClientMessage:
+		msg := (*C.XClientMessageEvent)(unsafe.Pointer(&ev))
+		data := (*[5]C.long)(unsafe.Pointer(&msg.data))
+		if w.wmDeleteWindow != 0 && C.Atom(data[0]) == w.wmDeleteWindow {
+			return CloseRequest{}
+		}
+	case C.GenericEvent:
+		if event, ok := w.convertXI2(&ev); ok {
+			return event
+		}
+	case C.ButtonPress, C.ButtonRelease:
+		button := (*C.XButtonEvent)(unsafe.Pointer(&ev))
+		if _, _, wheel := x11WheelDelta(uint(button.button)); wheel && w.xi2.state.active() {
+			return UnexpectedEvent{}
+		}
+	case C.EnterNotify:
+		w.xi2.state.reset()
+	}
+	return convert(ev)
+}
+
 func (w *x11WindowWrapper) SurfaceFactory() SurfaceFactory {
 	return w.surfaceFactory
 }
@@ -310,15 +384,25 @@ func (w *x11WindowWrapper) BeginFrame() {
 	if w.conn == nil {
 		return
 	}
+	if err := w.CreateGLContext(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// CreateGLContext initializes EGL on first use and makes the context current.
+func (w *x11WindowWrapper) CreateGLContext() error {
+	if w.conn == nil {
+		return fmt.Errorf("EGL: window is closed")
+	}
 	if w.eglDisplay == eglNoDisplay() {
-		w.initEGL()
-		return
+		return w.initEGL()
 	}
 	if w.eglSurface != eglNoSurface() && w.eglContext != eglNoContext() {
 		if C.eglMakeCurrent(w.eglDisplay, w.eglSurface, w.eglSurface, w.eglContext) == C.EGL_FALSE {
-			panic(fmt.Sprintf("EGL: eglMakeCurrent failed: %v", eglError()))
+			return fmt.Errorf("EGL: eglMakeCurrent failed: %v", eglError())
 		}
 	}
+	return nil
 }
 
 func (w *x11WindowWrapper) EndFrame() {
@@ -337,32 +421,73 @@ func (w *x11WindowWrapper) NewPlatformImageWrapper(img *image.RGBA, offsetX, off
 	return newx11ImageWrapper(w, img, offsetX, offsetY)
 }
 
+// GPUAvailable reports whether EGL can be initialized with the configured GL
+// API on this display. The display is left initialized for initEGL.
+func (w *x11WindowWrapper) GPUAvailable() bool {
+	if w.eglDisplay != eglNoDisplay() {
+		return true
+	}
+	if w.conn == nil || w.conn.display == nil {
+		return false
+	}
+	display := C.eglGetDisplay(C.EGLNativeDisplayType(unsafe.Pointer(w.conn.display)))
+	if display == eglNoDisplay() {
+		return false
+	}
+	if C.eglInitialize(display, nil, nil) == C.EGL_FALSE {
+		return false
+	}
+	w.eglProbed = display
+	return C.eglBindAPI(w.eglAPI()) != C.EGL_FALSE
+}
+
+// eglAPI and eglRenderableBit map the configured GL API to EGL.
+func (w *x11WindowWrapper) eglAPI() C.EGLenum {
+	if w.glConfig.API == GLAPIOpenGLES {
+		return C.EGL_OPENGL_ES_API
+	}
+	return C.EGL_OPENGL_API
+}
+
+func (w *x11WindowWrapper) eglRenderableBit() C.EGLint {
+	if w.glConfig.API == GLAPIOpenGLES {
+		return C.EGL_OPENGL_ES3_BIT
+	}
+	return C.EGL_OPENGL_BIT
+}
+
 // ----------------------------------------------------------------------------
 
-func (w *x11WindowWrapper) initEGL() {
+// initEGL creates the surface and context and makes them current. On failure
+// nothing but the initialized display is kept, and destroyEGL terminates it.
+func (w *x11WindowWrapper) initEGL() error {
 	if w.conn == nil || w.conn.display == nil {
-		return
+		return fmt.Errorf("EGL: no X display")
 	}
 	if w.eglDisplay != eglNoDisplay() {
-		return
+		return nil
+	}
+	if err := w.glConfig.Validate(); err != nil {
+		return fmt.Errorf("EGL: %w", err)
 	}
 
 	display := C.eglGetDisplay(C.EGLNativeDisplayType(unsafe.Pointer(w.conn.display)))
 	if display == eglNoDisplay() {
-		panic(fmt.Sprintf("EGL: eglGetDisplay failed: %v", eglError()))
+		return fmt.Errorf("EGL: eglGetDisplay failed: %v", eglError())
 	}
 
 	if C.eglInitialize(display, nil, nil) == C.EGL_FALSE {
-		panic(fmt.Sprintf("EGL: eglInitialize failed: %v", eglError()))
+		return fmt.Errorf("EGL: eglInitialize failed: %v", eglError())
 	}
+	w.eglProbed = display
 
-	if C.eglBindAPI(C.EGL_OPENGL_API) == C.EGL_FALSE {
-		panic(fmt.Sprintf("EGL: eglBindAPI failed: %v", eglError()))
+	if C.eglBindAPI(w.eglAPI()) == C.EGL_FALSE {
+		return fmt.Errorf("EGL: eglBindAPI failed: %v", eglError())
 	}
 
 	attrs := []C.EGLint{
 		C.EGL_SURFACE_TYPE, C.EGL_WINDOW_BIT,
-		C.EGL_RENDERABLE_TYPE, C.EGL_OPENGL_BIT,
+		C.EGL_RENDERABLE_TYPE, w.eglRenderableBit(),
 		C.EGL_RED_SIZE, 8,
 		C.EGL_GREEN_SIZE, 8,
 		C.EGL_BLUE_SIZE, 8,
@@ -374,26 +499,32 @@ func (w *x11WindowWrapper) initEGL() {
 	var config C.EGLConfig
 	var num C.EGLint
 	if C.eglChooseConfig(display, &attrs[0], &config, 1, &num) == C.EGL_FALSE || num == 0 {
-		panic(fmt.Sprintf("EGL: eglChooseConfig failed: %v", eglError()))
+		return fmt.Errorf("EGL: eglChooseConfig failed: %v", eglError())
 	}
 
 	surface := C.eglCreateWindowSurface(display, config, C.EGLNativeWindowType(w.window), nil)
 	if surface == eglNoSurface() {
-		panic(fmt.Sprintf("EGL: eglCreateWindowSurface failed: %v", eglError()))
+		return fmt.Errorf("EGL: eglCreateWindowSurface failed: %v", eglError())
 	}
 
+	major, minor := w.glConfig.Version()
 	ctxAttrs := []C.EGLint{
-		C.EGL_CONTEXT_MAJOR_VERSION, 3,
-		C.EGL_CONTEXT_MINOR_VERSION, 3,
+		C.EGL_CONTEXT_MAJOR_VERSION, C.EGLint(major),
+		C.EGL_CONTEXT_MINOR_VERSION, C.EGLint(minor),
 		C.EGL_NONE,
 	}
 	context := C.eglCreateContext(display, config, eglNoContext(), &ctxAttrs[0])
 	if context == eglNoContext() {
-		panic(fmt.Sprintf("EGL: eglCreateContext failed: %v", eglError()))
+		err := fmt.Errorf("EGL: eglCreateContext failed: %v", eglError())
+		C.eglDestroySurface(display, surface)
+		return err
 	}
 
 	if C.eglMakeCurrent(display, surface, surface, context) == C.EGL_FALSE {
-		panic(fmt.Sprintf("EGL: eglMakeCurrent failed: %v", eglError()))
+		err := fmt.Errorf("EGL: eglMakeCurrent failed: %v", eglError())
+		C.eglDestroyContext(display, context)
+		C.eglDestroySurface(display, surface)
+		return err
 	}
 	C.eglSwapInterval(display, 1)
 
@@ -401,12 +532,19 @@ func (w *x11WindowWrapper) initEGL() {
 	w.eglConfig = config
 	w.eglSurface = surface
 	w.eglContext = context
+	return nil
 }
 
 func (w *x11WindowWrapper) destroyEGL() {
 	if w.eglDisplay == eglNoDisplay() {
+		// Probed by GPUAvailable, or left by a failed initEGL.
+		if w.eglProbed != eglNoDisplay() {
+			C.eglTerminate(w.eglProbed)
+			w.eglProbed = eglNoDisplay()
+		}
 		return
 	}
+	w.eglProbed = eglNoDisplay()
 	C.eglMakeCurrent(w.eglDisplay, eglNoSurface(), eglNoSurface(), eglNoContext())
 	if w.eglContext != eglNoContext() {
 		C.eglDestroyContext(w.eglDisplay, w.eglContext)
@@ -448,30 +586,45 @@ func convert(event C.XEvent) Event {
 	case 2:
 		event := (*C.XKeyEvent)(unsafe.Pointer(&event))
 		code, label := decodeKeyEvent(event)
-		return KeyPress{Code: code, Label: label}
+		return KeyPress{Code: code, Key: x11Key(code), Label: label}
 	case 3:
 		event := (*C.XKeyEvent)(unsafe.Pointer(&event))
 		code, label := decodeKeyEvent(event)
-		return KeyRelease{Code: code, Label: label}
+		return KeyRelease{Code: code, Key: x11Key(code), Label: label}
 	case 4:
 		event := (*C.XButtonEvent)(unsafe.Pointer(&event))
 		if dx, dy, ok := x11WheelDelta(uint(event.button)); ok {
 			return MouseWheel{DeltaX: dx, DeltaY: dy, X: int(event.x), Y: int(event.y)}
 		}
-		return ButtonPress{Button: uint32(event.button), X: int(event.x), Y: int(event.y)}
+		button := uint32(event.button)
+		buttons := x11ButtonsMask(uint(event.state)) | buttonBit(button)
+		return ButtonPress{Button: button, Buttons: buttons, X: int(event.x), Y: int(event.y)}
 	case 5:
 		event := (*C.XButtonEvent)(unsafe.Pointer(&event))
 		if _, _, ok := x11WheelDelta(uint(event.button)); ok {
 			return UnexpectedEvent{}
 		}
-		return ButtonRelease{Button: uint32(event.button), X: int(event.x), Y: int(event.y)}
+		button := uint32(event.button)
+		buttons := x11ButtonsMask(uint(event.state)) &^ buttonBit(button)
+		return ButtonRelease{Button: button, Buttons: buttons, X: int(event.x), Y: int(event.y)}
 	case 6:
 		event := (*C.XButtonEvent)(unsafe.Pointer(&event))
 		return MotionNotify{X: int(event.x), Y: int(event.y)}
 	case 7:
-		return EnterNotify{}
+		event := (*C.XCrossingEvent)(unsafe.Pointer(&event))
+		if event.detail == notifyInferior {
+			return UnexpectedEvent{}
+		}
+		return EnterNotify{X: int(event.x), Y: int(event.y)}
 	case 8:
-		return LeaveNotify{}
+		// Reported for every exit, including through a corner and while a
+		// button is held (mode NotifyGrab/NotifyUngrab); only moves into a
+		// child window, where the pointer is still inside, are skipped.
+		event := (*C.XCrossingEvent)(unsafe.Pointer(&event))
+		if event.detail == notifyInferior {
+			return UnexpectedEvent{}
+		}
+		return LeaveNotify{X: int(event.x), Y: int(event.y)}
 	case 12:
 		return Expose{}
 	case 16:
@@ -486,10 +639,20 @@ func convert(event C.XEvent) Event {
 	}
 }
 
+// notifyInferior is the XCrossingEvent detail for the pointer moving between
+// the window and one of its children.
+const notifyInferior = 2
+
 func FD_SET(fd int, p *syscall.FdSet) {
 	p.Bits[fd/64] |= 1 << (uint(fd) % 64)
 }
 
+// x11ButtonsMask converts the Button1Mask..Button3Mask bits of an X event
+// state (held before the event) into the Buttons layout.
+func x11ButtonsMask(state uint) uint32 {
+	return uint32(state>>8) & 0x7
+}
+
 func x11WheelDelta(button uint) (float64, float64, bool) {
 	switch button {
 	case 4:
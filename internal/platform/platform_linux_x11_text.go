@@ -0,0 +1,127 @@
+//go:build linux && x11
+
+package platform
+
+/*
+#include <locale.h>
+#include <stdlib.h>
+#include <string.h>
+#include <X11/Xlib.h>
+#include <X11/Xutil.h>
+
+// gokxOpenIM opens the input method named by XMODIFIERS, falling back to the
+// built-in compose handling. A Go program never calls setlocale, so LC_CTYPE
+// is taken from the environment first when it is still "C"; without it Xlib
+// knows no compose sequences.
+static XIM gokxOpenIM(Display *d) {
+    const char *current = setlocale(LC_CTYPE, NULL);
+    if (current && strcmp(current, "C") == 0) {
+        setlocale(LC_CTYPE, "");
+    }
+    if (!XSupportsLocale()) {
+        return NULL;
+    }
+    XSetLocaleModifiers("");
+    XIM im = XOpenIM(d, NULL, NULL, NULL);
+    if (!im) {
+        XSetLocaleModifiers("@im=none");
+        im = XOpenIM(d, NULL, NULL, NULL);
+    }
+    return im;
+}
+
+static XIC gokxCreateIC(XIM im, Window win) {
+    return XCreateIC(im,
+        XNInputStyle, XIMPreeditNothing | XIMStatusNothing,
+        XNClientWindow, win,
+        XNFocusWindow, win,
+        NULL);
+}
+
+// gokxLookupText writes the UTF-8 text typed by ev into buf. It returns the
+// text length, or the size needed as a negative number when buf is too small.
+static int gokxLookupText(XIC ic, XKeyPressedEvent *ev, char *buf, int size) {
+    KeySym keysym;
+    Status status;
+    int n = Xutf8LookupString(ic, ev, buf, size, &keysym, &status);
+    if (status == XBufferOverflow) {
+        return -n;
+    }
+    if (status != XLookupChars && status != XLookupBoth) {
+        return 0;
+    }
+    return n;
+}
+*/
+import "C"
+
+import (
+	"unicode/utf8"
+	"unsafe"
+)
+
+// xim is the X input method context that composes TextInput from key
+// presses: dead keys, compose sequences and IMEs selected by XMODIFIERS.
+type xim struct {
+	im C.XIM
+	ic C.XIC
+}
+
+// initXIM opens the input method of w. Without one, text falls back to the
+// Latin-1 XLookupString.
+func (w *x11WindowWrapper) initXIM() {
+	im := C.gokxOpenIM(w.conn.display)
+	if im == nil {
+		return
+	}
+	ic := C.gokxCreateIC(im, w.window)
+	if ic == nil {
+		C.XCloseIM(im)
+		return
+	}
+	C.XSetICFocus(ic)
+	w.xim = xim{im: im, ic: ic}
+}
+
+func (w *x11WindowWrapper) closeXIM() {
+	if w.xim.ic != nil {
+		C.XDestroyIC(w.xim.ic)
+	}
+	if w.xim.im != nil {
+		C.XCloseIM(w.xim.im)
+	}
+	w.xim = xim{}
+}
+
+// filterXIM hands ev to the input method, which swallows the key presses it
+// consumes while composing (e.g. a dead key).
+func (w *x11WindowWrapper) filterXIM(ev *C.XEvent) bool {
+	return w.xim.ic != nil && C.XFilterEvent(ev, 0) != 0
+}
+
+// lookupText returns the text typed by a KeyPress event.
+func (w *x11WindowWrapper) lookupText(ev *C.XEvent) (string, bool) {
+	key := (*C.XKeyPressedEvent)(unsafe.Pointer(ev))
+	buf := make([]byte, 32)
+	if w.xim.ic == nil {
+		n := C.XLookupString(key, (*C.char)(unsafe.Pointer(&buf[0])), C.int(len(buf)), nil, nil)
+		return inputText(latin1(buf[:n]))
+	}
+	n := int(C.gokxLookupText(w.xim.ic, key, (*C.char)(unsafe.Pointer(&buf[0])), C.int(len(buf))))
+	if n < 0 {
+		buf = make([]byte, -n)
+		n = int(C.gokxLookupText(w.xim.ic, key, (*C.char)(unsafe.Pointer(&buf[0])), C.int(len(buf))))
+	}
+	if n <= 0 || !utf8.Valid(buf[:n]) {
+		return "", false
+	}
+	return inputText(string(buf[:n]))
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
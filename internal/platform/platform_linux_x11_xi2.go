@@ -0,0 +1,267 @@
+//go:build linux && x11
+
+package platform
+
+/*
+#cgo LDFLAGS: -lX11 -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <X11/Xlib.h>
+#include <X11/extensions/XI2.h>
+
+// libXi is loaded at runtime so the backend keeps working, with wheel buttons
+// only, where it is missing. The structs mirror <X11/extensions/XInput2.h>.
+
+typedef struct {
+    int deviceid;
+    int mask_len;
+    unsigned char *mask;
+} gokxXIEventMask;
+
+typedef struct {
+    int type;
+    int sourceid;
+} gokxXIAnyClassInfo;
+
+typedef struct {
+    int type;
+    int sourceid;
+    int number;
+    int scroll_type;
+    double increment;
+    int flags;
+} gokxXIScrollClassInfo;
+
+typedef struct {
+    int deviceid;
+    char *name;
+    int use;
+    int attachment;
+    Bool enabled;
+    int num_classes;
+    gokxXIAnyClassInfo **classes;
+} gokxXIDeviceInfo;
+
+typedef struct {
+    int mask_len;
+    unsigned char *mask;
+} gokxXIButtonState;
+
+typedef struct {
+    int mask_len;
+    unsigned char *mask;
+    double *values;
+} gokxXIValuatorState;
+
+typedef struct {
+    int base;
+    int latched;
+    int locked;
+    int effective;
+} gokxXIModifierState;
+
+typedef struct {
+    int type;
+    unsigned long serial;
+    Bool send_event;
+    Display *display;
+    int extension;
+    int evtype;
+    Time time;
+    int deviceid;
+    int sourceid;
+    int detail;
+    Window root;
+    Window event;
+    Window child;
+    double root_x;
+    double root_y;
+    double event_x;
+    double event_y;
+    int flags;
+    gokxXIButtonState buttons;
+    gokxXIValuatorState valuators;
+    gokxXIModifierState mods;
+    gokxXIModifierState group;
+} gokxXIDeviceEvent;
+
+static Status (*gokxXIQueryVersion)(Display *, int *, int *);
+static int (*gokxXISelectEvents)(Display *, Window, gokxXIEventMask *, int);
+static gokxXIDeviceInfo *(*gokxXIQueryDevice)(Display *, int, int *);
+static void (*gokxXIFreeDeviceInfo)(gokxXIDeviceInfo *);
+
+static int gokxLoadXI(void) {
+    if (gokxXIQueryVersion) {
+        return 1;
+    }
+    void *lib = dlopen("libXi.so.6", RTLD_LAZY | RTLD_LOCAL);
+    if (!lib) {
+        return 0;
+    }
+    void *queryVersion = dlsym(lib, "XIQueryVersion");
+    gokxXISelectEvents = dlsym(lib, "XISelectEvents");
+    gokxXIQueryDevice = dlsym(lib, "XIQueryDevice");
+    gokxXIFreeDeviceInfo = dlsym(lib, "XIFreeDeviceInfo");
+    if (!queryVersion || !gokxXISelectEvents || !gokxXIQueryDevice || !gokxXIFreeDeviceInfo) {
+        dlclose(lib);
+        return 0;
+    }
+    gokxXIQueryVersion = queryVersion;
+    return 1;
+}
+
+// gokxSelectXI2 selects pointer motion and device changes on win through
+// XInput 2.1, the first version with scroll valuators. It returns the
+// extension opcode, or -1 when XI 2.1 is not available.
+static int gokxSelectXI2(Display *d, Window win) {
+    int opcode, event, error;
+    if (!XQueryExtension(d, "XInputExtension", &opcode, &event, &error)) {
+        return -1;
+    }
+    if (!gokxLoadXI()) {
+        return -1;
+    }
+    int major = 2, minor = 1;
+    if (gokxXIQueryVersion(d, &major, &minor) != Success || major * 100 + minor < 201) {
+        return -1;
+    }
+    unsigned char bits[XIMaskLen(XI_LASTEVENT)] = {0};
+    XISetMask(bits, XI_Motion);
+    XISetMask(bits, XI_DeviceChanged);
+    gokxXIEventMask mask = {XIAllMasterDevices, sizeof(bits), bits};
+    if (gokxXISelectEvents(d, win, &mask, 1) != Success) {
+        return -1;
+    }
+    return opcode;
+}
+
+// gokxScrollAxes writes up to max scroll valuators of device (or of all
+// master devices) into the arrays and returns how many it wrote.
+static int gokxScrollAxes(Display *d, int device, int *ids, int *numbers, int *types, double *increments, int max) {
+    int n = 0;
+    gokxXIDeviceInfo *info = gokxXIQueryDevice(d, device, &n);
+    if (!info) {
+        return 0;
+    }
+    int count = 0;
+    for (int i = 0; i < n; i++) {
+        for (int c = 0; c < info[i].num_classes && count < max; c++) {
+            if (info[i].classes[c]->type != XIScrollClass) {
+                continue;
+            }
+            gokxXIScrollClassInfo *scroll = (gokxXIScrollClassInfo *)info[i].classes[c];
+            ids[count] = info[i].deviceid;
+            numbers[count] = scroll->number;
+            types[count] = scroll->scroll_type;
+            increments[count] = scroll->increment;
+            count++;
+        }
+    }
+    gokxXIFreeDeviceInfo(info);
+    return count;
+}
+
+// gokxValuator stores the value of valuator number in *value if ev carries it.
+static int gokxValuator(gokxXIDeviceEvent *ev, int number, double *value) {
+    if (number < 0 || number >= ev->valuators.mask_len * 8 || !XIMaskIsSet(ev->valuators.mask, number)) {
+        return 0;
+    }
+    double *v = ev->valuators.values;
+    for (int i = 0; i < number; i++) {
+        if (XIMaskIsSet(ev->valuators.mask, i)) {
+            v++;
+        }
+    }
+    *value = *v;
+    return 1;
+}
+*/
+import "C"
+
+import "unsafe"
+
+// maxScrollAxes bounds the scroll valuators read per device query.
+const maxScrollAxes = 32
+
+// xi2Scroll is the XInput2 smooth scrolling path of an X11 window. Selecting
+// XI_Motion replaces core MotionNotify for this client, so motion is
+// converted here as well.
+type xi2Scroll struct {
+	opcode  int
+	enabled bool
+	state   scrollState
+}
+
+// initXI2 enables smooth scrolling on w. Without XI 2.1 the window keeps
+// using the core wheel buttons.
+func (w *x11WindowWrapper) initXI2() {
+	opcode := int(C.gokxSelectXI2(w.conn.display, w.window))
+	if opcode < 0 {
+		return
+	}
+	w.xi2.opcode = opcode
+	w.xi2.enabled = true
+	w.refreshScrollAxes(C.XIAllMasterDevices)
+}
+
+// refreshScrollAxes re-reads the scroll valuators of device, or of every
+// master device.
+func (w *x11WindowWrapper) refreshScrollAxes(device C.int) {
+	var (
+		ids        [maxScrollAxes]C.int
+		numbers    [maxScrollAxes]C.int
+		types      [maxScrollAxes]C.int
+		increments [maxScrollAxes]C.double
+	)
+	n := int(C.gokxScrollAxes(w.conn.display, device, &ids[0], &numbers[0], &types[0], &increments[0], maxScrollAxes))
+	axes := make(map[int][]scrollAxis)
+	if device != C.XIAllMasterDevices {
+		axes[int(device)] = nil
+	}
+	for i := 0; i < n; i++ {
+		id := int(ids[i])
+		axes[id] = append(axes[id], scrollAxis{
+			number:     int(numbers[i]),
+			horizontal: types[i] != C.XIScrollTypeVertical,
+			increment:  float64(increments[i]),
+		})
+	}
+	for id, list := range axes {
+		w.xi2.state.setAxes(id, list)
+	}
+}
+
+// convertXI2 converts an XInput2 generic event. ok is false for events of
+// other extensions.
+func (w *x11WindowWrapper) convertXI2(ev *C.XEvent) (Event, bool) {
+	cookie := (*C.XGenericEventCookie)(unsafe.Pointer(ev))
+	if !w.xi2.enabled || int(cookie.extension) != w.xi2.opcode {
+		return nil, false
+	}
+	if C.XGetEventData(w.conn.display, cookie) == 0 {
+		return UnexpectedEvent{}, true
+	}
+	defer C.XFreeEventData(w.conn.display, cookie)
+
+	event := (*C.gokxXIDeviceEvent)(cookie.data)
+	switch cookie.evtype {
+	case C.XI_DeviceChanged:
+		w.refreshScrollAxes(event.deviceid)
+		return UnexpectedEvent{}, true
+	case C.XI_Motion:
+		x, y := int(event.event_x), int(event.event_y)
+		dx, dy, ok := w.xi2.state.delta(int(event.deviceid), func(number int) (float64, bool) {
+			var value C.double
+			if C.gokxValuator(event, C.int(number), &value) == 0 {
+				return 0, false
+			}
+			return float64(value), true
+		})
+		if ok {
+			return MouseWheel{DeltaX: dx, DeltaY: dy, X: x, Y: y}, true
+		}
+		return MotionNotify{X: x, Y: y}, true
+	default:
+		return UnexpectedEvent{}, true
+	}
+}
@@ -9,6 +9,7 @@ package platform
 import "C"
 import (
 	"fmt"
+	"image"
 	"runtime"
 	"unsafe"
 )
@@ -19,40 +20,95 @@ type sdlWindowWrapper struct {
 	title     string
 	width     int
 	height    int
+	// software is set for a window created without SDL_WINDOW_OPENGL. Only
+	// such a window presents frames through its surface: SDL forbids
+	// SDL_GetWindowSurface on OpenGL windows.
+	software bool
 }
 
-func NewPlatformWindowWrapper(conf WindowConfig) PlatformWindowWrapper {
+func NewPlatformWindowWrapper(conf WindowConfig) (PlatformWindowWrapper, error) {
+	if err := conf.GL.Validate(); err != nil {
+		return nil, err
+	}
 	runtime.LockOSThread()
 	if C.SDL_Init(C.SDL_INIT_VIDEO) != 0 {
-		panic(fmt.Sprintf("SDL_Init error: %s", C.GoString(C.SDL_GetError())))
+		err := fmt.Errorf("SDL_Init error: %s", C.GoString(C.SDL_GetError()))
+		runtime.UnlockOSThread()
+		return nil, err
 	}
-
-	C.SDL_GL_SetAttribute(C.SDL_GL_CONTEXT_MAJOR_VERSION, 3)
-	C.SDL_GL_SetAttribute(C.SDL_GL_CONTEXT_MINOR_VERSION, 3)
-	C.SDL_GL_SetAttribute(C.SDL_GL_CONTEXT_PROFILE_MASK, C.SDL_GL_CONTEXT_PROFILE_CORE)
+	major, minor := conf.GL.Version()
+	profile := C.int(C.SDL_GL_CONTEXT_PROFILE_CORE)
+	if conf.GL.API == GLAPIOpenGLES {
+		profile = C.SDL_GL_CONTEXT_PROFILE_ES
+	}
+	C.SDL_GL_SetAttribute(C.SDL_GL_CONTEXT_MAJOR_VERSION, C.int(major))
+	C.SDL_GL_SetAttribute(C.SDL_GL_CONTEXT_MINOR_VERSION, C.int(minor))
+	C.SDL_GL_SetAttribute(C.SDL_GL_CONTEXT_PROFILE_MASK, profile)
 	C.SDL_GL_SetAttribute(C.SDL_GL_DOUBLEBUFFER, 1)
 	C.SDL_GL_SetAttribute(C.SDL_GL_DEPTH_SIZE, 24)
 
-	cTitle := C.CString(conf.Title)
-	defer C.free(unsafe.Pointer(cTitle))
-
-	window := C.SDL_CreateWindow(cTitle, C.SDL_WINDOWPOS_CENTERED, C.SDL_WINDOWPOS_CENTERED,
-		C.int(conf.Width), C.int(conf.Height), C.SDL_WINDOW_SHOWN|C.SDL_WINDOW_OPENGL)
+	// Without a usable GL library the OpenGL window fails; fall back to a
+	// plain window for the software renderer.
+	software := false
+	window := createSDLWindow(conf.Title, conf.Width, conf.Height, false)
+	if window == nil {
+		software = true
+		window = createSDLWindow(conf.Title, conf.Width, conf.Height, true)
+	}
 	if window == nil {
-		panic(fmt.Sprintf("SDL_CreateWindow error: %s", C.GoString(C.SDL_GetError())))
+		err := fmt.Errorf("SDL_CreateWindow error: %s", C.GoString(C.SDL_GetError()))
+		C.SDL_Quit()
+		runtime.UnlockOSThread()
+		return nil, err
 	}
 
 	return &sdlWindowWrapper{
-		window: window,
-		title:  conf.Title,
-		width:  conf.Width,
-		height: conf.Height,
+		window:   window,
+		title:    conf.Title,
+		width:    conf.Width,
+		height:   conf.Height,
+		software: software,
+	}, nil
+}
+
+// createSDLWindow opens a centered window, with SDL_WINDOW_OPENGL unless
+// software is set. It returns nil on failure, with the cause in SDL_GetError.
+func createSDLWindow(title string, width, height int, software bool) *C.SDL_Window {
+	cTitle := C.CString(title)
+	defer C.free(unsafe.Pointer(cTitle))
+	flags := C.Uint32(C.SDL_WINDOW_SHOWN)
+	if !software {
+		flags |= C.SDL_WINDOW_OPENGL
 	}
+	return C.SDL_CreateWindow(cTitle, C.SDL_WINDOWPOS_CENTERED, C.SDL_WINDOWPOS_CENTERED,
+		C.int(width), C.int(height), flags)
+}
+
+// useSurface switches the window to the surface path for the software
+// renderer. An OpenGL window that has no GL context yet is recreated without
+// SDL_WINDOW_OPENGL; once a GL context exists the window stays OpenGL and
+// useSurface reports false.
+func (w *sdlWindowWrapper) useSurface() bool {
+	if w.software {
+		return true
+	}
+	if w.window == nil || w.glContext != nil {
+		return false
+	}
+	window := createSDLWindow(w.title, w.width, w.height, true)
+	if window == nil {
+		return false
+	}
+	C.SDL_DestroyWindow(w.window)
+	w.window = window
+	w.software = true
+	return true
 }
 
 func (w *sdlWindowWrapper) Show() {
 	C.SDL_ShowWindow(w.window)
 	C.SDL_EventState(C.SDL_QUIT, C.SDL_ENABLE)
+	C.SDL_StartTextInput()
 }
 
 func (w *sdlWindowWrapper) Close() {
@@ -77,24 +133,38 @@ func (w *sdlWindowWrapper) NextEventTimeout(timeoutMs int) Event {
 }
 
 func (w *sdlWindowWrapper) BeginFrame() {
-	if w.window == nil {
+	if w.window == nil || w.software {
 		return
 	}
+	if err := w.CreateGLContext(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// CreateGLContext creates the GL context on first use and makes it current.
+func (w *sdlWindowWrapper) CreateGLContext() error {
+	if w.window == nil {
+		return fmt.Errorf("SDL: window is closed")
+	}
+	if w.software {
+		return fmt.Errorf("SDL: window was created without OpenGL")
+	}
 	if w.glContext == nil {
 		ctx := C.SDL_GL_CreateContext(w.window)
 		if ctx == nil {
-			panic(fmt.Sprintf("SDL_GL_CreateContext error: %s", C.GoString(C.SDL_GetError())))
+			return fmt.Errorf("SDL_GL_CreateContext error: %s", C.GoString(C.SDL_GetError()))
 		}
 		w.glContext = ctx
 		C.SDL_GL_SetSwapInterval(1)
 	}
 	if C.SDL_GL_MakeCurrent(w.window, w.glContext) != 0 {
-		panic(fmt.Sprintf("SDL_GL_MakeCurrent error: %s", C.GoString(C.SDL_GetError())))
+		return fmt.Errorf("SDL_GL_MakeCurrent error: %s", C.GoString(C.SDL_GetError()))
 	}
+	return nil
 }
 
 func (w *sdlWindowWrapper) EndFrame() {
-	if w.window == nil {
+	if w.window == nil || w.software {
 		return
 	}
 	C.SDL_GL_SwapWindow(w.window)
@@ -104,33 +174,128 @@ func (w *sdlWindowWrapper) GLContext() any {
 	return nil
 }
 
+func (w *sdlWindowWrapper) GPUAvailable() bool {
+	if w.software {
+		return false
+	}
+	if w.glContext != nil {
+		return true
+	}
+	return C.SDL_GL_LoadLibrary(nil) == 0
+}
+
+// NewPlatformImageWrapper returns nil for a window that already has a GL
+// context, see useSurface.
+func (w *sdlWindowWrapper) NewPlatformImageWrapper(img *image.RGBA, offsetX, offsetY int) PlatformImageWrapper {
+	if !w.useSurface() {
+		return nil
+	}
+	return newSDLImageWrapper(w, img, offsetX, offsetY)
+}
+
+// ----------------------------------------------------------------------------
+
+// sdlImageWrapper blits an image.RGBA to the window surface. Pixels are copied
+// into C memory first, since SDL keeps the pixel pointer inside the surface.
+type sdlImageWrapper struct {
+	win              *sdlWindowWrapper
+	src              *image.RGBA
+	buf              unsafe.Pointer
+	surface          *C.SDL_Surface
+	offsetX, offsetY int
+	w, h             int
+}
+
+func newSDLImageWrapper(win *sdlWindowWrapper, img *image.RGBA, offsetX, offsetY int) *sdlImageWrapper {
+	w := img.Rect.Dx()
+	h := img.Rect.Dy()
+	buf := C.malloc(C.size_t(h * img.Stride))
+	surface := C.SDL_CreateRGBSurfaceWithFormatFrom(
+		buf, C.int(w), C.int(h), 32, C.int(img.Stride), C.SDL_PIXELFORMAT_RGBA32,
+	)
+	if surface == nil {
+		C.free(buf)
+		panic(fmt.Sprintf("SDL_CreateRGBSurfaceWithFormatFrom error: %s", C.GoString(C.SDL_GetError())))
+	}
+	return &sdlImageWrapper{
+		win: win, src: img, buf: buf, surface: surface,
+		offsetX: offsetX, offsetY: offsetY, w: w, h: h,
+	}
+}
+
+func (sw *sdlImageWrapper) Update(rect image.Rectangle) {
+	r := rect.Intersect(image.Rect(0, 0, sw.w, sw.h))
+	if r.Empty() || sw.surface == nil || sw.win.window == nil {
+		return
+	}
+	stride := sw.src.Stride
+	dst := unsafe.Slice((*byte)(sw.buf), sw.h*stride)
+	rowBytes := r.Dx() * 4
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		off := y*stride + r.Min.X*4
+		copy(dst[off:off+rowBytes], sw.src.Pix[off:off+rowBytes])
+	}
+
+	target := C.SDL_GetWindowSurface(sw.win.window)
+	if target == nil {
+		return
+	}
+	srcRect := C.SDL_Rect{x: C.int(r.Min.X), y: C.int(r.Min.Y), w: C.int(r.Dx()), h: C.int(r.Dy())}
+	dstRect := C.SDL_Rect{x: C.int(sw.offsetX + r.Min.X), y: C.int(sw.offsetY + r.Min.Y), w: C.int(r.Dx()), h: C.int(r.Dy())}
+	// SDL_BlitSurface is a macro for SDL_UpperBlit.
+	C.SDL_UpperBlit(sw.surface, &srcRect, target, &dstRect)
+	C.SDL_UpdateWindowSurfaceRects(sw.win.window, &dstRect, 1)
+}
+
+func (sw *sdlImageWrapper) Delete() {
+	if sw.surface != nil {
+		C.SDL_FreeSurface(sw.surface)
+		sw.surface = nil
+	}
+	if sw.buf != nil {
+		C.free(sw.buf)
+		sw.buf = nil
+	}
+	sw.src = nil
+}
+
 func convert(event C.SDL_Event) Event {
 	switch eventType := (*(*C.Uint32)(unsafe.Pointer(&event))); eventType {
 	case C.SDL_QUIT:
-		return DestroyNotify{}
+		return CloseRequest{}
 	case C.SDL_KEYDOWN:
 		keyEvent := (*C.SDL_KeyboardEvent)(unsafe.Pointer(&event))
 		code := uint64(keyEvent.keysym.scancode)
 		label := C.GoString(C.SDL_GetKeyName(keyEvent.keysym.sym))
-		return KeyPress{Code: code, Label: label}
+		return KeyPress{Code: code, Key: sdlKey(code), Label: label}
 	case C.SDL_KEYUP:
 		keyEvent := (*C.SDL_KeyboardEvent)(unsafe.Pointer(&event))
 		code := uint64(keyEvent.keysym.scancode)
 		label := C.GoString(C.SDL_GetKeyName(keyEvent.keysym.sym))
-		return KeyRelease{Code: code, Label: label}
+		return KeyRelease{Code: code, Key: sdlKey(code), Label: label}
+	case C.SDL_TEXTINPUT:
+		textEvent := (*C.SDL_TextInputEvent)(unsafe.Pointer(&event))
+		if text, ok := inputText(C.GoString(&textEvent.text[0])); ok {
+			return TextInput{Text: text}
+		}
+		return UnexpectedEvent{}
 	case C.SDL_MOUSEBUTTONDOWN:
 		mouseEvent := (*C.SDL_MouseButtonEvent)(unsafe.Pointer(&event))
+		button := uint32(mouseEvent.button)
 		return ButtonPress{
-			Button: uint32(mouseEvent.button),
-			X:      int(mouseEvent.x),
-			Y:      int(mouseEvent.y),
+			Button:  button,
+			Buttons: uint32(C.SDL_GetMouseState(nil, nil)) | buttonBit(button),
+			X:       int(mouseEvent.x),
+			Y:       int(mouseEvent.y),
 		}
 	case C.SDL_MOUSEBUTTONUP:
 		mouseEvent := (*C.SDL_MouseButtonEvent)(unsafe.Pointer(&event))
+		button := uint32(mouseEvent.button)
 		return ButtonRelease{
-			Button: uint32(mouseEvent.button),
-			X:      int(mouseEvent.x),
-			Y:      int(mouseEvent.y),
+			Button:  button,
+			Buttons: uint32(C.SDL_GetMouseState(nil, nil)) &^ buttonBit(button),
+			X:       int(mouseEvent.x),
+			Y:       int(mouseEvent.y),
 		}
 	case C.SDL_MOUSEMOTION:
 		mouseEvent := (*C.SDL_MouseMotionEvent)(unsafe.Pointer(&event))
@@ -159,10 +324,15 @@ func convert(event C.SDL_Event) Event {
 		switch windowEvent.event {
 		case C.SDL_WINDOWEVENT_EXPOSED:
 			return Expose{}
-		case C.SDL_WINDOWEVENT_ENTER:
-			return EnterNotify{}
-		case C.SDL_WINDOWEVENT_LEAVE:
-			return LeaveNotify{}
+		case C.SDL_WINDOWEVENT_ENTER, C.SDL_WINDOWEVENT_LEAVE:
+			// Window events carry no pointer position; use the last
+			// position SDL tracked for the window.
+			var mx, my C.int
+			C.SDL_GetMouseState(&mx, &my)
+			if windowEvent.event == C.SDL_WINDOWEVENT_ENTER {
+				return EnterNotify{X: int(mx), Y: int(my)}
+			}
+			return LeaveNotify{X: int(mx), Y: int(my)}
 		}
 	default:
 		if eventType >= C.SDL_USEREVENT && eventType < C.SDL_LASTEVENT {
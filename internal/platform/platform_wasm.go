@@ -4,6 +4,7 @@ package platform
 
 import (
 	"fmt"
+	"sync/atomic"
 	"syscall/js"
 	"time"
 )
@@ -15,6 +16,9 @@ type wasmWindowWrapper struct {
 	conf   WindowConfig
 	closed bool
 
+	closeRequestHandler func() bool
+	dropped             atomic.Uint64
+
 	funcs   []js.Func
 	removes []struct {
 		target js.Value
@@ -23,7 +27,10 @@ type wasmWindowWrapper struct {
 	}
 }
 
-func NewPlatformWindowWrapper(conf WindowConfig) PlatformWindowWrapper {
+// NewPlatformWindowWrapper appends a canvas to the page. Without WebGL2 the
+// window still opens, with GPUAvailable reporting false, so the software
+// renderer can be used.
+func NewPlatformWindowWrapper(conf WindowConfig) (PlatformWindowWrapper, error) {
 	doc := js.Global().Get("document")
 	doc.Set("title", conf.Title)
 
@@ -43,14 +50,11 @@ func NewPlatformWindowWrapper(conf WindowConfig) PlatformWindowWrapper {
 	doc.Get("body").Call("appendChild", canvas)
 
 	gl := canvas.Call("getContext", "webgl2")
-	if gl.IsNull() || gl.IsUndefined() {
-		panic("webgl2 context is required")
-	}
 
 	w := &wasmWindowWrapper{
 		canvas: canvas,
 		gl:     gl,
-		events: make(chan Event, 64),
+		events: make(chan Event, eventBufferSize(conf.EventBufferSize)),
 		conf:   conf,
 	}
 
@@ -102,11 +106,14 @@ func NewPlatformWindowWrapper(conf WindowConfig) PlatformWindowWrapper {
 	// klawiatura
 	addEventListener(doc, "keydown", func(e js.Value) {
 		key := e.Get("key").String()
-		w.events <- KeyPress{Code: 0, Label: key}
+		w.push(KeyPress{Code: 0, Key: domKey(e.Get("code").String()), Label: key})
+		if text, ok := domInputText(key, e.Get("ctrlKey").Bool(), e.Get("altKey").Bool(), e.Get("metaKey").Bool()); ok {
+			w.push(TextInput{Text: text})
+		}
 	})
 	addEventListener(doc, "keyup", func(e js.Value) {
 		key := e.Get("key").String()
-		w.events <- KeyRelease{Code: 0, Label: key}
+		w.push(KeyRelease{Code: 0, Key: domKey(e.Get("code").String()), Label: key})
 	})
 
 	// mapowanie DOM -> SDL/X11 (0,1,2) -> (1,2,3)
@@ -124,40 +131,83 @@ func NewPlatformWindowWrapper(conf WindowConfig) PlatformWindowWrapper {
 		}
 	}
 
+	// DOM e.buttons (1=left, 2=right, 4=middle) -> left, middle, right bits
+	mapMouseButtons := func(e js.Value) uint32 {
+		domButtons := uint32(e.Get("buttons").Int())
+		var buttons uint32
+		if domButtons&1 != 0 {
+			buttons |= buttonBit(1)
+		}
+		if domButtons&4 != 0 {
+			buttons |= buttonBit(2)
+		}
+		if domButtons&2 != 0 {
+			buttons |= buttonBit(3)
+		}
+		return buttons
+	}
+
 	// mysz
-	addEventListener(canvas, "mousedown", func(e js.Value) {
+	// Pointer capture keeps pointermove and pointerup on the canvas while a
+	// button is held, so a drag leaving the canvas still reports every move
+	// in canvas coordinates; the browser releases it after pointerup.
+	addEventListener(canvas, "pointerdown", func(e js.Value) {
+		canvas.Call("setPointerCapture", e.Get("pointerId"))
 		x, y := getCanvasCoords(e)
-		w.events <- ButtonPress{
-			Button: mapMouseButton(e), // <— TU
-			X:      x,
-			Y:      y,
-		}
+		w.push(ButtonPress{
+			Button:  mapMouseButton(e),
+			Buttons: mapMouseButtons(e),
+			X:       x,
+			Y:       y,
+		})
 	})
 
-	addEventListener(doc, "mouseup", func(e js.Value) {
+	addEventListener(canvas, "pointerup", func(e js.Value) {
 		x, y := getCanvasCoords(e)
-		w.events <- ButtonRelease{
-			Button: mapMouseButton(e), // <— TU
-			X:      x,
-			Y:      y,
-		}
+		w.push(ButtonRelease{
+			Button:  mapMouseButton(e),
+			Buttons: mapMouseButtons(e),
+			X:       x,
+			Y:       y,
+		})
 	})
 
 	addEventListener(canvas, "pointermove", func(e js.Value) {
+		// Pressing or releasing another button while one is held fires no
+		// pointerdown/pointerup, only a pointermove with button set.
+		if e.Get("button").Int() >= 0 {
+			x, y := getCanvasCoords(e)
+			button, buttons := mapMouseButton(e), mapMouseButtons(e)
+			if buttons&buttonBit(button) != 0 {
+				w.push(ButtonPress{Button: button, Buttons: buttons, X: x, Y: y})
+			} else {
+				w.push(ButtonRelease{Button: button, Buttons: buttons, X: x, Y: y})
+			}
+		}
 		coalesced := e.Call("getCoalescedEvents")
 		length := coalesced.Get("length").Int()
 		if length == 0 {
 			x, y := getCanvasCoords(e)
-			w.events <- MotionNotify{X: x, Y: y}
+			w.push(MotionNotify{X: x, Y: y})
 			return
 		}
 		for i := 0; i < length; i++ {
 			ev := coalesced.Index(i)
 			x, y := getCanvasCoords(ev)
-			w.events <- MotionNotify{X: x, Y: y}
+			w.push(MotionNotify{X: x, Y: y})
 		}
 	})
 
+	addEventListener(canvas, "mouseenter", func(e js.Value) {
+		x, y := getCanvasCoords(e)
+		w.push(EnterNotify{X: x, Y: y})
+	})
+
+	addEventListener(canvas, "mouseleave", func(e js.Value) {
+		x, y := getCanvasCoords(e)
+		w.push(LeaveNotify{X: x, Y: y})
+	})
+
 	addEventListener(canvas, "wheel", func(e js.Value) {
 		deltaX := -e.Get("deltaX").Float()
 		deltaY := -e.Get("deltaY").Float()
@@ -166,25 +216,45 @@ func NewPlatformWindowWrapper(conf WindowConfig) PlatformWindowWrapper {
 			deltaY /= 120.0
 		}
 		x, y := getCanvasCoords(e)
-		w.events <- MouseWheel{
+		w.push(MouseWheel{
 			DeltaX: deltaX,
 			DeltaY: deltaY,
 			X:      x,
 			Y:      y,
-		}
+		})
 	})
 
 	// wyłącz menu kontekstowe
 	addEventListener(canvas, "contextmenu", func(e js.Value) {})
 
+	// beforeunload must be answered synchronously, so it bypasses the event
+	// queue and the shared helper (which always calls preventDefault).
+	beforeUnload := js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 0 || w.closeRequestHandler == nil {
+			return nil
+		}
+		if !w.closeRequestHandler() {
+			args[0].Call("preventDefault")
+			args[0].Set("returnValue", "")
+		}
+		return nil
+	})
+	js.Global().Call("addEventListener", "beforeunload", beforeUnload)
+	w.funcs = append(w.funcs, beforeUnload)
+	w.removes = append(w.removes, struct {
+		target js.Value
+		typ    string
+		fn     js.Func
+	}{target: js.Global(), typ: "beforeunload", fn: beforeUnload})
+
 	// fokus i CreateNotify
 	go func() {
 		time.Sleep(10 * time.Millisecond)
 		canvas.Call("focus")
-		w.events <- CreateNotify{}
+		w.push(CreateNotify{})
 	}()
 
-	return w
+	return w, nil
 }
 
 func (w *wasmWindowWrapper) Show() {
@@ -211,7 +281,24 @@ func (w *wasmWindowWrapper) Close() {
 	w.funcs = nil
 	w.removes = nil
 
-	w.events <- DestroyNotify{}
+	w.push(DestroyNotify{})
+}
+
+// push enqueues e following the configured overflow policy. Without one it
+// waits for room, so no input is lost. JS callbacks must not block for long,
+// so Block is still bounded by its timeout.
+func (w *wasmWindowWrapper) push(e Event) {
+	if w.conf.EventOverflow == DefaultOverflow {
+		w.events <- e
+		return
+	}
+	if Enqueue(w.events, e, w.conf.EventOverflow, w.conf.EventBlockTimeout) {
+		w.dropped.Add(1)
+	}
+}
+
+func (w *wasmWindowWrapper) DroppedEvents() uint64 {
+	return w.dropped.Load()
 }
 
 func (w *wasmWindowWrapper) NextEventTimeout(timeoutMs int) Event {
@@ -223,9 +310,17 @@ func (w *wasmWindowWrapper) NextEventTimeout(timeoutMs int) Event {
 	}
 }
 
+func (w *wasmWindowWrapper) SetCloseRequestHandler(fn func() bool) {
+	w.closeRequestHandler = fn
+}
+
 func (w *wasmWindowWrapper) BeginFrame() {}
 func (w *wasmWindowWrapper) EndFrame()   {}
 
 func (w *wasmWindowWrapper) GLContext() any {
 	return w.gl
 }
+
+func (w *wasmWindowWrapper) GPUAvailable() bool {
+	return !w.gl.IsNull() && !w.gl.IsUndefined()
+}
@@ -0,0 +1,69 @@
+package platform
+
+import "time"
+
+// OverflowPolicy decides what happens when an event queue is full.
+type OverflowPolicy int
+
+const (
+	// DefaultOverflow keeps each queue's own behaviour: Enqueue treats it as
+	// DropNewest, while the WASM input queue waits for room without limit.
+	DefaultOverflow OverflowPolicy = iota
+	// DropNewest discards the event being enqueued.
+	DropNewest
+	// DropOldest discards the oldest queued event to make room.
+	DropOldest
+	// Block waits up to the configured timeout for room, then drops the event.
+	Block
+)
+
+// DefaultBlockTimeout is used by Block when no timeout is configured.
+const DefaultBlockTimeout = 100 * time.Millisecond
+
+// Enqueue sends v to ch according to policy and reports whether an event was
+// dropped (either v or, for DropOldest, the evicted one).
+func Enqueue[T any](ch chan T, v T, policy OverflowPolicy, timeout time.Duration) (dropped bool) {
+	select {
+	case ch <- v:
+		return false
+	default:
+	}
+	switch policy {
+	case DropOldest:
+		for {
+			select {
+			case <-ch:
+				dropped = true
+			default:
+			}
+			select {
+			case ch <- v:
+				return dropped
+			default:
+			}
+		}
+	case Block:
+		if timeout <= 0 {
+			timeout = DefaultBlockTimeout
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case ch <- v:
+			return false
+		case <-timer.C:
+			return true
+		}
+	default:
+		return true
+	}
+}
+
+// eventBufferSize returns the queue capacity for backends that buffer events
+// themselves.
+func eventBufferSize(size int) int {
+	if size <= 0 {
+		return 64
+	}
+	return size
+}
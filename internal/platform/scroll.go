@@ -0,0 +1,78 @@
+package platform
+
+// scrollAxis is one XInput2 scroll valuator of a pointer device. Valuators
+// report an absolute position; one increment of travel is one wheel click.
+type scrollAxis struct {
+	number     int
+	horizontal bool
+	increment  float64
+	last       float64
+	valid      bool
+}
+
+// scrollState turns absolute XInput2 scroll valuator values into MouseWheel
+// deltas, per master pointer device.
+type scrollState struct {
+	axes map[int][]scrollAxis
+}
+
+// setAxes replaces the scroll valuators of device; their positions are
+// re-learned from the next event.
+func (s *scrollState) setAxes(device int, axes []scrollAxis) {
+	if s.axes == nil {
+		s.axes = make(map[int][]scrollAxis)
+	}
+	if len(axes) == 0 {
+		delete(s.axes, device)
+		return
+	}
+	s.axes[device] = axes
+}
+
+// active reports whether any device scrolls through valuators, in which case
+// the core wheel buttons are emulated duplicates.
+func (s *scrollState) active() bool {
+	return len(s.axes) > 0
+}
+
+// reset forgets the last valuator positions, e.g. when the pointer re-enters
+// the window after scrolling elsewhere.
+func (s *scrollState) reset() {
+	for _, axes := range s.axes {
+		for i := range axes {
+			axes[i].valid = false
+		}
+	}
+}
+
+// delta returns the scroll of one device event in wheel clicks, with the sign
+// convention of the wheel buttons: DeltaY > 0 scrolls up, DeltaX > 0 right.
+// valuator reports the value of a valuator number if the event carries it.
+// ok is false when no scroll valuator moved.
+func (s *scrollState) delta(device int, valuator func(number int) (float64, bool)) (dx, dy float64, ok bool) {
+	axes := s.axes[device]
+	for i := range axes {
+		axis := &axes[i]
+		value, set := valuator(axis.number)
+		if !set {
+			continue
+		}
+		last, valid := axis.last, axis.valid
+		axis.last, axis.valid = value, true
+		if !valid || value == last {
+			continue
+		}
+		increment := axis.increment
+		if increment == 0 {
+			increment = 1
+		}
+		clicks := (value - last) / increment
+		if axis.horizontal {
+			dx += clicks
+		} else {
+			dy -= clicks
+		}
+		ok = true
+	}
+	return dx, dy, ok
+}
@@ -0,0 +1,52 @@
+package platform
+
+import "testing"
+
+func TestScrollStateDelta(t *testing.T) {
+	var s scrollState
+	s.setAxes(2, []scrollAxis{
+		{number: 2, horizontal: true, increment: 120},
+		{number: 3, increment: 15},
+	})
+	values := map[int]float64{2: 0, 3: 100}
+	valuator := func(n int) (float64, bool) {
+		v, ok := values[n]
+		return v, ok
+	}
+
+	if _, _, ok := s.delta(2, valuator); ok {
+		t.Fatal("first event should only learn the valuator positions")
+	}
+	values[2], values[3] = 60, 107.5
+	dx, dy, ok := s.delta(2, valuator)
+	if !ok || dx != 0.5 || dy != -0.5 {
+		t.Fatalf("delta = (%v,%v,%v), want (0.5,-0.5,true)", dx, dy, ok)
+	}
+	if _, _, ok := s.delta(2, valuator); ok {
+		t.Error("unchanged valuators should not scroll")
+	}
+	if _, _, ok := s.delta(9, valuator); ok {
+		t.Error("device without scroll axes should not scroll")
+	}
+
+	s.reset()
+	values[3] = 500
+	if _, _, ok := s.delta(2, valuator); ok {
+		t.Error("reset should re-learn positions instead of jumping")
+	}
+}
+
+func TestScrollStateActive(t *testing.T) {
+	var s scrollState
+	if s.active() {
+		t.Fatal("empty state should not be active")
+	}
+	s.setAxes(2, []scrollAxis{{number: 3, increment: 1}})
+	if !s.active() {
+		t.Fatal("state with scroll axes should be active")
+	}
+	s.setAxes(2, nil)
+	if s.active() {
+		t.Error("clearing the axes should deactivate the state")
+	}
+}
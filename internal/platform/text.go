@@ -0,0 +1,30 @@
+package platform
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// inputText drops the control characters key lookups report for keys such as
+// Backspace, Enter, Tab or Escape and reports whether printable text is left.
+func inputText(text string) (string, bool) {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	return text, text != ""
+}
+
+// domInputText returns the text a DOM keydown types: KeyboardEvent.key when
+// it is a single printable character ("a", "É", not "Enter" or "Dead") and
+// no Ctrl or Meta shortcut is held. Ctrl+Alt is AltGr on some layouts and
+// still types.
+func domInputText(key string, ctrl, alt, meta bool) (string, bool) {
+	if meta || (ctrl && !alt) || utf8.RuneCountInString(key) != 1 {
+		return "", false
+	}
+	return inputText(key)
+}
@@ -0,0 +1,49 @@
+package platform
+
+import "testing"
+
+func TestInputText_DropsControlCharacters(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"a", "a", true},
+		{"żółw", "żółw", true},
+		{"\b", "", false},
+		{"\r", "", false},
+		{"\x1b", "", false},
+		{"\x7f", "", false},
+		{"a\tb", "ab", true},
+	} {
+		got, ok := inputText(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("inputText(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestDOMInputText(t *testing.T) {
+	for _, tc := range []struct {
+		key             string
+		ctrl, alt, meta bool
+		want            string
+		ok              bool
+	}{
+		{key: "a", want: "a", ok: true},
+		{key: "É", want: "É", ok: true},
+		{key: " ", want: " ", ok: true},
+		{key: "Enter"},
+		{key: "Dead"},
+		{key: "Shift"},
+		{key: "c", ctrl: true},
+		{key: "v", meta: true},
+		{key: "@", ctrl: true, alt: true, want: "@", ok: true},
+	} {
+		got, ok := domInputText(tc.key, tc.ctrl, tc.alt, tc.meta)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("domInputText(%q, ctrl=%v alt=%v meta=%v) = %q, %v; want %q, %v",
+				tc.key, tc.ctrl, tc.alt, tc.meta, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package renderer
+
+import "github.com/kjkrol/gokx/pkg/gfx"
+
+// blendFactor is a backend-neutral blendFunc factor; each GL backend maps it
+// to its own enum.
+type blendFactor uint8
+
+const (
+	factorZero blendFactor = iota
+	factorOne
+	factorSrcAlpha
+	factorOneMinusSrcAlpha
+	factorDstColor
+)
+
+// blendFactors returns the blendFunc source and destination factors of a
+// layer color blend mode. Unknown modes fall back to alpha blending. Modes
+// for which premultipliedSource reports true expect the shader to output
+// rgb*a.
+func blendFactors(mode gfx.BlendMode) (src, dst blendFactor) {
+	switch mode {
+	case gfx.BlendAdditive:
+		return factorSrcAlpha, factorOne
+	case gfx.BlendMultiply:
+		return factorDstColor, factorOneMinusSrcAlpha
+	case gfx.BlendReplace:
+		return factorOne, factorZero
+	default:
+		return factorSrcAlpha, factorOneMinusSrcAlpha
+	}
+}
+
+// premultipliedSource reports whether the color pass shader must multiply
+// its output rgb by alpha (uPremultiply) for mode. Multiply needs it: with
+// (DST_COLOR, ONE_MINUS_SRC_ALPHA) a premultiplied source yields
+// dst*(src*a + 1-a), while a straight one would brighten dst where a < 1.
+func premultipliedSource(mode gfx.BlendMode) bool {
+	return mode == gfx.BlendMultiply
+}
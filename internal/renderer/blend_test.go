@@ -0,0 +1,71 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/kjkrol/gokx/pkg/gfx"
+)
+
+func TestBlendFactors(t *testing.T) {
+	cases := []struct {
+		mode     gfx.BlendMode
+		src, dst blendFactor
+	}{
+		{gfx.BlendAlpha, factorSrcAlpha, factorOneMinusSrcAlpha},
+		{gfx.BlendAdditive, factorSrcAlpha, factorOne},
+		{gfx.BlendMultiply, factorDstColor, factorOneMinusSrcAlpha},
+		{gfx.BlendReplace, factorOne, factorZero},
+		{gfx.BlendMode(99), factorSrcAlpha, factorOneMinusSrcAlpha},
+	}
+	for _, tc := range cases {
+		src, dst := blendFactors(tc.mode)
+		if src != tc.src || dst != tc.dst {
+			t.Errorf("blendFactors(%v) = (%d,%d), want (%d,%d)", tc.mode, src, dst, tc.src, tc.dst)
+		}
+	}
+}
+
+// blendPixel applies the color pass blend of mode to one channel the way
+// glBlendFunc does, premultiplying the source first when the shader would.
+func blendPixel(mode gfx.BlendMode, src, alpha, dst float32) float32 {
+	if premultipliedSource(mode) {
+		src *= alpha
+	}
+	factor := func(f blendFactor) float32 {
+		switch f {
+		case factorZero:
+			return 0
+		case factorOne:
+			return 1
+		case factorSrcAlpha:
+			return alpha
+		case factorOneMinusSrcAlpha:
+			return 1 - alpha
+		case factorDstColor:
+			return dst
+		}
+		panic("unknown blend factor")
+	}
+	srcFactor, dstFactor := blendFactors(mode)
+	return src*factor(srcFactor) + dst*factor(dstFactor)
+}
+
+func TestBlendMultiplyHalfTransparentDarkens(t *testing.T) {
+	// A half-transparent gray multiply layer over gray must darken it:
+	// dst*(src*a + 1-a) = 0.5*(0.5*0.5 + 0.5) = 0.375. Straight alpha gave
+	// src*dst + dst*(1-a) = 0.5, no darkening at all.
+	if got := blendPixel(gfx.BlendMultiply, 0.5, 0.5, 0.5); got != 0.375 {
+		t.Errorf("multiply 0.5 at a=0.5 over 0.5 = %v, want 0.375", got)
+	}
+	// White never changes dst, whatever its alpha.
+	if got := blendPixel(gfx.BlendMultiply, 1, 0.5, 0.5); got != 0.5 {
+		t.Errorf("multiply white at a=0.5 over 0.5 = %v, want 0.5", got)
+	}
+	// Fully opaque it is a plain product.
+	if got := blendPixel(gfx.BlendMultiply, 0.5, 1, 0.5); got != 0.25 {
+		t.Errorf("multiply 0.5 at a=1 over 0.5 = %v, want 0.25", got)
+	}
+	if premultipliedSource(gfx.BlendAlpha) || premultipliedSource(gfx.BlendAdditive) {
+		t.Error("only multiply expects a premultiplied source")
+	}
+}
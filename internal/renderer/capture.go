@@ -0,0 +1,34 @@
+package renderer
+
+import (
+	"errors"
+	"image"
+)
+
+var errNoFrame = errors.New("renderer: no frame rendered yet")
+
+// newCaptureImage allocates the destination of a framebuffer read of rect.
+func newCaptureImage(rect image.Rectangle) *image.RGBA {
+	return image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+}
+
+// captureOriginY converts the top of a window rect to the bottom-left
+// framebuffer origin glReadPixels expects.
+func captureOriginY(rect image.Rectangle, framebufferHeight int) int {
+	return framebufferHeight - rect.Max.Y
+}
+
+// flipRows reverses img's rows in place: glReadPixels returns the bottom row
+// first.
+func flipRows(img *image.RGBA) {
+	height := img.Rect.Dy()
+	rowLen := img.Rect.Dx() * 4
+	tmp := make([]byte, rowLen)
+	for top, bottom := 0, height-1; top < bottom; top, bottom = top+1, bottom-1 {
+		a := img.Pix[top*img.Stride : top*img.Stride+rowLen]
+		b := img.Pix[bottom*img.Stride : bottom*img.Stride+rowLen]
+		copy(tmp, a)
+		copy(a, b)
+		copy(b, tmp)
+	}
+}
@@ -0,0 +1,26 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFlipRows_ReversesRowOrder(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	for y := range 3 {
+		img.SetRGBA(0, y, color.RGBA{R: uint8(y + 1), A: 255})
+	}
+	flipRows(img)
+	for y := range 3 {
+		if got := img.RGBAAt(0, y).R; got != uint8(3-y) {
+			t.Errorf("row %d = %d, want %d", y, got, 3-y)
+		}
+	}
+}
+
+func TestCaptureOriginY_FlipsToBottomLeft(t *testing.T) {
+	if got := captureOriginY(image.Rect(10, 20, 30, 50), 100); got != 50 {
+		t.Fatalf("captureOriginY = %d, want 50", got)
+	}
+}
@@ -0,0 +1,68 @@
+package renderer
+
+import (
+	"fmt"
+	"log"
+)
+
+// GL error and framebuffer status codes shared by GL 3.3 and WebGL2.
+const (
+	glNoError                     = 0
+	glInvalidEnum                 = 0x0500
+	glInvalidValue                = 0x0501
+	glInvalidOperation            = 0x0502
+	glOutOfMemory                 = 0x0505
+	glInvalidFramebufferOperation = 0x0506
+
+	glFramebufferComplete                    = 0x8CD5
+	glFramebufferIncompleteAttachment        = 0x8CD6
+	glFramebufferIncompleteMissingAttachment = 0x8CD7
+	glFramebufferIncompleteDimensions        = 0x8CD9
+	glFramebufferUnsupported                 = 0x8CDD
+	glFramebufferIncompleteMultisample       = 0x8D56
+)
+
+func glErrorName(code uint32) string {
+	switch code {
+	case glNoError:
+		return "GL_NO_ERROR"
+	case glInvalidEnum:
+		return "GL_INVALID_ENUM"
+	case glInvalidValue:
+		return "GL_INVALID_VALUE"
+	case glInvalidOperation:
+		return "GL_INVALID_OPERATION"
+	case glOutOfMemory:
+		return "GL_OUT_OF_MEMORY"
+	case glInvalidFramebufferOperation:
+		return "GL_INVALID_FRAMEBUFFER_OPERATION"
+	}
+	return fmt.Sprintf("GL error 0x%04X", code)
+}
+
+func framebufferStatusName(status uint32) string {
+	switch status {
+	case glFramebufferComplete:
+		return "GL_FRAMEBUFFER_COMPLETE"
+	case glFramebufferIncompleteAttachment:
+		return "GL_FRAMEBUFFER_INCOMPLETE_ATTACHMENT (zero-sized or invalid texture)"
+	case glFramebufferIncompleteMissingAttachment:
+		return "GL_FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT"
+	case glFramebufferIncompleteDimensions:
+		return "GL_FRAMEBUFFER_INCOMPLETE_DIMENSIONS"
+	case glFramebufferUnsupported:
+		return "GL_FRAMEBUFFER_UNSUPPORTED"
+	case glFramebufferIncompleteMultisample:
+		return "GL_FRAMEBUFFER_INCOMPLETE_MULTISAMPLE"
+	}
+	return fmt.Sprintf("framebuffer status 0x%04X", status)
+}
+
+// maxErrorsPerCheck bounds the error drain loop; a lost context keeps
+// returning errors forever.
+const maxErrorsPerCheck = 8
+
+// debugf reports a diagnostic of the RendererConfig.Debug mode.
+func debugf(format string, args ...any) {
+	log.Printf("renderer: "+format, args...)
+}
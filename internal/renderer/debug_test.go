@@ -0,0 +1,15 @@
+package renderer
+
+import "testing"
+
+func TestDebugNames(t *testing.T) {
+	if got := glErrorName(glInvalidOperation); got != "GL_INVALID_OPERATION" {
+		t.Errorf("glErrorName = %q", got)
+	}
+	if got := glErrorName(0x1234); got != "GL error 0x1234" {
+		t.Errorf("unknown glErrorName = %q", got)
+	}
+	if got := framebufferStatusName(glFramebufferIncompleteMissingAttachment); got != "GL_FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT" {
+		t.Errorf("framebufferStatusName = %q", got)
+	}
+}
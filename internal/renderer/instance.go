@@ -1,15 +1,92 @@
 package renderer
 
 import (
+	"fmt"
 	"image/color"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
 	"github.com/kjkrol/gokx/pkg/gfx"
 )
 
-const floatsPerInstance = 12
+// instanceAttribute describes one per-instance vertex attribute of the
+// PASS_COLOR vertex shader. The instance buffer stores the attributes back to
+// back in instanceLayout order.
+type instanceAttribute struct {
+	name     string
+	location uint32
+	size     int // float components, 1..4
+}
+
+// instanceLayout is the single definition of the instance buffer layout; the
+// VAO setup, the stride and the GLSL declarations are all derived from it.
+var instanceLayout = []instanceAttribute{
+	{name: "iRect", location: 1, size: 4},     // x0, y0, x1, y1
+	{name: "iFill", location: 2, size: 4},     // fill or gradient From
+	{name: "iStroke", location: 3, size: 4},   // stroke
+	{name: "iFillTo", location: 4, size: 4},   // gradient To
+	{name: "iGradient", location: 5, size: 4}, // dir, span start, span end, unused
+	{name: "iUV", location: 6, size: 4},       // sprite texture rect u0, v0, u1, v1; zero without a texture
+}
+
+// Attribute indexes into instanceLayout, in buffer order.
+const (
+	attrRect = iota
+	attrFill
+	attrStroke
+	attrFillTo
+	attrGradient
+	attrUV
+	attrCount
+)
+
+var floatsPerInstance = instanceFloats(instanceLayout)
+
+func instanceFloats(layout []instanceAttribute) int {
+	n := 0
+	for _, attr := range layout {
+		n += attr.size
+	}
+	return n
+}
+
+// forEachInstanceAttribute calls fn with each attribute and its offset in
+// floats from the start of an instance.
+func forEachInstanceAttribute(fn func(attr instanceAttribute, offset int)) {
+	offset := 0
+	for _, attr := range instanceLayout {
+		fn(attr, offset)
+		offset += attr.size
+	}
+}
 
-func appendAABBInstance(dst []float32, aabb geom.AABB[uint32], style gfx.SpatialStyle) []float32 {
+// instanceAttributeDecls returns the GLSL input declarations for
+// instanceLayout, joined on one line so they fit in a #define.
+func instanceAttributeDecls() string {
+	var sb strings.Builder
+	for i, attr := range instanceLayout {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		typ := "float"
+		if attr.size > 1 {
+			typ = "vec" + strconv.Itoa(attr.size)
+		}
+		fmt.Fprintf(&sb, "layout(location = %d) in %s %s;", attr.location, typ, attr.name)
+	}
+	return sb.String()
+}
+
+func appendAABBInstance(dst []float32, aabb geom.AABB[uint32], style gfx.SpatialStyle, span [2]float32) []float32 {
+	return appendScaledAABBInstance(dst, aabb, 0, style, span)
+}
+
+// appendScaledAABBInstance appends an instance for an AABB in fixed-point
+// units (world units << bits); the rect attribute is in world units.
+func appendScaledAABBInstance(dst []float32, aabb geom.AABB[uint32], bits uint8, style gfx.SpatialStyle, span [2]float32) []float32 {
 	minX := aabb.TopLeft.X
 	minY := aabb.TopLeft.Y
 	maxX := aabb.BottomRight.X
@@ -21,34 +98,177 @@ func appendAABBInstance(dst []float32, aabb geom.AABB[uint32], style gfx.Spatial
 		maxY = minY
 	}
 
-	x0 := float32(minX)
-	y0 := float32(minY)
-	x1 := float32(maxX)
-	y1 := float32(maxY)
+	scale := float32(uint64(1) << bits)
+	x0 := float32(minX) / scale
+	y0 := float32(minY) / scale
+	x1 := float32(maxX) / scale
+	y1 := float32(maxY) / scale
 	if x1 <= x0 || y1 <= y0 {
 		return dst
 	}
 
 	fill := colorToFloat(style.Fill)
+	fillTo := fill
+	dir := float32(gfx.GradientNone)
+	if gradient, ok := style.ActiveGradient(); ok {
+		fill = colorToFloat(gradient.From)
+		fillTo = colorToFloat(gradient.To)
+		dir = float32(gradient.Dir)
+	}
 	stroke := colorToFloat(style.Stroke)
-	dst = append(dst,
-		x0, y0, x1, y1,
-		fill[0], fill[1], fill[2], fill[3],
-		stroke[0], stroke[1], stroke[2], stroke[3],
-	)
+	var uv [4]float32
+	if rect, ok := style.TextureRect(); ok {
+		uv = [4]float32{rect.TopLeft.X, rect.TopLeft.Y, rect.BottomRight.X, rect.BottomRight.Y}
+	}
+
+	var values [attrCount][4]float32
+	values[attrRect] = [4]float32{x0, y0, x1, y1}
+	values[attrFill] = fill
+	values[attrStroke] = stroke
+	values[attrFillTo] = fillTo
+	values[attrGradient] = [4]float32{dir, span[0], span[1], 0}
+	values[attrUV] = uv
+	for i, attr := range instanceLayout {
+		dst = append(dst, values[i][:attr.size]...)
+	}
+	return dst
+}
+
+// appendBackgroundInstance appends the instance drawing a layer's background
+// image over rect: the texture region uv, without fill or stroke.
+func appendBackgroundInstance(dst []float32, rect geom.AABB[uint32], uv [4]float32) []float32 {
+	var values [attrCount][4]float32
+	values[attrRect] = [4]float32{float32(rect.TopLeft.X), float32(rect.TopLeft.Y), float32(rect.BottomRight.X), float32(rect.BottomRight.Y)}
+	values[attrUV] = uv
+	for i, attr := range instanceLayout {
+		dst = append(dst, values[i][:attr.size]...)
+	}
 	return dst
 }
 
+// appendEntryInstance appends the instance of a fragment, at its sub-pixel
+// placement when the source tracks one. The gradient span and the sprite UV
+// are measured on the whole-unit fragment.
+func appendEntryInstance(dst []float32, source gfx.FrameSource, layer *gfx.Layer, entryID uint64, frag geom.AABB[uint32], drawable *gfx.Drawable) []float32 {
+	style := fragmentStyle(&drawable.AABB, frag, drawable.EffectiveStyle())
+	span := gradientSpan(&drawable.AABB, frag, style)
+	if sub, ok := source.(gfx.SubpixelFrameSource); ok {
+		if aabb, bits, ok := sub.EntrySubpixelAABB(layer, entryID); ok {
+			return appendScaledAABBInstance(dst, aabb, bits, style, span)
+		}
+	}
+	return appendAABBInstance(dst, frag, style, span)
+}
+
+// visitBucketInstances reports the instances of one bucket's cache, entries
+// and their data in the same order, skipping entries already in seen: an
+// entry spanning several buckets is cached in each. The rect is the iRect
+// attribute (the first of the layout) rounded out to whole world units.
+func visitBucketInstances(layer *gfx.Layer, entries []uint64, data []float32, seen map[uint64]struct{}, fn gfx.InstanceFunc) {
+	for idx, entryID := range entries {
+		start := idx * floatsPerInstance
+		if start+floatsPerInstance > len(data) {
+			return
+		}
+		if _, ok := seen[entryID]; ok {
+			continue
+		}
+		seen[entryID] = struct{}{}
+		drawable := layer.DrawableByEntryID(entryID)
+		if drawable == nil {
+			continue
+		}
+		rect := data[start : start+4]
+		aabb := geom.NewAABB(
+			geom.NewVec(int(math.Floor(float64(rect[0]))), int(math.Floor(float64(rect[1])))),
+			geom.NewVec(int(math.Ceil(float64(rect[2]))), int(math.Ceil(float64(rect[3])))),
+		)
+		fn(entryID>>2, aabb, drawable.EffectiveStyle())
+	}
+}
+
+// gradientSpan returns the [start, end] range (0..1) that frag covers along
+// the gradient axis of the whole drawable, so gradients stay continuous when
+// a drawable is split across the world seam.
+func gradientSpan(shape *plane.AABB[uint32], frag geom.AABB[uint32], style gfx.SpatialStyle) [2]float32 {
+	gradient, ok := style.ActiveGradient()
+	if !ok || shape == nil {
+		return [2]float32{0, 1}
+	}
+	if gradient.Dir == gfx.GradientVertical {
+		return fragmentSpan(shape, frag, vecY)
+	}
+	return fragmentSpan(shape, frag, vecX)
+}
+
+// fragmentStyle narrows the UV of a sprite style to the part of the texture
+// frag covers, so a sprite split across the world seam shows each part of
+// the image once. Other styles are returned unchanged.
+func fragmentStyle(shape *plane.AABB[uint32], frag geom.AABB[uint32], style gfx.SpatialStyle) gfx.SpatialStyle {
+	uv, ok := style.TextureRect()
+	if !ok || shape == nil {
+		return style
+	}
+	spanX := fragmentSpan(shape, frag, vecX)
+	spanY := fragmentSpan(shape, frag, vecY)
+	lerp := func(a, b, t float32) float32 { return a + (b-a)*t }
+	style.UV = geom.NewAABB(
+		geom.NewVec(lerp(uv.TopLeft.X, uv.BottomRight.X, spanX[0]), lerp(uv.TopLeft.Y, uv.BottomRight.Y, spanY[0])),
+		geom.NewVec(lerp(uv.TopLeft.X, uv.BottomRight.X, spanX[1]), lerp(uv.TopLeft.Y, uv.BottomRight.Y, spanY[1])),
+	)
+	return style
+}
+
+func vecX(v geom.Vec[uint32]) uint32 { return v.X }
+func vecY(v geom.Vec[uint32]) uint32 { return v.Y }
+
+// fragmentSpan returns the [start, end] range (0..1) that frag covers along
+// axis of the whole (unwrapped) shape.
+func fragmentSpan(shape *plane.AABB[uint32], frag geom.AABB[uint32], axis func(geom.Vec[uint32]) uint32) [2]float32 {
+	base := shape.AABB
+	baseMin := axis(base.TopLeft)
+	baseLen := axis(base.BottomRight) - baseMin
+	// Fragments starting before the base wrapped around the seam.
+	var wrappedLen uint32
+	shape.VisitFragments(func(_ plane.FragPosition, f geom.AABB[uint32]) bool {
+		if axis(f.TopLeft) < baseMin {
+			wrappedLen = max(wrappedLen, axis(f.BottomRight)-axis(f.TopLeft))
+		}
+		return true
+	})
+	total := baseLen + wrappedLen
+	if total == 0 {
+		return [2]float32{0, 1}
+	}
+	fragMin := axis(frag.TopLeft)
+	fragLen := axis(frag.BottomRight) - fragMin
+	offset := fragMin - baseMin
+	if fragMin < baseMin {
+		offset = baseLen + fragMin
+	}
+	return [2]float32{
+		float32(offset) / float32(total),
+		float32(offset+fragLen) / float32(total),
+	}
+}
+
+// colorToFloat converts c to straight (non-premultiplied) RGBA in [0,1].
+// color.Color.RGBA returns alpha-premultiplied channels, while the renderer
+// blends with SRC_ALPHA, ONE_MINUS_SRC_ALPHA, so the channels are divided by
+// alpha before upload.
 func colorToFloat(c color.Color) [4]float32 {
 	if c == nil {
 		return [4]float32{}
 	}
 	r, g, b, a := c.RGBA()
-	const inv = 1.0 / 65535.0
+	if a == 0 {
+		return [4]float32{}
+	}
+	alpha := float32(a)
 	return [4]float32{
-		float32(r) * inv,
-		float32(g) * inv,
-		float32(b) * inv,
-		float32(a) * inv,
+		float32(r) / alpha,
+		float32(g) / alpha,
+		float32(b) / alpha,
+		alpha / 65535.0,
 	}
 }
@@ -0,0 +1,172 @@
+package renderer
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokx/pkg/gfx"
+)
+
+// blendStraight mirrors glBlendFunc(SRC_ALPHA, ONE_MINUS_SRC_ALPHA).
+func blendStraight(src, dst [4]float32) [3]float32 {
+	var out [3]float32
+	for i := range out {
+		out[i] = src[i]*src[3] + dst[i]*(1-src[3])
+	}
+	return out
+}
+
+func TestColorToFloat_HalfGreenOverRed(t *testing.T) {
+	green := colorToFloat(color.RGBA{G: 128, A: 128})
+	red := colorToFloat(color.RGBA{R: 255, A: 255})
+
+	if green[1] != 1 {
+		t.Fatalf("premultiplied green not restored to straight alpha: %v", green)
+	}
+
+	got := blendStraight(green, red)
+	want := [3]float32{0.498, 0.502, 0}
+	for i := range got {
+		if math.Abs(float64(got[i]-want[i])) > 0.005 {
+			t.Fatalf("blended pixel = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestColorToFloat_TransparentIsZero(t *testing.T) {
+	if got := colorToFloat(color.RGBA{}); got != [4]float32{} {
+		t.Fatalf("transparent color = %v, want zero", got)
+	}
+}
+
+func TestColorToFloat_AcceptsAnyColorModel(t *testing.T) {
+	for _, c := range []color.Color{
+		color.NRGBA{G: 255, A: 128},
+		color.RGBA{G: 128, A: 128},
+		color.NRGBA64{G: 0xffff, A: 0x8080},
+	} {
+		got := colorToFloat(c)
+		if got[0] != 0 || got[1] != 1 || got[2] != 0 || math.Abs(float64(got[3])-128.0/255) > 0.001 {
+			t.Errorf("colorToFloat(%#v) = %v, want straight half-alpha green", c, got)
+		}
+	}
+	if got := colorToFloat(color.Gray{Y: 51}); math.Abs(float64(got[0])-0.2) > 0.001 || got[0] != got[2] || got[3] != 1 {
+		t.Errorf("colorToFloat(Gray) = %v, want opaque 0.2 gray", got)
+	}
+}
+
+func TestInstanceLayoutMatchesAppendedData(t *testing.T) {
+	aabb := geom.NewAABB(geom.NewVec[uint32](1, 2), geom.NewVec[uint32](5, 7))
+	style := gfx.SpatialStyle{Fill: color.RGBA{R: 255, A: 255}, Stroke: color.RGBA{G: 255, A: 255}}
+	data := appendAABBInstance(nil, aabb, style, [2]float32{0, 1})
+	if len(data) != floatsPerInstance {
+		t.Fatalf("expected %d floats, got %d", floatsPerInstance, len(data))
+	}
+
+	offsets := map[string]int{}
+	forEachInstanceAttribute(func(attr instanceAttribute, offset int) {
+		offsets[attr.name] = offset
+	})
+	rect := data[offsets["iRect"] : offsets["iRect"]+4]
+	if rect[0] != 1 || rect[1] != 2 || rect[2] != 5 || rect[3] != 7 {
+		t.Fatalf("unexpected iRect %v", rect)
+	}
+	if data[offsets["iFill"]] != 1 || data[offsets["iStroke"]+1] != 1 {
+		t.Fatalf("fill/stroke not at their attribute offsets: %v", data)
+	}
+	if data[offsets["iGradient"]+2] != 1 {
+		t.Fatalf("expected span end in iGradient, got %v", data[offsets["iGradient"]:])
+	}
+}
+
+func TestFragmentStyle_NarrowsSpriteUVToFragment(t *testing.T) {
+	space := plane.NewToroidal2D[uint32](64, 64)
+	shape := space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](60, 10), 8, 4))
+	style := gfx.SpatialStyle{
+		Texture: 1,
+		UV:      geom.NewAABB(geom.NewVec[float32](0, 0), geom.NewVec[float32](0.5, 1)),
+	}
+	var frags []geom.AABB[uint32]
+	shape.VisitFragments(func(_ plane.FragPosition, frag geom.AABB[uint32]) bool {
+		frags = append(frags, frag)
+		return true
+	})
+	if len(frags) != 1 {
+		t.Fatalf("fragments = %v, want one wrapped fragment", frags)
+	}
+
+	offset := 0
+	forEachInstanceAttribute(func(attr instanceAttribute, off int) {
+		if attr.name == "iUV" {
+			offset = off
+		}
+	})
+	for frag, want := range map[geom.AABB[uint32]][4]float32{
+		shape.AABB: {0, 0, 0.25, 1},
+		frags[0]:   {0.25, 0, 0.5, 1},
+	} {
+		data := appendAABBInstance(nil, frag, fragmentStyle(&shape, frag, style), [2]float32{0, 1})
+		if got := [4]float32(data[offset : offset+4]); got != want {
+			t.Errorf("iUV of %v = %v, want %v", frag, got, want)
+		}
+	}
+	plain := appendAABBInstance(nil, shape.AABB, fragmentStyle(&shape, shape.AABB, gfx.SpatialStyle{Fill: color.White}), [2]float32{0, 1})
+	if got := [4]float32(plain[offset : offset+4]); got != [4]float32{} {
+		t.Errorf("iUV without a texture = %v, want zero", got)
+	}
+}
+
+func TestInstanceAttributeDecls(t *testing.T) {
+	decls := instanceAttributeDecls()
+	if strings.Contains(decls, "\n") {
+		t.Fatalf("declarations must fit on one #define line: %q", decls)
+	}
+	for _, want := range []string{
+		"layout(location = 1) in vec4 iRect;",
+		"layout(location = 5) in vec4 iGradient;",
+	} {
+		if !strings.Contains(decls, want) {
+			t.Fatalf("missing %q in %q", want, decls)
+		}
+	}
+}
+
+func TestScaledAABBInstanceDividesBySubpixelScale(t *testing.T) {
+	aabb := geom.NewAABB(geom.NewVec[uint32](2688, 512), geom.NewVec[uint32](3712, 1600))
+	data := appendScaledAABBInstance(nil, aabb, 8, gfx.SpatialStyle{}, [2]float32{0, 1})
+	if len(data) != floatsPerInstance {
+		t.Fatalf("expected %d floats, got %d", floatsPerInstance, len(data))
+	}
+	if rect := data[:4]; rect[0] != 10.5 || rect[1] != 2 || rect[2] != 14.5 || rect[3] != 6.25 {
+		t.Fatalf("unexpected iRect %v", rect)
+	}
+}
+
+func TestVisitBucketInstances_ReportsEachEntryOnce(t *testing.T) {
+	layer := gfx.NewLayer(nil)
+	drawable := &gfx.Drawable{}
+	layer.AddDrawable(drawable)
+	id, _ := layer.DrawableID(drawable)
+	entryID := id << 2
+
+	data := appendScaledAABBInstance(nil, geom.NewAABB(geom.NewVec[uint32](9, 10), geom.NewVec[uint32](17, 18)), 1, gfx.SpatialStyle{}, [2]float32{0, 1})
+	seen := make(map[uint64]struct{})
+	var got []geom.AABB[int]
+	collect := func(gotID uint64, aabb geom.AABB[int], _ gfx.SpatialStyle) {
+		if gotID != id {
+			t.Fatalf("id = %d, want %d", gotID, id)
+		}
+		got = append(got, aabb)
+	}
+	visitBucketInstances(layer, []uint64{entryID}, data, seen, collect)
+	visitBucketInstances(layer, []uint64{entryID}, data, seen, collect)
+
+	want := geom.NewAABB(geom.NewVec(4, 5), geom.NewVec(9, 9))
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("instances = %v, want [%v]", got, want)
+	}
+}
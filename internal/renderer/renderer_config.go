@@ -1,10 +1,114 @@
 package renderer
 
 // RendererConfig describes GPU shader inputs provided by the caller.
-// ShaderSource must be a single-source shader that supports:
+// ShaderSource must be a single-source shader without a #version directive:
+// the renderer prepends the one matching the window's GL context
+// (gfx.WindowConfig.GL), "#version 330 core" by default and "#version 300 es"
+// plus highp precisions on OpenGL ES and WebGL2. A matching directive is
+// dropped; a mismatching one fails initialization. The desktop renderer uses
+// the OpenGL 3.3 core binding, so an OpenGL ES context must expose those entry
+// points for gl.Init to succeed; a missing one panics with its name.
+// It must support:
 // - stage defines: VERTEX, FRAGMENT
 // - pass defines: PASS_COLOR, PASS_COMPOSITE
-// - uniforms: PASS_COLOR expects uViewport, uOrigin, uWorld; PASS_COMPOSITE expects uViewport, uRect, uTexRect, uTex
+// - uniforms: PASS_COLOR expects uViewport, uOrigin, uWorld, uWrap and optionally uSprite; PASS_COMPOSITE expects uViewport, uRect, uTexRect, uTex
+//
+// PASS_COLOR instance attributes: iRect (location 1), iFill (2), iStroke (3),
+// iFillTo (4), iGradient (5: direction 0 none / 1 horizontal / 2 vertical,
+// then the span start and end within the drawable) and iUV (6). With a
+// gradient, fill is mix(iFill, iFillTo, mix(span.start, span.end, local
+// coordinate)).
+//
+// Sprites (gfx.Sprite) carry their texture rect in iUV as u0, v0, u1, v1, with
+// v = 0 at the top of the image; iUV is all zero for other instances. Their
+// fill is texture(uSprite, mix(iUV.xy, iUV.zw, local coordinate)); uSprite
+// (sampler2D) is bound to the texture of the layer's sprites. A shader
+// without uSprite draws sprites with their Fill. A layer's background image
+// (gfx.Layer.SetBackgroundImage) is drawn the same way: one transparent
+// instance per redrawn bucket, with uSprite bound to the image; tiled images
+// have iUV beyond 1 and rely on REPEAT sampling.
+//
+// PASS_COLOR should also declare uPremultiply (bool) and, when it is true,
+// output rgb*a: layers with gfx.BlendMultiply blend with factors that expect
+// a premultiplied source. A shader without it darkens too little where alpha
+// is below 1.
+//
+// PASS_COMPOSITE may also declare uTint (vec4) and uTintStrength (float): when
+// drawing panes to the window they carry Pane.SetTint, and the shader should
+// apply mix(color.rgb, uTint.rgb, uTintStrength). Both are optional.
+// Every stage is compiled with INSTANCE_ATTRIBUTES defined to the matching
+// layout(location = N) declarations, so PASS_COLOR vertex shaders should use
+// that macro rather than spelling the locations out.
+//
+// In PASS_COLOR, uViewport is the size of the layer cache in world units and
+// uOrigin its top-left world position; the target texture is that size times
+// the pane's RenderScale, so mapping (pos - uOrigin) / uViewport to clip
+// space applies the logical-to-pixel scale without further shader work.
+//
+// uWrap (bool) is true only when the pane wraps and the view is smaller than
+// the world; shaders must guard toroidal unwrapping with it rather than infer
+// wrapping from uWorld.
+//
+// PostPasses are optional full-screen passes run in order after all panes are
+// composited and before the result reaches the default framebuffer.
 type RendererConfig struct {
 	ShaderSource string
+	PostPasses   []PostPass
+	// Debug logs GL errors after initialization, shader builds, texture
+	// (re)allocation, instance uploads and every frame, and reports
+	// incomplete or zero-sized framebuffers instead of skipping them.
+	Debug bool
+	// InstanceShrink reallocates the instance buffers of buckets that keep
+	// using a small part of their capacity. The zero value disables it:
+	// buffers only grow.
+	InstanceShrink InstanceShrinkPolicy
+}
+
+// InstanceShrinkPolicy lets a bucket's instance buffer shrink after a spike,
+// e.g. a hotspot that moved on, instead of keeping its peak size. When the
+// bytes a bucket needs stay below Fraction of its capacity for Frames
+// consecutive frames of its layer, the buffer is reallocated at twice the
+// needed size. Fraction must be in (0, 0.5] and Frames positive, otherwise
+// the policy is off.
+type InstanceShrinkPolicy struct {
+	Fraction float64
+	Frames   int
+}
+
+func (p InstanceShrinkPolicy) enabled() bool {
+	return p.Fraction > 0 && p.Fraction <= 0.5 && p.Frames > 0
+}
+
+// shrinkCap counts one frame in which a bucket of capacity bytes needs
+// required bytes, using lowFrames as the bucket's counter of consecutive low
+// frames. It returns the capacity to reallocate to, or 0 to keep the buffer.
+func (p InstanceShrinkPolicy) shrinkCap(required, capacity int, lowFrames *int) int {
+	if !p.enabled() || float64(required) >= float64(capacity)*p.Fraction {
+		*lowFrames = 0
+		return 0
+	}
+	*lowFrames++
+	if *lowFrames < p.Frames {
+		return 0
+	}
+	*lowFrames = 0
+	newCap := max(2*required, floatsPerInstance*4)
+	if newCap >= capacity {
+		return 0
+	}
+	return newCap
+}
+
+// PostPass is an extra full-screen pass compiled from ShaderSource with the
+// stage define, PASS_POST and every entry of Defines.
+// The pass draws the unit quad (aPos at location 0, covering the whole window)
+// and receives:
+// - uViewport (vec2): window size in pixels
+// - uTex (sampler2D): output of the previous pass (or of the pane composite)
+// Sample the input with texture(uTex, gl_FragCoord.xy / uViewport).
+// Passes ping-pong between two window-sized textures; the last one writes to
+// the default framebuffer.
+type PostPass struct {
+	Name    string
+	Defines []string
 }
@@ -0,0 +1,37 @@
+package renderer
+
+import "testing"
+
+func TestInstanceShrinkPolicy_ShrinksAfterLowFrames(t *testing.T) {
+	instance := floatsPerInstance * 4
+	policy := InstanceShrinkPolicy{Fraction: 0.25, Frames: 3}
+	capacity := 1000 * instance
+	low := 0
+
+	for frame := 1; frame < 3; frame++ {
+		if got := policy.shrinkCap(10*instance, capacity, &low); got != 0 {
+			t.Fatalf("frame %d: shrank to %d before Frames low frames", frame, got)
+		}
+	}
+	if got := policy.shrinkCap(400*instance, capacity, &low); got != 0 || low != 0 {
+		t.Fatalf("a busy frame should reset the counter, got cap %d low %d", got, low)
+	}
+	for frame := 1; frame < 3; frame++ {
+		policy.shrinkCap(10*instance, capacity, &low)
+	}
+	if got := policy.shrinkCap(10*instance, capacity, &low); got != 20*instance {
+		t.Fatalf("shrunk capacity = %d, want %d", got, 20*instance)
+	}
+	for range 3 {
+		if got := policy.shrinkCap(0, instance, &low); got != 0 {
+			t.Fatalf("a one-instance buffer should not shrink, got %d", got)
+		}
+	}
+
+	off := InstanceShrinkPolicy{}
+	for range 10 {
+		if got := off.shrinkCap(0, capacity, &low); got != 0 {
+			t.Fatalf("zero policy shrank to %d", got)
+		}
+	}
+}
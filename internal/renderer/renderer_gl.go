@@ -1,9 +1,10 @@
-//go:build !js
+//go:build !js && cgo
 
 package renderer
 
 import (
 	"fmt"
+	"image"
 	"strings"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
@@ -13,20 +14,49 @@ import (
 
 type renderer struct {
 	shaderSource string
+	glConfig     gfx.GLContextConfig
+	postConfigs  []PostPass
+	debug        bool
+	shrink       InstanceShrinkPolicy
 	initialized  bool
+	// initErr is the error of a failed initialization; later calls return
+	// it rather than retrying.
+	initErr error
 
 	colorProgram     uint32
 	compositeProgram uint32
 	quadVbo          uint32
 	compositeVao     uint32
+	// background draws the single instance of a layer's background image
+	// over one bucket.
+	background *bucketState
 
 	colorViewportUniform     int32
 	colorOriginUniform       int32
 	colorWorldUniform        int32
+	colorWrapUniform         int32
+	colorSpriteUniform       int32
+	colorPremultUniform      int32
 	compositeViewportUniform int32
 	compositeRectUniform     int32
 	compositeTexUniform      int32
 	compositeTexRectUniform  int32
+	compositeTintUniform     int32
+	compositeStrengthUniform int32
+
+	postPasses  []postPassState
+	postTargets [2]*paneState
+	// textureTarget is the RenderToTexture texture; output points at it
+	// while such a frame is drawn and is nil for window frames.
+	textureTarget *paneState
+	output        *paneState
+
+	// caps caches Capabilities once queried.
+	caps *gfx.Caps
+
+	// sprites holds the textures loaded with LoadTexture.
+	sprites    map[gfx.TextureID]uint32
+	lastSprite gfx.TextureID
 
 	layerStates map[*gfx.Layer]*layerState
 	paneViews   map[*gfx.Pane]uint64
@@ -40,6 +70,15 @@ type layerState struct {
 	width   int
 	height  int
 	buckets map[geom.AABB[uint32]]*bucketState
+	// styleVersion is the Layer.StyleVersion the instances were built at.
+	styleVersion uint64
+	// sprite is the texture of the layer's sprites, bound for its color pass.
+	sprite gfx.TextureID
+	// background is the texture of Layer.BackgroundImage, uploaded at
+	// backgroundVersion; zero without an image.
+	background        uint32
+	backgroundSize    image.Point
+	backgroundVersion uint64
 }
 
 type bucketState struct {
@@ -49,6 +88,8 @@ type bucketState struct {
 	entries     []uint64
 	index       map[uint64]int
 	data        []float32
+	// lowFrames counts consecutive frames below the InstanceShrink fraction.
+	lowFrames int
 }
 
 type paneState struct {
@@ -58,9 +99,25 @@ type paneState struct {
 	height  int
 }
 
-func newRenderer(_ *gfx.Window, conf RendererConfig, source gfx.FrameSource) *renderer {
+type postPassState struct {
+	name            string
+	program         uint32
+	viewportUniform int32
+	texUniform      int32
+}
+
+func newRenderer(w *gfx.Window, conf RendererConfig, source gfx.FrameSource) *renderer {
+	var glConfig gfx.GLContextConfig
+	if w != nil {
+		glConfig = w.GLConfig()
+	}
 	return &renderer{
 		shaderSource: conf.ShaderSource,
+		glConfig:     glConfig,
+		postConfigs:  conf.PostPasses,
+		debug:        conf.Debug,
+		shrink:       conf.InstanceShrink,
+		sprites:      make(map[gfx.TextureID]uint32),
 		layerStates:  make(map[*gfx.Layer]*layerState),
 		paneViews:    make(map[*gfx.Pane]uint64),
 		paneStates:   make(map[*gfx.Pane]*paneState),
@@ -68,6 +125,131 @@ func newRenderer(_ *gfx.Window, conf RendererConfig, source gfx.FrameSource) *re
 	}
 }
 
+var _ gfx.RectCapturer = (*renderer)(nil)
+
+// CaptureRect recomposites the current frame into the back buffer and reads
+// rect back. It must run on the GL thread; the back buffer is redrawn by the
+// next frame before it is presented.
+func (r *renderer) CaptureRect(w *gfx.Window, rect image.Rectangle) (*image.RGBA, error) {
+	if err := r.ensureInit(); err != nil {
+		return nil, err
+	}
+	r.Render(w)
+	if !r.initialized {
+		return nil, errNoFrame
+	}
+	_, height := w.Size()
+	img := newCaptureImage(rect)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(
+		int32(rect.Min.X), int32(captureOriginY(rect, height)),
+		int32(rect.Dx()), int32(rect.Dy()),
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix),
+	)
+	flipRows(img)
+	return img, nil
+}
+
+var _ gfx.TextureRenderer = (*renderer)(nil)
+
+// RenderToTexture draws a frame into the renderer's offscreen texture instead
+// of the default framebuffer. It must run on the GL thread.
+func (r *renderer) RenderToTexture(w *gfx.Window, width, height int) (gfx.Texture, error) {
+	if err := r.ensureInit(); err != nil {
+		return gfx.Texture{}, err
+	}
+	if limit := r.maxTextureSize(); limit > 0 && (width > limit || height > limit) {
+		return gfx.Texture{}, gfx.ErrTextureSize
+	}
+	if r.textureTarget == nil {
+		state := &paneState{}
+		gl.GenTextures(1, &state.texture)
+		gl.GenFramebuffers(1, &state.fbo)
+		r.textureTarget = state
+	}
+	state := r.textureTarget
+	if state.width != width || state.height != height {
+		state.width = width
+		state.height = height
+		r.resizePaneTexture(state)
+	}
+	r.output = state
+	r.Render(w)
+	r.output = nil
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	return gfx.Texture{Handle: state.texture, Width: width, Height: height}, nil
+}
+
+var _ gfx.TextureLoader = (*renderer)(nil)
+
+// LoadTexture uploads img to a texture sampled by sprite instances. It must
+// run on the GL thread.
+func (r *renderer) LoadTexture(_ *gfx.Window, img image.Image) (gfx.TextureID, error) {
+	if img.Bounds().Empty() {
+		return 0, gfx.ErrTextureSize
+	}
+	if err := r.ensureInit(); err != nil {
+		return 0, err
+	}
+	pix := fitImage(textureImage(img), r.maxTextureSize())
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(pix.Rect.Dx()), int32(pix.Rect.Dy()), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pix.Pix))
+	r.checkGL("load texture")
+	r.lastSprite++
+	r.sprites[r.lastSprite] = texture
+	return r.lastSprite, nil
+}
+
+var _ gfx.CapsReporter = (*renderer)(nil)
+
+// Capabilities queries the GL context once and reports the same caps after.
+// It must run on the GL thread.
+func (r *renderer) Capabilities(*gfx.Window) gfx.Caps {
+	if r.caps != nil {
+		return *r.caps
+	}
+	caps := gfx.Caps{Kind: gfx.RendererGL}
+	if err := r.ensureInit(); err != nil {
+		return caps
+	}
+	var maxSize, units, samples int32
+	gl.GetIntegerv(gl.MAX_TEXTURE_SIZE, &maxSize)
+	gl.GetIntegerv(gl.MAX_TEXTURE_IMAGE_UNITS, &units)
+	gl.GetIntegerv(gl.MAX_SAMPLES, &samples)
+	caps.MaxTextureSize = int(maxSize)
+	caps.MaxTextureUnits = int(units)
+	caps.MaxSamples = int(samples)
+	// Both are core in the GL 3.3 profile the renderer requires.
+	caps.Instancing = true
+	caps.Framebuffers = true
+	caps.Vendor = gl.GoStr(gl.GetString(gl.VENDOR))
+	caps.Renderer = gl.GoStr(gl.GetString(gl.RENDERER))
+	caps.Version = gl.GoStr(gl.GetString(gl.VERSION))
+	r.caps = &caps
+	return caps
+}
+
+// maxTextureSize returns GL_MAX_TEXTURE_SIZE, or 0 when unknown.
+func (r *renderer) maxTextureSize() int {
+	return r.Capabilities(nil).MaxTextureSize
+}
+
+// outputTarget returns the framebuffer receiving the finished frame and its
+// size: the window, or the RenderToTexture texture.
+func (r *renderer) outputTarget(width, height int) (uint32, int, int) {
+	if r.output != nil {
+		return r.output.fbo, r.output.width, r.output.height
+	}
+	return 0, width, height
+}
+
 func (r *renderer) Render(w *gfx.Window) {
 	if w == nil || r.source == nil {
 		return
@@ -76,13 +258,16 @@ func (r *renderer) Render(w *gfx.Window) {
 	if defaultPane == nil || defaultPane.Config == nil {
 		return
 	}
-	r.ensureInit()
+	if err := r.ensureInit(); err != nil {
+		panic(err)
+	}
 
 	width, height := w.Size()
 	if width <= 0 || height <= 0 {
 		return
 	}
 
+	r.releaseRemovedLayers()
 	panes := w.Panes()
 	for _, pane := range panes {
 		if pane == nil || pane.Config == nil {
@@ -92,7 +277,7 @@ func (r *renderer) Render(w *gfx.Window) {
 		if view == nil {
 			continue
 		}
-		layers := pane.Layers()
+		layers := pane.CompositeLayers()
 		viewVersion := view.Version()
 		prevVersion, ok := r.paneViews[pane]
 		viewChanged := !ok || prevVersion != viewVersion
@@ -107,17 +292,13 @@ func (r *renderer) Render(w *gfx.Window) {
 			}
 			layerPlans[layerPlan.Layer] = layerPlan
 		}
-		worldSize := view.WorldSize()
-		viewSize := view.Size()
-		if !view.Wrap() || viewSize.X >= worldSize.X || viewSize.Y >= worldSize.Y {
-			worldSize = geom.NewVec[uint32](0, 0)
-		}
+		worldSize, wrap := viewWrap(view)
 		for _, layer := range layers {
 			plan, ok := layerPlans[layer]
 			if !ok {
 				continue
 			}
-			r.renderLayerBuckets(layer, plan, worldSize)
+			r.renderLayerBuckets(layer, plan, worldSize, wrap)
 		}
 		if len(frame.CompositeRects) == 0 {
 			continue
@@ -125,8 +306,9 @@ func (r *renderer) Render(w *gfx.Window) {
 		r.compositePane(pane, layers, layerPlans, frame, worldSize)
 	}
 
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.Viewport(0, 0, int32(width), int32(height))
+	fbo, viewWidth, viewHeight := r.finalFramebuffer(width, height)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	gl.Viewport(0, 0, int32(viewWidth), int32(viewHeight))
 	gl.ClearColor(0, 0, 0, 1)
 	gl.Clear(gl.COLOR_BUFFER_BIT)
 
@@ -144,22 +326,100 @@ func (r *renderer) Render(w *gfx.Window) {
 		if pane == nil || pane.Config == nil {
 			continue
 		}
-		x0 := float32(pane.Config.OffsetX)
-		y0 := float32(pane.Config.OffsetY)
-		x1 := float32(pane.Config.OffsetX + pane.Config.Width)
-		y1 := float32(pane.Config.OffsetY + pane.Config.Height)
+		// The pane texture is nearest-sampled, so an internal size set
+		// on the pane is upscaled by the integer factor of PresentRect.
+		content := pane.ContentRect()
+		if bars, ok := paneLetterbox(pane, content); ok {
+			r.clearLetterbox(bars, width, height, viewWidth, viewHeight)
+		}
+		x0, y0 := float32(content.Min.X), float32(content.Min.Y)
+		x1, y1 := float32(content.Max.X), float32(content.Max.Y)
 		gl.Uniform4f(r.compositeRectUniform, x0, y0, x1, y1)
 		gl.Uniform4f(r.compositeTexRectUniform, 0, 0, 1, 1)
+		tint, strength := paneTint(pane)
+		gl.Uniform4f(r.compositeTintUniform, tint[0], tint[1], tint[2], tint[3])
+		gl.Uniform1f(r.compositeStrengthUniform, strength)
 		gl.BindTexture(gl.TEXTURE_2D, state.texture)
 		gl.DrawArrays(gl.TRIANGLES, 0, 6)
 	}
+
+	r.runPostPasses(width, height)
+	r.checkGL("frame")
+}
+
+// clearLetterbox clears the letterbox of a pane in the final target, whose
+// viewWidth x viewHeight pixels map the width x height window.
+func (r *renderer) clearLetterbox(bars letterbox, width, height, viewWidth, viewHeight int) {
+	scissor := windowScissor(bars.rect, width, height, viewWidth, viewHeight)
+	if scissor.W <= 0 || scissor.H <= 0 {
+		return
+	}
+	c := colorToFloat(bars.color)
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(int32(scissor.X), int32(scissor.Y), int32(scissor.W), int32(scissor.H))
+	gl.ClearColor(c[0], c[1], c[2], c[3])
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.Disable(gl.SCISSOR_TEST)
+}
+
+// finalFramebuffer returns the target of the pane composite and its size: the
+// output target, or the first ping-pong texture when post passes are
+// configured.
+func (r *renderer) finalFramebuffer(width, height int) (uint32, int, int) {
+	if len(r.postPasses) == 0 {
+		return r.outputTarget(width, height)
+	}
+	for i := range r.postTargets {
+		if r.postTargets[i] == nil {
+			state := &paneState{}
+			gl.GenTextures(1, &state.texture)
+			gl.GenFramebuffers(1, &state.fbo)
+			r.postTargets[i] = state
+		}
+		state := r.postTargets[i]
+		if state.width != width || state.height != height {
+			state.width = width
+			state.height = height
+			r.resizePaneTexture(state)
+		}
+	}
+	return r.postTargets[0].fbo, width, height
+}
+
+func (r *renderer) runPostPasses(width, height int) {
+	if len(r.postPasses) == 0 {
+		return
+	}
+	gl.Disable(gl.BLEND)
+	gl.BindVertexArray(r.compositeVao)
+	gl.ActiveTexture(gl.TEXTURE0)
+	src := 0
+	for i, pass := range r.postPasses {
+		dst, viewWidth, viewHeight := r.outputTarget(width, height)
+		if i < len(r.postPasses)-1 {
+			dst, viewWidth, viewHeight = r.postTargets[1-src].fbo, width, height
+		}
+		gl.BindFramebuffer(gl.FRAMEBUFFER, dst)
+		gl.Viewport(0, 0, int32(viewWidth), int32(viewHeight))
+		gl.UseProgram(pass.program)
+		gl.Uniform2f(pass.viewportUniform, float32(width), float32(height))
+		gl.Uniform1i(pass.texUniform, 0)
+		gl.BindTexture(gl.TEXTURE_2D, r.postTargets[src].texture)
+		gl.DrawArrays(gl.TRIANGLES, 0, 6)
+		src = 1 - src
+	}
+	gl.Enable(gl.BLEND)
 }
 
 func (r *renderer) Close() {
 	if !r.initialized {
 		return
 	}
-	for _, state := range r.layerStates {
+	for layer, state := range r.layerStates {
+		layer.SetInstanceVisitor(nil)
+		r.deleteLayerState(state)
+	}
+	for _, state := range r.paneStates {
 		if state == nil {
 			continue
 		}
@@ -169,19 +429,8 @@ func (r *renderer) Close() {
 		if state.fbo != 0 {
 			gl.DeleteFramebuffers(1, &state.fbo)
 		}
-		for _, bucket := range state.buckets {
-			if bucket == nil {
-				continue
-			}
-			if bucket.instanceVbo != 0 {
-				gl.DeleteBuffers(1, &bucket.instanceVbo)
-			}
-			if bucket.vao != 0 {
-				gl.DeleteVertexArrays(1, &bucket.vao)
-			}
-		}
 	}
-	for _, state := range r.paneStates {
+	for _, state := range r.postTargets {
 		if state == nil {
 			continue
 		}
@@ -192,49 +441,153 @@ func (r *renderer) Close() {
 			gl.DeleteFramebuffers(1, &state.fbo)
 		}
 	}
+	if state := r.textureTarget; state != nil {
+		gl.DeleteTextures(1, &state.texture)
+		gl.DeleteFramebuffers(1, &state.fbo)
+	}
+	for _, texture := range r.sprites {
+		gl.DeleteTextures(1, &texture)
+	}
+	for _, pass := range r.postPasses {
+		if pass.program != 0 {
+			gl.DeleteProgram(pass.program)
+		}
+	}
 	if r.quadVbo != 0 {
 		gl.DeleteBuffers(1, &r.quadVbo)
 	}
 	if r.compositeVao != 0 {
 		gl.DeleteVertexArrays(1, &r.compositeVao)
 	}
+	if bg := r.background; bg != nil {
+		gl.DeleteBuffers(1, &bg.instanceVbo)
+		gl.DeleteVertexArrays(1, &bg.vao)
+		r.background = nil
+	}
 	if r.colorProgram != 0 {
 		gl.DeleteProgram(r.colorProgram)
 	}
 	if r.compositeProgram != 0 {
 		gl.DeleteProgram(r.compositeProgram)
 	}
+	r.sprites = nil
 	r.layerStates = nil
 	r.paneStates = nil
+	r.postPasses = nil
+	r.postTargets = [2]*paneState{}
+	r.textureTarget = nil
+	r.caps = nil
 	r.initialized = false
 }
 
-func (r *renderer) ensureInit() {
-	if r.initialized {
+// deleteLayerState frees the GL objects of a layer.
+func (r *renderer) deleteLayerState(state *layerState) {
+	if state == nil {
 		return
 	}
+	if state.texture != 0 {
+		gl.DeleteTextures(1, &state.texture)
+	}
+	if state.fbo != 0 {
+		gl.DeleteFramebuffers(1, &state.fbo)
+	}
+	if state.background != 0 {
+		gl.DeleteTextures(1, &state.background)
+	}
+	for _, bucket := range state.buckets {
+		if bucket == nil {
+			continue
+		}
+		if bucket.instanceVbo != 0 {
+			gl.DeleteBuffers(1, &bucket.instanceVbo)
+		}
+		if bucket.vao != 0 {
+			gl.DeleteVertexArrays(1, &bucket.vao)
+		}
+	}
+}
+
+// releaseRemovedLayers frees the state of layers removed from their pane
+// (see gfx.Pane.Clear).
+func (r *renderer) releaseRemovedLayers() {
+	for layer, state := range r.layerStates {
+		if layer.GetPane() == nil {
+			r.deleteLayerState(state)
+			delete(r.layerStates, layer)
+		}
+	}
+}
+
+var _ gfx.RendererInitializer = (*renderer)(nil)
+
+// Init compiles the shaders and creates the GL objects. gfx.NewWindowE calls
+// it with the context current; otherwise the first Render does, panicking on
+// failure.
+func (r *renderer) Init(*gfx.Window) error {
+	return r.ensureInit()
+}
+
+func (r *renderer) ensureInit() error {
+	if r.initialized {
+		return nil
+	}
+	if r.initErr == nil {
+		r.initErr = r.initGL()
+	}
+	return r.initErr
+}
+
+func (r *renderer) initGL() error {
 	if err := gl.Init(); err != nil {
-		panic(fmt.Sprintf("gl.Init error: %v", err))
+		return fmt.Errorf("gl.Init error: %w", err)
 	}
+	source, err := r.glConfig.StripShaderVersion(r.shaderSource)
+	if err != nil {
+		return err
+	}
+	r.shaderSource = source
 
-	r.colorProgram = r.buildProgram("PASS_COLOR")
-	r.compositeProgram = r.buildProgram("PASS_COMPOSITE")
+	if r.colorProgram, err = r.buildProgram("PASS_COLOR"); err != nil {
+		return err
+	}
+	if r.compositeProgram, err = r.buildProgram("PASS_COMPOSITE"); err != nil {
+		return err
+	}
+	for _, conf := range r.postConfigs {
+		program, err := r.buildProgram(append([]string{"PASS_POST"}, conf.Defines...)...)
+		if err != nil {
+			return fmt.Errorf("post pass %q: %w", conf.Name, err)
+		}
+		r.postPasses = append(r.postPasses, postPassState{
+			name:            conf.Name,
+			program:         program,
+			viewportUniform: gl.GetUniformLocation(program, gl.Str("uViewport\x00")),
+			texUniform:      gl.GetUniformLocation(program, gl.Str("uTex\x00")),
+		})
+	}
 
 	r.colorViewportUniform = gl.GetUniformLocation(r.colorProgram, gl.Str("uViewport\x00"))
 	r.colorOriginUniform = gl.GetUniformLocation(r.colorProgram, gl.Str("uOrigin\x00"))
 	r.colorWorldUniform = gl.GetUniformLocation(r.colorProgram, gl.Str("uWorld\x00"))
+	r.colorWrapUniform = gl.GetUniformLocation(r.colorProgram, gl.Str("uWrap\x00"))
+	r.colorSpriteUniform = gl.GetUniformLocation(r.colorProgram, gl.Str("uSprite\x00"))
+	r.colorPremultUniform = gl.GetUniformLocation(r.colorProgram, gl.Str("uPremultiply\x00"))
 	r.compositeViewportUniform = gl.GetUniformLocation(r.compositeProgram, gl.Str("uViewport\x00"))
 	r.compositeRectUniform = gl.GetUniformLocation(r.compositeProgram, gl.Str("uRect\x00"))
 	r.compositeTexUniform = gl.GetUniformLocation(r.compositeProgram, gl.Str("uTex\x00"))
 	r.compositeTexRectUniform = gl.GetUniformLocation(r.compositeProgram, gl.Str("uTexRect\x00"))
+	r.compositeTintUniform = gl.GetUniformLocation(r.compositeProgram, gl.Str("uTint\x00"))
+	r.compositeStrengthUniform = gl.GetUniformLocation(r.compositeProgram, gl.Str("uTintStrength\x00"))
 
 	r.initQuad()
+	r.checkGL("init")
 
 	gl.Enable(gl.BLEND)
 	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 	gl.Disable(gl.DEPTH_TEST)
 
 	r.initialized = true
+	return nil
 }
 
 func (r *renderer) initQuad() {
@@ -255,9 +608,14 @@ func (r *renderer) initQuad() {
 	gl.BindBuffer(gl.ARRAY_BUFFER, r.quadVbo)
 	gl.EnableVertexAttribArray(0)
 	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 2*4, gl.PtrOffset(0))
+
+	r.background = &bucketState{}
+	gl.GenVertexArrays(1, &r.background.vao)
+	gl.GenBuffers(1, &r.background.instanceVbo)
+	r.setupBucketVAO(r.background)
 }
 
-func (r *renderer) renderLayerBuckets(layer *gfx.Layer, plan gfx.LayerPlan, worldSize geom.Vec[uint32]) {
+func (r *renderer) renderLayerBuckets(layer *gfx.Layer, plan gfx.LayerPlan, worldSize geom.Vec[uint32], wrap bool) {
 	if layer == nil || r.source == nil {
 		return
 	}
@@ -270,30 +628,48 @@ func (r *renderer) renderLayerBuckets(layer *gfx.Layer, plan gfx.LayerPlan, worl
 	if cacheWidth <= 0 || cacheHeight <= 0 {
 		return
 	}
-	state := r.ensureLayerState(layer, cacheWidth, cacheHeight)
+	scaleX, scaleY := layer.GetPane().RenderScale()
+	// A cache rect too large for one texture renders at a lower resolution.
+	fit, width, height := fitTexture(scaledSize(cacheWidth, scaleX), scaledSize(cacheHeight, scaleY), r.maxTextureSize())
+	scaleX, scaleY = scaleX*fit, scaleY*fit
+	state := r.ensureLayerState(layer, width, height)
 	r.syncBucketStates(layer, state)
+	r.shrinkBuckets(state)
+	r.restyleBuckets(layer, state)
+	r.syncBackground(layer, state)
 	if plan.BucketRect == nil || len(plan.BucketIndices) == 0 {
 		return
 	}
 	bgColor := colorToFloat(layer.Background())
+	// worldSize is zero on a bounded world; a stretched image needs its size.
+	world := layer.GetPane().Viewport().WorldSize()
 
 	gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
 	gl.Viewport(0, 0, int32(state.width), int32(state.height))
 	gl.UseProgram(r.colorProgram)
-	gl.Uniform2f(r.colorViewportUniform, float32(state.width), float32(state.height))
+	gl.Uniform2f(r.colorViewportUniform, float32(cacheWidth), float32(cacheHeight))
 	gl.Uniform2f(r.colorOriginUniform, float32(cacheRect.TopLeft.X), float32(cacheRect.TopLeft.Y))
 	gl.Uniform2f(r.colorWorldUniform, float32(worldSize.X), float32(worldSize.Y))
+	gl.Uniform1i(r.colorWrapUniform, boolToInt32(wrap))
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, r.sprites[state.sprite])
+	gl.Uniform1i(r.colorSpriteUniform, 0)
+	blend := layer.ColorBlendMode()
+	r.setColorBlend(blend)
 	gl.Enable(gl.SCISSOR_TEST)
 
 	for _, idx := range plan.BucketIndices {
 		bucket := plan.BucketRect(idx)
-		scissor := bucketScissor(bucket, cacheRect, worldSize, state.width, state.height)
+		scissor := bucketScissor(bucket, cacheRect, worldSize, scaleX, scaleY, state.height)
 		if scissor.W <= 0 || scissor.H <= 0 {
 			continue
 		}
 		gl.Scissor(int32(scissor.X), int32(scissor.Y), int32(scissor.W), int32(scissor.H))
 		gl.ClearColor(bgColor[0], bgColor[1], bgColor[2], bgColor[3])
 		gl.Clear(gl.COLOR_BUFFER_BIT)
+		if state.background != 0 {
+			r.drawBackground(state, bucket, world, layer.BackgroundFit(), blend)
+		}
 
 		bucketState := state.buckets[bucket]
 		if bucketState == nil || len(bucketState.entries) == 0 {
@@ -304,14 +680,95 @@ func (r *renderer) renderLayerBuckets(layer *gfx.Layer, plan gfx.LayerPlan, worl
 	}
 
 	gl.Disable(gl.SCISSOR_TEST)
+	if blend != gfx.BlendAlpha {
+		r.setColorBlend(gfx.BlendAlpha)
+	}
 	r.source.AcknowledgeRendered(layer, plan.BucketIndices)
 }
 
+// syncBackground uploads the layer's background image after it changed, or
+// frees its texture once removed. A tiled image repeats past its edges.
+func (r *renderer) syncBackground(layer *gfx.Layer, state *layerState) {
+	version := layer.BackgroundVersion()
+	if state.backgroundVersion == version {
+		return
+	}
+	state.backgroundVersion = version
+	img := layer.BackgroundImage()
+	if img == nil {
+		if state.background != 0 {
+			gl.DeleteTextures(1, &state.background)
+			state.background = 0
+		}
+		return
+	}
+	pix := fitImage(textureImage(img), r.maxTextureSize())
+	if state.background == 0 {
+		gl.GenTextures(1, &state.background)
+	}
+	wrap := int32(gl.CLAMP_TO_EDGE)
+	if layer.BackgroundFit() == gfx.BackgroundTile {
+		wrap = gl.REPEAT
+	}
+	gl.BindTexture(gl.TEXTURE_2D, state.background)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, wrap)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, wrap)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(pix.Rect.Dx()), int32(pix.Rect.Dy()), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pix.Pix))
+	// Tiles repeat every image pixel in world units, even when fitted.
+	state.backgroundSize = img.Bounds().Size()
+	r.checkGL("background upload")
+}
+
+// drawBackground draws the layer's background image over bucket with alpha
+// blending, whatever the layer's blend mode, then rebinds the sprite texture.
+func (r *renderer) drawBackground(state *layerState, bucket geom.AABB[uint32], world geom.Vec[uint32], fit gfx.BackgroundFit, blend gfx.BlendMode) {
+	data := appendBackgroundInstance(make([]float32, 0, floatsPerInstance), bucket, backgroundUV(bucket, world, state.backgroundSize, fit))
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.background.instanceVbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, gl.Ptr(data), gl.STREAM_DRAW)
+	if blend != gfx.BlendAlpha {
+		r.setColorBlend(gfx.BlendAlpha)
+	}
+	gl.BindTexture(gl.TEXTURE_2D, state.background)
+	gl.BindVertexArray(r.background.vao)
+	gl.DrawArraysInstanced(gl.TRIANGLES, 0, 6, 1)
+	gl.BindTexture(gl.TEXTURE_2D, r.sprites[state.sprite])
+	if blend != gfx.BlendAlpha {
+		r.setColorBlend(blend)
+	}
+}
+
+// setColorBlend sets the blend function of the color pass and tells the
+// shader whether to premultiply its output.
+func (r *renderer) setColorBlend(blend gfx.BlendMode) {
+	src, dst := blendFactors(blend)
+	gl.BlendFunc(glBlendFactor(src), glBlendFactor(dst))
+	gl.Uniform1i(r.colorPremultUniform, boolToInt32(premultipliedSource(blend)))
+}
+
+func glBlendFactor(f blendFactor) uint32 {
+	switch f {
+	case factorZero:
+		return gl.ZERO
+	case factorOne:
+		return gl.ONE
+	case factorOneMinusSrcAlpha:
+		return gl.ONE_MINUS_SRC_ALPHA
+	case factorDstColor:
+		return gl.DST_COLOR
+	default:
+		return gl.SRC_ALPHA
+	}
+}
+
 func (r *renderer) compositePane(pane *gfx.Pane, layers []*gfx.Layer, layerPlans map[*gfx.Layer]gfx.LayerPlan, frame gfx.FramePlan, worldSize geom.Vec[uint32]) {
 	if pane == nil || pane.Config == nil {
 		return
 	}
-	state := r.ensurePaneState(pane, pane.Config.Width, pane.Config.Height)
+	paneWidth, paneHeight := pane.RenderSize()
+	fit, paneWidth, paneHeight := fitTexture(paneWidth, paneHeight, r.maxTextureSize())
+	state := r.ensurePaneState(pane, paneWidth, paneHeight)
 	if state == nil || state.texture == 0 {
 		return
 	}
@@ -327,10 +784,13 @@ func (r *renderer) compositePane(pane *gfx.Pane, layers []*gfx.Layer, layerPlans
 	gl.ActiveTexture(gl.TEXTURE0)
 	gl.Uniform1i(r.compositeTexUniform, 0)
 	gl.Uniform4f(r.compositeRectUniform, 0, 0, float32(state.width), float32(state.height))
+	gl.Uniform1f(r.compositeStrengthUniform, 0)
 
 	gl.Enable(gl.SCISSOR_TEST)
+	scaleX, scaleY := pane.RenderScale()
+	scaleX, scaleY = scaleX*fit, scaleY*fit
 	for _, rect := range frame.CompositeRects {
-		scissor := paneScissor(rect, state.height)
+		scissor := paneScissor(rect, scaleX, scaleY, state.height)
 		if scissor.W <= 0 || scissor.H <= 0 {
 			continue
 		}
@@ -346,7 +806,7 @@ func (r *renderer) compositePane(pane *gfx.Pane, layers []*gfx.Layer, layerPlans
 			if layerState == nil || layerState.texture == 0 {
 				continue
 			}
-			uv := texRect(frame.ViewRect, plan.CacheRect, worldSize)
+			uv := texRect(frame.LayerViewRect(plan), plan.CacheRect, worldSize)
 			gl.Uniform4f(r.compositeTexRectUniform, uv[0], uv[1], uv[2], uv[3])
 			gl.BindTexture(gl.TEXTURE_2D, layerState.texture)
 			gl.DrawArrays(gl.TRIANGLES, 0, 6)
@@ -373,6 +833,9 @@ func (r *renderer) ensurePaneState(pane *gfx.Pane, width, height int) *paneState
 
 func (r *renderer) resizePaneTexture(state *paneState) {
 	if state.width <= 0 || state.height <= 0 {
+		if r.debug {
+			debugf("pane texture %dx%d: empty size, framebuffer left incomplete", state.width, state.height)
+		}
 		return
 	}
 	gl.BindTexture(gl.TEXTURE_2D, state.texture)
@@ -384,53 +847,39 @@ func (r *renderer) resizePaneTexture(state *paneState) {
 
 	gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, state.texture, 0)
+	r.checkFramebuffer("pane texture", state.width, state.height)
 }
 
-type scissorRect struct {
-	X int
-	Y int
-	W int
-	H int
-}
-
-func bucketScissor(bucket, cacheRect geom.AABB[uint32], worldSize geom.Vec[uint32], cacheWidth, cacheHeight int) scissorRect {
-	origin := cacheRect.TopLeft
-	x0 := unwrapCoord(bucket.TopLeft.X, origin.X, worldSize.X)
-	x1 := unwrapCoord(bucket.BottomRight.X, origin.X, worldSize.X)
-	y0 := unwrapCoord(bucket.TopLeft.Y, origin.Y, worldSize.Y)
-	y1 := unwrapCoord(bucket.BottomRight.Y, origin.Y, worldSize.Y)
-	if worldSize.X > 0 && x1 < x0 {
-		x1 += worldSize.X
-	}
-	if worldSize.Y > 0 && y1 < y0 {
-		y1 += worldSize.Y
+// checkGL drains and logs pending GL errors in debug mode.
+func (r *renderer) checkGL(op string) {
+	if !r.debug {
+		return
 	}
-	localX0 := int(x0 - origin.X)
-	localX1 := int(x1 - origin.X)
-	localY0 := int(y0 - origin.Y)
-	localY1 := int(y1 - origin.Y)
-	return scissorRect{
-		X: localX0,
-		Y: cacheHeight - localY1,
-		W: localX1 - localX0,
-		H: localY1 - localY0,
+	for i := 0; i < maxErrorsPerCheck; i++ {
+		code := gl.GetError()
+		if code == gl.NO_ERROR {
+			return
+		}
+		debugf("%s: %s", op, glErrorName(code))
 	}
 }
 
-func paneScissor(rect geom.AABB[uint32], paneHeight int) scissorRect {
-	return scissorRect{
-		X: int(rect.TopLeft.X),
-		Y: paneHeight - int(rect.BottomRight.Y),
-		W: int(rect.BottomRight.X - rect.TopLeft.X),
-		H: int(rect.BottomRight.Y - rect.TopLeft.Y),
+// checkFramebuffer reports an incomplete bound framebuffer in debug mode.
+func (r *renderer) checkFramebuffer(op string, width, height int) {
+	if !r.debug {
+		return
+	}
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		debugf("%s %dx%d: %s", op, width, height, framebufferStatusName(status))
 	}
 }
 
-func unwrapCoord(value, origin, worldSize uint32) uint32 {
-	if worldSize > 0 && value < origin {
-		return value + worldSize
+func boolToInt32(v bool) int32 {
+	if v {
+		return 1
 	}
-	return value
+	return 0
 }
 
 func texRect(viewRect, cacheRect geom.AABB[uint32], worldSize geom.Vec[uint32]) [4]float32 {
@@ -470,6 +919,7 @@ func (r *renderer) ensureLayerState(layer *gfx.Layer, width, height int) *layerS
 		gl.GenTextures(1, &state.texture)
 		gl.GenFramebuffers(1, &state.fbo)
 		r.layerStates[layer] = state
+		layer.SetInstanceVisitor(r)
 	}
 	if state.width != width || state.height != height {
 		state.width = width
@@ -480,6 +930,9 @@ func (r *renderer) ensureLayerState(layer *gfx.Layer, width, height int) *layerS
 }
 
 func (r *renderer) resizeLayerTexture(state *layerState) {
+	if r.debug && (state.width <= 0 || state.height <= 0) {
+		debugf("layer texture %dx%d: empty size, framebuffer will be incomplete", state.width, state.height)
+	}
 	gl.BindTexture(gl.TEXTURE_2D, state.texture)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
@@ -489,6 +942,7 @@ func (r *renderer) resizeLayerTexture(state *layerState) {
 
 	gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, state.texture, 0)
+	r.checkFramebuffer("layer texture", state.width, state.height)
 }
 
 func (r *renderer) syncBucketStates(layer *gfx.Layer, state *layerState) {
@@ -522,6 +976,49 @@ func (r *renderer) syncBucketStates(layer *gfx.Layer, state *layerState) {
 			r.uploadBucketUpdates(bucket, updates)
 		}
 	}
+	r.checkGL("instance upload")
+}
+
+var _ gfx.InstanceVisitor = (*renderer)(nil)
+
+// VisitInstances reports the instance data cached for layer; see
+// gfx.Layer.ForEachInstance.
+func (r *renderer) VisitInstances(layer *gfx.Layer, fn gfx.InstanceFunc) {
+	state := r.layerStates[layer]
+	if state == nil {
+		return
+	}
+	seen := make(map[uint64]struct{})
+	for _, bucket := range state.buckets {
+		if bucket != nil {
+			visitBucketInstances(layer, bucket.entries, bucket.data, seen, fn)
+		}
+	}
+}
+
+// restyleBuckets rebuilds the instance data of every entry after
+// Layer.MarkDirty, so style changes that moved nothing reach the GPU.
+func (r *renderer) restyleBuckets(layer *gfx.Layer, state *layerState) {
+	version := layer.StyleVersion()
+	if state.styleVersion == version {
+		return
+	}
+	state.styleVersion = version
+	scratch := make([]float32, 0, floatsPerInstance)
+	for _, bucket := range state.buckets {
+		if bucket == nil {
+			continue
+		}
+		for idx, entryID := range bucket.entries {
+			data, ok := r.bucketEntryData(layer, entryID, scratch)
+			start := idx * floatsPerInstance
+			if !ok || start+floatsPerInstance > len(bucket.data) {
+				continue
+			}
+			copy(bucket.data[start:start+floatsPerInstance], data)
+		}
+		r.uploadBucketFull(bucket)
+	}
 }
 
 func (r *renderer) ensureBucketState(state *layerState, bucketRect geom.AABB[uint32]) *bucketState {
@@ -550,16 +1047,12 @@ func (r *renderer) setupBucketVAO(bucket *bucketState) {
 	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 2*4, gl.PtrOffset(0))
 
 	gl.BindBuffer(gl.ARRAY_BUFFER, bucket.instanceVbo)
-	stride := floatsPerInstance * 4
-	gl.EnableVertexAttribArray(1)
-	gl.VertexAttribPointer(1, 4, gl.FLOAT, false, int32(stride), gl.PtrOffset(0))
-	gl.VertexAttribDivisor(1, 1)
-	gl.EnableVertexAttribArray(2)
-	gl.VertexAttribPointer(2, 4, gl.FLOAT, false, int32(stride), gl.PtrOffset(4*4))
-	gl.VertexAttribDivisor(2, 1)
-	gl.EnableVertexAttribArray(3)
-	gl.VertexAttribPointer(3, 4, gl.FLOAT, false, int32(stride), gl.PtrOffset(8*4))
-	gl.VertexAttribDivisor(3, 1)
+	stride := int32(floatsPerInstance * 4)
+	forEachInstanceAttribute(func(attr instanceAttribute, offset int) {
+		gl.EnableVertexAttribArray(attr.location)
+		gl.VertexAttribPointer(attr.location, int32(attr.size), gl.FLOAT, false, stride, gl.PtrOffset(offset*4))
+		gl.VertexAttribDivisor(attr.location, 1)
+	})
 }
 
 func (r *renderer) bucketEntryData(layer *gfx.Layer, entryID uint64, scratch []float32) ([]float32, bool) {
@@ -570,12 +1063,17 @@ func (r *renderer) bucketEntryData(layer *gfx.Layer, entryID uint64, scratch []f
 	if !ok {
 		return scratch, false
 	}
-	drawable := layer.DrawableByID(entryID >> 2)
+	drawable := layer.DrawableByEntryID(entryID)
 	if drawable == nil {
 		return scratch, false
 	}
+	if texture := drawable.EffectiveStyle().Texture; texture != 0 {
+		if state := r.layerStates[layer]; state != nil {
+			state.sprite = texture
+		}
+	}
 	scratch = scratch[:0]
-	scratch = appendAABBInstance(scratch, frag, drawable.Style)
+	scratch = appendEntryInstance(scratch, r.source, layer, entryID, frag, drawable)
 	if len(scratch) != floatsPerInstance {
 		return scratch, false
 	}
@@ -679,6 +1177,25 @@ func (r *renderer) ensureBucketCapacity(bucket *bucketState, required int) bool
 	return true
 }
 
+// shrinkBuckets reallocates the instance buffers the InstanceShrink policy
+// deems oversized and re-uploads their instances.
+func (r *renderer) shrinkBuckets(state *layerState) {
+	if !r.shrink.enabled() {
+		return
+	}
+	for _, bucket := range state.buckets {
+		required := len(bucket.entries) * floatsPerInstance * 4
+		newCap := r.shrink.shrinkCap(required, bucket.instanceCap, &bucket.lowFrames)
+		if newCap == 0 {
+			continue
+		}
+		gl.BindBuffer(gl.ARRAY_BUFFER, bucket.instanceVbo)
+		gl.BufferData(gl.ARRAY_BUFFER, newCap, nil, gl.DYNAMIC_DRAW)
+		bucket.instanceCap = newCap
+		r.uploadBucketFull(bucket)
+	}
+}
+
 func (r *renderer) uploadBucketFull(bucket *bucketState) {
 	if len(bucket.data) == 0 {
 		return
@@ -701,17 +1218,18 @@ func (r *renderer) uploadBucketUpdates(bucket *bucketState, updates []int) {
 	}
 }
 
-func (r *renderer) buildProgram(pass string) uint32 {
-	vertexSource := r.buildShaderSource("VERTEX", pass)
-	fragmentSource := r.buildShaderSource("FRAGMENT", pass)
+func (r *renderer) buildProgram(defines ...string) (uint32, error) {
+	vertexSource := r.buildShaderSource("VERTEX", defines...)
+	fragmentSource := r.buildShaderSource("FRAGMENT", defines...)
 
 	vertexShader, err := compileShader(gl.VERTEX_SHADER, vertexSource)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 	fragmentShader, err := compileShader(gl.FRAGMENT_SHADER, fragmentSource)
 	if err != nil {
-		panic(err)
+		gl.DeleteShader(vertexShader)
+		return 0, err
 	}
 
 	program := gl.CreateProgram()
@@ -726,19 +1244,26 @@ func (r *renderer) buildProgram(pass string) uint32 {
 		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
-		panic(fmt.Errorf("link error: %s", log))
+		gl.DeleteShader(vertexShader)
+		gl.DeleteShader(fragmentShader)
+		gl.DeleteProgram(program)
+		return 0, fmt.Errorf("link error: %s", log)
 	}
 
 	gl.DeleteShader(vertexShader)
 	gl.DeleteShader(fragmentShader)
-	return program
+	r.checkGL("build program " + strings.Join(defines, ","))
+	return program, nil
 }
 
-func (r *renderer) buildShaderSource(stage, pass string) string {
+func (r *renderer) buildShaderSource(stage string, defines ...string) string {
 	var sb strings.Builder
-	sb.WriteString("#version 330 core\n")
+	sb.WriteString(r.glConfig.ShaderHeader())
 	sb.WriteString("#define " + stage + "\n")
-	sb.WriteString("#define " + pass + "\n")
+	for _, define := range defines {
+		sb.WriteString("#define " + define + "\n")
+	}
+	sb.WriteString("#define INSTANCE_ATTRIBUTES " + instanceAttributeDecls() + "\n")
 	sb.WriteString(r.shaderSource)
 	if !strings.HasSuffix(r.shaderSource, "\n") {
 		sb.WriteString("\n")
@@ -760,6 +1285,7 @@ func compileShader(shaderType uint32, source string) (uint32, error) {
 		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
 		return 0, fmt.Errorf("compile error: %s", log)
 	}
 	return shader, nil
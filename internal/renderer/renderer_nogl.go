@@ -0,0 +1,10 @@
+//go:build !js && !cgo
+
+package renderer
+
+import "github.com/kjkrol/gokx/pkg/gfx"
+
+// Without cgo there is no GL binding; every factory falls back to software.
+func newRenderer(_ *gfx.Window, _ RendererConfig, _ gfx.FrameSource) gfx.Renderer {
+	return &softwareRenderer{}
+}
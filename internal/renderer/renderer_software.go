@@ -0,0 +1,363 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokx/pkg/gfx"
+)
+
+// softwareRenderer rasterizes panes on the CPU into a window-sized image.RGBA
+// and presents it through Window.NewImageBlitter. It reads drawables straight
+// from the layers, so it needs no FrameSource.
+type softwareRenderer struct {
+	mu      sync.Mutex // guards frame against concurrent Snapshot
+	frame   *image.RGBA
+	blitter gfx.ImageBlitter
+	sprites spriteTextures
+	// backgrounds holds copies of the layers' background images.
+	backgrounds backgroundImages
+}
+
+var (
+	_ gfx.SoftwareRenderer = (*softwareRenderer)(nil)
+	_ gfx.Snapshotter      = (*softwareRenderer)(nil)
+	_ gfx.RectCapturer     = (*softwareRenderer)(nil)
+	_ gfx.TextureLoader    = (*softwareRenderer)(nil)
+	_ gfx.CapsReporter     = (*softwareRenderer)(nil)
+)
+
+// NewSoftwareRendererFactory returns a factory for the CPU renderer. It works
+// without a GL context; on platforms without a blit path (WASM) frames are
+// rendered but not presented.
+func NewSoftwareRendererFactory() gfx.RendererFactory {
+	return func(w *gfx.Window) gfx.Renderer {
+		return &softwareRenderer{}
+	}
+}
+
+// NewAutoRendererFactory picks the GL renderer when the platform can create a
+// GL context and falls back to the software renderer otherwise.
+func NewAutoRendererFactory(conf RendererConfig, source gfx.FrameSource) gfx.RendererFactory {
+	return func(w *gfx.Window) gfx.Renderer {
+		if w.GPUAvailable() {
+			return newRenderer(w, conf, source)
+		}
+		return &softwareRenderer{}
+	}
+}
+
+func (r *softwareRenderer) Software() bool { return true }
+
+func (r *softwareRenderer) Render(w *gfx.Window) {
+	if w == nil {
+		return
+	}
+	width, height := w.Size()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ensureFrame(w, width, height)
+	draw.Draw(r.frame, r.frame.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	if r.backgrounds == nil {
+		r.backgrounds = make(backgroundImages)
+	}
+	r.backgrounds.prune()
+	for _, pane := range w.Panes() {
+		paintPane(r.frame, pane, r.sprites, r.backgrounds)
+	}
+	if r.blitter != nil {
+		r.blitter.Update(r.frame.Bounds())
+	}
+}
+
+// Snapshot returns a copy of the last rendered frame; safe to call from any
+// goroutine.
+func (r *softwareRenderer) Snapshot() *image.RGBA {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.frame == nil {
+		return nil
+	}
+	img := image.NewRGBA(r.frame.Rect)
+	copy(img.Pix, r.frame.Pix)
+	return img
+}
+
+// CaptureRect copies rect of the last rendered frame.
+func (r *softwareRenderer) CaptureRect(_ *gfx.Window, rect image.Rectangle) (*image.RGBA, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.frame == nil {
+		return nil, errNoFrame
+	}
+	rect = rect.Intersect(r.frame.Rect)
+	if rect.Empty() {
+		return nil, gfx.ErrEmptyCapture
+	}
+	img := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(img, img.Bounds(), r.frame, rect.Min, draw.Src)
+	return img, nil
+}
+
+// LoadTexture keeps a copy of img for the sprites drawn with it.
+func (r *softwareRenderer) LoadTexture(_ *gfx.Window, img image.Image) (gfx.TextureID, error) {
+	if img.Bounds().Empty() {
+		return 0, gfx.ErrTextureSize
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sprites = append(r.sprites, textureImage(img))
+	return gfx.TextureID(len(r.sprites)), nil
+}
+
+// Capabilities reports the CPU rasterizer: no texture size limit, no GPU
+// features.
+func (r *softwareRenderer) Capabilities(*gfx.Window) gfx.Caps {
+	return gfx.Caps{Kind: gfx.RendererSoftware, Renderer: "software"}
+}
+
+func (r *softwareRenderer) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.blitter != nil {
+		r.blitter.Delete()
+		r.blitter = nil
+	}
+	r.frame = nil
+	r.sprites = nil
+	r.backgrounds = nil
+}
+
+func (r *softwareRenderer) ensureFrame(w *gfx.Window, width, height int) {
+	if r.frame != nil && r.frame.Rect.Dx() == width && r.frame.Rect.Dy() == height {
+		return
+	}
+	if r.blitter != nil {
+		r.blitter.Delete()
+	}
+	r.frame = image.NewRGBA(image.Rect(0, 0, width, height))
+	r.blitter = w.NewImageBlitter(r.frame, 0, 0)
+}
+
+func paintPane(dst *image.RGBA, pane *gfx.Pane, sprites spriteTextures, backgrounds backgroundImages) {
+	if pane == nil || pane.Config == nil || pane.Viewport() == nil {
+		return
+	}
+	// Painting straight at the content scale matches the GL nearest
+	// upscale of an internal size.
+	content := pane.ContentRect()
+	if bars, ok := paneLetterbox(pane, content); ok {
+		draw.Draw(dst, bars.rect.Intersect(dst.Bounds()), image.NewUniform(bars.color), image.Point{}, draw.Over)
+	}
+	paneRect := content.Intersect(dst.Bounds())
+	if paneRect.Empty() {
+		return
+	}
+	target := dst.SubImage(paneRect).(*image.RGBA)
+	view := pane.Viewport()
+	world := view.WorldSize()
+	wrapX, wrapY := view.WrapAxes()
+	unwrap, wrap := viewWrap(view)
+	scaleX, scaleY := pane.LogicalScale()
+
+	for _, layer := range pane.CompositeLayers() {
+		draw.Draw(target, paneRect, image.NewUniform(layer.Background()), image.Point{}, draw.Over)
+		layerOrigin := layer.ParallaxViewRectAxes(view.Rect(), world, wrapX, wrapY).TopLeft
+		if texture := backgrounds.get(layer); texture != nil {
+			paintBackground(target, paneRect, layerOrigin, world, wrapX, wrapY, scaleX, scaleY, layer.BackgroundFit(), texture)
+		}
+		for _, drawable := range layer.Drawables() {
+			paintDrawable(target, paneRect.Min, layerOrigin, unwrap, wrap, scaleX, scaleY, drawable, sprites)
+		}
+	}
+	if tint, strength := paneTint(pane); strength > 0 {
+		tintRect(target, paneRect, tint, strength)
+	}
+}
+
+// paneTint returns the pane tint as straight RGBA and its strength, matching
+// the uTint/uTintStrength uniforms of the composite pass.
+func paneTint(pane *gfx.Pane) ([4]float32, float32) {
+	c, strength := pane.Tint()
+	if strength == 0 {
+		return [4]float32{}, 0
+	}
+	return colorToFloat(c), strength
+}
+
+// letterbox is the rect of a pane cleared before its content is drawn into a
+// smaller ContentRect, and the color it is cleared to.
+type letterbox struct {
+	rect  image.Rectangle
+	color color.Color
+}
+
+// paneLetterbox returns the letterbox of pane: its whole rect, cleared to the
+// background of the bottom layer. ok is false when content fills the pane or
+// the background is transparent, leaving what lies beneath the bars visible.
+func paneLetterbox(pane *gfx.Pane, content image.Rectangle) (letterbox, bool) {
+	conf := pane.Config
+	rect := image.Rect(conf.OffsetX, conf.OffsetY, conf.OffsetX+conf.Width, conf.OffsetY+conf.Height)
+	if content == rect {
+		return letterbox{}, false
+	}
+	layers := pane.CompositeLayers()
+	if len(layers) == 0 || layers[0] == nil {
+		return letterbox{}, false
+	}
+	bg := layers[0].Background()
+	if bg == nil {
+		return letterbox{}, false
+	}
+	if _, _, _, a := bg.RGBA(); a == 0 {
+		return letterbox{}, false
+	}
+	return letterbox{rect: rect, color: bg}, true
+}
+
+// tintRect lerps the RGB channels of rect toward tint, like the composite
+// shader's mix(color.rgb, uTint.rgb, uTintStrength). Alpha is kept.
+func tintRect(dst *image.RGBA, rect image.Rectangle, tint [4]float32, strength float32) {
+	var target [3]float32
+	for i := range target {
+		target[i] = tint[i] * 255
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(rect.Min.X, y):]
+		for x := 0; x < rect.Dx(); x++ {
+			px := row[x*4 : x*4+3]
+			for i := range px {
+				v := float32(px[i])
+				px[i] = uint8(v + (target[i]-v)*strength + 0.5)
+			}
+		}
+	}
+}
+
+// paintDrawable draws the drawable and its wrap fragments. Like the color
+// shader, fragments left of (above) the view origin are unwrapped by one world
+// size when wrapping is on. World units are scaled to pixels by scaleX/scaleY.
+// Sprites sample their texture from sprites.
+func paintDrawable(dst *image.RGBA, offset image.Point, origin, world geom.Vec[uint32], wrap bool, scaleX, scaleY float64, drawable *gfx.Drawable, sprites spriteTextures) {
+	if drawable == nil {
+		return
+	}
+	style := drawable.EffectiveStyle()
+	paint := func(rect geom.AABB[uint32]) {
+		x0, y0 := int(rect.TopLeft.X), int(rect.TopLeft.Y)
+		x1, y1 := int(rect.BottomRight.X), int(rect.BottomRight.Y)
+		if wrap && rect.TopLeft.X < origin.X {
+			x0 += int(world.X)
+			x1 += int(world.X)
+		}
+		if wrap && rect.TopLeft.Y < origin.Y {
+			y0 += int(world.Y)
+			y1 += int(world.Y)
+		}
+		screen := image.Rect(
+			scaledSize(x0-int(origin.X), scaleX), scaledSize(y0-int(origin.Y), scaleY),
+			scaledSize(x1-int(origin.X), scaleX), scaledSize(y1-int(origin.Y), scaleY),
+		)
+		if texture := sprites.get(style.Texture); texture != nil {
+			paintSprite(dst, screen.Add(offset), fragmentStyle(&drawable.AABB, rect, style), texture)
+			return
+		}
+		span := gradientSpan(&drawable.AABB, rect, style)
+		paintRect(dst, screen.Add(offset), style, span)
+	}
+	paint(drawable.AABB.AABB)
+	drawable.AABB.VisitFragments(func(_ plane.FragPosition, frag geom.AABB[uint32]) bool {
+		paint(frag)
+		return true
+	})
+}
+
+// scaledSize converts a length or coordinate in world units to pixels.
+func scaledSize(v int, scale float64) int {
+	return int(math.Round(float64(v) * scale))
+}
+
+// paintRect mirrors the color pass: a 1px stroke border when the stroke is
+// visible, fill (or gradient) inside. span is the gradient range covered by
+// rect, see gradientSpan.
+func paintRect(dst *image.RGBA, rect image.Rectangle, style gfx.SpatialStyle, span [2]float32) {
+	if rect.Empty() {
+		return
+	}
+	fill := rect
+	if visible(style.Stroke) {
+		fill = rect.Inset(1)
+		stroke := image.NewUniform(style.Stroke)
+		if fill.Empty() {
+			draw.Draw(dst, rect, stroke, image.Point{}, draw.Over)
+			return
+		}
+		edges := [4]image.Rectangle{
+			image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, fill.Min.Y),
+			image.Rect(rect.Min.X, fill.Max.Y, rect.Max.X, rect.Max.Y),
+			image.Rect(rect.Min.X, fill.Min.Y, fill.Min.X, fill.Max.Y),
+			image.Rect(fill.Max.X, fill.Min.Y, rect.Max.X, fill.Max.Y),
+		}
+		for _, edge := range edges {
+			draw.Draw(dst, edge, stroke, image.Point{}, draw.Over)
+		}
+	}
+	if gradient, ok := style.ActiveGradient(); ok {
+		paintGradient(dst, rect, fill, gradient, span)
+		return
+	}
+	if visible(style.Fill) {
+		draw.Draw(dst, fill, image.NewUniform(style.Fill), image.Point{}, draw.Over)
+	}
+}
+
+// paintGradient fills one scanline (vertical) or column (horizontal) at a
+// time; the ramp is measured across rect, like vLocal in the color shader.
+func paintGradient(dst *image.RGBA, rect, fill image.Rectangle, gradient *gfx.Gradient, span [2]float32) {
+	from := colorToFloat(gradient.From)
+	to := colorToFloat(gradient.To)
+	vertical := gradient.Dir == gfx.GradientVertical
+	steps := rect.Dx()
+	if vertical {
+		steps = rect.Dy()
+	}
+	for i := 0; i < steps; i++ {
+		local := (float32(i) + 0.5) / float32(steps)
+		c := lerpColor(from, to, span[0]+(span[1]-span[0])*local)
+		line := image.Rect(rect.Min.X+i, fill.Min.Y, rect.Min.X+i+1, fill.Max.Y)
+		if vertical {
+			line = image.Rect(fill.Min.X, rect.Min.Y+i, fill.Max.X, rect.Min.Y+i+1)
+		}
+		line = line.Intersect(fill)
+		if c.A == 0 || line.Empty() {
+			continue
+		}
+		draw.Draw(dst, line, image.NewUniform(c), image.Point{}, draw.Over)
+	}
+}
+
+// lerpColor interpolates straight-alpha colors from colorToFloat.
+func lerpColor(from, to [4]float32, t float32) color.NRGBA {
+	var out [4]uint8
+	for i := range out {
+		v := from[i] + (to[i]-from[i])*t
+		out[i] = uint8(min(max(v, 0), 1)*255 + 0.5)
+	}
+	return color.NRGBA{R: out[0], G: out[1], B: out[2], A: out[3]}
+}
+
+func visible(c color.Color) bool {
+	if c == nil {
+		return false
+	}
+	_, _, _, a := c.RGBA()
+	return a > 0
+}
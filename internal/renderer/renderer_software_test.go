@@ -0,0 +1,314 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokx/pkg/gfx"
+)
+
+func TestPaintDrawable_WrapsAcrossSeam(t *testing.T) {
+	space := plane.NewToroidal2D[uint32](64, 64)
+	green := color.RGBA{G: 255, A: 255}
+	drawable := &gfx.Drawable{
+		AABB:  space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](60, 10), 8, 4)),
+		Style: gfx.SpatialStyle{Fill: green},
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	world := geom.NewVec[uint32](64, 64)
+
+	paintDrawable(dst, image.Point{}, geom.NewVec[uint32](0, 0), world, true, 1, 1, drawable, nil)
+
+	for _, p := range []image.Point{{62, 11}, {2, 11}} {
+		if got := dst.RGBAAt(p.X, p.Y); got != green {
+			t.Errorf("pixel %v = %v, want fill", p, got)
+		}
+	}
+	if got := dst.RGBAAt(10, 11); got.A != 0 {
+		t.Errorf("pixel outside the drawable painted: %v", got)
+	}
+}
+
+func TestPaintDrawable_SpriteSamplesTextureAcrossSeam(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	texture := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	texture.Set(0, 0, red)
+	texture.Set(1, 0, blue)
+	r := &softwareRenderer{}
+	id, err := r.LoadTexture(nil, texture)
+	if err != nil {
+		t.Fatalf("LoadTexture: %v", err)
+	}
+	texture.Set(0, 0, color.White) // the renderer keeps its own copy
+
+	space := plane.NewToroidal2D[uint32](64, 64)
+	sprite := gfx.Sprite{
+		AABB:      space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](60, 10), 8, 4)),
+		TextureID: id,
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	world := geom.NewVec[uint32](64, 64)
+
+	paintDrawable(dst, image.Point{}, geom.NewVec[uint32](0, 0), world, true, 1, 1, sprite.Drawable(), r.sprites)
+
+	// The left half of the image lands before the seam, the right half wraps.
+	for p, want := range map[image.Point]color.RGBA{{60, 11}: red, {63, 13}: red, {0, 11}: blue, {3, 13}: blue} {
+		if got := dst.RGBAAt(p.X, p.Y); got != want {
+			t.Errorf("pixel %v = %v, want %v", p, got, want)
+		}
+	}
+	if got := dst.RGBAAt(4, 11); got.A != 0 {
+		t.Errorf("pixel outside the sprite painted: %v", got)
+	}
+}
+
+func TestPaintPane_LayerOrderDecidesStacking(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	pane := gfx.NewPane(&gfx.PaneConfig{Width: 16, Height: 16}, 1)
+	pane.AddLayer(1)
+	space := plane.NewEuclidean2D[uint32](16, 16)
+	square := func(x, y uint32, fill color.Color) *gfx.Drawable {
+		return &gfx.Drawable{
+			AABB:  space.WrapAABB(geom.NewAABBAt(geom.NewVec(x, y), 8, 8)),
+			Style: gfx.SpatialStyle{Fill: fill},
+		}
+	}
+	pane.GetLayer(0).AddDrawable(square(0, 0, red))
+	pane.GetLayer(1).AddDrawable(square(4, 4, blue))
+	overlap := func() color.RGBA {
+		dst := image.NewRGBA(image.Rect(0, 0, 16, 16))
+		paintPane(dst, pane, nil, nil)
+		return dst.RGBAAt(6, 6)
+	}
+
+	if got := overlap(); got != blue {
+		t.Fatalf("overlap = %v, want the upper blue layer", got)
+	}
+	pane.SwapLayers(0, 1)
+	if got := overlap(); got != red {
+		t.Fatalf("after SwapLayers overlap = %v, want red on top", got)
+	}
+	pane.MoveLayer(1, 0)
+	if got := overlap(); got != blue {
+		t.Fatalf("after MoveLayer overlap = %v, want blue on top again", got)
+	}
+}
+
+func TestPaintPane_TopDownCompositeOrderDrawsFirstLayerOnTop(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	pane := gfx.NewPane(&gfx.PaneConfig{Width: 16, Height: 16, CompositeOrder: gfx.CompositeTopDown}, 1)
+	pane.AddLayer(1)
+	pane.GetLayer(0).SetBackground(color.Transparent)
+	space := plane.NewEuclidean2D[uint32](16, 16)
+	square := func(x, y uint32, fill color.Color) *gfx.Drawable {
+		return &gfx.Drawable{
+			AABB:  space.WrapAABB(geom.NewAABBAt(geom.NewVec(x, y), 8, 8)),
+			Style: gfx.SpatialStyle{Fill: fill},
+		}
+	}
+	pane.GetLayer(0).AddDrawable(square(0, 0, red))
+	pane.GetLayer(1).AddDrawable(square(4, 4, blue))
+	overlap := func() color.RGBA {
+		dst := image.NewRGBA(image.Rect(0, 0, 16, 16))
+		paintPane(dst, pane, nil, nil)
+		return dst.RGBAAt(6, 6)
+	}
+
+	if got := overlap(); got != red {
+		t.Fatalf("overlap = %v, want red from layer 0 on top", got)
+	}
+	pane.MoveLayer(1, 0)
+	if got := overlap(); got != blue {
+		t.Fatalf("after MoveLayer overlap = %v, want blue on top", got)
+	}
+}
+
+func TestPaintBackground_StretchesOrTilesOverWorld(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	texture := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	texture.Set(0, 0, red)
+	texture.Set(1, 0, blue)
+	world := geom.NewVec[uint32](8, 4)
+
+	for _, tc := range []struct {
+		name string
+		fit  gfx.BackgroundFit
+		want map[image.Point]color.RGBA
+	}{
+		{"stretch", gfx.BackgroundStretch, map[image.Point]color.RGBA{{0, 0}: red, {3, 3}: red, {4, 0}: blue, {7, 3}: blue}},
+		{"tile", gfx.BackgroundTile, map[image.Point]color.RGBA{{0, 0}: red, {1, 0}: blue, {6, 3}: red, {7, 3}: blue}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := image.NewRGBA(image.Rect(0, 0, 10, 4))
+			paintBackground(dst, dst.Bounds(), geom.NewVec[uint32](0, 0), world, false, false, 1, 1, tc.fit, texture)
+			for p, want := range tc.want {
+				if got := dst.RGBAAt(p.X, p.Y); got != want {
+					t.Errorf("pixel %v = %v, want %v", p, got, want)
+				}
+			}
+			if got := dst.RGBAAt(8, 0); got.A != 0 {
+				t.Errorf("pixel past the bounded world painted: %v", got)
+			}
+		})
+	}
+}
+
+func TestBackgroundUV_StretchedOrTiled(t *testing.T) {
+	bucket := geom.NewAABBAt(geom.NewVec[uint32](32, 0), 32, 32)
+	world := geom.NewVec[uint32](128, 64)
+	size := image.Pt(16, 16)
+	if got, want := backgroundUV(bucket, world, size, gfx.BackgroundStretch), [4]float32{0.25, 0, 0.5, 0.5}; got != want {
+		t.Errorf("stretched uv = %v, want %v", got, want)
+	}
+	if got, want := backgroundUV(bucket, world, size, gfx.BackgroundTile), [4]float32{2, 0, 4, 2}; got != want {
+		t.Errorf("tiled uv = %v, want %v", got, want)
+	}
+}
+
+func TestFitImage_ShrinksToMaxTextureSize(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 2))
+	draw.Draw(img, image.Rect(0, 0, 4, 2), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(4, 0, 8, 2), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	if fitImage(img, 8) != img || fitImage(img, 0) != img {
+		t.Fatal("an image within the limit should be used as is")
+	}
+	fitted := fitImage(img, 4)
+	if got := fitted.Rect.Size(); got != image.Pt(4, 1) {
+		t.Fatalf("fitted size = %v, want 4x1", got)
+	}
+	if fitted.NRGBAAt(1, 0) != red || fitted.NRGBAAt(2, 0) != blue {
+		t.Errorf("fitted pixels = %v, %v; want red then blue", fitted.NRGBAAt(1, 0), fitted.NRGBAAt(2, 0))
+	}
+}
+
+func TestPaintRect_StrokeAndFill(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	dst := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	paintRect(dst, image.Rect(1, 1, 6, 6), gfx.SpatialStyle{Fill: blue, Stroke: red}, [2]float32{0, 1})
+
+	if got := dst.RGBAAt(1, 3); got != red {
+		t.Errorf("border pixel = %v, want stroke", got)
+	}
+	if got := dst.RGBAAt(3, 3); got != blue {
+		t.Errorf("inner pixel = %v, want fill", got)
+	}
+	if got := dst.RGBAAt(6, 6); got.A != 0 {
+		t.Errorf("pixel past the rect painted: %v", got)
+	}
+}
+
+func TestPaintRect_NonRGBAFillIsComposited(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	paintRect(dst, dst.Bounds(), gfx.SpatialStyle{Fill: color.NRGBA{G: 255, A: 128}}, [2]float32{0, 1})
+
+	// NRGBA{0,255,0,128} premultiplies to G=A=0x8080, so over opaque black
+	// the pixel gets half green.
+	if got, want := dst.RGBAAt(1, 1), (color.RGBA{G: 128, A: 255}); got != want {
+		t.Errorf("pixel = %v, want %v", got, want)
+	}
+}
+
+func TestPaintRect_VerticalGradient(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	style := gfx.SpatialStyle{Gradient: &gfx.Gradient{
+		From: color.RGBA{R: 255, A: 255},
+		To:   color.RGBA{B: 255, A: 255},
+		Dir:  gfx.GradientVertical,
+	}}
+
+	paintRect(dst, dst.Bounds(), style, [2]float32{0, 1})
+
+	top, bottom := dst.RGBAAt(0, 0), dst.RGBAAt(0, 3)
+	if top.R <= top.B || bottom.B <= bottom.R {
+		t.Fatalf("gradient not ramping top->bottom: top=%v bottom=%v", top, bottom)
+	}
+	if row := dst.RGBAAt(3, 1); row != dst.RGBAAt(0, 1) {
+		t.Errorf("vertical gradient should be constant along a row")
+	}
+}
+
+func TestGradientSpan_ContinuousAcrossSeam(t *testing.T) {
+	space := plane.NewToroidal2D[uint32](64, 64)
+	shape := space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](56, 0), 16, 4))
+	style := gfx.SpatialStyle{Gradient: &gfx.Gradient{Dir: gfx.GradientHorizontal}}
+
+	base := gradientSpan(&shape, shape.AABB, style)
+	if base != [2]float32{0, 0.5} {
+		t.Errorf("base span = %v, want [0 0.5]", base)
+	}
+	var wrapped [2]float32
+	shape.VisitFragments(func(_ plane.FragPosition, frag geom.AABB[uint32]) bool {
+		wrapped = gradientSpan(&shape, frag, style)
+		return false
+	})
+	if wrapped != [2]float32{0.5, 1} {
+		t.Errorf("wrapped span = %v, want [0.5 1]", wrapped)
+	}
+}
+
+func TestTintRect_HalfGrayOverRed(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+	tintRect(dst, image.Rect(0, 0, 2, 4), colorToFloat(color.RGBA{R: 128, G: 128, B: 128, A: 255}), 0.5)
+
+	if got, want := dst.RGBAAt(1, 1), (color.RGBA{R: 192, G: 64, B: 64, A: 255}); got != want {
+		t.Errorf("tinted pixel = %v, want %v", got, want)
+	}
+	if got := dst.RGBAAt(2, 1); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("pixel outside the rect tinted: %v", got)
+	}
+}
+
+func TestSoftwareRenderer_SnapshotCopiesFrame(t *testing.T) {
+	r := &softwareRenderer{}
+	if r.Snapshot() != nil {
+		t.Fatal("expected no snapshot before the first frame")
+	}
+	r.frame = image.NewRGBA(image.Rect(0, 0, 4, 4))
+	r.frame.SetRGBA(1, 1, color.RGBA{R: 255, A: 255})
+
+	snap := r.Snapshot()
+	if got := snap.RGBAAt(1, 1); got != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("snapshot pixel = %v, want red", got)
+	}
+	snap.SetRGBA(1, 1, color.RGBA{})
+	if got := r.frame.RGBAAt(1, 1); got.R != 255 {
+		t.Fatal("snapshot shares pixels with the live frame")
+	}
+}
+
+func TestSoftwareRenderer_CaptureRectCropsFrame(t *testing.T) {
+	r := &softwareRenderer{}
+	if _, err := r.CaptureRect(nil, image.Rect(0, 0, 2, 2)); err == nil {
+		t.Fatal("expected an error before the first frame")
+	}
+	r.frame = image.NewRGBA(image.Rect(0, 0, 8, 8))
+	r.frame.SetRGBA(5, 6, color.RGBA{B: 255, A: 255})
+
+	img, err := r.CaptureRect(nil, image.Rect(4, 4, 8, 8))
+	if err != nil {
+		t.Fatalf("CaptureRect: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	if got := img.RGBAAt(1, 2); got != (color.RGBA{B: 255, A: 255}) {
+		t.Fatalf("pixel (1,2) = %v, want the frame pixel at (5,6)", got)
+	}
+}
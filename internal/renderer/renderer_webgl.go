@@ -3,7 +3,9 @@
 package renderer
 
 import (
+	"errors"
 	"fmt"
+	"image"
 	"strings"
 	"syscall/js"
 
@@ -13,22 +15,50 @@ import (
 
 type renderer struct {
 	shaderSource string
+	postConfigs  []PostPass
+	debug        bool
+	shrink       InstanceShrinkPolicy
 	gl           js.Value
 	consts       glConsts
 	initialized  bool
+	// initErr is the error of a failed initialization; later calls return
+	// it rather than retrying.
+	initErr error
 
 	colorProgram     js.Value
 	compositeProgram js.Value
 	quadVbo          js.Value
 	compositeVao     js.Value
+	// background draws the single instance of a layer's background image
+	// over one bucket.
+	background *bucketState
 
 	colorViewportUniform     js.Value
 	colorOriginUniform       js.Value
 	colorWorldUniform        js.Value
+	colorWrapUniform         js.Value
+	colorSpriteUniform       js.Value
+	colorPremultUniform      js.Value
 	compositeViewportUniform js.Value
 	compositeRectUniform     js.Value
 	compositeTexUniform      js.Value
 	compositeTexRectUniform  js.Value
+	compositeTintUniform     js.Value
+	compositeStrengthUniform js.Value
+
+	postPasses  []postPassState
+	postTargets [2]*paneState
+	// textureTarget is the RenderToTexture texture; output points at it
+	// while such a frame is drawn and is nil for window frames.
+	textureTarget *paneState
+	output        *paneState
+
+	// caps caches Capabilities once queried.
+	caps *gfx.Caps
+
+	// sprites holds the textures loaded with LoadTexture.
+	sprites    map[gfx.TextureID]js.Value
+	lastSprite gfx.TextureID
 
 	layerStates map[*gfx.Layer]*layerState
 	paneViews   map[*gfx.Pane]uint64
@@ -42,6 +72,15 @@ type layerState struct {
 	width   int
 	height  int
 	buckets map[geom.AABB[uint32]]*bucketState
+	// styleVersion is the Layer.StyleVersion the instances were built at.
+	styleVersion uint64
+	// sprite is the texture of the layer's sprites, bound for its color pass.
+	sprite gfx.TextureID
+	// background is the texture of Layer.BackgroundImage, uploaded at
+	// backgroundVersion; undefined without an image.
+	background        js.Value
+	backgroundSize    image.Point
+	backgroundVersion uint64
 }
 
 type bucketState struct {
@@ -51,6 +90,8 @@ type bucketState struct {
 	entries     []uint64
 	index       map[uint64]int
 	data        []float32
+	// lowFrames counts consecutive frames below the InstanceShrink fraction.
+	lowFrames int
 }
 
 type paneState struct {
@@ -60,6 +101,13 @@ type paneState struct {
 	height  int
 }
 
+type postPassState struct {
+	name            string
+	program         js.Value
+	viewportUniform js.Value
+	texUniform      js.Value
+}
+
 type glConsts struct {
 	arrayBuffer      int
 	staticDraw       int
@@ -76,10 +124,14 @@ type glConsts struct {
 	textureMagFilter int
 	nearest          int
 	clampToEdge      int
+	repeat           int
 	colorBufferBit   int
 	blend            int
 	srcAlpha         int
 	oneMinusSrcAlpha int
+	zero             int
+	one              int
+	dstColor         int
 	compileStatus    int
 	linkStatus       int
 	vertexShader     int
@@ -93,12 +145,18 @@ type glConsts struct {
 func newRenderer(window *gfx.Window, conf RendererConfig, source gfx.FrameSource) *renderer {
 	glAny := window.GLContext()
 	gl, ok := glAny.(js.Value)
+	var initErr error
 	if !ok || gl.IsUndefined() || gl.IsNull() {
-		panic("webgl2 context is required")
+		initErr = errors.New("webgl2 context is required")
 	}
 	return &renderer{
+		initErr:      initErr,
 		shaderSource: conf.ShaderSource,
+		postConfigs:  conf.PostPasses,
+		debug:        conf.Debug,
+		shrink:       conf.InstanceShrink,
 		gl:           gl,
+		sprites:      make(map[gfx.TextureID]js.Value),
 		layerStates:  make(map[*gfx.Layer]*layerState),
 		paneViews:    make(map[*gfx.Pane]uint64),
 		paneStates:   make(map[*gfx.Pane]*paneState),
@@ -106,6 +164,133 @@ func newRenderer(window *gfx.Window, conf RendererConfig, source gfx.FrameSource
 	}
 }
 
+var _ gfx.RectCapturer = (*renderer)(nil)
+
+// CaptureRect recomposites the current frame and reads rect back in the same
+// task, before the browser clears the drawing buffer.
+func (r *renderer) CaptureRect(w *gfx.Window, rect image.Rectangle) (*image.RGBA, error) {
+	if err := r.ensureInit(); err != nil {
+		return nil, err
+	}
+	r.Render(w)
+	if !r.initialized {
+		return nil, errNoFrame
+	}
+	_, height := w.Size()
+	img := newCaptureImage(rect)
+	pixels := js.Global().Get("Uint8Array").New(len(img.Pix))
+	r.gl.Call("bindFramebuffer", r.consts.framebuffer, js.Null())
+	r.gl.Call("readPixels",
+		rect.Min.X, captureOriginY(rect, height),
+		rect.Dx(), rect.Dy(),
+		r.consts.rgba, r.consts.unsignedByte, pixels,
+	)
+	js.CopyBytesToGo(img.Pix, pixels)
+	flipRows(img)
+	return img, nil
+}
+
+var _ gfx.TextureRenderer = (*renderer)(nil)
+
+// RenderToTexture draws a frame into the renderer's offscreen texture instead
+// of the canvas. The returned Texture.Value is the WebGLTexture.
+func (r *renderer) RenderToTexture(w *gfx.Window, width, height int) (gfx.Texture, error) {
+	if err := r.ensureInit(); err != nil {
+		return gfx.Texture{}, err
+	}
+	if limit := r.maxTextureSize(); limit > 0 && (width > limit || height > limit) {
+		return gfx.Texture{}, gfx.ErrTextureSize
+	}
+	if r.textureTarget == nil {
+		state := &paneState{}
+		state.texture = r.gl.Call("createTexture")
+		state.fbo = r.gl.Call("createFramebuffer")
+		r.textureTarget = state
+	}
+	state := r.textureTarget
+	if state.width != width || state.height != height {
+		state.width = width
+		state.height = height
+		r.resizePaneTexture(state)
+	}
+	r.output = state
+	r.Render(w)
+	r.output = nil
+	r.gl.Call("bindFramebuffer", r.consts.framebuffer, js.Null())
+	return gfx.Texture{Value: state.texture, Width: width, Height: height}, nil
+}
+
+var _ gfx.TextureLoader = (*renderer)(nil)
+
+// LoadTexture uploads img to a texture sampled by sprite instances.
+func (r *renderer) LoadTexture(_ *gfx.Window, img image.Image) (gfx.TextureID, error) {
+	if img.Bounds().Empty() {
+		return 0, gfx.ErrTextureSize
+	}
+	if err := r.ensureInit(); err != nil {
+		return 0, err
+	}
+	pix := fitImage(textureImage(img), r.maxTextureSize())
+	texture := r.gl.Call("createTexture")
+	r.gl.Call("bindTexture", r.consts.texture2D, texture)
+	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureMinFilter, r.consts.nearest)
+	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureMagFilter, r.consts.nearest)
+	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureWrapS, r.consts.clampToEdge)
+	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureWrapT, r.consts.clampToEdge)
+	r.gl.Call("texImage2D", r.consts.texture2D, 0, r.consts.rgba8, pix.Rect.Dx(), pix.Rect.Dy(), 0, r.consts.rgba, r.consts.unsignedByte, uint8Array(pix.Pix))
+	r.checkGL("load texture")
+	r.lastSprite++
+	r.sprites[r.lastSprite] = texture
+	return r.lastSprite, nil
+}
+
+var _ gfx.CapsReporter = (*renderer)(nil)
+
+// Capabilities queries the WebGL context once and reports the same caps
+// after. Vendor and Renderer are the unmasked strings when the browser
+// exposes WEBGL_debug_renderer_info.
+func (r *renderer) Capabilities(*gfx.Window) gfx.Caps {
+	if r.caps != nil {
+		return *r.caps
+	}
+	caps := gfx.Caps{Kind: gfx.RendererWebGL}
+	if err := r.ensureInit(); err != nil {
+		return caps
+	}
+	param := func(name string) js.Value {
+		return r.gl.Call("getParameter", r.gl.Get(name))
+	}
+	caps.MaxTextureSize = param("MAX_TEXTURE_SIZE").Int()
+	caps.MaxTextureUnits = param("MAX_TEXTURE_IMAGE_UNITS").Int()
+	caps.MaxSamples = param("MAX_SAMPLES").Int()
+	// Both are core in WebGL2.
+	caps.Instancing = true
+	caps.Framebuffers = true
+	caps.Vendor = param("VENDOR").String()
+	caps.Renderer = param("RENDERER").String()
+	caps.Version = param("VERSION").String()
+	if info := r.gl.Call("getExtension", "WEBGL_debug_renderer_info"); info.Truthy() {
+		caps.Vendor = r.gl.Call("getParameter", info.Get("UNMASKED_VENDOR_WEBGL")).String()
+		caps.Renderer = r.gl.Call("getParameter", info.Get("UNMASKED_RENDERER_WEBGL")).String()
+	}
+	r.caps = &caps
+	return caps
+}
+
+// maxTextureSize returns MAX_TEXTURE_SIZE, or 0 when unknown.
+func (r *renderer) maxTextureSize() int {
+	return r.Capabilities(nil).MaxTextureSize
+}
+
+// outputTarget returns the framebuffer receiving the finished frame and its
+// size: the canvas, or the RenderToTexture texture.
+func (r *renderer) outputTarget(width, height int) (js.Value, int, int) {
+	if r.output != nil {
+		return r.output.fbo, r.output.width, r.output.height
+	}
+	return js.Null(), width, height
+}
+
 func (r *renderer) Render(w *gfx.Window) {
 	if w == nil || r.source == nil {
 		return
@@ -114,13 +299,16 @@ func (r *renderer) Render(w *gfx.Window) {
 	if defaultPane == nil || defaultPane.Config == nil {
 		return
 	}
-	r.ensureInit()
+	if err := r.ensureInit(); err != nil {
+		panic(err)
+	}
 
 	width, height := w.Size()
 	if width <= 0 || height <= 0 {
 		return
 	}
 
+	r.releaseRemovedLayers()
 	panes := w.Panes()
 	for _, pane := range panes {
 		if pane == nil || pane.Config == nil {
@@ -130,7 +318,7 @@ func (r *renderer) Render(w *gfx.Window) {
 		if view == nil {
 			continue
 		}
-		layers := pane.Layers()
+		layers := pane.CompositeLayers()
 		viewVersion := view.Version()
 		prevVersion, ok := r.paneViews[pane]
 		viewChanged := !ok || prevVersion != viewVersion
@@ -145,17 +333,13 @@ func (r *renderer) Render(w *gfx.Window) {
 			}
 			layerPlans[layerPlan.Layer] = layerPlan
 		}
-		worldSize := view.WorldSize()
-		viewSize := view.Size()
-		if !view.Wrap() || viewSize.X >= worldSize.X || viewSize.Y >= worldSize.Y {
-			worldSize = geom.NewVec[uint32](0, 0)
-		}
+		worldSize, wrap := viewWrap(view)
 		for _, layer := range layers {
 			plan, ok := layerPlans[layer]
 			if !ok {
 				continue
 			}
-			r.renderLayerBuckets(layer, plan, worldSize)
+			r.renderLayerBuckets(layer, plan, worldSize, wrap)
 		}
 		if len(frame.CompositeRects) == 0 {
 			continue
@@ -163,8 +347,9 @@ func (r *renderer) Render(w *gfx.Window) {
 		r.compositePane(pane, layers, layerPlans, frame, worldSize)
 	}
 
-	r.gl.Call("bindFramebuffer", r.consts.framebuffer, js.Null())
-	r.gl.Call("viewport", 0, 0, width, height)
+	fbo, viewWidth, viewHeight := r.finalFramebuffer(width, height)
+	r.gl.Call("bindFramebuffer", r.consts.framebuffer, fbo)
+	r.gl.Call("viewport", 0, 0, viewWidth, viewHeight)
 	r.gl.Call("clearColor", 0, 0, 0, 1)
 	r.gl.Call("clear", r.consts.colorBufferBit)
 
@@ -182,22 +367,100 @@ func (r *renderer) Render(w *gfx.Window) {
 		if pane == nil || pane.Config == nil {
 			continue
 		}
-		x0 := float32(pane.Config.OffsetX)
-		y0 := float32(pane.Config.OffsetY)
-		x1 := float32(pane.Config.OffsetX + pane.Config.Width)
-		y1 := float32(pane.Config.OffsetY + pane.Config.Height)
+		// The pane texture is nearest-sampled, so an internal size set
+		// on the pane is upscaled by the integer factor of PresentRect.
+		content := pane.ContentRect()
+		if bars, ok := paneLetterbox(pane, content); ok {
+			r.clearLetterbox(bars, width, height, viewWidth, viewHeight)
+		}
+		x0, y0 := float32(content.Min.X), float32(content.Min.Y)
+		x1, y1 := float32(content.Max.X), float32(content.Max.Y)
 		r.gl.Call("uniform4f", r.compositeRectUniform, x0, y0, x1, y1)
 		r.gl.Call("uniform4f", r.compositeTexRectUniform, 0, 0, 1, 1)
+		tint, strength := paneTint(pane)
+		r.gl.Call("uniform4f", r.compositeTintUniform, tint[0], tint[1], tint[2], tint[3])
+		r.gl.Call("uniform1f", r.compositeStrengthUniform, strength)
 		r.gl.Call("bindTexture", r.consts.texture2D, state.texture)
 		r.gl.Call("drawArrays", r.consts.triangles, 0, 6)
 	}
+
+	r.runPostPasses(width, height)
+	r.checkGL("frame")
+}
+
+// clearLetterbox clears the letterbox of a pane in the final target, whose
+// viewWidth x viewHeight pixels map the width x height window.
+func (r *renderer) clearLetterbox(bars letterbox, width, height, viewWidth, viewHeight int) {
+	scissor := windowScissor(bars.rect, width, height, viewWidth, viewHeight)
+	if scissor.W <= 0 || scissor.H <= 0 {
+		return
+	}
+	c := colorToFloat(bars.color)
+	r.gl.Call("enable", r.consts.scissorTest)
+	r.gl.Call("scissor", scissor.X, scissor.Y, scissor.W, scissor.H)
+	r.gl.Call("clearColor", c[0], c[1], c[2], c[3])
+	r.gl.Call("clear", r.consts.colorBufferBit)
+	r.gl.Call("disable", r.consts.scissorTest)
+}
+
+// finalFramebuffer returns the target of the pane composite and its size: the
+// output target, or the first ping-pong texture when post passes are
+// configured.
+func (r *renderer) finalFramebuffer(width, height int) (js.Value, int, int) {
+	if len(r.postPasses) == 0 {
+		return r.outputTarget(width, height)
+	}
+	for i := range r.postTargets {
+		if r.postTargets[i] == nil {
+			state := &paneState{}
+			state.texture = r.gl.Call("createTexture")
+			state.fbo = r.gl.Call("createFramebuffer")
+			r.postTargets[i] = state
+		}
+		state := r.postTargets[i]
+		if state.width != width || state.height != height {
+			state.width = width
+			state.height = height
+			r.resizePaneTexture(state)
+		}
+	}
+	return r.postTargets[0].fbo, width, height
+}
+
+func (r *renderer) runPostPasses(width, height int) {
+	if len(r.postPasses) == 0 {
+		return
+	}
+	r.gl.Call("disable", r.consts.blend)
+	r.gl.Call("bindVertexArray", r.compositeVao)
+	r.gl.Call("activeTexture", r.consts.texture0)
+	src := 0
+	for i, pass := range r.postPasses {
+		dst, viewWidth, viewHeight := r.outputTarget(width, height)
+		if i < len(r.postPasses)-1 {
+			dst, viewWidth, viewHeight = r.postTargets[1-src].fbo, width, height
+		}
+		r.gl.Call("bindFramebuffer", r.consts.framebuffer, dst)
+		r.gl.Call("viewport", 0, 0, viewWidth, viewHeight)
+		r.gl.Call("useProgram", pass.program)
+		r.gl.Call("uniform2f", pass.viewportUniform, width, height)
+		r.gl.Call("uniform1i", pass.texUniform, 0)
+		r.gl.Call("bindTexture", r.consts.texture2D, r.postTargets[src].texture)
+		r.gl.Call("drawArrays", r.consts.triangles, 0, 6)
+		src = 1 - src
+	}
+	r.gl.Call("enable", r.consts.blend)
 }
 
 func (r *renderer) Close() {
 	if !r.initialized {
 		return
 	}
-	for _, state := range r.layerStates {
+	for layer, state := range r.layerStates {
+		layer.SetInstanceVisitor(nil)
+		r.deleteLayerState(state)
+	}
+	for _, state := range r.paneStates {
 		if state == nil {
 			continue
 		}
@@ -207,19 +470,8 @@ func (r *renderer) Close() {
 		if state.fbo.Truthy() {
 			r.gl.Call("deleteFramebuffer", state.fbo)
 		}
-		for _, bucket := range state.buckets {
-			if bucket == nil {
-				continue
-			}
-			if bucket.instanceVbo.Truthy() {
-				r.gl.Call("deleteBuffer", bucket.instanceVbo)
-			}
-			if bucket.vao.Truthy() {
-				r.gl.Call("deleteVertexArray", bucket.vao)
-			}
-		}
 	}
-	for _, state := range r.paneStates {
+	for _, state := range r.postTargets {
 		if state == nil {
 			continue
 		}
@@ -230,46 +482,152 @@ func (r *renderer) Close() {
 			r.gl.Call("deleteFramebuffer", state.fbo)
 		}
 	}
+	if state := r.textureTarget; state != nil {
+		r.gl.Call("deleteTexture", state.texture)
+		r.gl.Call("deleteFramebuffer", state.fbo)
+	}
+	for _, texture := range r.sprites {
+		r.gl.Call("deleteTexture", texture)
+	}
+	for _, pass := range r.postPasses {
+		if pass.program.Truthy() {
+			r.gl.Call("deleteProgram", pass.program)
+		}
+	}
 	if r.quadVbo.Truthy() {
 		r.gl.Call("deleteBuffer", r.quadVbo)
 	}
 	if r.compositeVao.Truthy() {
 		r.gl.Call("deleteVertexArray", r.compositeVao)
 	}
+	if bg := r.background; bg != nil {
+		r.gl.Call("deleteBuffer", bg.instanceVbo)
+		r.gl.Call("deleteVertexArray", bg.vao)
+		r.background = nil
+	}
 	if r.colorProgram.Truthy() {
 		r.gl.Call("deleteProgram", r.colorProgram)
 	}
 	if r.compositeProgram.Truthy() {
 		r.gl.Call("deleteProgram", r.compositeProgram)
 	}
+	r.sprites = nil
 	r.layerStates = nil
 	r.paneStates = nil
+	r.postPasses = nil
+	r.postTargets = [2]*paneState{}
+	r.textureTarget = nil
+	r.caps = nil
 	r.initialized = false
 }
 
-func (r *renderer) ensureInit() {
-	if r.initialized {
+// deleteLayerState frees the WebGL objects of a layer.
+func (r *renderer) deleteLayerState(state *layerState) {
+	if state == nil {
 		return
 	}
+	if state.texture.Truthy() {
+		r.gl.Call("deleteTexture", state.texture)
+	}
+	if state.fbo.Truthy() {
+		r.gl.Call("deleteFramebuffer", state.fbo)
+	}
+	if state.background.Truthy() {
+		r.gl.Call("deleteTexture", state.background)
+	}
+	for _, bucket := range state.buckets {
+		if bucket == nil {
+			continue
+		}
+		if bucket.instanceVbo.Truthy() {
+			r.gl.Call("deleteBuffer", bucket.instanceVbo)
+		}
+		if bucket.vao.Truthy() {
+			r.gl.Call("deleteVertexArray", bucket.vao)
+		}
+	}
+}
+
+// releaseRemovedLayers frees the state of layers removed from their pane
+// (see gfx.Pane.Clear).
+func (r *renderer) releaseRemovedLayers() {
+	for layer, state := range r.layerStates {
+		if layer.GetPane() == nil {
+			r.deleteLayerState(state)
+			delete(r.layerStates, layer)
+		}
+	}
+}
+
+// webGL2Config is the fixed context of the browser backend.
+var webGL2Config = gfx.GLContextConfig{API: gfx.GLAPIOpenGLES, Major: 3}
+
+var _ gfx.RendererInitializer = (*renderer)(nil)
+
+// Init compiles the shaders and creates the WebGL objects. gfx.NewWindowE
+// calls it; otherwise the first Render does, panicking on failure.
+func (r *renderer) Init(*gfx.Window) error {
+	return r.ensureInit()
+}
+
+func (r *renderer) ensureInit() error {
+	if r.initialized {
+		return nil
+	}
+	if r.initErr == nil {
+		r.initErr = r.initGL()
+	}
+	return r.initErr
+}
+
+func (r *renderer) initGL() error {
 	r.initConsts()
+	source, err := webGL2Config.StripShaderVersion(r.shaderSource)
+	if err != nil {
+		return err
+	}
+	r.shaderSource = source
 
-	r.colorProgram = r.buildProgram("PASS_COLOR")
-	r.compositeProgram = r.buildProgram("PASS_COMPOSITE")
+	if r.colorProgram, err = r.buildProgram("PASS_COLOR"); err != nil {
+		return err
+	}
+	if r.compositeProgram, err = r.buildProgram("PASS_COMPOSITE"); err != nil {
+		return err
+	}
+	for _, conf := range r.postConfigs {
+		program, err := r.buildProgram(append([]string{"PASS_POST"}, conf.Defines...)...)
+		if err != nil {
+			return fmt.Errorf("post pass %q: %w", conf.Name, err)
+		}
+		r.postPasses = append(r.postPasses, postPassState{
+			name:            conf.Name,
+			program:         program,
+			viewportUniform: r.gl.Call("getUniformLocation", program, "uViewport"),
+			texUniform:      r.gl.Call("getUniformLocation", program, "uTex"),
+		})
+	}
 
 	r.colorViewportUniform = r.gl.Call("getUniformLocation", r.colorProgram, "uViewport")
 	r.colorOriginUniform = r.gl.Call("getUniformLocation", r.colorProgram, "uOrigin")
 	r.colorWorldUniform = r.gl.Call("getUniformLocation", r.colorProgram, "uWorld")
+	r.colorWrapUniform = r.gl.Call("getUniformLocation", r.colorProgram, "uWrap")
+	r.colorSpriteUniform = r.gl.Call("getUniformLocation", r.colorProgram, "uSprite")
+	r.colorPremultUniform = r.gl.Call("getUniformLocation", r.colorProgram, "uPremultiply")
 	r.compositeViewportUniform = r.gl.Call("getUniformLocation", r.compositeProgram, "uViewport")
 	r.compositeRectUniform = r.gl.Call("getUniformLocation", r.compositeProgram, "uRect")
 	r.compositeTexUniform = r.gl.Call("getUniformLocation", r.compositeProgram, "uTex")
 	r.compositeTexRectUniform = r.gl.Call("getUniformLocation", r.compositeProgram, "uTexRect")
+	r.compositeTintUniform = r.gl.Call("getUniformLocation", r.compositeProgram, "uTint")
+	r.compositeStrengthUniform = r.gl.Call("getUniformLocation", r.compositeProgram, "uTintStrength")
 
 	r.initQuad()
+	r.checkGL("init")
 
 	r.gl.Call("enable", r.consts.blend)
 	r.gl.Call("blendFunc", r.consts.srcAlpha, r.consts.oneMinusSrcAlpha)
 
 	r.initialized = true
+	return nil
 }
 
 func (r *renderer) initConsts() {
@@ -289,10 +647,14 @@ func (r *renderer) initConsts() {
 		textureMagFilter: r.gl.Get("TEXTURE_MAG_FILTER").Int(),
 		nearest:          r.gl.Get("NEAREST").Int(),
 		clampToEdge:      r.gl.Get("CLAMP_TO_EDGE").Int(),
+		repeat:           r.gl.Get("REPEAT").Int(),
 		colorBufferBit:   r.gl.Get("COLOR_BUFFER_BIT").Int(),
 		blend:            r.gl.Get("BLEND").Int(),
 		srcAlpha:         r.gl.Get("SRC_ALPHA").Int(),
 		oneMinusSrcAlpha: r.gl.Get("ONE_MINUS_SRC_ALPHA").Int(),
+		zero:             r.gl.Get("ZERO").Int(),
+		one:              r.gl.Get("ONE").Int(),
+		dstColor:         r.gl.Get("DST_COLOR").Int(),
 		compileStatus:    r.gl.Get("COMPILE_STATUS").Int(),
 		linkStatus:       r.gl.Get("LINK_STATUS").Int(),
 		vertexShader:     r.gl.Get("VERTEX_SHADER").Int(),
@@ -323,9 +685,15 @@ func (r *renderer) initQuad() {
 	r.gl.Call("bindBuffer", r.consts.arrayBuffer, r.quadVbo)
 	r.gl.Call("enableVertexAttribArray", 0)
 	r.gl.Call("vertexAttribPointer", 0, 2, r.consts.floatType, false, 2*4, 0)
+
+	r.background = &bucketState{
+		vao:         r.gl.Call("createVertexArray"),
+		instanceVbo: r.gl.Call("createBuffer"),
+	}
+	r.setupBucketVAO(r.background)
 }
 
-func (r *renderer) renderLayerBuckets(layer *gfx.Layer, plan gfx.LayerPlan, worldSize geom.Vec[uint32]) {
+func (r *renderer) renderLayerBuckets(layer *gfx.Layer, plan gfx.LayerPlan, worldSize geom.Vec[uint32], wrap bool) {
 	if layer == nil || r.source == nil {
 		return
 	}
@@ -338,30 +706,48 @@ func (r *renderer) renderLayerBuckets(layer *gfx.Layer, plan gfx.LayerPlan, worl
 	if cacheWidth <= 0 || cacheHeight <= 0 {
 		return
 	}
-	state := r.ensureLayerState(layer, cacheWidth, cacheHeight)
+	scaleX, scaleY := layer.GetPane().RenderScale()
+	// A cache rect too large for one texture renders at a lower resolution.
+	fit, width, height := fitTexture(scaledSize(cacheWidth, scaleX), scaledSize(cacheHeight, scaleY), r.maxTextureSize())
+	scaleX, scaleY = scaleX*fit, scaleY*fit
+	state := r.ensureLayerState(layer, width, height)
 	r.syncBucketStates(layer, state)
+	r.shrinkBuckets(state)
+	r.restyleBuckets(layer, state)
+	r.syncBackground(layer, state)
 	if plan.BucketRect == nil || len(plan.BucketIndices) == 0 {
 		return
 	}
 	bgColor := colorToFloat(layer.Background())
+	// worldSize is zero on a bounded world; a stretched image needs its size.
+	world := layer.GetPane().Viewport().WorldSize()
 
 	r.gl.Call("bindFramebuffer", r.consts.framebuffer, state.fbo)
 	r.gl.Call("viewport", 0, 0, state.width, state.height)
 	r.gl.Call("useProgram", r.colorProgram)
-	r.gl.Call("uniform2f", r.colorViewportUniform, float32(state.width), float32(state.height))
+	r.gl.Call("uniform2f", r.colorViewportUniform, float32(cacheWidth), float32(cacheHeight))
 	r.gl.Call("uniform2f", r.colorOriginUniform, float32(cacheRect.TopLeft.X), float32(cacheRect.TopLeft.Y))
 	r.gl.Call("uniform2f", r.colorWorldUniform, float32(worldSize.X), float32(worldSize.Y))
+	r.gl.Call("uniform1i", r.colorWrapUniform, boolToInt32(wrap))
+	r.gl.Call("activeTexture", r.consts.texture0)
+	r.gl.Call("bindTexture", r.consts.texture2D, r.spriteTexture(state.sprite))
+	r.gl.Call("uniform1i", r.colorSpriteUniform, 0)
+	blend := layer.ColorBlendMode()
+	r.setColorBlend(blend)
 	r.gl.Call("enable", r.consts.scissorTest)
 
 	for _, idx := range plan.BucketIndices {
 		bucket := plan.BucketRect(idx)
-		scissor := bucketScissor(bucket, cacheRect, worldSize, state.width, state.height)
+		scissor := bucketScissor(bucket, cacheRect, worldSize, scaleX, scaleY, state.height)
 		if scissor.W <= 0 || scissor.H <= 0 {
 			continue
 		}
 		r.gl.Call("scissor", scissor.X, scissor.Y, scissor.W, scissor.H)
 		r.gl.Call("clearColor", bgColor[0], bgColor[1], bgColor[2], bgColor[3])
 		r.gl.Call("clear", r.consts.colorBufferBit)
+		if state.background.Truthy() {
+			r.drawBackground(state, bucket, world, layer.BackgroundFit(), blend)
+		}
 
 		bucketState := state.buckets[bucket]
 		if bucketState == nil || len(bucketState.entries) == 0 {
@@ -372,14 +758,103 @@ func (r *renderer) renderLayerBuckets(layer *gfx.Layer, plan gfx.LayerPlan, worl
 	}
 
 	r.gl.Call("disable", r.consts.scissorTest)
+	if blend != gfx.BlendAlpha {
+		r.setColorBlend(gfx.BlendAlpha)
+	}
 	r.source.AcknowledgeRendered(layer, plan.BucketIndices)
 }
 
+// syncBackground uploads the layer's background image after it changed, or
+// frees its texture once removed. A tiled image repeats past its edges.
+func (r *renderer) syncBackground(layer *gfx.Layer, state *layerState) {
+	version := layer.BackgroundVersion()
+	if state.backgroundVersion == version {
+		return
+	}
+	state.backgroundVersion = version
+	img := layer.BackgroundImage()
+	if img == nil {
+		if state.background.Truthy() {
+			r.gl.Call("deleteTexture", state.background)
+			state.background = js.Undefined()
+		}
+		return
+	}
+	pix := fitImage(textureImage(img), r.maxTextureSize())
+	if !state.background.Truthy() {
+		state.background = r.gl.Call("createTexture")
+	}
+	wrap := r.consts.clampToEdge
+	if layer.BackgroundFit() == gfx.BackgroundTile {
+		wrap = r.consts.repeat
+	}
+	r.gl.Call("bindTexture", r.consts.texture2D, state.background)
+	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureMinFilter, r.consts.nearest)
+	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureMagFilter, r.consts.nearest)
+	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureWrapS, wrap)
+	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureWrapT, wrap)
+	r.gl.Call("texImage2D", r.consts.texture2D, 0, r.consts.rgba8, pix.Rect.Dx(), pix.Rect.Dy(), 0, r.consts.rgba, r.consts.unsignedByte, uint8Array(pix.Pix))
+	// Tiles repeat every image pixel in world units, even when fitted.
+	state.backgroundSize = img.Bounds().Size()
+	r.checkGL("background upload")
+}
+
+// drawBackground draws the layer's background image over bucket with alpha
+// blending, whatever the layer's blend mode, then rebinds the sprite texture.
+func (r *renderer) drawBackground(state *layerState, bucket geom.AABB[uint32], world geom.Vec[uint32], fit gfx.BackgroundFit, blend gfx.BlendMode) {
+	data := appendBackgroundInstance(make([]float32, 0, floatsPerInstance), bucket, backgroundUV(bucket, world, state.backgroundSize, fit))
+	r.gl.Call("bindBuffer", r.consts.arrayBuffer, r.background.instanceVbo)
+	r.gl.Call("bufferData", r.consts.arrayBuffer, float32Array(data), r.consts.dynamicDraw)
+	if blend != gfx.BlendAlpha {
+		r.setColorBlend(gfx.BlendAlpha)
+	}
+	r.gl.Call("bindTexture", r.consts.texture2D, state.background)
+	r.gl.Call("bindVertexArray", r.background.vao)
+	r.gl.Call("drawArraysInstanced", r.consts.triangles, 0, 6, 1)
+	r.gl.Call("bindTexture", r.consts.texture2D, r.spriteTexture(state.sprite))
+	if blend != gfx.BlendAlpha {
+		r.setColorBlend(blend)
+	}
+}
+
+// setColorBlend sets the blend function of the color pass and tells the
+// shader whether to premultiply its output.
+func (r *renderer) setColorBlend(blend gfx.BlendMode) {
+	src, dst := blendFactors(blend)
+	r.gl.Call("blendFunc", r.blendFactor(src), r.blendFactor(dst))
+	r.gl.Call("uniform1i", r.colorPremultUniform, boolToInt32(premultipliedSource(blend)))
+}
+
+// spriteTexture returns the texture loaded as id, or null to unbind.
+func (r *renderer) spriteTexture(id gfx.TextureID) js.Value {
+	if texture, ok := r.sprites[id]; ok {
+		return texture
+	}
+	return js.Null()
+}
+
+func (r *renderer) blendFactor(f blendFactor) int {
+	switch f {
+	case factorZero:
+		return r.consts.zero
+	case factorOne:
+		return r.consts.one
+	case factorOneMinusSrcAlpha:
+		return r.consts.oneMinusSrcAlpha
+	case factorDstColor:
+		return r.consts.dstColor
+	default:
+		return r.consts.srcAlpha
+	}
+}
+
 func (r *renderer) compositePane(pane *gfx.Pane, layers []*gfx.Layer, layerPlans map[*gfx.Layer]gfx.LayerPlan, frame gfx.FramePlan, worldSize geom.Vec[uint32]) {
 	if pane == nil || pane.Config == nil {
 		return
 	}
-	state := r.ensurePaneState(pane, pane.Config.Width, pane.Config.Height)
+	paneWidth, paneHeight := pane.RenderSize()
+	fit, paneWidth, paneHeight := fitTexture(paneWidth, paneHeight, r.maxTextureSize())
+	state := r.ensurePaneState(pane, paneWidth, paneHeight)
 	if state == nil || state.texture.IsUndefined() || state.texture.IsNull() {
 		return
 	}
@@ -395,10 +870,13 @@ func (r *renderer) compositePane(pane *gfx.Pane, layers []*gfx.Layer, layerPlans
 	r.gl.Call("activeTexture", r.consts.texture0)
 	r.gl.Call("uniform1i", r.compositeTexUniform, 0)
 	r.gl.Call("uniform4f", r.compositeRectUniform, 0, 0, float32(state.width), float32(state.height))
+	r.gl.Call("uniform1f", r.compositeStrengthUniform, 0)
 
 	r.gl.Call("enable", r.consts.scissorTest)
+	scaleX, scaleY := pane.RenderScale()
+	scaleX, scaleY = scaleX*fit, scaleY*fit
 	for _, rect := range frame.CompositeRects {
-		scissor := paneScissor(rect, state.height)
+		scissor := paneScissor(rect, scaleX, scaleY, state.height)
 		if scissor.W <= 0 || scissor.H <= 0 {
 			continue
 		}
@@ -414,7 +892,7 @@ func (r *renderer) compositePane(pane *gfx.Pane, layers []*gfx.Layer, layerPlans
 			if layerState == nil || layerState.texture.IsUndefined() || layerState.texture.IsNull() {
 				continue
 			}
-			uv := texRect(frame.ViewRect, plan.CacheRect, worldSize)
+			uv := texRect(frame.LayerViewRect(plan), plan.CacheRect, worldSize)
 			r.gl.Call("uniform4f", r.compositeTexRectUniform, uv[0], uv[1], uv[2], uv[3])
 			r.gl.Call("bindTexture", r.consts.texture2D, layerState.texture)
 			r.gl.Call("drawArrays", r.consts.triangles, 0, 6)
@@ -441,6 +919,9 @@ func (r *renderer) ensurePaneState(pane *gfx.Pane, width, height int) *paneState
 
 func (r *renderer) resizePaneTexture(state *paneState) {
 	if state.width <= 0 || state.height <= 0 {
+		if r.debug {
+			debugf("pane texture %dx%d: empty size, framebuffer left incomplete", state.width, state.height)
+		}
 		return
 	}
 	r.gl.Call("bindTexture", r.consts.texture2D, state.texture)
@@ -452,53 +933,39 @@ func (r *renderer) resizePaneTexture(state *paneState) {
 
 	r.gl.Call("bindFramebuffer", r.consts.framebuffer, state.fbo)
 	r.gl.Call("framebufferTexture2D", r.consts.framebuffer, r.consts.colorAttachment0, r.consts.texture2D, state.texture, 0)
+	r.checkFramebuffer("pane texture", state.width, state.height)
 }
 
-type scissorRect struct {
-	X int
-	Y int
-	W int
-	H int
-}
-
-func bucketScissor(bucket, cacheRect geom.AABB[uint32], worldSize geom.Vec[uint32], cacheWidth, cacheHeight int) scissorRect {
-	origin := cacheRect.TopLeft
-	x0 := unwrapCoord(bucket.TopLeft.X, origin.X, worldSize.X)
-	x1 := unwrapCoord(bucket.BottomRight.X, origin.X, worldSize.X)
-	y0 := unwrapCoord(bucket.TopLeft.Y, origin.Y, worldSize.Y)
-	y1 := unwrapCoord(bucket.BottomRight.Y, origin.Y, worldSize.Y)
-	if worldSize.X > 0 && x1 < x0 {
-		x1 += worldSize.X
-	}
-	if worldSize.Y > 0 && y1 < y0 {
-		y1 += worldSize.Y
+// checkGL drains and logs pending WebGL errors in debug mode.
+func (r *renderer) checkGL(op string) {
+	if !r.debug {
+		return
 	}
-	localX0 := int(x0 - origin.X)
-	localX1 := int(x1 - origin.X)
-	localY0 := int(y0 - origin.Y)
-	localY1 := int(y1 - origin.Y)
-	return scissorRect{
-		X: localX0,
-		Y: cacheHeight - localY1,
-		W: localX1 - localX0,
-		H: localY1 - localY0,
+	for i := 0; i < maxErrorsPerCheck; i++ {
+		code := uint32(r.gl.Call("getError").Int())
+		if code == glNoError {
+			return
+		}
+		debugf("%s: %s", op, glErrorName(code))
 	}
 }
 
-func paneScissor(rect geom.AABB[uint32], paneHeight int) scissorRect {
-	return scissorRect{
-		X: int(rect.TopLeft.X),
-		Y: paneHeight - int(rect.BottomRight.Y),
-		W: int(rect.BottomRight.X - rect.TopLeft.X),
-		H: int(rect.BottomRight.Y - rect.TopLeft.Y),
+// checkFramebuffer reports an incomplete bound framebuffer in debug mode.
+func (r *renderer) checkFramebuffer(op string, width, height int) {
+	if !r.debug {
+		return
+	}
+	status := uint32(r.gl.Call("checkFramebufferStatus", r.consts.framebuffer).Int())
+	if status != glFramebufferComplete {
+		debugf("%s %dx%d: %s", op, width, height, framebufferStatusName(status))
 	}
 }
 
-func unwrapCoord(value, origin, worldSize uint32) uint32 {
-	if worldSize > 0 && value < origin {
-		return value + worldSize
+func boolToInt32(v bool) int32 {
+	if v {
+		return 1
 	}
-	return value
+	return 0
 }
 
 func texRect(viewRect, cacheRect geom.AABB[uint32], worldSize geom.Vec[uint32]) [4]float32 {
@@ -538,6 +1005,7 @@ func (r *renderer) ensureLayerState(layer *gfx.Layer, width, height int) *layerS
 		state.texture = r.gl.Call("createTexture")
 		state.fbo = r.gl.Call("createFramebuffer")
 		r.layerStates[layer] = state
+		layer.SetInstanceVisitor(r)
 	}
 	if state.width != width || state.height != height {
 		state.width = width
@@ -548,6 +1016,9 @@ func (r *renderer) ensureLayerState(layer *gfx.Layer, width, height int) *layerS
 }
 
 func (r *renderer) resizeLayerTexture(state *layerState) {
+	if r.debug && (state.width <= 0 || state.height <= 0) {
+		debugf("layer texture %dx%d: empty size, framebuffer will be incomplete", state.width, state.height)
+	}
 	r.gl.Call("bindTexture", r.consts.texture2D, state.texture)
 	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureMinFilter, r.consts.nearest)
 	r.gl.Call("texParameteri", r.consts.texture2D, r.consts.textureMagFilter, r.consts.nearest)
@@ -557,6 +1028,7 @@ func (r *renderer) resizeLayerTexture(state *layerState) {
 
 	r.gl.Call("bindFramebuffer", r.consts.framebuffer, state.fbo)
 	r.gl.Call("framebufferTexture2D", r.consts.framebuffer, r.consts.colorAttachment0, r.consts.texture2D, state.texture, 0)
+	r.checkFramebuffer("layer texture", state.width, state.height)
 }
 
 func (r *renderer) syncBucketStates(layer *gfx.Layer, state *layerState) {
@@ -590,6 +1062,49 @@ func (r *renderer) syncBucketStates(layer *gfx.Layer, state *layerState) {
 			r.uploadBucketUpdates(bucket, updates)
 		}
 	}
+	r.checkGL("instance upload")
+}
+
+var _ gfx.InstanceVisitor = (*renderer)(nil)
+
+// VisitInstances reports the instance data cached for layer; see
+// gfx.Layer.ForEachInstance.
+func (r *renderer) VisitInstances(layer *gfx.Layer, fn gfx.InstanceFunc) {
+	state := r.layerStates[layer]
+	if state == nil {
+		return
+	}
+	seen := make(map[uint64]struct{})
+	for _, bucket := range state.buckets {
+		if bucket != nil {
+			visitBucketInstances(layer, bucket.entries, bucket.data, seen, fn)
+		}
+	}
+}
+
+// restyleBuckets rebuilds the instance data of every entry after
+// Layer.MarkDirty, so style changes that moved nothing reach the GPU.
+func (r *renderer) restyleBuckets(layer *gfx.Layer, state *layerState) {
+	version := layer.StyleVersion()
+	if state.styleVersion == version {
+		return
+	}
+	state.styleVersion = version
+	scratch := make([]float32, 0, floatsPerInstance)
+	for _, bucket := range state.buckets {
+		if bucket == nil {
+			continue
+		}
+		for idx, entryID := range bucket.entries {
+			data, ok := r.bucketEntryData(layer, entryID, scratch)
+			start := idx * floatsPerInstance
+			if !ok || start+floatsPerInstance > len(bucket.data) {
+				continue
+			}
+			copy(bucket.data[start:start+floatsPerInstance], data)
+		}
+		r.uploadBucketFull(bucket)
+	}
 }
 
 func (r *renderer) ensureBucketState(state *layerState, bucketRect geom.AABB[uint32]) *bucketState {
@@ -619,15 +1134,11 @@ func (r *renderer) setupBucketVAO(bucket *bucketState) {
 
 	r.gl.Call("bindBuffer", r.consts.arrayBuffer, bucket.instanceVbo)
 	stride := floatsPerInstance * 4
-	r.gl.Call("enableVertexAttribArray", 1)
-	r.gl.Call("vertexAttribPointer", 1, 4, r.consts.floatType, false, stride, 0)
-	r.gl.Call("vertexAttribDivisor", 1, 1)
-	r.gl.Call("enableVertexAttribArray", 2)
-	r.gl.Call("vertexAttribPointer", 2, 4, r.consts.floatType, false, stride, 4*4)
-	r.gl.Call("vertexAttribDivisor", 2, 1)
-	r.gl.Call("enableVertexAttribArray", 3)
-	r.gl.Call("vertexAttribPointer", 3, 4, r.consts.floatType, false, stride, 8*4)
-	r.gl.Call("vertexAttribDivisor", 3, 1)
+	forEachInstanceAttribute(func(attr instanceAttribute, offset int) {
+		r.gl.Call("enableVertexAttribArray", attr.location)
+		r.gl.Call("vertexAttribPointer", attr.location, attr.size, r.consts.floatType, false, stride, offset*4)
+		r.gl.Call("vertexAttribDivisor", attr.location, 1)
+	})
 }
 
 func (r *renderer) bucketEntryData(layer *gfx.Layer, entryID uint64, scratch []float32) ([]float32, bool) {
@@ -638,12 +1149,17 @@ func (r *renderer) bucketEntryData(layer *gfx.Layer, entryID uint64, scratch []f
 	if !ok {
 		return scratch, false
 	}
-	drawable := layer.DrawableByID(entryID >> 2)
+	drawable := layer.DrawableByEntryID(entryID)
 	if drawable == nil {
 		return scratch, false
 	}
+	if texture := drawable.EffectiveStyle().Texture; texture != 0 {
+		if state := r.layerStates[layer]; state != nil {
+			state.sprite = texture
+		}
+	}
 	scratch = scratch[:0]
-	scratch = appendAABBInstance(scratch, frag, drawable.Style)
+	scratch = appendEntryInstance(scratch, r.source, layer, entryID, frag, drawable)
 	if len(scratch) != floatsPerInstance {
 		return scratch, false
 	}
@@ -747,6 +1263,25 @@ func (r *renderer) ensureBucketCapacity(bucket *bucketState, required int) bool
 	return true
 }
 
+// shrinkBuckets reallocates the instance buffers the InstanceShrink policy
+// deems oversized and re-uploads their instances.
+func (r *renderer) shrinkBuckets(state *layerState) {
+	if !r.shrink.enabled() {
+		return
+	}
+	for _, bucket := range state.buckets {
+		required := len(bucket.entries) * floatsPerInstance * 4
+		newCap := r.shrink.shrinkCap(required, bucket.instanceCap, &bucket.lowFrames)
+		if newCap == 0 {
+			continue
+		}
+		r.gl.Call("bindBuffer", r.consts.arrayBuffer, bucket.instanceVbo)
+		r.gl.Call("bufferData", r.consts.arrayBuffer, newCap, r.consts.dynamicDraw)
+		bucket.instanceCap = newCap
+		r.uploadBucketFull(bucket)
+	}
+}
+
 func (r *renderer) uploadBucketFull(bucket *bucketState) {
 	if len(bucket.data) == 0 {
 		return
@@ -771,12 +1306,19 @@ func (r *renderer) uploadBucketUpdates(bucket *bucketState, updates []int) {
 	}
 }
 
-func (r *renderer) buildProgram(pass string) js.Value {
-	vertexSource := r.buildShaderSource("VERTEX", pass)
-	fragmentSource := r.buildShaderSource("FRAGMENT", pass)
+func (r *renderer) buildProgram(defines ...string) (js.Value, error) {
+	vertexSource := r.buildShaderSource("VERTEX", defines...)
+	fragmentSource := r.buildShaderSource("FRAGMENT", defines...)
 
-	vertexShader := r.compileShader(r.consts.vertexShader, vertexSource)
-	fragmentShader := r.compileShader(r.consts.fragmentShader, fragmentSource)
+	vertexShader, err := r.compileShader(r.consts.vertexShader, vertexSource)
+	if err != nil {
+		return js.Null(), err
+	}
+	fragmentShader, err := r.compileShader(r.consts.fragmentShader, fragmentSource)
+	if err != nil {
+		r.gl.Call("deleteShader", vertexShader)
+		return js.Null(), err
+	}
 
 	program := r.gl.Call("createProgram")
 	r.gl.Call("attachShader", program, vertexShader)
@@ -785,32 +1327,38 @@ func (r *renderer) buildProgram(pass string) js.Value {
 
 	if !r.gl.Call("getProgramParameter", program, r.consts.linkStatus).Bool() {
 		log := r.gl.Call("getProgramInfoLog", program).String()
-		panic(fmt.Errorf("link error: %s", log))
+		r.gl.Call("deleteShader", vertexShader)
+		r.gl.Call("deleteShader", fragmentShader)
+		r.gl.Call("deleteProgram", program)
+		return js.Null(), fmt.Errorf("link error: %s", log)
 	}
 
 	r.gl.Call("deleteShader", vertexShader)
 	r.gl.Call("deleteShader", fragmentShader)
-	return program
+	r.checkGL("build program " + strings.Join(defines, ","))
+	return program, nil
 }
 
-func (r *renderer) compileShader(shaderType int, source string) js.Value {
+func (r *renderer) compileShader(shaderType int, source string) (js.Value, error) {
 	shader := r.gl.Call("createShader", shaderType)
 	r.gl.Call("shaderSource", shader, source)
 	r.gl.Call("compileShader", shader)
 	if !r.gl.Call("getShaderParameter", shader, r.consts.compileStatus).Bool() {
 		log := r.gl.Call("getShaderInfoLog", shader).String()
-		panic(fmt.Errorf("compile error: %s", log))
+		r.gl.Call("deleteShader", shader)
+		return js.Null(), fmt.Errorf("compile error: %s", log)
 	}
-	return shader
+	return shader, nil
 }
 
-func (r *renderer) buildShaderSource(stage, pass string) string {
+func (r *renderer) buildShaderSource(stage string, defines ...string) string {
 	var sb strings.Builder
-	sb.WriteString("#version 300 es\n")
-	sb.WriteString("precision highp float;\n")
-	sb.WriteString("precision highp int;\n")
+	sb.WriteString(webGL2Config.ShaderHeader())
 	sb.WriteString("#define " + stage + "\n")
-	sb.WriteString("#define " + pass + "\n")
+	for _, define := range defines {
+		sb.WriteString("#define " + define + "\n")
+	}
+	sb.WriteString("#define INSTANCE_ATTRIBUTES " + instanceAttributeDecls() + "\n")
 	sb.WriteString(r.shaderSource)
 	if !strings.HasSuffix(r.shaderSource, "\n") {
 		sb.WriteString("\n")
@@ -0,0 +1,102 @@
+package renderer
+
+import (
+	"image"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokx/pkg/gfx"
+)
+
+// scissorRect is a GL scissor box: origin at the bottom-left, in pixels.
+type scissorRect struct {
+	X int
+	Y int
+	W int
+	H int
+}
+
+// bucketScissor returns the scissor of bucket inside a layer texture that
+// covers cacheRect at scaleX/scaleY pixels per world unit; texHeight is the
+// texture height in pixels. Buckets left of (above) the cache origin are
+// unwrapped by one world size, matching the color shader.
+func bucketScissor(bucket, cacheRect geom.AABB[uint32], worldSize geom.Vec[uint32], scaleX, scaleY float64, texHeight int) scissorRect {
+	origin := cacheRect.TopLeft
+	x0 := unwrapCoord(bucket.TopLeft.X, origin.X, worldSize.X)
+	x1 := unwrapCoord(bucket.BottomRight.X, origin.X, worldSize.X)
+	y0 := unwrapCoord(bucket.TopLeft.Y, origin.Y, worldSize.Y)
+	y1 := unwrapCoord(bucket.BottomRight.Y, origin.Y, worldSize.Y)
+	if worldSize.X > 0 && x1 < x0 {
+		x1 += worldSize.X
+	}
+	if worldSize.Y > 0 && y1 < y0 {
+		y1 += worldSize.Y
+	}
+	return glScissor(
+		int(x0)-int(origin.X), int(y0)-int(origin.Y),
+		int(x1)-int(origin.X), int(y1)-int(origin.Y),
+		scaleX, scaleY, texHeight,
+	)
+}
+
+// paneScissor returns the scissor of a view-local rect (world units) inside a
+// pane texture of paneHeight pixels.
+func paneScissor(rect geom.AABB[uint32], scaleX, scaleY float64, paneHeight int) scissorRect {
+	return glScissor(
+		int(rect.TopLeft.X), int(rect.TopLeft.Y),
+		int(rect.BottomRight.X), int(rect.BottomRight.Y),
+		scaleX, scaleY, paneHeight,
+	)
+}
+
+// windowScissor returns the scissor of a window rect inside a target of
+// viewWidth x viewHeight pixels that the width x height window is mapped to.
+func windowScissor(rect image.Rectangle, width, height, viewWidth, viewHeight int) scissorRect {
+	sx, sy := 1.0, 1.0
+	if width > 0 && height > 0 {
+		sx = float64(viewWidth) / float64(width)
+		sy = float64(viewHeight) / float64(height)
+	}
+	return glScissor(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y, sx, sy, viewHeight)
+}
+
+// glScissor scales the top-down box [x0,x1)x[y0,y1) from world units to
+// pixels and flips it into GL's bottom-up space. Every edge is scaled on its
+// own and the flip uses the real target height in pixels, so boxes sharing an
+// edge in world units share it exactly in pixels: no seams, no overlap.
+func glScissor(x0, y0, x1, y1 int, scaleX, scaleY float64, height int) scissorRect {
+	px0, px1 := scaledSize(x0, scaleX), scaledSize(x1, scaleX)
+	py0, py1 := scaledSize(y0, scaleY), scaledSize(y1, scaleY)
+	return scissorRect{
+		X: px0,
+		Y: height - py1,
+		W: px1 - px0,
+		H: py1 - py0,
+	}
+}
+
+func unwrapCoord(value, origin, worldSize uint32) uint32 {
+	if worldSize > 0 && value < origin {
+		return value + worldSize
+	}
+	return value
+}
+
+// viewWrap returns the world size the renderers unwrap view coordinates by:
+// the world size on each wrapping axis and zero on the others. wrap is false,
+// and the size zero, when no axis wraps or the view covers the world on a
+// wrapping axis, as the cache then holds the world without seams.
+func viewWrap(view *gfx.Viewport) (geom.Vec[uint32], bool) {
+	world := view.WorldSize()
+	size := view.Size()
+	wrapX, wrapY := view.WrapAxes()
+	if !wrapX && !wrapY || wrapX && size.X >= world.X || wrapY && size.Y >= world.Y {
+		return geom.Vec[uint32]{}, false
+	}
+	if !wrapX {
+		world.X = 0
+	}
+	if !wrapY {
+		world.Y = 0
+	}
+	return world, true
+}
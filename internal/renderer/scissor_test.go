@@ -0,0 +1,133 @@
+package renderer
+
+import (
+	"image"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokx/pkg/gfx"
+)
+
+// assertExactCoverage paints every scissor into a width x height grid and fails on any
+// pixel painted zero times (a seam) or more than once (an overlap).
+func assertExactCoverage(t *testing.T, name string, width, height int, scissors []scissorRect) {
+	t.Helper()
+	hits := make([]int, width*height)
+	for _, s := range scissors {
+		for y := max(s.Y, 0); y < min(s.Y+s.H, height); y++ {
+			for x := max(s.X, 0); x < min(s.X+s.W, width); x++ {
+				hits[y*width+x]++
+			}
+		}
+	}
+	for i, n := range hits {
+		if n != 1 {
+			t.Fatalf("%s: pixel (%d,%d) painted %d times", name, i%width, i/width, n)
+		}
+	}
+}
+
+func TestBucketScissor_SolidCoverage(t *testing.T) {
+	const bucketSize = 32
+	world := geom.NewVec[uint32](256, 256)
+	scales := []float64{1, 2, 1.5, 0.75, 1.37}
+	// Cache origins are bucket aligned; 192 and 224 put the toroidal seam
+	// inside the cache.
+	origins := []geom.Vec[uint32]{{X: 0, Y: 0}, {X: 64, Y: 32}, {X: 192, Y: 224}, {X: 224, Y: 160}}
+
+	for _, scale := range scales {
+		for _, origin := range origins {
+			cacheW, cacheH := uint32(5*bucketSize), uint32(3*bucketSize)
+			cacheRect := geom.NewAABBAt(origin, cacheW, cacheH)
+			texW, texH := scaledSize(int(cacheW), scale), scaledSize(int(cacheH), scale)
+
+			var scissors []scissorRect
+			for by := uint32(0); by < cacheH; by += bucketSize {
+				for bx := uint32(0); bx < cacheW; bx += bucketSize {
+					tl := geom.NewVec((origin.X+bx)%world.X, (origin.Y+by)%world.Y)
+					bucket := geom.NewAABBAt(tl, bucketSize, bucketSize)
+					scissors = append(scissors, bucketScissor(bucket, cacheRect, world, scale, scale, texH))
+				}
+			}
+			assertExactCoverage(t, "bucket scissors", texW, texH, scissors)
+		}
+	}
+}
+
+// An 8192-wide cache rect on hardware limited to 4096 renders into a fitted
+// texture at half resolution, still covered by its bucket scissors.
+func TestFitTexture_BucketScissorsCoverFittedLayer(t *testing.T) {
+	const bucketSize = 32
+	world := geom.NewVec[uint32](8192, 256)
+	cacheRect := geom.NewAABBAt(geom.NewVec[uint32](0, 64), 8192, 3*bucketSize)
+	scale, texW, texH := fitTexture(8192, 3*bucketSize, 4096)
+	if scale != 0.5 || texW != 4096 || texH != 48 {
+		t.Fatalf("fitTexture = %v, %dx%d; want 0.5, 4096x48", scale, texW, texH)
+	}
+	if scale, w, h := fitTexture(1024, 512, 4096); scale != 1 || w != 1024 || h != 512 {
+		t.Fatalf("a fitting texture changed to %v, %dx%d", scale, w, h)
+	}
+
+	var scissors []scissorRect
+	for by := uint32(0); by < 3*bucketSize; by += bucketSize {
+		for bx := uint32(0); bx < 8192; bx += bucketSize {
+			bucket := geom.NewAABBAt(geom.NewVec(bx, 64+by), bucketSize, bucketSize)
+			scissors = append(scissors, bucketScissor(bucket, cacheRect, world, scale, scale, texH))
+		}
+	}
+	assertExactCoverage(t, "fitted bucket scissors", texW, texH, scissors)
+}
+
+func TestPaneScissor_SolidCoverage(t *testing.T) {
+	const bucketSize = 32
+	viewW, viewH := 157, 101 // odd pane size
+	for _, scale := range []float64{1, 1.5, 0.8} {
+		for _, offset := range []int{0, 7, 31} {
+			// Composite rects are buckets clipped to the view, in view-local
+			// coordinates; a non-aligned view origin shifts the cuts.
+			var scissors []scissorRect
+			paneH := scaledSize(viewH, scale)
+			for y := -offset; y < viewH; y += bucketSize {
+				for x := -offset; x < viewW; x += bucketSize {
+					x0, y0 := max(x, 0), max(y, 0)
+					x1, y1 := min(x+bucketSize, viewW), min(y+bucketSize, viewH)
+					rect := geom.NewAABB(geom.NewVec(uint32(x0), uint32(y0)), geom.NewVec(uint32(x1), uint32(y1)))
+					scissors = append(scissors, paneScissor(rect, scale, scale, paneH))
+				}
+			}
+			assertExactCoverage(t, "pane scissors", scaledSize(viewW, scale), paneH, scissors)
+		}
+	}
+}
+
+func TestWindowScissor_FlipsAndScalesToTarget(t *testing.T) {
+	rect := image.Rect(10, 20, 50, 30)
+	if got, want := windowScissor(rect, 100, 100, 100, 100), (scissorRect{X: 10, Y: 70, W: 40, H: 10}); got != want {
+		t.Errorf("same-size target: %+v, want %+v", got, want)
+	}
+	if got, want := windowScissor(rect, 100, 100, 200, 50), (scissorRect{X: 20, Y: 35, W: 80, H: 5}); got != want {
+		t.Errorf("scaled target: %+v, want %+v", got, want)
+	}
+}
+
+func TestViewWrap_ZeroesNonWrappingAxes(t *testing.T) {
+	world := geom.NewVec[uint32](256, 256)
+	cases := []struct {
+		name         string
+		view         geom.Vec[uint32]
+		wrapX, wrapY bool
+		want         geom.Vec[uint32]
+		wrap         bool
+	}{
+		{"torus", geom.NewVec[uint32](64, 64), true, true, world, true},
+		{"bounded", geom.NewVec[uint32](64, 64), false, false, geom.Vec[uint32]{}, false},
+		{"cylinder", geom.NewVec[uint32](64, 64), true, false, geom.NewVec[uint32](256, 0), true},
+		{"view covers the wrapping axis", geom.NewVec[uint32](256, 64), true, false, geom.Vec[uint32]{}, false},
+	}
+	for _, tc := range cases {
+		got, wrap := viewWrap(gfx.NewViewportAxes(world, tc.view, tc.wrapX, tc.wrapY))
+		if got != tc.want || wrap != tc.wrap {
+			t.Errorf("%s: viewWrap = %v, %v, want %v, %v", tc.name, got, wrap, tc.want, tc.wrap)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package renderer
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokx/pkg/gfx"
+)
+
+// textureImage returns a copy of img as straight-alpha RGBA with its top-left
+// pixel at (0, 0): the layout sprite textures are uploaded in, the first row
+// being the top of the image at V = 0.
+func textureImage(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(out, out.Bounds(), img, bounds.Min, draw.Src)
+	return out
+}
+
+// fitTexture shrinks a width x height texture, keeping its aspect ratio, so
+// that neither side exceeds maxSize (GL_MAX_TEXTURE_SIZE), and returns the
+// scale applied with the fitted size. maxSize <= 0 means no limit.
+func fitTexture(width, height, maxSize int) (scale float64, fitWidth, fitHeight int) {
+	if maxSize <= 0 || width <= maxSize && height <= maxSize {
+		return 1, width, height
+	}
+	scale = min(float64(maxSize)/float64(width), float64(maxSize)/float64(height))
+	fitWidth = min(max(scaledSize(width, scale), 1), maxSize)
+	fitHeight = min(max(scaledSize(height, scale), 1), maxSize)
+	return scale, fitWidth, fitHeight
+}
+
+// fitImage returns img nearest-resampled down to fit maxSize (see
+// fitTexture), or img itself when it fits. Textures are sampled with
+// normalized coordinates, so a fitted image draws in place of the original at
+// a lower resolution.
+func fitImage(img *image.NRGBA, maxSize int) *image.NRGBA {
+	size := img.Rect.Size()
+	scale, width, height := fitTexture(size.X, size.Y, maxSize)
+	if scale == 1 {
+		return img
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		sy := min(int((float64(y)+0.5)*float64(size.Y)/float64(height)), size.Y-1)
+		for x := range width {
+			sx := min(int((float64(x)+0.5)*float64(size.X)/float64(width)), size.X-1)
+			src := img.PixOffset(img.Rect.Min.X+sx, img.Rect.Min.Y+sy)
+			dst := out.PixOffset(x, y)
+			copy(out.Pix[dst:dst+4], img.Pix[src:src+4])
+		}
+	}
+	return out
+}
+
+// spriteTextures holds the textures of the software renderer; TextureID n is
+// at index n-1.
+type spriteTextures []*image.NRGBA
+
+func (t spriteTextures) get(id gfx.TextureID) *image.NRGBA {
+	if id == 0 || int(id) > len(t) {
+		return nil
+	}
+	return t[id-1]
+}
+
+// paintSprite mirrors the color pass for sprites: the UV region of texture
+// stretched over rect with nearest sampling, inside a 1px stroke border when
+// the stroke is visible.
+func paintSprite(dst *image.RGBA, rect image.Rectangle, style gfx.SpatialStyle, texture *image.NRGBA) {
+	if rect.Empty() {
+		return
+	}
+	paintRect(dst, rect, gfx.SpatialStyle{Stroke: style.Stroke}, [2]float32{0, 1})
+	inner := rect
+	if visible(style.Stroke) {
+		inner = rect.Inset(1)
+	}
+	inner = inner.Intersect(dst.Bounds())
+	uv, ok := style.TextureRect()
+	if !ok || inner.Empty() {
+		return
+	}
+	size := texture.Rect.Size()
+	sampled := image.NewNRGBA(inner)
+	for y := inner.Min.Y; y < inner.Max.Y; y++ {
+		v := uv.TopLeft.Y + (uv.BottomRight.Y-uv.TopLeft.Y)*(float32(y-rect.Min.Y)+0.5)/float32(rect.Dy())
+		ty := texel(v, size.Y)
+		for x := inner.Min.X; x < inner.Max.X; x++ {
+			u := uv.TopLeft.X + (uv.BottomRight.X-uv.TopLeft.X)*(float32(x-rect.Min.X)+0.5)/float32(rect.Dx())
+			src := texture.PixOffset(texel(u, size.X), ty)
+			dstOff := sampled.PixOffset(x, y)
+			copy(sampled.Pix[dstOff:dstOff+4], texture.Pix[src:src+4])
+		}
+	}
+	draw.Draw(dst, inner, sampled, inner.Min, draw.Over)
+}
+
+// texel returns the texel nearest-sampled at the normalized coordinate t,
+// clamped to the edge like CLAMP_TO_EDGE.
+func texel(t float32, size int) int {
+	return min(max(int(math.Floor(float64(t*float32(size)))), 0), size-1)
+}
+
+// backgroundUV returns the texture rect of a layer's background image over
+// rect, a rect in the world: its share of the world when the image is
+// stretched, its size in image pixels when tiled (sampled with REPEAT).
+func backgroundUV(rect geom.AABB[uint32], world geom.Vec[uint32], size image.Point, fit gfx.BackgroundFit) [4]float32 {
+	sideX, sideY := float32(world.X), float32(world.Y)
+	if fit == gfx.BackgroundTile {
+		sideX, sideY = float32(size.X), float32(size.Y)
+	}
+	if sideX == 0 || sideY == 0 {
+		return [4]float32{}
+	}
+	return [4]float32{
+		float32(rect.TopLeft.X) / sideX, float32(rect.TopLeft.Y) / sideY,
+		float32(rect.BottomRight.X) / sideX, float32(rect.BottomRight.Y) / sideY,
+	}
+}
+
+// backgroundImages caches the layer background images of the software
+// renderer with the Layer.BackgroundVersion they were copied at.
+type backgroundImages map[*gfx.Layer]backgroundImage
+
+type backgroundImage struct {
+	version uint64
+	texture *image.NRGBA
+}
+
+// get returns the background image of layer, copying it again after it
+// changed, or nil without one.
+func (b backgroundImages) get(layer *gfx.Layer) *image.NRGBA {
+	img := layer.BackgroundImage()
+	if img == nil {
+		delete(b, layer)
+		return nil
+	}
+	version := layer.BackgroundVersion()
+	if cached, ok := b[layer]; ok && cached.version == version {
+		return cached.texture
+	}
+	texture := textureImage(img)
+	b[layer] = backgroundImage{version: version, texture: texture}
+	return texture
+}
+
+// prune drops the images of layers removed from their pane.
+func (b backgroundImages) prune() {
+	for layer := range b {
+		if layer.GetPane() == nil {
+			delete(b, layer)
+		}
+	}
+}
+
+// paintBackground mirrors the background instances of the color pass: texture
+// sampled at every pixel of rect, whose top-left shows the world position
+// origin at scaleX/scaleY pixels per world unit. Pixels outside a bounded
+// world are left alone.
+func paintBackground(dst *image.RGBA, rect image.Rectangle, origin, world geom.Vec[uint32], wrapX, wrapY bool, scaleX, scaleY float64, fit gfx.BackgroundFit, texture *image.NRGBA) {
+	rect = rect.Intersect(dst.Bounds())
+	if rect.Empty() {
+		return
+	}
+	size := texture.Rect.Size()
+	sampled := image.NewNRGBA(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		ty, ok := backgroundTexel(float64(origin.Y)+(float64(y-rect.Min.Y)+0.5)/scaleY, world.Y, wrapY, size.Y, fit)
+		if !ok {
+			continue
+		}
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			tx, ok := backgroundTexel(float64(origin.X)+(float64(x-rect.Min.X)+0.5)/scaleX, world.X, wrapX, size.X, fit)
+			if !ok {
+				continue
+			}
+			src := texture.PixOffset(tx, ty)
+			dstOff := sampled.PixOffset(x, y)
+			copy(sampled.Pix[dstOff:dstOff+4], texture.Pix[src:src+4])
+		}
+	}
+	draw.Draw(dst, rect, sampled, rect.Min, draw.Over)
+}
+
+// backgroundTexel returns the background texel at the world position pos on
+// one axis of side world units, or false past the edge of a bounded world.
+func backgroundTexel(pos float64, side uint32, wrap bool, size int, fit gfx.BackgroundFit) (int, bool) {
+	if side == 0 {
+		return 0, false
+	}
+	if pos >= float64(side) {
+		if !wrap {
+			return 0, false
+		}
+		pos = math.Mod(pos, float64(side))
+	}
+	if fit == gfx.BackgroundTile {
+		return int(math.Floor(pos)) % size, true
+	}
+	return texel(float32(pos/float64(side)), size), true
+}
@@ -18,6 +18,12 @@ func float32Array(data []float32) js.Value {
 	return arr
 }
 
+func uint8Array(data []byte) js.Value {
+	arr := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(arr, data)
+	return arr
+}
+
 func float32Bytes(data []float32) []byte {
 	if len(data) == 0 {
 		return nil
@@ -14,12 +14,27 @@ type (
 		Update(SystemAPI, time.Duration)
 	}
 
+	// Teardowner is an optional System extension for releasing resources
+	// acquired in Init. Engine.Shutdown calls Teardown once per system.
+	Teardowner interface {
+		Teardown()
+	}
+
 	View struct {
 		mask Bitmask
+		// exclude and anyOf are set by QueryBuilder: entities must have
+		// none of exclude and at least one component of each anyOf group.
+		exclude Bitmask
+		anyOf   []Bitmask
+		cache   *viewCache
 	}
 
 	SystemAPI interface {
 		NewView(components ...any) View
+		// NewCachedView is like NewView but Each iterates a materialized
+		// entity list, rebuilt only after a component of the view was
+		// assigned to or removed from some entity.
+		NewCachedView(components ...any) View
 		Each(v View, fn func(e Entity))
 		registry() *registry
 	}
@@ -28,18 +43,37 @@ type (
 		registry  *registry
 		scheduler *scheduler
 	}
+
+	// EngineOption configures an Engine in NewEngine.
+	EngineOption func(*Engine)
 )
 
+// WithPooling makes the engine recycle component storage: the pointer of a
+// component removed by Unassign or RemoveEntity is reused by the next Assign
+// of the same type, and re-assigning a component updates it in place. The
+// component masks of removed entities are reused as well. With
+// pooling, pointers obtained from Map must not be kept after their component
+// is removed.
+func WithPooling() EngineOption {
+	return func(e *Engine) {
+		e.registry.pooling = true
+	}
+}
+
 func Map[T any](api SystemAPI) map[Entity]*T {
 	return mapTypeToComponent[T](api.registry())
 }
 
-func NewEngine() *Engine {
+func NewEngine(opts ...EngineOption) *Engine {
 	reg := newRegistry()
-	return &Engine{
+	engine := &Engine{
 		registry:  reg,
 		scheduler: newScheduler(reg),
 	}
+	for _, opt := range opts {
+		opt(engine)
+	}
+	return engine
 }
 
 func (e *Engine) CreateEntity() Entity {
@@ -50,10 +84,43 @@ func (e *Engine) RemoveEntity(entity Entity) {
 	e.registry.removeEntity(entity)
 }
 
+// EntityCount returns the number of live entities.
+func (e *Engine) EntityCount() int {
+	return len(e.registry.masks)
+}
+
+// LiveEntities returns the live entities in ascending order, e.g. to check
+// that RemoveEntity cleaned up.
+func (e *Engine) LiveEntities() []Entity {
+	return e.registry.liveEntities()
+}
+
+// RegisterSystems calls Init exactly once per system and appends it to the
+// update order. Registering an already registered system is a no-op.
 func (e *Engine) RegisterSystems(systems []System) {
-	e.scheduler.registerSystems(systems)
+	_ = e.scheduler.registerSystems(systems)
 }
 
+// RegisterSystemsE is like RegisterSystems but reports systems that were
+// already registered with ErrSystemAlreadyRegistered.
+func (e *Engine) RegisterSystemsE(systems []System) error {
+	return e.scheduler.registerSystems(systems)
+}
+
+// RunOnce runs a one-shot system, e.g. level setup: it calls Init, a single
+// Update with a zero duration and, if implemented, Teardown. The system is not
+// registered, so later UpdateSystems calls skip it.
+func (e *Engine) RunOnce(system System) {
+	e.scheduler.runOnce(system)
+}
+
+// Shutdown tears down registered systems in reverse registration order and
+// unregisters them. Calling it again is a no-op until new systems are added.
+func (e *Engine) Shutdown() {
+	e.scheduler.shutdown()
+}
+
+// UpdateSystems runs Update on every registered system; it never calls Init.
 func (e *Engine) UpdateSystems(duration time.Duration) {
 	e.scheduler.updateSystems(duration)
 }
@@ -62,6 +129,27 @@ func RegisterComponent[T any](e *Engine) ComponentID {
 	return registerComponent[T](e.registry)
 }
 
+// ComponentCount returns the number of entities holding a T component.
+func ComponentCount[T any](e *Engine) int {
+	return len(mapTypeToComponent[T](e.registry))
+}
+
+// OnAdd registers fn to run whenever a T component is added to an entity
+// without one, e.g. to create GPU state for it. fn runs synchronously in
+// Assign, after the component is stored, with c pointing at it; re-assigning
+// a component the entity already has does not call it. Hooks run in
+// registration order.
+func OnAdd[T any](e *Engine, fn func(entity Entity, c *T)) {
+	onAdd(e.registry, fn)
+}
+
+// OnRemove registers fn to run whenever a T component is removed from an
+// entity, by Unassign or RemoveEntity. fn runs before the component is
+// deleted, so Map still holds it.
+func OnRemove[T any](e *Engine, fn func(entity Entity)) {
+	onRemove[T](e.registry, fn)
+}
+
 func Assign[T any](e *Engine, entity Entity, component T) {
 	assign(e.registry, entity, component)
 }
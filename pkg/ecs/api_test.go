@@ -95,3 +95,201 @@ func TestECS_UseCase(t *testing.T) {
 		t.Error("Dane encji A powinny zostać usunięte z mapy Order")
 	}
 }
+
+type eachCollector struct {
+	cached bool
+	view   ecs.View
+	seen   []ecs.Entity
+}
+
+func (s *eachCollector) Init(api ecs.SystemAPI) {
+	if s.cached {
+		s.view = api.NewCachedView(Order{}, Status{})
+	} else {
+		s.view = api.NewView(Order{}, Status{})
+	}
+}
+
+func (s *eachCollector) Update(api ecs.SystemAPI, _ time.Duration) {
+	s.seen = s.seen[:0]
+	api.Each(s.view, func(e ecs.Entity) {
+		s.seen = append(s.seen, e)
+	})
+}
+
+func TestECS_CachedViewTracksMembership(t *testing.T) {
+	engine := ecs.NewEngine()
+	ecs.RegisterComponent[Order](engine)
+	ecs.RegisterComponent[Status](engine)
+
+	a := engine.CreateEntity()
+	ecs.Assign(engine, a, Order{})
+	ecs.Assign(engine, a, Status{})
+	b := engine.CreateEntity()
+	ecs.Assign(engine, b, Order{})
+
+	system := &eachCollector{cached: true}
+	engine.RegisterSystems([]ecs.System{system})
+
+	step := func(want ...ecs.Entity) {
+		t.Helper()
+		engine.UpdateSystems(time.Millisecond)
+		if len(system.seen) != len(want) {
+			t.Fatalf("Each visited %v, want %v", system.seen, want)
+		}
+		for i := range want {
+			if system.seen[i] != want[i] {
+				t.Fatalf("Each visited %v, want %v", system.seen, want)
+			}
+		}
+	}
+
+	step(a)
+	ecs.Assign(engine, b, Status{})
+	step(a, b)
+	ecs.Unassign[Order](engine, a)
+	step(b)
+	engine.RemoveEntity(b)
+	step()
+}
+
+func TestWithPooling_RecyclesRemovedComponents(t *testing.T) {
+	engine := ecs.NewEngine(ecs.WithPooling())
+	probe := &querySystem{}
+	engine.RegisterSystems([]ecs.System{probe})
+	orders := ecs.Map[Order](probe.api)
+
+	a := engine.CreateEntity()
+	ecs.Assign(engine, a, Order{ID: "a", Total: 10})
+	first := orders[a]
+	ecs.Assign(engine, a, Order{ID: "a2"})
+	if orders[a] != first || first.ID != "a2" {
+		t.Fatalf("re-assign should update in place, got %p %+v", orders[a], *orders[a])
+	}
+
+	engine.RemoveEntity(a)
+	b := engine.CreateEntity()
+	ecs.Assign(engine, b, Order{ID: "b"})
+	if orders[b] != first {
+		t.Fatal("removed component pointer should be reused")
+	}
+	if orders[b].Total != 0 || orders[b].ID != "b" {
+		t.Fatalf("recycled component = %+v, want fresh Order{ID: b}", *orders[b])
+	}
+
+	ecs.Unassign[Order](engine, b)
+	c := engine.CreateEntity()
+	ecs.Assign(engine, c, Order{ID: "c"})
+	if orders[c] != first {
+		t.Error("unassigned component pointer should be reused")
+	}
+}
+
+func benchmarkSpawnDespawn(b *testing.B, opts ...ecs.EngineOption) {
+	const batch = 100_000
+	engine := ecs.NewEngine(opts...)
+	entities := make([]ecs.Entity, batch)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range entities {
+			e := engine.CreateEntity()
+			ecs.Assign(engine, e, Order{Total: float64(j)})
+			ecs.Assign(engine, e, Status{})
+			entities[j] = e
+		}
+		for _, e := range entities {
+			engine.RemoveEntity(e)
+		}
+	}
+}
+
+func BenchmarkSpawnDespawn_Alloc(b *testing.B)  { benchmarkSpawnDespawn(b) }
+func BenchmarkSpawnDespawn_Pooled(b *testing.B) { benchmarkSpawnDespawn(b, ecs.WithPooling()) }
+
+func benchmarkEach(b *testing.B, cached bool) {
+	engine := ecs.NewEngine()
+	entities := make([]ecs.Entity, 10000)
+	for i := range entities {
+		e := engine.CreateEntity()
+		ecs.Assign(engine, e, Order{})
+		if i%2 == 0 {
+			ecs.Assign(engine, e, Status{})
+		}
+		entities[i] = e
+	}
+	system := &eachCollector{cached: cached}
+	engine.RegisterSystems([]ecs.System{system})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// A handful of entities change membership every 100 frames.
+		if i%100 == 0 {
+			e := entities[(i/100)%len(entities)]
+			ecs.Assign(engine, e, Status{})
+		}
+		engine.UpdateSystems(time.Millisecond)
+	}
+}
+
+func BenchmarkEach_Scan(b *testing.B)   { benchmarkEach(b, false) }
+func BenchmarkEach_Cached(b *testing.B) { benchmarkEach(b, true) }
+
+func TestEngine_CountsEntitiesAndComponents(t *testing.T) {
+	engine := ecs.NewEngine()
+	a, b, c := engine.CreateEntity(), engine.CreateEntity(), engine.CreateEntity()
+	ecs.Assign(engine, a, Order{ID: "A"})
+	ecs.Assign(engine, c, Order{ID: "C"})
+	ecs.Assign(engine, c, Status{})
+
+	if got := engine.EntityCount(); got != 3 {
+		t.Fatalf("EntityCount = %d, want 3", got)
+	}
+	if got := ecs.ComponentCount[Order](engine); got != 2 {
+		t.Fatalf("ComponentCount[Order] = %d, want 2", got)
+	}
+
+	engine.RemoveEntity(c)
+	if got := engine.EntityCount(); got != 2 {
+		t.Errorf("EntityCount after RemoveEntity = %d, want 2", got)
+	}
+	if got := ecs.ComponentCount[Order](engine); got != 1 {
+		t.Errorf("ComponentCount[Order] after RemoveEntity = %d, want 1", got)
+	}
+	if got := ecs.ComponentCount[Status](engine); got != 0 {
+		t.Errorf("ComponentCount[Status] after RemoveEntity = %d, want 0", got)
+	}
+	if got := engine.LiveEntities(); len(got) != 2 || got[0] != a || got[1] != b {
+		t.Errorf("LiveEntities = %v, want [%d %d]", got, a, b)
+	}
+}
+
+func TestEngine_OnAddAndOnRemoveHooks(t *testing.T) {
+	engine, api := newQueryFixture(t)
+	orders := ecs.Map[Order](api)
+	var added []string
+	var removed []ecs.Entity
+	ecs.OnAdd(engine, func(e ecs.Entity, o *Order) { added = append(added, o.ID) })
+	ecs.OnRemove[Order](engine, func(e ecs.Entity) {
+		if _, ok := orders[e]; !ok {
+			t.Errorf("component of %d deleted before OnRemove", e)
+		}
+		removed = append(removed, e)
+	})
+
+	a, b := engine.CreateEntity(), engine.CreateEntity()
+	ecs.Assign(engine, a, Order{ID: "A"})
+	ecs.Assign(engine, a, Order{ID: "A2"})
+	ecs.Assign(engine, b, Order{ID: "B"})
+	if len(added) != 2 || added[0] != "A" || added[1] != "B" {
+		t.Fatalf("added = %v, want [A B]: re-assigning must not fire OnAdd", added)
+	}
+
+	ecs.Unassign[Order](engine, a)
+	ecs.Unassign[Order](engine, a)
+	engine.RemoveEntity(b)
+	if len(removed) != 2 || removed[0] != a || removed[1] != b {
+		t.Fatalf("removed = %v, want [%d %d]", removed, a, b)
+	}
+}
@@ -13,6 +13,11 @@ func (b Bitmask) Set(bit ComponentID) Bitmask {
 	return b
 }
 
+func (b Bitmask) Has(bit ComponentID) bool {
+	word, pos := bit/64, bit%64
+	return len(b) > int(word) && b[word]&(1<<pos) != 0
+}
+
 func (b Bitmask) Matches(required Bitmask) bool {
 	if len(b) < len(required) {
 		return false
@@ -25,6 +30,37 @@ func (b Bitmask) Matches(required Bitmask) bool {
 	return true
 }
 
+// Intersects reports whether b and other share a set bit.
+func (b Bitmask) Intersects(other Bitmask) bool {
+	for i := range min(len(b), len(other)) {
+		if b[i]&other[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Any reports whether any bit is set.
+func (b Bitmask) Any() bool {
+	for _, word := range b {
+		if word != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Or returns the union of b and other.
+func (b Bitmask) Or(other Bitmask) Bitmask {
+	for len(b) < len(other) {
+		b = append(b, 0)
+	}
+	for i, word := range other {
+		b[i] |= word
+	}
+	return b
+}
+
 func (b Bitmask) ForEachSet(fn func(id ComponentID)) {
 	for wordIdx, word := range b {
 		if word == 0 {
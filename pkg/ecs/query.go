@@ -0,0 +1,74 @@
+package ecs
+
+import "slices"
+
+// QueryBuilder composes a View from component filters:
+//
+//	view := ecs.Query(api).With(Pos{}, Vel{}).Without(Frozen{}).Optional(Sprite{}).Build()
+//
+// Like NewView it panics on components that are not registered.
+type QueryBuilder struct {
+	api     SystemAPI
+	with    Bitmask
+	without Bitmask
+	anyOf   []Bitmask
+	cached  bool
+}
+
+func Query(api SystemAPI) *QueryBuilder {
+	return &QueryBuilder{api: api}
+}
+
+// With requires every given component (And).
+func (q *QueryBuilder) With(components ...any) *QueryBuilder {
+	q.with = q.with.Or(componentMask(q.api.registry(), components...))
+	return q
+}
+
+// Without excludes entities having any of the given components.
+func (q *QueryBuilder) Without(components ...any) *QueryBuilder {
+	q.without = q.without.Or(componentMask(q.api.registry(), components...))
+	return q
+}
+
+// Or requires at least one of the given components. Each call adds a
+// separate group, and every group must match.
+func (q *QueryBuilder) Or(components ...any) *QueryBuilder {
+	if mask := componentMask(q.api.registry(), components...); mask.Any() {
+		q.anyOf = append(q.anyOf, mask)
+	}
+	return q
+}
+
+// Optional does not filter: matching entities are included whether or not
+// they have the components. Read them in the iteration callback with Get,
+// which returns nil when the component is missing.
+func (q *QueryBuilder) Optional(components ...any) *QueryBuilder {
+	componentMask(q.api.registry(), components...)
+	return q
+}
+
+// Cached makes Build return a cached view (see SystemAPI.NewCachedView).
+func (q *QueryBuilder) Cached() *QueryBuilder {
+	q.cached = true
+	return q
+}
+
+// Build returns the view. The builder may be extended and built again without
+// affecting views built before.
+func (q *QueryBuilder) Build() View {
+	v := View{
+		mask:    slices.Clone(q.with),
+		exclude: slices.Clone(q.without),
+		anyOf:   slices.Clone(q.anyOf),
+	}
+	if q.cached {
+		v.cache = &viewCache{}
+	}
+	return v
+}
+
+// Get returns the entity's component, or nil when it has none.
+func Get[T any](api SystemAPI, e Entity) *T {
+	return Map[T](api)[e]
+}
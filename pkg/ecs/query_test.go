@@ -0,0 +1,142 @@
+package ecs_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kjkrol/gokx/pkg/ecs"
+)
+
+type qPos struct{ X, Y int }
+type qVel struct{ X, Y int }
+type qFrozen struct{}
+type qSprite struct{ Name string }
+type qPlayer struct{}
+type qEnemy struct{}
+
+// querySystem captures the SystemAPI so tests can build queries.
+type querySystem struct {
+	api ecs.SystemAPI
+}
+
+func (s *querySystem) Init(api ecs.SystemAPI)              { s.api = api }
+func (s *querySystem) Update(ecs.SystemAPI, time.Duration) {}
+
+func newQueryFixture(t *testing.T) (*ecs.Engine, ecs.SystemAPI) {
+	t.Helper()
+	engine := ecs.NewEngine()
+	ecs.RegisterComponent[qPos](engine)
+	ecs.RegisterComponent[qVel](engine)
+	ecs.RegisterComponent[qFrozen](engine)
+	ecs.RegisterComponent[qSprite](engine)
+	ecs.RegisterComponent[qPlayer](engine)
+	ecs.RegisterComponent[qEnemy](engine)
+	system := &querySystem{}
+	engine.RegisterSystems([]ecs.System{system})
+	return engine, system.api
+}
+
+func collect(api ecs.SystemAPI, view ecs.View) []ecs.Entity {
+	var out []ecs.Entity
+	api.Each(view, func(e ecs.Entity) { out = append(out, e) })
+	slices.Sort(out)
+	return out
+}
+
+func TestQuery_WithRequiresAll(t *testing.T) {
+	engine, api := newQueryFixture(t)
+	both := engine.CreateEntity()
+	ecs.Assign(engine, both, qPos{})
+	ecs.Assign(engine, both, qVel{})
+	posOnly := engine.CreateEntity()
+	ecs.Assign(engine, posOnly, qPos{})
+
+	got := collect(api, ecs.Query(api).With(qPos{}, qVel{}).Build())
+	if !slices.Equal(got, []ecs.Entity{both}) {
+		t.Fatalf("With matched %v, want [%d]", got, both)
+	}
+}
+
+func TestQuery_WithoutExcludes(t *testing.T) {
+	engine, api := newQueryFixture(t)
+	moving := engine.CreateEntity()
+	ecs.Assign(engine, moving, qPos{})
+	frozen := engine.CreateEntity()
+	ecs.Assign(engine, frozen, qPos{})
+	ecs.Assign(engine, frozen, qFrozen{})
+
+	got := collect(api, ecs.Query(api).With(qPos{}).Without(qFrozen{}).Build())
+	if !slices.Equal(got, []ecs.Entity{moving}) {
+		t.Fatalf("Without matched %v, want [%d]", got, moving)
+	}
+}
+
+func TestQuery_OrRequiresOneOfGroup(t *testing.T) {
+	engine, api := newQueryFixture(t)
+	player := engine.CreateEntity()
+	ecs.Assign(engine, player, qPos{})
+	ecs.Assign(engine, player, qPlayer{})
+	enemy := engine.CreateEntity()
+	ecs.Assign(engine, enemy, qPos{})
+	ecs.Assign(engine, enemy, qEnemy{})
+	prop := engine.CreateEntity()
+	ecs.Assign(engine, prop, qPos{})
+
+	got := collect(api, ecs.Query(api).With(qPos{}).Or(qPlayer{}, qEnemy{}).Build())
+	if !slices.Equal(got, []ecs.Entity{player, enemy}) {
+		t.Fatalf("Or matched %v, want [%d %d]", got, player, enemy)
+	}
+}
+
+func TestQuery_OptionalIncludesMissingAsNil(t *testing.T) {
+	engine, api := newQueryFixture(t)
+	drawn := engine.CreateEntity()
+	ecs.Assign(engine, drawn, qPos{})
+	ecs.Assign(engine, drawn, qSprite{Name: "ship"})
+	hidden := engine.CreateEntity()
+	ecs.Assign(engine, hidden, qPos{})
+
+	view := ecs.Query(api).With(qPos{}).Optional(qSprite{}).Build()
+	sprites := map[ecs.Entity]*qSprite{}
+	api.Each(view, func(e ecs.Entity) {
+		sprites[e] = ecs.Get[qSprite](api, e)
+	})
+	if len(sprites) != 2 {
+		t.Fatalf("Optional should not filter, visited %v", sprites)
+	}
+	if s := sprites[drawn]; s == nil || s.Name != "ship" {
+		t.Errorf("expected the sprite of %d, got %v", drawn, s)
+	}
+	if s := sprites[hidden]; s != nil {
+		t.Errorf("expected nil sprite for %d, got %v", hidden, s)
+	}
+}
+
+func TestQuery_CachedViewTracksExcludedComponent(t *testing.T) {
+	engine, api := newQueryFixture(t)
+	e := engine.CreateEntity()
+	ecs.Assign(engine, e, qPos{})
+
+	view := ecs.Query(api).With(qPos{}).Without(qFrozen{}).Cached().Build()
+	if got := collect(api, view); !slices.Equal(got, []ecs.Entity{e}) {
+		t.Fatalf("cached view matched %v, want [%d]", got, e)
+	}
+	ecs.Assign(engine, e, qFrozen{})
+	if got := collect(api, view); len(got) != 0 {
+		t.Fatalf("cached view should drop the frozen entity, got %v", got)
+	}
+}
+
+func TestQuery_BuildSnapshotsFilters(t *testing.T) {
+	engine, api := newQueryFixture(t)
+	e := engine.CreateEntity()
+	ecs.Assign(engine, e, qPos{})
+
+	builder := ecs.Query(api).With(qPos{})
+	view := builder.Build()
+	builder.With(qVel{})
+	if got := collect(api, view); !slices.Equal(got, []ecs.Entity{e}) {
+		t.Fatalf("extending the builder changed a built view: %v", got)
+	}
+}
@@ -2,6 +2,7 @@ package ecs
 
 import (
 	"reflect"
+	"slices"
 )
 
 type registry struct {
@@ -11,15 +12,73 @@ type registry struct {
 	storages   map[ComponentID]any
 	typeIDs    map[reflect.Type]ComponentID
 	deleters   map[ComponentID]func(Entity)
+	// addHooks and removeHooks hold the OnAdd and OnRemove callbacks per
+	// component ID.
+	addHooks    map[ComponentID][]func(Entity)
+	removeHooks map[ComponentID][]func(Entity)
+	// epochs counts membership changes per component: it is bumped whenever
+	// the component is added to or removed from an entity.
+	epochs []uint64
+	// entityEpoch is bumped on entity creation and removal; it only matters
+	// to views without components, which match every entity.
+	entityEpoch uint64
+	// parentID is the component ID of Parent; children indexes the sorted
+	// direct children of each parent (see relation.go).
+	parentID      ComponentID
+	children      map[Entity][]Entity
+	parentRemoval ParentRemovalPolicy
+	// pools holds a *componentPool[T] per component ID when pooling is
+	// enabled (see WithPooling); freeMasks keeps the emptied masks of
+	// removed entities for reuse.
+	pooling   bool
+	pools     map[ComponentID]any
+	freeMasks []Bitmask
+}
+
+// componentPool recycles the backing pointers of removed components of one
+// type.
+type componentPool[T any] struct {
+	free []*T
+}
+
+func (p *componentPool[T]) get() *T {
+	if n := len(p.free); n > 0 {
+		c := p.free[n-1]
+		p.free = p.free[:n-1]
+		return c
+	}
+	return new(T)
+}
+
+// put zeroes c so a pooled component keeps nothing reachable.
+func (p *componentPool[T]) put(c *T) {
+	var zero T
+	*c = zero
+	p.free = append(p.free, c)
+}
+
+func poolOf[T any](r *registry, id ComponentID) *componentPool[T] {
+	if pool, ok := r.pools[id]; ok {
+		return pool.(*componentPool[T])
+	}
+	pool := &componentPool[T]{}
+	r.pools[id] = pool
+	return pool
 }
 
 func newRegistry() *registry {
-	return &registry{
-		masks:    make(map[Entity]Bitmask),
-		storages: make(map[ComponentID]any),
-		typeIDs:  make(map[reflect.Type]ComponentID),
-		deleters: make(map[ComponentID]func(Entity)),
+	r := &registry{
+		masks:       make(map[Entity]Bitmask),
+		storages:    make(map[ComponentID]any),
+		typeIDs:     make(map[reflect.Type]ComponentID),
+		deleters:    make(map[ComponentID]func(Entity)),
+		addHooks:    make(map[ComponentID][]func(Entity)),
+		removeHooks: make(map[ComponentID][]func(Entity)),
+		children:    make(map[Entity][]Entity),
+		pools:       make(map[ComponentID]any),
 	}
+	r.parentID = registerComponent[Parent](r)
+	return r
 }
 
 func (r *registry) createEntity() Entity {
@@ -31,36 +90,75 @@ func (r *registry) createEntity() Entity {
 		r.lastEntity++
 		e = r.lastEntity
 	}
-	r.masks[e] = Bitmask{}
+	mask := Bitmask{}
+	if n := len(r.freeMasks); n > 0 {
+		mask = r.freeMasks[n-1]
+		r.freeMasks = r.freeMasks[:n-1]
+	}
+	r.masks[e] = mask
+	r.entityEpoch++
 	return e
 }
 
 func (r *registry) removeEntity(e Entity) {
-	mask, ok := r.masks[e]
-	if !ok {
+	if _, ok := r.masks[e]; !ok {
 		return
 	}
+	r.removeRelations(e)
 
+	mask := r.masks[e]
 	mask.ForEachSet(func(id ComponentID) {
+		r.runHooks(r.removeHooks[id], e)
 		if deleteFn, exists := r.deleters[id]; exists {
 			deleteFn(e)
 		}
+		r.epochs[id]++
 	})
 
 	delete(r.masks, e)
+	if r.pooling {
+		r.freeMasks = append(r.freeMasks, mask[:0])
+	}
+	r.entityEpoch++
 	r.freeList = append(r.freeList, e)
 }
 
+func (r *registry) liveEntities() []Entity {
+	out := make([]Entity, 0, len(r.masks))
+	for e := range r.masks {
+		out = append(out, e)
+	}
+	slices.Sort(out)
+	return out
+}
+
 func assign[T any](r *registry, e Entity, component T) {
 	id := registerComponent[T](r)
 	assignByID(r, e, id, component)
 }
 
 func assignByID[T any](r *registry, e Entity, id ComponentID, component T) {
-	r.masks[e] = r.masks[e].Set(id)
+	mask := r.masks[e]
+	added := !mask.Has(id)
+	if added {
+		r.epochs[id]++
+	}
+	r.masks[e] = mask.Set(id)
 	storage := r.storages[id].(map[Entity]*T)
-	c := component
-	storage[e] = &c
+	if !r.pooling {
+		c := component
+		storage[e] = &c
+	} else {
+		c, ok := storage[e]
+		if !ok {
+			c = poolOf[T](r, id).get()
+			storage[e] = c
+		}
+		*c = component
+	}
+	if added {
+		r.runHooks(r.addHooks[id], e)
+	}
 }
 
 func unassign[T any](r *registry, e Entity) {
@@ -76,23 +174,89 @@ func unassign[T any](r *registry, e Entity) {
 }
 
 func unassignByID[T any](r *registry, e Entity, id ComponentID) {
+	if mask, ok := r.masks[e]; ok && mask.Has(id) {
+		r.runHooks(r.removeHooks[id], e)
+	}
 	if storage, ok := r.storages[id].(map[Entity]*T); ok {
+		if c, ok := storage[e]; ok && r.pooling {
+			poolOf[T](r, id).put(c)
+		}
 		delete(storage, e)
 	}
 
-	if mask, ok := r.masks[e]; ok {
+	if mask, ok := r.masks[e]; ok && mask.Has(id) {
 		r.masks[e] = mask.Clear(id)
+		r.epochs[id]++
+	}
+}
+
+// onAdd registers fn to run when a T component is added to an entity.
+func onAdd[T any](r *registry, fn func(e Entity, c *T)) {
+	id := registerComponent[T](r)
+	storage := r.storages[id].(map[Entity]*T)
+	r.addHooks[id] = append(r.addHooks[id], func(e Entity) {
+		fn(e, storage[e])
+	})
+}
+
+// onRemove registers fn to run when a T component is removed from an entity.
+func onRemove[T any](r *registry, fn func(e Entity)) {
+	id := registerComponent[T](r)
+	r.removeHooks[id] = append(r.removeHooks[id], fn)
+}
+
+func (r *registry) runHooks(hooks []func(Entity), e Entity) {
+	for _, hook := range hooks {
+		hook(e)
 	}
 }
 
 func (r *registry) eachEntitiesMathesView(v View, fn func(e Entity)) {
 	for e, m := range r.masks {
-		if m.Matches(v.mask) {
+		if v.matches(m) {
 			fn(e)
 		}
 	}
 }
 
+// eachEntitiesCached iterates the view's materialized entity list, rebuilding
+// it first if any of the view's components changed membership since the last
+// build. Entities removed by fn during iteration are still visited.
+func (r *registry) eachEntitiesCached(v View, fn func(e Entity)) {
+	epoch := r.viewEpoch(v)
+	if !v.cache.valid || v.cache.epoch != epoch {
+		v.cache.entities = v.cache.entities[:0]
+		for e, m := range r.masks {
+			if v.matches(m) {
+				v.cache.entities = append(v.cache.entities, e)
+			}
+		}
+		slices.Sort(v.cache.entities)
+		v.cache.epoch = epoch
+		v.cache.valid = true
+	}
+	for _, e := range v.cache.entities {
+		fn(e)
+	}
+}
+
+// viewEpoch sums the epochs of the view's components; since epochs only grow,
+// the sum changes whenever any of them does. A view without required
+// components can match new entities, so entity creation counts too.
+func (r *registry) viewEpoch(v View) uint64 {
+	var epoch uint64
+	add := func(id ComponentID) { epoch += r.epochs[id] }
+	v.mask.ForEachSet(add)
+	v.exclude.ForEachSet(add)
+	for _, group := range v.anyOf {
+		group.ForEachSet(add)
+	}
+	if !v.mask.Any() {
+		epoch += r.entityEpoch
+	}
+	return epoch
+}
+
 func mapTypeToComponent[T any](r *registry) map[Entity]*T {
 	id := registerComponent[T](r)
 	return r.storages[id].(map[Entity]*T)
@@ -112,8 +276,12 @@ func registerComponent[T any](r *registry) ComponentID {
 	r.storages[id] = storage
 
 	r.deleters[id] = func(e Entity) {
+		if c, ok := storage[e]; ok && r.pooling {
+			poolOf[T](r, id).put(c)
+		}
 		delete(storage, e)
 	}
+	r.epochs = append(r.epochs, 0)
 
 	return id
 }
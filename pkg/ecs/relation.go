@@ -0,0 +1,125 @@
+package ecs
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	// ErrUnknownEntity is reported by SetParent when child or parent does
+	// not exist.
+	ErrUnknownEntity = errors.New("ecs: unknown entity")
+	// ErrParentCycle is reported by SetParent when parent is child itself or
+	// one of its descendants.
+	ErrParentCycle = errors.New("ecs: parent would create a cycle")
+)
+
+// Parent is the relationship component linking a child to its parent
+// entity. Change it only through SetParent and ClearParent, which keep the
+// children index in sync; a view over Parent{} matches every entity that has
+// a parent.
+type Parent struct {
+	Entity Entity
+}
+
+// ParentRemovalPolicy selects what RemoveEntity does with the children of a
+// removed entity.
+type ParentRemovalPolicy int
+
+const (
+	// OrphanChildren clears the Parent component of the children (default).
+	OrphanChildren ParentRemovalPolicy = iota
+	// DestroyChildren removes the children, and their descendants, too.
+	DestroyChildren
+)
+
+// SetParentRemovalPolicy configures how RemoveEntity treats children.
+func (e *Engine) SetParentRemovalPolicy(policy ParentRemovalPolicy) {
+	e.registry.parentRemoval = policy
+}
+
+// SetParent makes parent the parent of child, replacing any previous parent.
+func SetParent(e *Engine, child, parent Entity) error {
+	return e.registry.setParent(child, parent)
+}
+
+// ClearParent detaches child from its parent, if any.
+func ClearParent(e *Engine, child Entity) {
+	e.registry.clearParent(child)
+}
+
+// Children returns the direct children of parent in ascending order.
+func Children(api SystemAPI, parent Entity) []Entity {
+	return slices.Clone(api.registry().children[parent])
+}
+
+func (r *registry) parents() map[Entity]*Parent {
+	return r.storages[r.parentID].(map[Entity]*Parent)
+}
+
+func (r *registry) setParent(child, parent Entity) error {
+	if _, ok := r.masks[child]; !ok {
+		return ErrUnknownEntity
+	}
+	if _, ok := r.masks[parent]; !ok {
+		return ErrUnknownEntity
+	}
+	parents := r.parents()
+	for p := parent; ; {
+		if p == child {
+			return ErrParentCycle
+		}
+		link, ok := parents[p]
+		if !ok {
+			break
+		}
+		p = link.Entity
+	}
+	r.clearParent(child)
+	assignByID(r, child, r.parentID, Parent{Entity: parent})
+	kids := r.children[parent]
+	idx, _ := slices.BinarySearch(kids, child)
+	r.children[parent] = slices.Insert(kids, idx, child)
+	return nil
+}
+
+func (r *registry) clearParent(child Entity) {
+	link, ok := r.parents()[child]
+	if !ok {
+		return
+	}
+	r.detachChild(link.Entity, child)
+	unassignByID[Parent](r, child, r.parentID)
+}
+
+func (r *registry) detachChild(parent, child Entity) {
+	kids := r.children[parent]
+	if idx, found := slices.BinarySearch(kids, child); found {
+		kids = slices.Delete(kids, idx, idx+1)
+	}
+	if len(kids) == 0 {
+		delete(r.children, parent)
+		return
+	}
+	r.children[parent] = kids
+}
+
+// removeRelations unlinks e from its parent and applies the removal policy
+// to its children. It runs before e's components are deleted.
+func (r *registry) removeRelations(e Entity) {
+	if link, ok := r.parents()[e]; ok {
+		r.detachChild(link.Entity, e)
+	}
+	kids := r.children[e]
+	if len(kids) == 0 {
+		return
+	}
+	delete(r.children, e)
+	for _, child := range kids {
+		if r.parentRemoval == DestroyChildren {
+			r.removeEntity(child)
+			continue
+		}
+		unassignByID[Parent](r, child, r.parentID)
+	}
+}
@@ -0,0 +1,147 @@
+package ecs_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kjkrol/gokx/pkg/ecs"
+)
+
+type childCollector struct {
+	parent   ecs.Entity
+	children []ecs.Entity
+	parented []ecs.Entity
+	view     ecs.View
+}
+
+func (s *childCollector) Init(api ecs.SystemAPI) {
+	s.view = api.NewView(ecs.Parent{})
+}
+
+func (s *childCollector) Update(api ecs.SystemAPI, _ time.Duration) {
+	s.children = ecs.Children(api, s.parent)
+	s.parented = s.parented[:0]
+	api.Each(s.view, func(e ecs.Entity) {
+		s.parented = append(s.parented, e)
+	})
+	slices.Sort(s.parented)
+}
+
+func TestSetParent_ChildrenAndParentView(t *testing.T) {
+	engine := ecs.NewEngine()
+	tank := engine.CreateEntity()
+	turret := engine.CreateEntity()
+	gun := engine.CreateEntity()
+	loose := engine.CreateEntity()
+
+	if err := ecs.SetParent(engine, turret, tank); err != nil {
+		t.Fatalf("SetParent: %v", err)
+	}
+	if err := ecs.SetParent(engine, gun, tank); err != nil {
+		t.Fatalf("SetParent: %v", err)
+	}
+	sys := &childCollector{parent: tank}
+	engine.RegisterSystems([]ecs.System{sys})
+	engine.UpdateSystems(time.Millisecond)
+
+	if want := []ecs.Entity{turret, gun}; !slices.Equal(sys.children, want) {
+		t.Fatalf("children = %v, want %v", sys.children, want)
+	}
+	if want := []ecs.Entity{turret, gun}; !slices.Equal(sys.parented, want) {
+		t.Fatalf("entities with a parent = %v, want %v", sys.parented, want)
+	}
+
+	// Reparenting moves the child between children lists.
+	if err := ecs.SetParent(engine, gun, turret); err != nil {
+		t.Fatalf("SetParent: %v", err)
+	}
+	engine.UpdateSystems(time.Millisecond)
+	if want := []ecs.Entity{turret}; !slices.Equal(sys.children, want) {
+		t.Fatalf("children after reparent = %v, want %v", sys.children, want)
+	}
+
+	ecs.ClearParent(engine, turret)
+	engine.UpdateSystems(time.Millisecond)
+	if len(sys.children) != 0 || slices.Contains(sys.parented, turret) || slices.Contains(sys.parented, loose) {
+		t.Fatalf("unexpected relations after ClearParent: children=%v parented=%v", sys.children, sys.parented)
+	}
+}
+
+func TestSetParent_RejectsCyclesAndUnknownEntities(t *testing.T) {
+	engine := ecs.NewEngine()
+	a := engine.CreateEntity()
+	b := engine.CreateEntity()
+	c := engine.CreateEntity()
+	if err := ecs.SetParent(engine, b, a); err != nil {
+		t.Fatalf("SetParent: %v", err)
+	}
+	if err := ecs.SetParent(engine, c, b); err != nil {
+		t.Fatalf("SetParent: %v", err)
+	}
+
+	if err := ecs.SetParent(engine, a, c); !errors.Is(err, ecs.ErrParentCycle) {
+		t.Fatalf("expected ErrParentCycle, got %v", err)
+	}
+	if err := ecs.SetParent(engine, a, a); !errors.Is(err, ecs.ErrParentCycle) {
+		t.Fatalf("expected ErrParentCycle for self-parenting, got %v", err)
+	}
+	if err := ecs.SetParent(engine, a, 999); !errors.Is(err, ecs.ErrUnknownEntity) {
+		t.Fatalf("expected ErrUnknownEntity, got %v", err)
+	}
+}
+
+func TestRemoveEntity_ParentRemovalPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		policy    ecs.ParentRemovalPolicy
+		survivors int
+	}{
+		{"orphan", ecs.OrphanChildren, 2},
+		{"destroy", ecs.DestroyChildren, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := ecs.NewEngine()
+			engine.SetParentRemovalPolicy(tc.policy)
+			root := engine.CreateEntity()
+			child := engine.CreateEntity()
+			grandchild := engine.CreateEntity()
+			_ = ecs.SetParent(engine, child, root)
+			_ = ecs.SetParent(engine, grandchild, child)
+
+			sys := &childCollector{parent: root}
+			engine.RegisterSystems([]ecs.System{sys})
+			engine.RemoveEntity(root)
+			engine.UpdateSystems(time.Millisecond)
+
+			all := 0
+			countAll := &entityCounter{count: &all}
+			engine.RegisterSystems([]ecs.System{countAll})
+			engine.UpdateSystems(time.Millisecond)
+			if all != tc.survivors {
+				t.Fatalf("expected %d surviving entities, got %d", tc.survivors, all)
+			}
+			if tc.policy == ecs.OrphanChildren {
+				// child lost its parent; grandchild keeps child as parent.
+				if want := []ecs.Entity{grandchild}; !slices.Equal(sys.parented, want) {
+					t.Fatalf("entities with a parent = %v, want %v", sys.parented, want)
+				}
+			}
+		})
+	}
+}
+
+type entityCounter struct {
+	view  ecs.View
+	count *int
+}
+
+func (s *entityCounter) Init(api ecs.SystemAPI) {
+	s.view = api.NewView()
+}
+
+func (s *entityCounter) Update(api ecs.SystemAPI, _ time.Duration) {
+	*s.count = 0
+	api.Each(s.view, func(ecs.Entity) { *s.count++ })
+}
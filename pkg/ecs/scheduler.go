@@ -1,20 +1,28 @@
 package ecs
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"time"
 )
 
 var _ SystemAPI = (*scheduler)(nil)
 
+// ErrSystemAlreadyRegistered is reported when the same system is registered twice.
+var ErrSystemAlreadyRegistered = errors.New("ecs: system already registered")
+
 type scheduler struct {
-	register *registry
-	systems  []System
+	register   *registry
+	systems    []System
+	registered map[System]struct{}
 }
 
 func newScheduler(register *registry) *scheduler {
 	return &scheduler{
-		register: register,
-		systems:  make([]System, 0),
+		register:   register,
+		systems:    make([]System, 0),
+		registered: make(map[System]struct{}),
 	}
 }
 
@@ -22,15 +30,40 @@ func (e *scheduler) NewView(components ...any) View {
 	return newView(e.register, components...)
 }
 
+func (e *scheduler) NewCachedView(components ...any) View {
+	v := newView(e.register, components...)
+	v.cache = &viewCache{}
+	return v
+}
+
 func (e *scheduler) Each(v View, fn func(e Entity)) {
+	if v.cache != nil {
+		e.register.eachEntitiesCached(v, fn)
+		return
+	}
 	e.register.eachEntitiesMathesView(v, fn)
 }
 
-func (e *scheduler) registerSystems(systems []System) {
+func (e *scheduler) registerSystems(systems []System) error {
+	var errs []error
 	for _, system := range systems {
+		if system == nil {
+			continue
+		}
+		// Non-comparable systems (passed by value) cannot be tracked and are
+		// always registered.
+		trackable := reflect.TypeOf(system).Comparable()
+		if trackable {
+			if _, ok := e.registered[system]; ok {
+				errs = append(errs, fmt.Errorf("%w: %T", ErrSystemAlreadyRegistered, system))
+				continue
+			}
+			e.registered[system] = struct{}{}
+		}
 		system.Init(e)
 		e.systems = append(e.systems, system)
 	}
+	return errors.Join(errs...)
 }
 
 func (e *scheduler) updateSystems(duration time.Duration) {
@@ -39,4 +72,26 @@ func (e *scheduler) updateSystems(duration time.Duration) {
 	}
 }
 
+func (e *scheduler) runOnce(system System) {
+	if system == nil {
+		return
+	}
+	system.Init(e)
+	system.Update(e, 0)
+	if t, ok := system.(Teardowner); ok {
+		t.Teardown()
+	}
+}
+
+func (e *scheduler) shutdown() {
+	systems := e.systems
+	e.systems = make([]System, 0)
+	e.registered = make(map[System]struct{})
+	for i := len(systems) - 1; i >= 0; i-- {
+		if t, ok := systems[i].(Teardowner); ok {
+			t.Teardown()
+		}
+	}
+}
+
 func (e *scheduler) registry() *registry { return e.register }
@@ -0,0 +1,105 @@
+package ecs_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kjkrol/gokx/pkg/ecs"
+)
+
+type countingSystem struct {
+	inits     int
+	updates   int
+	teardowns int
+}
+
+func (s *countingSystem) Init(api ecs.SystemAPI) {
+	s.inits++
+}
+
+func (s *countingSystem) Update(api ecs.SystemAPI, d time.Duration) {
+	s.updates++
+}
+
+func (s *countingSystem) Teardown() {
+	s.teardowns++
+}
+
+func TestRegisterSystems_InitCalledOnce(t *testing.T) {
+	engine := ecs.NewEngine()
+	system := &countingSystem{}
+
+	engine.RegisterSystems([]ecs.System{system})
+	engine.RegisterSystems([]ecs.System{system})
+	for range 3 {
+		engine.UpdateSystems(time.Millisecond)
+	}
+
+	if system.inits != 1 {
+		t.Errorf("Init should run once, ran %d times", system.inits)
+	}
+	if system.updates != 3 {
+		t.Errorf("Update should run once per UpdateSystems, ran %d times", system.updates)
+	}
+}
+
+func TestRegisterSystemsE_ReportsDuplicate(t *testing.T) {
+	engine := ecs.NewEngine()
+	system := &countingSystem{}
+
+	if err := engine.RegisterSystemsE([]ecs.System{system}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := engine.RegisterSystemsE([]ecs.System{system})
+	if !errors.Is(err, ecs.ErrSystemAlreadyRegistered) {
+		t.Fatalf("expected ErrSystemAlreadyRegistered, got %v", err)
+	}
+	if system.inits != 1 {
+		t.Errorf("Init should run once, ran %d times", system.inits)
+	}
+}
+
+func TestShutdown_TeardownCalledOncePerSystem(t *testing.T) {
+	engine := ecs.NewEngine()
+	first := &countingSystem{}
+	second := &countingSystem{}
+
+	engine.RegisterSystems([]ecs.System{first, second})
+	engine.Shutdown()
+	engine.Shutdown()
+	engine.UpdateSystems(time.Millisecond)
+
+	for i, system := range []*countingSystem{first, second} {
+		if system.teardowns != 1 {
+			t.Errorf("system %d: Teardown should run once, ran %d times", i, system.teardowns)
+		}
+		if system.updates != 0 {
+			t.Errorf("system %d: updated after Shutdown", i)
+		}
+	}
+
+	// Systems can be registered again after Shutdown.
+	if err := engine.RegisterSystemsE([]ecs.System{first}); err != nil {
+		t.Fatalf("re-register after Shutdown: %v", err)
+	}
+	if first.inits != 2 {
+		t.Errorf("expected Init on re-registration, inits=%d", first.inits)
+	}
+}
+
+func TestRunOnce_RunsSystemWithoutRegistering(t *testing.T) {
+	engine := ecs.NewEngine()
+	system := &countingSystem{}
+
+	engine.RunOnce(system)
+	engine.UpdateSystems(time.Millisecond)
+
+	if system.inits != 1 || system.updates != 1 || system.teardowns != 1 {
+		t.Errorf("RunOnce: inits=%d updates=%d teardowns=%d, want 1 each",
+			system.inits, system.updates, system.teardowns)
+	}
+	if err := engine.RegisterSystemsE([]ecs.System{system}); err != nil {
+		t.Errorf("a system run once should still be registrable: %v", err)
+	}
+}
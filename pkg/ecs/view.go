@@ -2,15 +2,38 @@ package ecs
 
 import "reflect"
 
+type viewCache struct {
+	entities []Entity
+	epoch    uint64
+	valid    bool
+}
+
 func newView(r *registry, components ...any) View {
-	var v View
+	return View{mask: componentMask(r, components...)}
+}
+
+func componentMask(r *registry, components ...any) Bitmask {
+	var mask Bitmask
 	for _, c := range components {
 		t := reflect.TypeOf(c)
 		id, ok := r.typeIDs[t]
 		if !ok {
 			panic("ecs: component " + t.String() + " must be registered before creating a View")
 		}
-		v.mask = v.mask.Set(id)
+		mask = mask.Set(id)
+	}
+	return mask
+}
+
+// matches reports whether an entity mask passes every filter of the view.
+func (v View) matches(m Bitmask) bool {
+	if !m.Matches(v.mask) || m.Intersects(v.exclude) {
+		return false
+	}
+	for _, group := range v.anyOf {
+		if !m.Intersects(group) {
+			return false
+		}
 	}
-	return v
+	return true
 }
@@ -0,0 +1,33 @@
+package gfx
+
+// BlendMode selects how a layer's drawables combine with what is already in
+// the layer texture during the color pass. Layers are always composited onto
+// the pane with alpha blending.
+type BlendMode uint8
+
+const (
+	// BlendAlpha draws over: src*a + dst*(1-a). The default.
+	BlendAlpha BlendMode = iota
+	// BlendAdditive adds light: src*a + dst. Overlapping particles glow.
+	BlendAdditive
+	// BlendMultiply darkens: dst*(src*a + 1-a). Shaders must honor the
+	// uPremultiply uniform of the color pass for it.
+	BlendMultiply
+	// BlendReplace writes src as is, ignoring what was drawn before.
+	BlendReplace
+)
+
+func (m BlendMode) String() string {
+	switch m {
+	case BlendAlpha:
+		return "alpha"
+	case BlendAdditive:
+		return "additive"
+	case BlendMultiply:
+		return "multiply"
+	case BlendReplace:
+		return "replace"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,80 @@
+package gfx
+
+// RendererKind identifies the backend drawing a window.
+type RendererKind uint8
+
+const (
+	// RendererUnknown is a renderer that does not report its capabilities.
+	RendererUnknown RendererKind = iota
+	// RendererGL is the desktop OpenGL renderer.
+	RendererGL
+	// RendererWebGL is the browser WebGL2 renderer.
+	RendererWebGL
+	// RendererSoftware rasterizes on the CPU.
+	RendererSoftware
+)
+
+func (k RendererKind) String() string {
+	switch k {
+	case RendererGL:
+		return "gl"
+	case RendererWebGL:
+		return "webgl"
+	case RendererSoftware:
+		return "software"
+	default:
+		return "unknown"
+	}
+}
+
+// Caps describes what a window's renderer supports, so applications can pick
+// features before using them. Limits are zero when the backend has none or
+// does not report them.
+type Caps struct {
+	Kind RendererKind
+	// MaxTextureSize is the largest texture side in pixels
+	// (GL_MAX_TEXTURE_SIZE). A layer texture covers the pane's view plus its
+	// bucket margin at the render scale; GPU renderers draw layers and panes
+	// that would exceed it at a lower resolution, and shrink larger images
+	// passed to LoadTexture or Layer.SetBackgroundImage.
+	MaxTextureSize int
+	// MaxTextureUnits is the number of textures a fragment shader can sample
+	// (GL_MAX_TEXTURE_IMAGE_UNITS).
+	MaxTextureUnits int
+	// MaxSamples is the largest MSAA sample count (GL_MAX_SAMPLES).
+	MaxSamples int
+	// Instancing reports instanced drawing, which the bucket color pass uses.
+	Instancing bool
+	// Framebuffers reports offscreen framebuffers, which layer textures,
+	// post passes and RenderToTexture use.
+	Framebuffers bool
+	// Vendor, Renderer and Version are the GL_VENDOR, GL_RENDERER and
+	// GL_VERSION strings, or their WebGL equivalents.
+	Vendor   string
+	Renderer string
+	Version  string
+}
+
+// CapsReporter is implemented by renderers that report their capabilities.
+// GPU renderers query the context, so Capabilities runs with it current.
+type CapsReporter interface {
+	Capabilities(w *Window) Caps
+}
+
+// Capabilities reports what the window's renderer supports. A software
+// renderer that does not implement CapsReporter reports only its kind, any
+// other renderer RendererUnknown. Call it from the window loop goroutine,
+// like LoadTexture, since GPU renderers query their context.
+func (w *Window) Capabilities() Caps {
+	reporter, ok := w.renderer.(CapsReporter)
+	if !ok {
+		if w.softwareRendering() {
+			return Caps{Kind: RendererSoftware}
+		}
+		return Caps{}
+	}
+	if !w.softwareRendering() {
+		w.platformWinWrapper.BeginFrame()
+	}
+	return reporter.Capabilities(w)
+}
@@ -2,20 +2,159 @@ package gfx
 
 import (
 	"image/color"
+	"slices"
 
+	"github.com/kjkrol/gokg/pkg/geom"
 	"github.com/kjkrol/gokg/pkg/plane"
 )
 
 type SpatialStyle struct {
 	Fill   color.Color
 	Stroke color.Color
+	// Gradient, when set with a direction, replaces Fill with a two-color
+	// ramp across the drawable.
+	Gradient *Gradient
+	// Texture, when set, draws the UV region of a texture loaded with
+	// Window.LoadTexture instead of Fill or Gradient; Stroke still outlines
+	// it. See Sprite.
+	Texture TextureID
+	// UV is the texture region in normalized coordinates, (0, 0) being the
+	// top-left of the image. The zero value is the whole texture.
+	UV geom.AABB[float32]
+}
+
+// GradientDir selects the axis a Gradient runs along.
+type GradientDir int
+
+const (
+	GradientNone GradientDir = iota
+	// GradientHorizontal runs From (left) to To (right).
+	GradientHorizontal
+	// GradientVertical runs From (top) to To (bottom).
+	GradientVertical
+)
+
+type Gradient struct {
+	From, To color.Color
+	Dir      GradientDir
+}
+
+// ActiveGradient returns the gradient when it should replace Fill.
+func (s SpatialStyle) ActiveGradient() (*Gradient, bool) {
+	if s.Gradient == nil || s.Gradient.Dir == GradientNone {
+		return nil, false
+	}
+	return s.Gradient, true
+}
+
+// TextureRect returns the texture region drawn when Texture is set.
+func (s SpatialStyle) TextureRect() (geom.AABB[float32], bool) {
+	if s.Texture == 0 {
+		return geom.AABB[float32]{}, false
+	}
+	if s.UV == (geom.AABB[float32]{}) {
+		return geom.NewAABB(geom.NewVec[float32](0, 0), geom.NewVec[float32](1, 1)), true
+	}
+	return s.UV, true
 }
 
 type Drawable struct {
+	// ID identifies the drawable in grid queries and drawable events.
+	// Leave it zero to have Layer.AddDrawable assign one.
 	ID uint64
 	plane.AABB[uint32]
 	Style SpatialStyle
-	layer *Layer
+	// StyleRef, when set, overrides Style with a style shared by many
+	// drawables. After changing the shared style, call Layer.MarkDirty on
+	// the layers using it.
+	StyleRef *SpatialStyle
+	// UserData carries application state alongside the drawable, e.g. the
+	// game object behind an ID returned by Layer.DrawableByID. Rendering and
+	// the grid ignore it.
+	UserData any
+	layer    *Layer
+	// source is the drawable a proxy mirrors and proxies the proxies of a
+	// source (see Layer.AddProxy).
+	source  *Drawable
+	proxies []*Drawable
+}
+
+// EffectiveStyle returns the style the drawable is rendered with: *StyleRef
+// when set, Style otherwise. A proxy uses the style of its source.
+func (d *Drawable) EffectiveStyle() SpatialStyle {
+	if d.source != nil {
+		return d.source.EffectiveStyle()
+	}
+	if d.StyleRef != nil {
+		return *d.StyleRef
+	}
+	return d.Style
+}
+
+// WorldBounds returns the logical bounds of the drawable: the base AABB
+// extended by the fragments wrapped past the right and bottom world edges.
+// The result is unwrapped, so on a torus it may extend beyond the world size;
+// it matches the extent the grid indexes (see grid.BucketGridManager).
+func (d *Drawable) WorldBounds() geom.AABB[uint32] {
+	base := d.AABB.AABB
+	var extraW, extraH uint32
+	d.AABB.VisitFragments(func(pos plane.FragPosition, frag geom.AABB[uint32]) bool {
+		switch pos {
+		case plane.FRAG_RIGHT, plane.FRAG_BOTTOM_RIGHT:
+			extraW = max(extraW, frag.BottomRight.X-frag.TopLeft.X)
+		}
+		switch pos {
+		case plane.FRAG_BOTTOM, plane.FRAG_BOTTOM_RIGHT:
+			extraH = max(extraH, frag.BottomRight.Y-frag.TopLeft.Y)
+		}
+		return true
+	})
+	return geom.NewAABB(
+		base.TopLeft,
+		geom.NewVec(base.BottomRight.X+extraW, base.BottomRight.Y+extraH),
+	)
+}
+
+// Center returns the center of WorldBounds, rounded down. Like WorldBounds it
+// is unwrapped; wrap it with the pane's space when it must lie in the world.
+func (d *Drawable) Center() geom.Vec[uint32] {
+	bounds := d.WorldBounds()
+	return geom.NewVec(
+		bounds.TopLeft.X+(bounds.BottomRight.X-bounds.TopLeft.X)/2,
+		bounds.TopLeft.Y+(bounds.BottomRight.Y-bounds.TopLeft.Y)/2,
+	)
+}
+
+// Layer returns the layer the drawable is on, or nil.
+func (d *Drawable) Layer() *Layer {
+	return d.layer
+}
+
+// Source returns the drawable a proxy mirrors, or nil if d is not a proxy.
+func (d *Drawable) Source() *Drawable {
+	return d.source
+}
+
+// Proxies returns the proxies of d created by Layer.AddProxy.
+func (d *Drawable) Proxies() []*Drawable {
+	return slices.Clone(d.proxies)
+}
+
+// releaseProxies removes the proxies of d from their layers and, if d is a
+// proxy, unties it from its source, keeping the source's current style.
+func (d *Drawable) releaseProxies() {
+	proxies := d.proxies
+	d.proxies = nil
+	for _, proxy := range proxies {
+		if proxy.layer != nil {
+			proxy.layer.RemoveDrawable(proxy)
+		}
+	}
+	if source := d.source; source != nil {
+		d.Style = source.EffectiveStyle()
+		d.source = nil
+		source.proxies = slices.DeleteFunc(source.proxies, func(p *Drawable) bool { return p == d })
+	}
 }
 
 func (d *Drawable) attach(layer *Layer) {
@@ -25,3 +164,23 @@ func (d *Drawable) attach(layer *Layer) {
 func (d *Drawable) detach() {
 	d.layer = nil
 }
+
+// notifyStyleChanged reports a style change of d to the observer of its layer;
+// a static layer resyncs so the update reaches the renderer.
+func (d *Drawable) notifyStyleChanged() {
+	layer := d.layer
+	if layer == nil {
+		return
+	}
+	if layer.static {
+		layer.syncPending = true
+	}
+	observer, ok := layer.observer.(DrawableStyleObserver)
+	if !ok {
+		layer.markAllDirty()
+		return
+	}
+	if id, ok := layer.DrawableID(d); ok {
+		observer.OnDrawableStyleChanged(layer, d, id)
+	}
+}
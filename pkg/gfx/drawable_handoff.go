@@ -0,0 +1,46 @@
+package gfx
+
+import "sync"
+
+// drawableHandoff double-buffers drawable events between a threaded update
+// goroutine and the loop thread. Events collect in the back buffer while a
+// step runs; publish makes them visible to take as one batch, so a frame
+// never shows half of an update step.
+type drawableHandoff struct {
+	mu    sync.Mutex
+	back  []Event
+	front []Event
+}
+
+func (h *drawableHandoff) add(event Event) {
+	h.mu.Lock()
+	h.back = append(h.back, event)
+	h.mu.Unlock()
+}
+
+// publish moves the back buffer behind any batches not yet taken.
+func (h *drawableHandoff) publish() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.back) == 0 {
+		return
+	}
+	if len(h.front) == 0 {
+		h.front, h.back = h.back, h.front[:0]
+		return
+	}
+	h.front = append(h.front, h.back...)
+	h.back = h.back[:0]
+}
+
+// take returns the published events, reusing spare's storage for the next
+// batch. The returned slice is owned by the caller until passed back as
+// spare.
+func (h *drawableHandoff) take(spare []Event) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := h.front
+	clear(spare)
+	h.front = spare[:0]
+	return events
+}
@@ -0,0 +1,42 @@
+package gfx
+
+import "testing"
+
+func TestDrawableHandoff_TakeReturnsOnlyPublishedSteps(t *testing.T) {
+	var h drawableHandoff
+	h.add(DrawableSetAdded{})
+	if got := h.take(nil); len(got) != 0 {
+		t.Fatalf("unpublished events were taken: %v", got)
+	}
+
+	h.publish()
+	h.add(DrawableSetRemoved{})
+	got := h.take(nil)
+	if len(got) != 1 {
+		t.Fatalf("expected the published step only, got %v", got)
+	}
+	if _, ok := got[0].(DrawableSetAdded); !ok {
+		t.Fatalf("unexpected event %T", got[0])
+	}
+
+	h.publish()
+	if got := h.take(got); len(got) != 1 {
+		t.Fatalf("expected the second step, got %v", got)
+	}
+}
+
+func TestDrawableHandoff_PublishKeepsUntakenSteps(t *testing.T) {
+	var h drawableHandoff
+	h.add(DrawableSetAdded{})
+	h.publish()
+	h.add(DrawableSetTranslated{})
+	h.publish()
+
+	got := h.take(nil)
+	if len(got) != 2 {
+		t.Fatalf("expected both steps, got %v", got)
+	}
+	if _, ok := got[1].(DrawableSetTranslated); !ok {
+		t.Fatalf("steps out of order: %v", got)
+	}
+}
@@ -0,0 +1,92 @@
+package gfx
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+)
+
+func TestDrawable_WorldBoundsUnionsWrappedFragments(t *testing.T) {
+	space := plane.NewToroidal2D[uint32](256, 256)
+	// Crosses both seams, so it is split into 4 fragments.
+	d := &Drawable{AABB: space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](250, 250), 10, 10))}
+
+	want := geom.NewAABB(geom.NewVec[uint32](250, 250), geom.NewVec[uint32](260, 260))
+	if got := d.WorldBounds(); got != want {
+		t.Fatalf("WorldBounds = %v, want %v", got, want)
+	}
+	if got := d.Center(); got != geom.NewVec[uint32](255, 255) {
+		t.Fatalf("Center = %v, want (255,255)", got)
+	}
+
+	plain := &Drawable{AABB: space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 20), 6, 4))}
+	if got := plain.WorldBounds(); got != plain.AABB.AABB {
+		t.Fatalf("unwrapped drawable bounds = %v, want %v", got, plain.AABB.AABB)
+	}
+	if got := plain.Center(); got != geom.NewVec[uint32](13, 22) {
+		t.Fatalf("Center = %v, want (13,22)", got)
+	}
+}
+
+func TestDrawable_EffectiveStylePrefersStyleRef(t *testing.T) {
+	shared := &SpatialStyle{Fill: color.White}
+	d := &Drawable{Style: SpatialStyle{Fill: color.Black}}
+	if got := d.EffectiveStyle().Fill; got != color.Black {
+		t.Fatalf("fill without StyleRef = %v, want black", got)
+	}
+	d.StyleRef = shared
+	shared.Fill = color.RGBA{R: 255, A: 255}
+	if got := d.EffectiveStyle().Fill; got != shared.Fill {
+		t.Errorf("fill with StyleRef = %v, want the shared red", got)
+	}
+}
+
+func TestDrawable_UserDataSurvivesLookup(t *testing.T) {
+	type gameObject struct{ name string }
+	layer := newTestPane(t, 1).GetLayer(0)
+	obj := &gameObject{name: "ship"}
+	drawable := &Drawable{UserData: obj}
+	layer.AddDrawable(drawable)
+
+	got, ok := layer.DrawableByID(drawable.ID).UserData.(*gameObject)
+	if !ok || got != obj {
+		t.Errorf("UserData = %v, want %v", got, obj)
+	}
+}
+
+func TestLayer_AddProxyMirrorsSource(t *testing.T) {
+	main := newTestPane(t, 1).GetLayer(0)
+	minimap := newTestPane(t, 1).GetLayer(0)
+	red := color.RGBA{R: 255, A: 255}
+	unit := &Drawable{Style: SpatialStyle{Fill: red}, UserData: "unit"}
+	main.AddDrawable(unit)
+
+	proxy := minimap.AddProxy(unit)
+	if proxy.Layer() != minimap || proxy.Source() != unit || proxy.ID == unit.ID {
+		t.Fatalf("proxy on %p from %p with ID %d, want its own ID on the minimap layer", proxy.Layer(), proxy.Source(), proxy.ID)
+	}
+	if got := proxy.EffectiveStyle().Fill; got != red {
+		t.Errorf("proxy fill = %v, want the source's", got)
+	}
+	if proxy.UserData != "unit" {
+		t.Errorf("proxy UserData = %v, want the source's", proxy.UserData)
+	}
+	if again := minimap.AddProxy(proxy); again.Source() != unit {
+		t.Errorf("proxy of a proxy mirrors %p, want the original source", again.Source())
+	}
+
+	minimap.RemoveDrawable(proxy)
+	if proxy.Source() != nil || len(unit.Proxies()) != 1 {
+		t.Errorf("removed proxy still tied to its source")
+	}
+	if got := proxy.EffectiveStyle().Fill; got != red {
+		t.Errorf("removed proxy fill = %v, want the style it last showed", got)
+	}
+
+	main.RemoveDrawable(unit)
+	if got := len(minimap.Drawables()); got != 0 || len(unit.Proxies()) != 0 {
+		t.Errorf("removing the source left %d proxies on the minimap", got)
+	}
+}
@@ -1,12 +1,26 @@
 package gfx
 
-import "time"
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// ECSEngine is the part of ecs.Engine the window loop drives.
+type ECSEngine interface {
+	UpdateSystems(time.Duration)
+	Shutdown()
+}
 
 type ecsUpdater struct {
 	lastTime      time.Time
 	fixedTimeStep time.Duration
 	accumulator   time.Duration
 	update        func(time.Duration)
+	alphaBits     atomic.Uint64
+	// paused, when set and true, skips the update and keeps the clock
+	// current so resuming does not replay the paused time.
+	paused func() bool
 }
 
 func newECSUpdater(fixedTimeStep time.Duration, update func(time.Duration)) *ecsUpdater {
@@ -23,6 +37,10 @@ func newECSUpdater(fixedTimeStep time.Duration, update func(time.Duration)) *ecs
 
 func (u *ecsUpdater) run() time.Duration {
 	workStart := time.Now()
+	if u.paused != nil && u.paused() {
+		u.lastTime = workStart
+		return 0
+	}
 	frameTime := workStart.Sub(u.lastTime)
 	u.lastTime = workStart
 	// Zabezpieczenie przed "Spiralą Śmierci" (Spiral of Death).
@@ -36,6 +54,13 @@ func (u *ecsUpdater) run() time.Duration {
 		u.update(u.fixedTimeStep)
 		u.accumulator -= u.fixedTimeStep
 	}
+	u.alphaBits.Store(math.Float64bits(float64(u.accumulator) / float64(u.fixedTimeStep)))
 
 	return time.Since(workStart)
 }
+
+// alpha is the fraction of a fixed step left in the accumulator after the
+// last run, in [0, 1).
+func (u *ecsUpdater) alpha() float64 {
+	return math.Float64frombits(u.alphaBits.Load())
+}
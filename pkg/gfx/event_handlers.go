@@ -0,0 +1,76 @@
+package gfx
+
+import (
+	"slices"
+	"sync"
+)
+
+// EventHandler handles an event dispatched by the window loop and reports
+// whether it consumed it.
+type EventHandler func(event Event) (handled bool)
+
+// eventHandlers is the prioritized handler chain of a window. The slice is
+// replaced, never modified in place, so dispatch walks a snapshot and
+// handlers may add or remove handlers while an event is dispatched.
+type eventHandlers struct {
+	mu      sync.Mutex
+	entries []eventHandlerEntry
+	nextID  uint64
+}
+
+type eventHandlerEntry struct {
+	id       uint64
+	priority int
+	handler  EventHandler
+}
+
+// AddEventHandler registers handler for every event the window loop
+// dispatches, e.g. for a UI layer that must see input before the game.
+// Handlers run on the loop goroutine in descending priority, equal
+// priorities in registration order, ahead of the ListenEvents dispatcher.
+// One returning true stops propagation: lower-priority handlers and the
+// dispatcher do not see the event. The window's own handling of drawable
+// events, close requests and the pointer position always runs first. The
+// returned func removes the handler. Safe to call from any goroutine,
+// handlers included.
+func (w *Window) AddEventHandler(priority int, handler EventHandler) (remove func()) {
+	if handler == nil {
+		return func() {}
+	}
+	h := &w.eventHandlers
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	entry := eventHandlerEntry{id: h.nextID, priority: priority, handler: handler}
+	// Insert after every entry of the same or a higher priority.
+	at := len(h.entries)
+	for i, existing := range h.entries {
+		if existing.priority < priority {
+			at = i
+			break
+		}
+	}
+	h.entries = slices.Insert(slices.Clip(h.entries), at, entry)
+	return func() { h.remove(entry.id) }
+}
+
+func (h *eventHandlers) remove(id uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = slices.DeleteFunc(slices.Clone(h.entries), func(entry eventHandlerEntry) bool {
+		return entry.id == id
+	})
+}
+
+// dispatch runs the handlers on event and reports whether one consumed it.
+func (h *eventHandlers) dispatch(event Event) bool {
+	h.mu.Lock()
+	entries := h.entries
+	h.mu.Unlock()
+	for _, entry := range entries {
+		if entry.handler(event) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package gfx
 
 import (
+	"github.com/kjkrol/gokg/pkg/geom"
 	"github.com/kjkrol/gokg/pkg/plane"
 	"github.com/kjkrol/gokx/internal/platform"
 )
@@ -8,30 +9,74 @@ import (
 type Event interface{}
 
 type Expose struct{}
+
+// KeyPress reports a pressed key. Key is portable across backends; Code is
+// the backend's raw code and Label its key name.
 type KeyPress struct {
 	Code  uint64
+	Key   Key
 	Label string
 }
 type KeyRelease struct {
 	Code  uint64
+	Key   Key
 	Label string
 }
+
+// TextInput reports composed text typed by the user, with the keyboard
+// layout, dead keys and input method applied, for text entry. It follows the
+// KeyPress that produced it, if any; keys that type nothing (arrows,
+// Backspace, Enter, shortcuts) only send KeyPress.
+type TextInput struct {
+	Text string
+}
+
+// Buttons masks reported by ButtonPress and ButtonRelease.
+const (
+	ButtonLeftMask uint32 = 1 << iota
+	ButtonMiddleMask
+	ButtonRightMask
+)
+
+// ButtonPress reports a pressed mouse button. Buttons holds every button held
+// at the time of the event (including Button), so chords such as
+// left+right can be detected with a mask test.
 type ButtonPress struct {
-	Button uint32
-	X, Y   int
+	Button  uint32
+	Buttons uint32
+	X, Y    int
 }
+
+// ButtonRelease reports a released mouse button. Buttons holds the buttons
+// still held after the release.
 type ButtonRelease struct {
-	Button uint32
-	X, Y   int
+	Button  uint32
+	Buttons uint32
+	X, Y    int
 }
 type MotionNotify struct {
 	X, Y int
 }
-type EnterNotify struct{}
-type LeaveNotify struct{}
+
+// EnterNotify reports the pointer entering the window at X, Y (window
+// coordinates). SDL reports the last tracked pointer position.
+type EnterNotify struct {
+	X, Y int
+}
+
+// LeaveNotify reports the pointer leaving the window; X, Y is where it
+// crossed the edge and may lie outside the window.
+type LeaveNotify struct {
+	X, Y int
+}
+
 type CreateNotify struct{}
 type DestroyNotify struct{}
 type ClientMessage struct{}
+
+// CloseRequest reports that the user asked to close the window. The window
+// stops on its own unless a handler set via SetCloseRequestHandler vetoes it.
+type CloseRequest struct{}
 type MouseWheel struct {
 	DeltaX float64
 	DeltaY float64
@@ -39,6 +84,24 @@ type MouseWheel struct {
 }
 type UnexpectedEvent struct{}
 
+// pointerPosition returns the window position carried by a pointer event.
+// LeaveNotify is not one: its position may lie outside the window.
+func pointerPosition(event Event) (x, y int, ok bool) {
+	switch e := event.(type) {
+	case MotionNotify:
+		return e.X, e.Y, true
+	case ButtonPress:
+		return e.X, e.Y, true
+	case ButtonRelease:
+		return e.X, e.Y, true
+	case EnterNotify:
+		return e.X, e.Y, true
+	case MouseWheel:
+		return e.X, e.Y, true
+	}
+	return 0, 0, false
+}
+
 type DrawableAdd struct {
 	PaneID     uint64
 	LayerID    uint64
@@ -60,6 +123,18 @@ type DrawableTranslate struct {
 	New        plane.AABB[uint32]
 }
 
+// DrawableMove moves a drawable by Delta world units relative to its current
+// position in the grid, for producers that only know velocities. Applying it
+// reads the current entry, so it costs a lookup more than the absolute
+// DrawableTranslate; use DrawableTranslate to teleport or when the new AABB is
+// already at hand. The drawable's AABB is updated to the new position.
+type DrawableMove struct {
+	PaneID     uint64
+	LayerID    uint64
+	DrawableID uint64
+	Delta      geom.Vec[int]
+}
+
 type DrawableSetAdded struct {
 	Items []DrawableAdd
 }
@@ -72,6 +147,10 @@ type DrawableSetTranslated struct {
 	Items []DrawableTranslate
 }
 
+type DrawableSetMoved struct {
+	Items []DrawableMove
+}
+
 // DrawableEventsApplier consumes bulk drawable events and flushes pending changes.
 type DrawableEventsApplier interface {
 	ApplyAdded(items []DrawableAdd)
@@ -80,28 +159,46 @@ type DrawableEventsApplier interface {
 	FlushTouched()
 }
 
+// FlushTouchedReporter is implemented by a DrawableEventsApplier that can
+// tell whether its next FlushTouched has changes to apply, so a window
+// rendering on demand draws changes made outside the event loop.
+type FlushTouchedReporter interface {
+	Touched() bool
+}
+
+// DrawableMovedApplier is implemented by a DrawableEventsApplier that also
+// applies relative moves. The window drops DrawableSetMoved events, and does
+// not move cursor drawables, for an applier without it.
+type DrawableMovedApplier interface {
+	ApplyMoved(items []DrawableMove)
+}
+
 func convert(event platform.Event) Event {
 	switch e := event.(type) {
 	case platform.KeyPress:
-		return KeyPress{Code: e.Code, Label: e.Label}
+		return KeyPress{Code: e.Code, Key: Key(e.Key), Label: e.Label}
 	case platform.KeyRelease:
-		return KeyRelease{Code: e.Code, Label: e.Label}
+		return KeyRelease{Code: e.Code, Key: Key(e.Key), Label: e.Label}
+	case platform.TextInput:
+		return TextInput{Text: e.Text}
 	case platform.ButtonPress:
-		return ButtonPress{Button: e.Button, X: e.X, Y: e.Y}
+		return ButtonPress{Button: e.Button, Buttons: e.Buttons, X: e.X, Y: e.Y}
 	case platform.ButtonRelease:
-		return ButtonRelease{Button: e.Button, X: e.X, Y: e.Y}
+		return ButtonRelease{Button: e.Button, Buttons: e.Buttons, X: e.X, Y: e.Y}
 	case platform.MotionNotify:
 		return MotionNotify{X: e.X, Y: e.Y}
 	case platform.EnterNotify:
-		return EnterNotify{}
+		return EnterNotify{X: e.X, Y: e.Y}
 	case platform.LeaveNotify:
-		return LeaveNotify{}
+		return LeaveNotify{X: e.X, Y: e.Y}
 	case platform.CreateNotify:
 		return CreateNotify{}
 	case platform.DestroyNotify:
 		return DestroyNotify{}
 	case platform.ClientMessage:
 		return ClientMessage{}
+	case platform.CloseRequest:
+		return CloseRequest{}
 	case platform.MouseWheel:
 		return MouseWheel{DeltaX: e.DeltaX, DeltaY: e.DeltaY, X: e.X, Y: e.Y}
 	default:
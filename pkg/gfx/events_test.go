@@ -0,0 +1,22 @@
+package gfx
+
+import (
+	"testing"
+
+	"github.com/kjkrol/gokx/internal/platform"
+)
+
+func TestConvert_EnterLeaveCarryCoordinates(t *testing.T) {
+	if got := convert(platform.EnterNotify{X: 3, Y: 4}); got != (EnterNotify{X: 3, Y: 4}) {
+		t.Errorf("enter = %#v", got)
+	}
+	if got := convert(platform.LeaveNotify{X: -1, Y: 7}); got != (LeaveNotify{X: -1, Y: 7}) {
+		t.Errorf("leave = %#v", got)
+	}
+}
+
+func TestConvert_TextInput(t *testing.T) {
+	if got := convert(platform.TextInput{Text: "é"}); got != (TextInput{Text: "é"}) {
+		t.Errorf("text input = %#v", got)
+	}
+}
@@ -12,6 +12,14 @@ type FrameSource interface {
 	AcknowledgeRendered(layer *Layer, bucketIndices []uint32)
 }
 
+// SubpixelFrameSource is implemented by frame sources that keep sub-pixel
+// entry positions. EntrySubpixelAABB returns the fragment in fixed-point units
+// (world units << bits); ok is false for entries placed in whole world units,
+// which render from EntryAABB.
+type SubpixelFrameSource interface {
+	EntrySubpixelAABB(layer *Layer, entryID uint64) (aabb spatial.AABB, bits uint8, ok bool)
+}
+
 type FramePlan struct {
 	ViewRect       spatial.AABB
 	ViewChanged    bool
@@ -20,7 +28,10 @@ type FramePlan struct {
 }
 
 type LayerPlan struct {
-	Layer         *Layer
+	Layer *Layer
+	// ViewRect is the world rect the layer shows; it differs from the frame's
+	// ViewRect for parallax layers. Sources may leave it zero.
+	ViewRect      spatial.AABB
 	CacheRect     spatial.AABB
 	BucketIndices []uint32
 	BucketRect    func(uint32) geom.AABB[uint32]
@@ -32,3 +43,12 @@ type BucketDelta struct {
 	Removed []uint64
 	Updated []uint64
 }
+
+// LayerViewRect returns the world rect plan's layer shows: plan.ViewRect when
+// the source set one, else the frame's ViewRect.
+func (f FramePlan) LayerViewRect(plan LayerPlan) spatial.AABB {
+	if plan.ViewRect.BottomRight == plan.ViewRect.TopLeft {
+		return f.ViewRect
+	}
+	return plan.ViewRect
+}
@@ -5,6 +5,8 @@ import "sync/atomic"
 var drawableIDSeq uint64
 
 // NextDrawableID returns a globally unique drawable ID.
+// Layer.AddDrawable calls it for drawables added with a zero ID; use it
+// directly only when the ID must be known before the drawable is added.
 func NextDrawableID() uint64 {
 	return atomic.AddUint64(&drawableIDSeq, 1)
 }
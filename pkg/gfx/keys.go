@@ -0,0 +1,64 @@
+package gfx
+
+import "github.com/kjkrol/gokx/internal/platform"
+
+// Key identifies a physical key independently of the backend. KeyPress.Code
+// still carries the backend's raw code (X11 keysym, SDL scancode, 0 on WASM).
+type Key uint16
+
+const (
+	KeyUnknown    Key = Key(platform.KeyUnknown)
+	KeyA          Key = Key(platform.KeyA)
+	KeyB          Key = Key(platform.KeyB)
+	KeyC          Key = Key(platform.KeyC)
+	KeyD          Key = Key(platform.KeyD)
+	KeyE          Key = Key(platform.KeyE)
+	KeyF          Key = Key(platform.KeyF)
+	KeyG          Key = Key(platform.KeyG)
+	KeyH          Key = Key(platform.KeyH)
+	KeyI          Key = Key(platform.KeyI)
+	KeyJ          Key = Key(platform.KeyJ)
+	KeyK          Key = Key(platform.KeyK)
+	KeyL          Key = Key(platform.KeyL)
+	KeyM          Key = Key(platform.KeyM)
+	KeyN          Key = Key(platform.KeyN)
+	KeyO          Key = Key(platform.KeyO)
+	KeyP          Key = Key(platform.KeyP)
+	KeyQ          Key = Key(platform.KeyQ)
+	KeyR          Key = Key(platform.KeyR)
+	KeyS          Key = Key(platform.KeyS)
+	KeyT          Key = Key(platform.KeyT)
+	KeyU          Key = Key(platform.KeyU)
+	KeyV          Key = Key(platform.KeyV)
+	KeyW          Key = Key(platform.KeyW)
+	KeyX          Key = Key(platform.KeyX)
+	KeyY          Key = Key(platform.KeyY)
+	KeyZ          Key = Key(platform.KeyZ)
+	Key0          Key = Key(platform.Key0)
+	Key1          Key = Key(platform.Key1)
+	Key2          Key = Key(platform.Key2)
+	Key3          Key = Key(platform.Key3)
+	Key4          Key = Key(platform.Key4)
+	Key5          Key = Key(platform.Key5)
+	Key6          Key = Key(platform.Key6)
+	Key7          Key = Key(platform.Key7)
+	Key8          Key = Key(platform.Key8)
+	Key9          Key = Key(platform.Key9)
+	KeyEscape     Key = Key(platform.KeyEscape)
+	KeyEnter      Key = Key(platform.KeyEnter)
+	KeySpace      Key = Key(platform.KeySpace)
+	KeyTab        Key = Key(platform.KeyTab)
+	KeyBackspace  Key = Key(platform.KeyBackspace)
+	KeyLeft       Key = Key(platform.KeyLeft)
+	KeyRight      Key = Key(platform.KeyRight)
+	KeyUp         Key = Key(platform.KeyUp)
+	KeyDown       Key = Key(platform.KeyDown)
+	KeyLeftShift  Key = Key(platform.KeyLeftShift)
+	KeyRightShift Key = Key(platform.KeyRightShift)
+	KeyLeftCtrl   Key = Key(platform.KeyLeftCtrl)
+	KeyRightCtrl  Key = Key(platform.KeyRightCtrl)
+	KeyLeftAlt    Key = Key(platform.KeyLeftAlt)
+	KeyRightAlt   Key = Key(platform.KeyRightAlt)
+	KeyLeftSuper  Key = Key(platform.KeyLeftSuper)
+	KeyRightSuper Key = Key(platform.KeyRightSuper)
+)
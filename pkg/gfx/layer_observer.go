@@ -9,3 +9,31 @@ type LayerObserver interface {
 	OnDrawableRemoved(layer *Layer, drawable *Drawable, id uint64)
 	OnLayerDirtyRect(layer *Layer, rect spatial.AABB)
 }
+
+// DrawableMoveObserver is an optional LayerObserver extension. When source and
+// destination layers share the observer, MoveDrawableTo reports a single move
+// instead of a removal followed by an addition.
+type DrawableMoveObserver interface {
+	OnDrawableMoved(src, dst *Layer, drawable *Drawable, id uint64)
+}
+
+// DrawableStyleObserver is an optional LayerObserver extension notified by
+// Layer.ModifyStyle, so renderers re-upload the drawable's instance without a
+// spatial move.
+type DrawableStyleObserver interface {
+	OnDrawableStyleChanged(layer *Layer, drawable *Drawable, id uint64)
+}
+
+// LayerRemovedObserver is an optional LayerObserver extension notified when
+// Pane.Clear removes a layer, so the observer can free what it keeps for it.
+// The layer still reports its pane and ID during the call.
+type LayerRemovedObserver interface {
+	OnLayerRemoved(layer *Layer)
+}
+
+// LayerQuerier is implemented by layer observers backed by a spatial index.
+// QueryRange reports the fragment entry IDs intersecting rect (wrap-aware);
+// map them back with Layer.DrawableByEntryID.
+type LayerQuerier interface {
+	QueryRange(layer *Layer, rect spatial.AABB, collector func(entryID uint64))
+}
@@ -1,6 +1,10 @@
 package gfx
 
 import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
 	"sync"
 
 	"github.com/kjkrol/gokg/pkg/geom"
@@ -10,6 +14,84 @@ type PaneConfig struct {
 	Width, Height    int
 	OffsetX, OffsetY int
 	World            WorldConfig
+	// LogicalWidth and LogicalHeight, when set, give the viewport size in
+	// world units; the renderer scales them to the Width x Height pixels of
+	// the pane. Unset, one world unit is one pixel.
+	LogicalWidth, LogicalHeight int
+	// InternalWidth and InternalHeight, when set, give the pixel size the
+	// pane renders at, e.g. a small pixel-art resolution. The result is
+	// upscaled with nearest filtering by the largest integer factor that
+	// fits Width x Height and centered, letterboxing the remainder (see
+	// PresentRect). The logical size defaults to the internal size.
+	InternalWidth, InternalHeight int
+	// CompositeOrder sets which end of Layers() is drawn on top. The zero
+	// value, CompositeBottomUp, draws Layers()[0] at the bottom.
+	CompositeOrder CompositeOrder
+}
+
+// CompositeOrder selects how the stacking positions of a pane's layers map
+// to draw order.
+type CompositeOrder uint8
+
+const (
+	// CompositeBottomUp draws the layers in slice order: position 0 is the
+	// bottom of the stack and the last layer is on top.
+	CompositeBottomUp CompositeOrder = iota
+	// CompositeTopDown draws the layers in reverse slice order: position 0
+	// is on top. The base layer starts opaque, so give it a transparent
+	// background or move it to the end to keep the layers below visible.
+	CompositeTopDown
+)
+
+// LogicalSize returns the viewport size in world units.
+func (c *PaneConfig) LogicalSize() (int, int) {
+	w, h := c.RenderSize()
+	if c.LogicalWidth > 0 {
+		w = c.LogicalWidth
+	}
+	if c.LogicalHeight > 0 {
+		h = c.LogicalHeight
+	}
+	return w, h
+}
+
+// RenderSize returns the pixel size of the pane's render target: the internal
+// size when set, Width x Height otherwise.
+func (c *PaneConfig) RenderSize() (int, int) {
+	w, h := c.Width, c.Height
+	if c.InternalWidth > 0 {
+		w = c.InternalWidth
+	}
+	if c.InternalHeight > 0 {
+		h = c.InternalHeight
+	}
+	return w, h
+}
+
+// PresentRect returns the window rect the pane's render target is drawn to.
+// It is the pane rect unless an internal size is set; then it is the internal
+// size times the largest integer factor fitting the pane, centered in it. A
+// pane smaller than its internal size is scaled down to fit instead.
+func (c *PaneConfig) PresentRect() image.Rectangle {
+	rect := image.Rect(c.OffsetX, c.OffsetY, c.OffsetX+c.Width, c.OffsetY+c.Height)
+	if c.InternalWidth <= 0 && c.InternalHeight <= 0 {
+		return rect
+	}
+	iw, ih := c.RenderSize()
+	if iw <= 0 || ih <= 0 {
+		return rect
+	}
+	w, h := iw, ih
+	if k := min(c.Width/iw, c.Height/ih); k >= 1 {
+		w, h = iw*k, ih*k
+	} else {
+		fit := min(float64(c.Width)/float64(iw), float64(c.Height)/float64(ih))
+		w = max(0, int(float64(iw)*fit))
+		h = max(0, int(float64(ih)*fit))
+	}
+	x := c.OffsetX + (c.Width-w)/2
+	y := c.OffsetY + (c.Height-h)/2
+	return image.Rect(x, y, x+w, y+h)
 }
 
 type Pane struct {
@@ -19,25 +101,52 @@ type Pane struct {
 	viewport       *Viewport
 	onLayerCreated func(*Layer)
 	layerObserver  LayerObserver
+	tint           color.Color
+	tintStrength   float32
+	aspectW        int
+	aspectH        int
+	cursor         *Drawable
+	onViewChange   func()
+	viewVersion    uint64
+	viewSeen       bool
+	viewDirty      bool
+	zOrder         int
+	nextLayerID    int
+	scrollRemX     float64
+	scrollRemY     float64
 	mu             sync.Mutex
 }
 
+// NewPane creates a pane that belongs to no window, e.g. to drive a layer
+// observer in tests. Panes drawn on screen come from Window.AddPane.
+func NewPane(conf *PaneConfig, id uint64) *Pane {
+	return newPane(conf, id)
+}
+
 func newPane(conf *PaneConfig, id uint64) *Pane {
 	layers := make([]*Layer, 1)
-	conf.World = normalizeWorldConfig(conf.World, conf.Width, conf.Height)
+	applyInitialZoom(conf)
+	logicalWidth, logicalHeight := conf.LogicalSize()
+	conf.World = normalizeWorldConfig(conf.World, logicalWidth, logicalHeight)
 	worldSide := conf.World.WorldResolution.Side()
 	pane := Pane{
-		ID:     id,
-		Config: conf,
-		layers: layers,
+		ID:          id,
+		Config:      conf,
+		layers:      layers,
+		nextLayerID: 1,
 	}
-	pane.viewport = NewViewport(
+	wrapX, wrapY := conf.World.WrapAxes()
+	pane.viewport = NewViewportAxes(
 		geom.NewVec(worldSide, worldSide),
-		geom.NewVec(uint32(conf.Width), uint32(conf.Height)),
-		conf.World.WorldWrap,
+		geom.NewVec(uint32(logicalWidth), uint32(logicalHeight)),
+		wrapX, wrapY,
 	)
+	pane.viewport.SetOrigin(conf.World.InitialOrigin.X, conf.World.InitialOrigin.Y)
 	layer := NewLayerDefault(&pane)
 	layer.idx = 0
+	// The base layer is opaque; layers from AddLayer stay transparent so
+	// lower layers show through them.
+	layer.background = color.Black
 	if pane.layerObserver != nil {
 		layer.SetObserver(pane.layerObserver)
 	}
@@ -45,6 +154,21 @@ func newPane(conf *PaneConfig, id uint64) *Pane {
 	return &pane
 }
 
+// applyInitialZoom folds WorldConfig.InitialZoom into the logical size.
+func applyInitialZoom(conf *PaneConfig) {
+	zoom := float64(conf.World.InitialZoom)
+	if zoom <= 0 || zoom == 1 {
+		return
+	}
+	w, h := conf.LogicalSize()
+	conf.LogicalWidth = max(1, int(math.Round(float64(w)/zoom)))
+	conf.LogicalHeight = max(1, int(math.Round(float64(h)/zoom)))
+	conf.World.InitialZoom = 1
+}
+
+// IDValue returns the pane ID: 0 for the default pane, then 1, 2, ... in
+// AddPane order. IDs are never reused within a window, so adding panes in
+// the same order rebuilds a saved scene with the same IDs.
 func (p *Pane) IDValue() uint64 {
 	if p == nil {
 		return 0
@@ -52,28 +176,37 @@ func (p *Pane) IDValue() uint64 {
 	return p.ID
 }
 
-func (p *Pane) AddLayer(num int) bool {
-	// Require sequential addition so layer index == position == ID.
-	if num < 0 || num != len(p.layers) {
+// AddLayer creates a layer and inserts it at stacking position index
+// (0 = bottom, len(Layers()) = top; the other way round under
+// CompositeTopDown), shifting the layers at and above index
+// up by one. The new layer gets the next free ID; existing layer IDs are
+// stable, as with MoveLayer. It returns false, adding nothing, when index is
+// outside [0, len(Layers())]. Inserting below existing layers marks the pane
+// dirty so its composite is restacked.
+func (p *Pane) AddLayer(index int) bool {
+	p.mu.Lock()
+	n := len(p.layers)
+	if index < 0 || index > n {
+		p.mu.Unlock()
 		return false
 	}
-	for _, existing := range p.layers {
-		if existing != nil && existing.idx == num {
-			return false
-		}
-	}
+	// IDs are never reused, not even after Clear, however the layers are
+	// stacked.
 	layer := NewLayerDefault(p)
-	layer.idx = num
+	layer.idx = p.nextLayerID
+	p.nextLayerID++
+	p.layers = slices.Insert(p.layers, index, layer)
+	p.mu.Unlock()
+
 	if p.layerObserver != nil {
 		layer.SetObserver(p.layerObserver)
 	}
-
-	p.mu.Lock()
-	p.layers = append(p.layers, layer)
-	p.mu.Unlock()
 	if p.onLayerCreated != nil {
 		p.onLayerCreated(layer)
 	}
+	if index < n {
+		layer.markAllDirty()
+	}
 	return true
 }
 
@@ -86,6 +219,57 @@ func (p *Pane) GetLayer(num int) *Layer {
 	return p.layers[num]
 }
 
+// MoveLayer moves the layer at position from to position to, shifting the
+// layers in between. Layers are composited in slice order (reversed under
+// CompositeTopDown), so this changes the stacking; layer IDs are stable and
+// unaffected.
+func (p *Pane) MoveLayer(from, to int) bool {
+	p.mu.Lock()
+	n := len(p.layers)
+	if from < 0 || from >= n || to < 0 || to >= n {
+		p.mu.Unlock()
+		return false
+	}
+	if from == to {
+		p.mu.Unlock()
+		return true
+	}
+	layer := p.layers[from]
+	if from < to {
+		copy(p.layers[from:to], p.layers[from+1:to+1])
+	} else {
+		copy(p.layers[to+1:from+1], p.layers[to:from])
+	}
+	p.layers[to] = layer
+	lowest := p.layers[min(from, to)]
+	p.mu.Unlock()
+
+	// Composites are rebuilt for every dirty rect across all layers, so one
+	// fully dirty layer is enough to restack the whole pane.
+	lowest.markAllDirty()
+	return true
+}
+
+// SwapLayers exchanges the stacking positions of two layers.
+func (p *Pane) SwapLayers(a, b int) bool {
+	p.mu.Lock()
+	n := len(p.layers)
+	if a < 0 || a >= n || b < 0 || b >= n {
+		p.mu.Unlock()
+		return false
+	}
+	if a == b {
+		p.mu.Unlock()
+		return true
+	}
+	p.layers[a], p.layers[b] = p.layers[b], p.layers[a]
+	lowest := p.layers[min(a, b)]
+	p.mu.Unlock()
+
+	lowest.markAllDirty()
+	return true
+}
+
 func (p *Pane) Layers() []*Layer {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -94,6 +278,67 @@ func (p *Pane) Layers() []*Layer {
 	return out
 }
 
+// CompositeLayers returns the layers in draw order, bottom first, as set by
+// PaneConfig.CompositeOrder. Renderers composite in this order.
+func (p *Pane) CompositeLayers() []*Layer {
+	layers := p.Layers()
+	if p.Config != nil && p.Config.CompositeOrder == CompositeTopDown {
+		slices.Reverse(layers)
+	}
+	return layers
+}
+
+// EachLayer calls fn for every layer in stacking order, Layers()[0] first, with
+// its ID. A saved stack is rebuilt by creating the layers in ID order with
+// AddLayer, which reproduces the IDs, and then restacking with MoveLayer.
+func (p *Pane) EachLayer(fn func(id uint64, l *Layer)) {
+	for _, layer := range p.Layers() {
+		fn(layer.ID(), layer)
+	}
+}
+
+// Clear resets the pane to its base layer (ID 0), e.g. when switching
+// scenes: every other layer is removed and so are the base layer's drawables,
+// while its background and the viewport are kept. The observer of a removed
+// layer is told through LayerRemovedObserver (gridbridge.Bridge drops the
+// layer's grid manager) and GPU renderers free the layer's textures and
+// instance buffers on the next frame. Drawables of removed layers are
+// detached without per-drawable notifications. Layers added afterwards get
+// new IDs.
+//
+// Clear must run on the window loop goroutine, e.g. from OnFrame or an event
+// handler: the renderer reads the removed layers while drawing. Under
+// SetUpdateThreaded, ECS systems must not call it.
+func (p *Pane) Clear() {
+	p.mu.Lock()
+	var base *Layer
+	removed := make([]*Layer, 0, len(p.layers))
+	for _, layer := range p.layers {
+		if layer.idx == 0 {
+			base = layer
+			continue
+		}
+		removed = append(removed, layer)
+	}
+	p.layers = p.layers[:0]
+	if base != nil {
+		p.layers = append(p.layers, base)
+	}
+	p.mu.Unlock()
+
+	for _, layer := range removed {
+		layer.release()
+	}
+	if base == nil {
+		return
+	}
+	for _, drawable := range base.Drawables() {
+		base.RemoveDrawable(drawable)
+	}
+	// The composite still shows the removed layers; repaint all of it.
+	base.markAllDirty()
+}
+
 func (p *Pane) Close() {
 	p.Config = nil
 	p.viewport = nil
@@ -110,26 +355,399 @@ func (p *Pane) WindowToPaneCoords(x, y int) (int, int) {
 	return x - p.Config.OffsetX, y - p.Config.OffsetY
 }
 
+// LogicalScale returns the window pixels per world unit along each axis; both
+// are 1 unless the pane sets a logical or internal size.
+func (p *Pane) LogicalScale() (float64, float64) {
+	if p == nil || p.Config == nil {
+		return 1, 1
+	}
+	rect := p.ContentRect()
+	return unitScale(rect.Dx(), rect.Dy(), p.Config)
+}
+
+// RenderScale returns the render-target pixels per world unit along each
+// axis. It equals LogicalScale unless the pane sets an internal size.
+func (p *Pane) RenderScale() (float64, float64) {
+	if p == nil || p.Config == nil {
+		return 1, 1
+	}
+	w, h := p.RenderSize()
+	return unitScale(w, h, p.Config)
+}
+
+// RenderSize returns the pixel size of the pane's render target: the internal
+// size when set, otherwise the ContentRect size.
+func (p *Pane) RenderSize() (int, int) {
+	if p.Config.InternalWidth > 0 || p.Config.InternalHeight > 0 {
+		return p.Config.RenderSize()
+	}
+	rect := p.ContentRect()
+	return rect.Dx(), rect.Dy()
+}
+
+func unitScale(width, height int, conf *PaneConfig) (float64, float64) {
+	w, h := conf.LogicalSize()
+	sx, sy := 1.0, 1.0
+	if w > 0 && width > 0 {
+		sx = float64(width) / float64(w)
+	}
+	if h > 0 && height > 0 {
+		sy = float64(height) / float64(h)
+	}
+	return sx, sy
+}
+
+// WindowToWorldCoords maps a window pixel to world units:
+// world = origin + (window - contentOrigin) / scale, wrapped on the wrapping
+// axes. The content origin is the pane offset unless the pane is letterboxed
+// (see ContentRect).
 func (p *Pane) WindowToWorldCoords(x, y int) (uint32, uint32) {
-	px, py := p.WindowToPaneCoords(x, y)
+	content := p.ContentRect().Min
+	px, py := x-content.X, y-content.Y
+	sx, sy := p.LogicalScale()
+	px = int(math.Floor(float64(px) / sx))
+	py = int(math.Floor(float64(py) / sy))
 	if p.viewport == nil {
 		return clampIntToUint(px), clampIntToUint(py)
 	}
 	origin := p.viewport.Origin()
 	wx := clampIntToUint(px) + origin.X
 	wy := clampIntToUint(py) + origin.Y
-	if p.viewport.Wrap() {
-		world := p.viewport.WorldSize()
+	wrapX, wrapY := p.viewport.WrapAxes()
+	world := p.viewport.WorldSize()
+	if wrapX {
 		wx = wrapUint(wx, world.X)
+	}
+	if wrapY {
 		wy = wrapUint(wy, world.Y)
 	}
 	return wx, wy
 }
 
+// PointerWorld returns the world position of a pointer event (MotionNotify,
+// ButtonPress, ButtonRelease, EnterNotify or MouseWheel) through
+// WindowToWorldCoords, which accounts for the pane offset and letterboxing,
+// the viewport origin, the logical scale (zoom) and wrapping. ok is false
+// for other events and for positions outside the pane's ContentRect.
+func (p *Pane) PointerWorld(event Event) (geom.Vec[uint32], bool) {
+	x, y, ok := pointerPosition(event)
+	if !ok || !image.Pt(x, y).In(p.ContentRect()) {
+		return geom.Vec[uint32]{}, false
+	}
+	wx, wy := p.WindowToWorldCoords(x, y)
+	return geom.NewVec(wx, wy), true
+}
+
+// WorldToWindowCoords is the inverse of WindowToWorldCoords:
+// window = contentOrigin + (world - origin) * scale. On a wrapping axis the
+// world point is taken at its copy right of (below) the origin.
+func (p *Pane) WorldToWindowCoords(x, y uint32) (int, int) {
+	dx, dy := int64(x), int64(y)
+	if p.viewport != nil {
+		origin := p.viewport.Origin()
+		dx -= int64(origin.X)
+		dy -= int64(origin.Y)
+		wrapX, wrapY := p.viewport.WrapAxes()
+		world := p.viewport.WorldSize()
+		if wrapX && dx < 0 {
+			dx += int64(world.X)
+		}
+		if wrapY && dy < 0 {
+			dy += int64(world.Y)
+		}
+	}
+	sx, sy := p.LogicalScale()
+	content := p.ContentRect().Min
+	return content.X + int(math.Round(float64(dx)*sx)),
+		content.Y + int(math.Round(float64(dy)*sy))
+}
+
+// SetWorldSize changes the extent of a bounded world after construction, e.g.
+// when loading another map into the same window. The size is clamped to the
+// side of WorldConfig.WorldResolution, which fixes the grid the layers are
+// indexed in. The viewport origin is clamped into the new world and every
+// layer is repainted. A world wrapping on any axis keeps its size and ignores
+// the call.
+func (p *Pane) SetWorldSize(w, h uint32) {
+	if p.viewport == nil || p.viewport.Wrap() {
+		return
+	}
+	side := p.Config.World.WorldResolution.Side()
+	world := geom.NewVec(min(w, side), min(h, side))
+	if world == p.viewport.WorldSize() {
+		return
+	}
+	layers := p.Layers()
+	// Repaint the old extent too, so buckets outside a shrunk world drop
+	// their cached contents.
+	for _, layer := range layers {
+		layer.markAllDirty()
+	}
+	p.viewport.SetWorldSize(world)
+	for _, layer := range layers {
+		layer.Invalidate()
+	}
+}
+
+// CenterViewOn moves the viewport so the world point (x, y) sits at its
+// center: origin = point - viewSize/2. The origin wraps on a torus and is
+// clamped to the world on a bounded plane, as with Viewport.SetOrigin.
+func (p *Pane) CenterViewOn(worldX, worldY uint32) {
+	if p.viewport == nil {
+		return
+	}
+	size := p.viewport.Size()
+	// Underflow wraps modulo 2^32, which normalize maps back into a
+	// power-of-two torus or clamps to 0 on a bounded plane.
+	p.viewport.SetOrigin(worldX-size.X/2, worldY-size.Y/2)
+}
+
+// ScrollByPixels moves the viewport by a delta in window pixels, converted to
+// world units by the pane's LogicalScale. Fractions of a world unit carry
+// over to the next call, so slow drags at a high scale still move the view.
+func (p *Pane) ScrollByPixels(dx, dy int) {
+	if p.viewport == nil {
+		return
+	}
+	sx, sy := p.LogicalScale()
+	p.mu.Lock()
+	p.scrollRemX += float64(dx) / sx
+	p.scrollRemY += float64(dy) / sy
+	wx, wy := math.Trunc(p.scrollRemX), math.Trunc(p.scrollRemY)
+	p.scrollRemX -= wx
+	p.scrollRemY -= wy
+	p.mu.Unlock()
+	if wx == 0 && wy == 0 {
+		return
+	}
+	p.viewport.Move(int32(wx), int32(wy))
+}
+
+// SetTint makes the final composite lerp the pane's colors toward c by
+// strength (clamped to [0, 1]), e.g. a gray tint to mark a panel disabled. The
+// pane contents are not re-rendered. A nil color or zero strength removes the
+// tint. Under render-on-demand, calls made outside the event loop need
+// Window.Invalidate to show up.
+func (p *Pane) SetTint(c color.Color, strength float32) {
+	strength = min(max(strength, 0), 1)
+	if c == nil {
+		strength = 0
+	}
+	p.mu.Lock()
+	p.tint = c
+	p.tintStrength = strength
+	p.mu.Unlock()
+}
+
+// SetAspectLock keeps the pane's content at a ratioW:ratioH aspect ratio: it
+// is drawn into the largest centered rect of that ratio within the pane (see
+// ContentRect) and the bars around it are cleared to the background of the
+// bottom layer. Give the pane a logical size of the same ratio so world units
+// stay square. A non-positive ratio removes the lock. A new lock changes
+// RenderSize, so every layer is repainted.
+func (p *Pane) SetAspectLock(ratioW, ratioH int) {
+	if ratioW <= 0 || ratioH <= 0 {
+		ratioW, ratioH = 0, 0
+	}
+	p.mu.Lock()
+	changed := p.aspectW != ratioW || p.aspectH != ratioH
+	p.aspectW, p.aspectH = ratioW, ratioH
+	p.mu.Unlock()
+	if !changed {
+		return
+	}
+	for _, layer := range p.Layers() {
+		layer.markAllDirty()
+	}
+	if p.viewport != nil {
+		p.viewport.invalidate()
+	}
+}
+
+// ContentRect returns the window rect the pane's content is drawn to:
+// PaneConfig.PresentRect, narrowed to the ratio set by SetAspectLock. Map
+// pointer positions through it (or WindowToWorldCoords, which does) to stay
+// correct inside a letterboxed pane.
+func (p *Pane) ContentRect() image.Rectangle {
+	rect := p.Config.PresentRect()
+	p.mu.Lock()
+	rw, rh := p.aspectW, p.aspectH
+	p.mu.Unlock()
+	if rw == 0 || rh == 0 {
+		return rect
+	}
+	w, h := rect.Dx(), rect.Dy()
+	if w*rh > h*rw {
+		w = h * rw / rh
+	} else {
+		h = w * rh / rw
+	}
+	x := rect.Min.X + (rect.Dx()-w)/2
+	y := rect.Min.Y + (rect.Dy()-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// SetCursorDrawable makes d a software cursor, e.g. a reticle or a brush
+// preview: while the pointer is over the pane, the window loop centers d on
+// the pointer's world position once per frame, before the grid is flushed.
+// d must already be on one of the pane's layers; it is moved with a
+// DrawableMove through the window's DrawableMovedApplier, which keeps it
+// wrapped into the space. Pass nil to stop tracking; d stays where it is.
+func (p *Pane) SetCursorDrawable(d *Drawable) {
+	p.mu.Lock()
+	p.cursor = d
+	p.mu.Unlock()
+}
+
+// CursorDrawable returns the drawable set by SetCursorDrawable, or nil.
+func (p *Pane) CursorDrawable() *Drawable {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cursor
+}
+
+// cursorMove returns the move centering the cursor drawable on the window
+// pixel (x, y). ok is false when there is no cursor, it is not on one of the
+// pane's layers, the pixel lies outside the content or the cursor is already
+// there.
+func (p *Pane) cursorMove(x, y int) (DrawableMove, bool) {
+	d := p.CursorDrawable()
+	if d == nil || d.layer == nil || d.layer.GetPane() != p || p.viewport == nil {
+		return DrawableMove{}, false
+	}
+	if !image.Pt(x, y).In(p.ContentRect()) {
+		return DrawableMove{}, false
+	}
+	id, ok := d.layer.DrawableID(d)
+	if !ok {
+		return DrawableMove{}, false
+	}
+	tx, ty := p.WindowToWorldCoords(x, y)
+	center := d.Center()
+	world := p.viewport.WorldSize()
+	wrapX, wrapY := p.viewport.WrapAxes()
+	delta := geom.NewVec(
+		int(cursorDelta(center.X, tx, world.X, wrapX)),
+		int(cursorDelta(center.Y, ty, world.Y, wrapY)),
+	)
+	if delta.X == 0 && delta.Y == 0 {
+		return DrawableMove{}, false
+	}
+	return DrawableMove{PaneID: p.ID, LayerID: d.layer.ID(), DrawableID: id, Delta: delta}, true
+}
+
+// cursorDelta is the signed step from from to to, the short way across the
+// seam on a wrapping axis.
+func cursorDelta(from, to, side uint32, wrap bool) int64 {
+	if wrap && side > 0 {
+		return wrapDelta(from%side, to, side)
+	}
+	return int64(to) - int64(from)
+}
+
+// SetZOrder sets the pane's compositing order within its window: panes with a
+// higher z-order are drawn on top. Panes with equal z-order (all start at 0)
+// keep their insertion order, the default pane first. A change invalidates
+// the viewport, so the pane is recomposited and a window rendering on demand
+// redraws.
+func (p *Pane) SetZOrder(z int) {
+	p.mu.Lock()
+	changed := p.zOrder != z
+	p.zOrder = z
+	p.mu.Unlock()
+	if changed && p.viewport != nil {
+		p.viewport.invalidate()
+	}
+}
+
+func (p *Pane) ZOrder() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.zOrder
+}
+
+// Tint returns the color and strength set by SetTint; strength is 0 when the
+// pane is not tinted.
+func (p *Pane) Tint() (color.Color, float32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tintStrength == 0 {
+		return nil, 0
+	}
+	return p.tint, p.tintStrength
+}
+
+// ViewDirty reports whether the pane's viewport moved, resized or had its
+// world changed since the previous frame, i.e. whether this frame
+// recomposites the view; it is true for the first frame as well. The window
+// updates it every render tick after OnFrame, so OnFrame sees the state of
+// the previous frame.
+func (p *Pane) ViewDirty() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.viewDirty
+}
+
+// OnViewChange sets a callback the window runs on the loop goroutine in every
+// frame for which ViewDirty becomes true, before the grid is flushed, so
+// drawables it adds, e.g. streamed tiles, show in that frame. Pass nil to
+// remove it.
+func (p *Pane) OnViewChange(fn func()) {
+	p.mu.Lock()
+	p.onViewChange = fn
+	p.mu.Unlock()
+}
+
+// updateViewDirty sets ViewDirty from the viewport version and returns the
+// OnViewChange callback to run, if the view changed.
+func (p *Pane) updateViewDirty() func() {
+	if p.viewport == nil {
+		return nil
+	}
+	version := p.viewport.Version()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.viewDirty = !p.viewSeen || version != p.viewVersion
+	p.viewVersion = version
+	p.viewSeen = true
+	if !p.viewDirty {
+		return nil
+	}
+	return p.onViewChange
+}
+
 func (p *Pane) Viewport() *Viewport {
 	return p.viewport
 }
 
+// VisibleDrawables returns the drawables of layer intersecting the current
+// viewport, each once even if it is split across the world seam. It requires a
+// layer observer implementing LayerQuerier (e.g. gridbridge.Bridge) and
+// returns nil otherwise.
+func (p *Pane) VisibleDrawables(layer *Layer) []*Drawable {
+	if p == nil || layer == nil || p.viewport == nil {
+		return nil
+	}
+	querier, ok := p.layerObserver.(LayerQuerier)
+	if !ok {
+		return nil
+	}
+	var out []*Drawable
+	seen := make(map[*Drawable]struct{})
+	querier.QueryRange(layer, p.viewport.Rect(), func(entryID uint64) {
+		drawable := layer.DrawableByEntryID(entryID)
+		if drawable == nil {
+			return
+		}
+		if _, dup := seen[drawable]; dup {
+			return
+		}
+		seen[drawable] = struct{}{}
+		out = append(out, drawable)
+	})
+	return out
+}
+
 func (p *Pane) SetLayerObserver(observer LayerObserver) {
 	p.layerObserver = observer
 	p.mu.Lock()
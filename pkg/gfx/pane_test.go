@@ -0,0 +1,513 @@
+package gfx
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
+
+type recordingObserver struct {
+	dirty []*Layer
+}
+
+func (o *recordingObserver) OnDrawableAdded(*Layer, *Drawable, uint64)   {}
+func (o *recordingObserver) OnDrawableRemoved(*Layer, *Drawable, uint64) {}
+func (o *recordingObserver) OnLayerDirtyRect(layer *Layer, _ spatial.AABB) {
+	o.dirty = append(o.dirty, layer)
+}
+
+func newTestPane(t *testing.T, layers int) *Pane {
+	t.Helper()
+	pane := newPane(&PaneConfig{Width: 64, Height: 64}, 0)
+	for i := 1; i < layers; i++ {
+		if !pane.AddLayer(i) {
+			t.Fatalf("AddLayer(%d) failed", i)
+		}
+	}
+	return pane
+}
+
+func layerIDs(pane *Pane) []uint64 {
+	out := make([]uint64, 0)
+	for _, layer := range pane.Layers() {
+		out = append(out, layer.ID())
+	}
+	return out
+}
+
+func assertLayerIDs(t *testing.T, pane *Pane, want ...uint64) {
+	t.Helper()
+	got := layerIDs(pane)
+	if len(got) != len(want) {
+		t.Fatalf("layer ids = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("layer ids = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPane_MoveLayerRestacks(t *testing.T) {
+	pane := newTestPane(t, 4)
+	observer := &recordingObserver{}
+	pane.SetLayerObserver(observer)
+
+	if !pane.MoveLayer(3, 1) {
+		t.Fatal("MoveLayer(3, 1) failed")
+	}
+	assertLayerIDs(t, pane, 0, 3, 1, 2)
+	if pane.GetLayer(1).ID() != 3 {
+		t.Errorf("GetLayer(1) should return moved layer 3")
+	}
+	if len(observer.dirty) != 1 || observer.dirty[0].ID() != 3 {
+		t.Errorf("lowest affected layer should be marked dirty, got %v", observer.dirty)
+	}
+
+	if !pane.MoveLayer(1, 3) {
+		t.Fatal("MoveLayer(1, 3) failed")
+	}
+	assertLayerIDs(t, pane, 0, 1, 2, 3)
+
+	if pane.MoveLayer(0, 4) || pane.MoveLayer(-1, 0) {
+		t.Error("out of range moves should be rejected")
+	}
+}
+
+func TestPane_EachLayerVisitsStackWithIDs(t *testing.T) {
+	pane := newTestPane(t, 3)
+	pane.MoveLayer(2, 0)
+
+	var ids []uint64
+	pane.EachLayer(func(id uint64, l *Layer) {
+		if l.ID() != id {
+			t.Errorf("layer %d reported with ID %d", l.ID(), id)
+		}
+		ids = append(ids, id)
+	})
+	if !slices.Equal(ids, []uint64{2, 0, 1}) {
+		t.Fatalf("EachLayer IDs = %v, want stacking order [2 0 1]", ids)
+	}
+}
+
+func TestPane_AddLayerInsertsAtIndex(t *testing.T) {
+	pane := newTestPane(t, 3)
+	observer := &recordingObserver{}
+	pane.SetLayerObserver(observer)
+
+	// Appending on top leaves the composite alone.
+	if !pane.AddLayer(3) {
+		t.Fatal("AddLayer(3) failed")
+	}
+	assertLayerIDs(t, pane, 0, 1, 2, 3)
+	if len(observer.dirty) != 0 {
+		t.Errorf("appending a layer should not dirty the pane, got %v", observer.dirty)
+	}
+
+	if !pane.AddLayer(1) {
+		t.Fatal("AddLayer(1) failed")
+	}
+	assertLayerIDs(t, pane, 0, 4, 1, 2, 3)
+	if pane.GetLayer(1).ID() != 4 {
+		t.Errorf("GetLayer(1) should return the inserted layer")
+	}
+	if len(observer.dirty) != 1 || observer.dirty[0].ID() != 4 {
+		t.Errorf("inserted layer should be marked dirty, got %v", observer.dirty)
+	}
+
+	if pane.AddLayer(-1) || pane.AddLayer(6) {
+		t.Error("out of range inserts should be rejected")
+	}
+	assertLayerIDs(t, pane, 0, 4, 1, 2, 3)
+}
+
+func TestPane_SwapLayers(t *testing.T) {
+	pane := newTestPane(t, 3)
+	if !pane.SwapLayers(0, 2) {
+		t.Fatal("SwapLayers(0, 2) failed")
+	}
+	assertLayerIDs(t, pane, 2, 1, 0)
+
+	// IDs stay stable, so new layers still get the next free ID.
+	if !pane.AddLayer(3) {
+		t.Fatal("AddLayer(3) failed after swap")
+	}
+	assertLayerIDs(t, pane, 2, 1, 0, 3)
+}
+
+func TestPane_CompositeLayersFollowsCompositeOrder(t *testing.T) {
+	pane := newTestPane(t, 3)
+	ids := func() []uint64 {
+		out := make([]uint64, 0)
+		for _, layer := range pane.CompositeLayers() {
+			out = append(out, layer.ID())
+		}
+		return out
+	}
+	if got := ids(); !slices.Equal(got, []uint64{0, 1, 2}) {
+		t.Fatalf("bottom-up composite order = %v, want [0 1 2]", got)
+	}
+	pane.Config.CompositeOrder = CompositeTopDown
+	if got := ids(); !slices.Equal(got, []uint64{2, 1, 0}) {
+		t.Fatalf("top-down composite order = %v, want [2 1 0]", got)
+	}
+	assertLayerIDs(t, pane, 0, 1, 2)
+}
+
+// clearObserver records the layers and drawable IDs Pane.Clear releases.
+type clearObserver struct {
+	recordingObserver
+	removedLayers    []uint64
+	removedDrawables []uint64
+	detachedEarly    bool
+}
+
+func (o *clearObserver) OnDrawableRemoved(_ *Layer, _ *Drawable, id uint64) {
+	o.removedDrawables = append(o.removedDrawables, id)
+}
+
+func (o *clearObserver) OnLayerRemoved(layer *Layer) {
+	o.detachedEarly = o.detachedEarly || layer.GetPane() == nil
+	o.removedLayers = append(o.removedLayers, layer.ID())
+}
+
+func TestPane_ClearKeepsOnlyEmptyBaseLayer(t *testing.T) {
+	pane := newTestPane(t, 3)
+	pane.SwapLayers(0, 2)
+	observer := &clearObserver{}
+	pane.SetLayerObserver(observer)
+	base := pane.GetLayer(2)
+	top := pane.GetLayer(0)
+	kept := &Drawable{}
+	dropped := &Drawable{}
+	base.AddDrawable(kept)
+	top.AddDrawable(dropped)
+	proxy := base.AddProxy(dropped)
+
+	pane.Clear()
+
+	assertLayerIDs(t, pane, 0)
+	if !slices.Equal(observer.removedLayers, []uint64{2, 1}) {
+		t.Fatalf("removed layers = %v, want [2 1]", observer.removedLayers)
+	}
+	if len(base.Drawables()) != 0 || kept.Layer() != nil || dropped.Layer() != nil || proxy.Layer() != nil {
+		t.Fatal("Clear should detach every drawable, proxies included")
+	}
+	if !slices.Contains(observer.removedDrawables, kept.ID) || slices.Contains(observer.removedDrawables, dropped.ID) {
+		t.Errorf("removed drawables = %v: want base drawables reported, removed layers silent", observer.removedDrawables)
+	}
+	if observer.detachedEarly {
+		t.Error("OnLayerRemoved should run while the layer still reports its pane")
+	}
+	if top.GetPane() != nil {
+		t.Error("a removed layer should no longer report its pane")
+	}
+	if n := len(observer.dirty); n == 0 || observer.dirty[n-1] != base {
+		t.Errorf("the base layer should be repainted, dirty = %v", observer.dirty)
+	}
+
+	pane.AddLayer(1)
+	assertLayerIDs(t, pane, 0, 3)
+}
+
+// queryObserver reports fixed entry IDs for every query.
+type queryObserver struct {
+	recordingObserver
+	entries []uint64
+	rects   []spatial.AABB
+}
+
+func (o *queryObserver) QueryRange(_ *Layer, rect spatial.AABB, collector func(uint64)) {
+	o.rects = append(o.rects, rect)
+	for _, id := range o.entries {
+		collector(id)
+	}
+}
+
+func TestPane_VisibleDrawablesDeduplicatesFragments(t *testing.T) {
+	pane := newTestPane(t, 1)
+	layer := pane.GetLayer(0)
+	a := &Drawable{ID: 10}
+	b := &Drawable{ID: 11}
+	layer.AddDrawable(a)
+	layer.AddDrawable(b)
+
+	if got := pane.VisibleDrawables(layer); got != nil {
+		t.Fatalf("without a querier VisibleDrawables should return nil, got %v", got)
+	}
+
+	// a is split into two fragments; 99 is unknown to the layer.
+	observer := &queryObserver{entries: []uint64{10 << 2, 10<<2 | 1, 11 << 2, 99 << 2}}
+	pane.SetLayerObserver(observer)
+
+	got := pane.VisibleDrawables(layer)
+	if len(got) != 2 || got[0] != a || got[1] != b {
+		t.Fatalf("VisibleDrawables = %v, want [a b]", got)
+	}
+	if len(observer.rects) != 1 || observer.rects[0] != pane.Viewport().Rect() {
+		t.Errorf("query should use the viewport rect, got %v", observer.rects)
+	}
+}
+
+func TestPane_LogicalUnitsTransform(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 200, Height: 100,
+		OffsetX: 10, OffsetY: 20,
+		LogicalWidth: 100, LogicalHeight: 50,
+		World: WorldConfig{WorldResolution: spatial.Size256x256, WorldWrap: true},
+	}, 1)
+
+	if got := pane.Viewport().Size(); got != geom.NewVec[uint32](100, 50) {
+		t.Fatalf("viewport size = %v, want logical (100,50)", got)
+	}
+	if sx, sy := pane.LogicalScale(); sx != 2 || sy != 2 {
+		t.Fatalf("LogicalScale = (%v,%v), want (2,2)", sx, sy)
+	}
+
+	pane.Viewport().SetOrigin(250, 0)
+	wx, wy := pane.WindowToWorldCoords(10+30, 20+41)
+	if wx != 9 || wy != 20 {
+		t.Errorf("WindowToWorldCoords = (%d,%d), want (9,20)", wx, wy)
+	}
+	x, y := pane.WorldToWindowCoords(9, 20)
+	if x != 10+30 || y != 20+40 {
+		t.Errorf("WorldToWindowCoords = (%d,%d), want (40,60)", x, y)
+	}
+}
+
+func TestPane_InitialOriginAndZoom(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 128, Height: 64,
+		World: WorldConfig{
+			WorldResolution: spatial.Size256x256,
+			InitialOrigin:   geom.NewVec[uint32](100, 250),
+			InitialZoom:     2,
+		},
+	}, 1)
+
+	if got := pane.Viewport().Size(); got != geom.NewVec[uint32](64, 32) {
+		t.Errorf("zoomed viewport size = %v, want (64,32)", got)
+	}
+	if sx, sy := pane.LogicalScale(); sx != 2 || sy != 2 {
+		t.Errorf("LogicalScale = (%v,%v), want (2,2)", sx, sy)
+	}
+	// Without wrap the origin is clamped so the view stays inside the world.
+	if got := pane.Viewport().Origin(); got != geom.NewVec[uint32](100, 224) {
+		t.Errorf("initial origin = %v, want (100,224)", got)
+	}
+}
+
+func TestPane_PointerWorldMapsPointerEvents(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 128, Height: 64,
+		OffsetX: 10, OffsetY: 20,
+		World: WorldConfig{
+			WorldResolution: spatial.Size256x256,
+			WorldWrap:       true,
+			InitialZoom:     2,
+		},
+	}, 1)
+	pane.Viewport().SetOrigin(250, 0)
+
+	want := geom.NewVec[uint32](4, 3)
+	for _, event := range []Event{
+		MotionNotify{X: 30, Y: 26},
+		ButtonPress{Button: 1, X: 30, Y: 26},
+		ButtonRelease{Button: 1, X: 30, Y: 26},
+		MouseWheel{DeltaY: 1, X: 30, Y: 26},
+	} {
+		if got, ok := pane.PointerWorld(event); !ok || got != want {
+			t.Errorf("PointerWorld(%T) = %v %v, want %v", event, got, ok, want)
+		}
+	}
+	if _, ok := pane.PointerWorld(KeyPress{}); ok {
+		t.Error("PointerWorld should reject non-pointer events")
+	}
+	if _, ok := pane.PointerWorld(MotionNotify{X: 5, Y: 26}); ok {
+		t.Error("PointerWorld should reject positions outside the pane")
+	}
+}
+
+func TestPaneSetTintClampsAndClears(t *testing.T) {
+	pane := newTestPane(t, 1)
+	if c, strength := pane.Tint(); c != nil || strength != 0 {
+		t.Fatalf("expected no tint by default, got %v %v", c, strength)
+	}
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	pane.SetTint(gray, 2)
+	if c, strength := pane.Tint(); c != gray || strength != 1 {
+		t.Fatalf("expected gray at strength 1, got %v %v", c, strength)
+	}
+	pane.SetTint(gray, 0)
+	if c, _ := pane.Tint(); c != nil {
+		t.Fatalf("expected zero strength to clear the tint, got %v", c)
+	}
+	pane.SetTint(nil, 0.5)
+	if _, strength := pane.Tint(); strength != 0 {
+		t.Fatalf("expected nil color to clear the tint, got %v", strength)
+	}
+}
+
+func TestPane_CenterViewOn(t *testing.T) {
+	wrapped := newPane(&PaneConfig{
+		Width: 64, Height: 32,
+		World: WorldConfig{WorldResolution: spatial.Size256x256, WorldWrap: true},
+	}, 1)
+	wrapped.CenterViewOn(10, 100)
+	if got := wrapped.Viewport().Origin(); got != geom.NewVec[uint32](234, 84) {
+		t.Errorf("wrapped origin = %v, want (234,84)", got)
+	}
+
+	bounded := newPane(&PaneConfig{
+		Width: 64, Height: 32,
+		World: WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 1)
+	bounded.CenterViewOn(10, 250)
+	if got := bounded.Viewport().Origin(); got != geom.NewVec[uint32](0, 224) {
+		t.Errorf("bounded origin = %v, want (0,224)", got)
+	}
+	bounded.CenterViewOn(128, 128)
+	if got := bounded.Viewport().Origin(); got != geom.NewVec[uint32](96, 112) {
+		t.Errorf("centered origin = %v, want (96,112)", got)
+	}
+}
+
+func TestPane_ScrollByPixelsCarriesFractions(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 200, Height: 100,
+		LogicalWidth: 100, LogicalHeight: 50,
+		World: WorldConfig{WorldResolution: spatial.Size256x256, WorldWrap: true},
+	}, 1)
+
+	pane.ScrollByPixels(1, -1)
+	if got := pane.Viewport().Origin(); got != geom.NewVec[uint32](0, 0) {
+		t.Fatalf("origin after half-unit scroll = %v, want (0,0)", got)
+	}
+	pane.ScrollByPixels(1, -1)
+	if got := pane.Viewport().Origin(); got != geom.NewVec[uint32](1, 255) {
+		t.Fatalf("origin after carried scroll = %v, want (1,255)", got)
+	}
+	pane.ScrollByPixels(20, 0)
+	if got := pane.Viewport().Origin(); got != geom.NewVec[uint32](11, 255) {
+		t.Errorf("origin after 20px scroll = %v, want (11,255)", got)
+	}
+}
+
+func TestPane_SetWorldSizeReclampsOrigin(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 64, Height: 32,
+		World: WorldConfig{WorldResolution: spatial.Size256x256, InitialOrigin: geom.NewVec[uint32](180, 200)},
+	}, 1)
+	observer := &recordingObserver{}
+	pane.SetLayerObserver(observer)
+
+	pane.SetWorldSize(128, 512)
+	if got := pane.Viewport().WorldSize(); got != geom.NewVec[uint32](128, 256) {
+		t.Errorf("world size = %v, want (128,256) clamped to the resolution", got)
+	}
+	if got := pane.Viewport().Origin(); got != geom.NewVec[uint32](64, 200) {
+		t.Errorf("origin = %v, want (64,200)", got)
+	}
+	if len(observer.dirty) == 0 {
+		t.Error("SetWorldSize should mark the layers dirty")
+	}
+
+	wrapped := newPane(&PaneConfig{
+		Width: 64, Height: 32,
+		World: WorldConfig{WorldResolution: spatial.Size256x256, WorldWrap: true},
+	}, 1)
+	wrapped.SetWorldSize(128, 128)
+	if got := wrapped.Viewport().WorldSize(); got != geom.NewVec[uint32](256, 256) {
+		t.Errorf("wrapped world size = %v, want it unchanged", got)
+	}
+}
+
+func TestPane_InternalSizeIntegerUpscale(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 640, Height: 480, OffsetX: 10,
+		InternalWidth: 160, InternalHeight: 112,
+		World: WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 1)
+
+	if got, want := pane.Config.PresentRect(), image.Rect(10, 16, 650, 464); got != want {
+		t.Errorf("PresentRect = %v, want %v", got, want)
+	}
+	if w, h := pane.Config.LogicalSize(); w != 160 || h != 112 {
+		t.Errorf("LogicalSize = %dx%d, want the internal 160x112", w, h)
+	}
+	if sx, sy := pane.RenderScale(); sx != 1 || sy != 1 {
+		t.Errorf("RenderScale = (%v,%v), want (1,1)", sx, sy)
+	}
+	if sx, sy := pane.LogicalScale(); sx != 4 || sy != 4 {
+		t.Errorf("LogicalScale = (%v,%v), want (4,4)", sx, sy)
+	}
+	if x, y := pane.WindowToWorldCoords(10+4*5+3, 16+4*7); x != 5 || y != 7 {
+		t.Errorf("WindowToWorldCoords = (%d,%d), want (5,7)", x, y)
+	}
+	if x, y := pane.WorldToWindowCoords(5, 7); x != 30 || y != 44 {
+		t.Errorf("WorldToWindowCoords = (%d,%d), want (30,44)", x, y)
+	}
+
+	small := PaneConfig{Width: 80, Height: 80, InternalWidth: 160, InternalHeight: 80}
+	if got, want := small.PresentRect(), image.Rect(0, 20, 80, 60); got != want {
+		t.Errorf("PresentRect of a smaller pane = %v, want %v fit to the pane", got, want)
+	}
+}
+
+func TestPane_AspectLockLetterboxesContent(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 200, Height: 100,
+		LogicalWidth: 50, LogicalHeight: 50,
+		World: WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 1)
+
+	pane.SetAspectLock(1, 1)
+	if got, want := pane.ContentRect(), image.Rect(50, 0, 150, 100); got != want {
+		t.Errorf("ContentRect = %v, want %v", got, want)
+	}
+	if w, h := pane.RenderSize(); w != 100 || h != 100 {
+		t.Errorf("RenderSize = %dx%d, want the content size", w, h)
+	}
+	if sx, sy := pane.LogicalScale(); sx != 2 || sy != 2 {
+		t.Errorf("LogicalScale = (%v,%v), want (2,2)", sx, sy)
+	}
+	if x, y := pane.WindowToWorldCoords(50+21, 41); x != 10 || y != 20 {
+		t.Errorf("WindowToWorldCoords = (%d,%d), want (10,20)", x, y)
+	}
+
+	pane.SetAspectLock(0, 9)
+	if got, want := pane.ContentRect(), image.Rect(0, 0, 200, 100); got != want {
+		t.Errorf("ContentRect after unlock = %v, want the pane rect", got)
+	}
+}
+
+func TestPane_AspectLockRepaintsLayers(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 200, Height: 100,
+		World: WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 1)
+	pane.AddLayer(1)
+	observer := &recordingObserver{}
+	pane.SetLayerObserver(observer)
+	version := pane.Viewport().Version()
+
+	pane.SetAspectLock(1, 1)
+	if len(observer.dirty) != 2 {
+		t.Errorf("dirty layers = %d, want both layers repainted", len(observer.dirty))
+	}
+	if pane.Viewport().Version() == version {
+		t.Error("SetAspectLock should bump the viewport version for on-demand windows")
+	}
+
+	observer.dirty = nil
+	version = pane.Viewport().Version()
+	pane.SetAspectLock(1, 1)
+	if len(observer.dirty) != 0 || pane.Viewport().Version() != version {
+		t.Errorf("dirty=%d version+%d, want no repaint for an unchanged lock",
+			len(observer.dirty), pane.Viewport().Version()-version)
+	}
+}
@@ -1,9 +1,13 @@
 package gfx
 
 import (
+	"image"
 	"image/color"
+	"math"
 
 	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
 )
 
 type Layer struct {
@@ -11,9 +15,33 @@ type Layer struct {
 	idx          int
 	drawables    []*Drawable
 	background   color.Color
+	backdrop     backdrop
 	observer     LayerObserver
 	idByDrawable map[*Drawable]uint64
 	drawableByID map[uint64]*Drawable
+	static       bool
+	syncPending  bool
+	parallax     parallaxState
+	colorBlend   BlendMode
+	styleVersion uint64
+	instances    InstanceVisitor
+}
+
+// backdrop is the layer's background image (see SetBackgroundImage).
+type backdrop struct {
+	image   image.Image
+	fit     BackgroundFit
+	version uint64
+}
+
+// parallaxState holds the layer's scroll factors and, on a wrapping world,
+// the accumulated layer origin (see ParallaxViewRect).
+type parallaxState struct {
+	factorX, factorY float32
+	enabled          bool
+	tracking         bool
+	lastView         geom.Vec[uint32]
+	originX, originY float64
 }
 
 func NewLayer(pane *Pane) *Layer {
@@ -32,17 +60,83 @@ func (l *Layer) GetPane() *Pane {
 	return l.pane
 }
 
+// ID returns the layer identifier assigned at creation: 0 for the base layer,
+// then 1, 2, ... in AddLayer order, never reused (see Pane.Clear). It is stable across
+// MoveLayer/SwapLayers and keys the layer's grid manager.
 func (l *Layer) ID() uint64 {
 	return uint64(l.idx)
 }
 
+// Background returns the color each bucket is cleared to before drawing. The
+// pane's base layer defaults to opaque black, every other layer to
+// transparent.
 func (l *Layer) Background() color.Color {
 	bg := l.background
+	if bg == nil {
+		return color.Transparent
+	}
 	return bg
 }
 
 func (l *Layer) SetBackground(color color.Color) {
 	l.background = color
+	l.markAllDirty()
+}
+
+// BackgroundFit selects how a layer's background image covers the world.
+type BackgroundFit uint8
+
+const (
+	// BackgroundStretch scales the image over the whole world.
+	BackgroundStretch BackgroundFit = iota
+	// BackgroundTile repeats the image from the world origin, one image pixel
+	// per world unit.
+	BackgroundTile
+)
+
+// SetBackgroundImage sets a static image drawn over the background color
+// before the layer's drawables, e.g. a menu screen or map backdrop, placed per
+// SetBackgroundFit. Renderers copy img, so later changes to its pixels need
+// another call. A nil or empty image removes it.
+func (l *Layer) SetBackgroundImage(img image.Image) {
+	if img != nil && img.Bounds().Empty() {
+		img = nil
+	}
+	if img == nil && l.backdrop.image == nil {
+		return
+	}
+	l.backdrop.image = img
+	l.backdrop.version++
+	l.markAllDirty()
+}
+
+// SetBackgroundFit selects how the background image covers the world; the
+// default is BackgroundStretch.
+func (l *Layer) SetBackgroundFit(fit BackgroundFit) {
+	if l.backdrop.fit == fit {
+		return
+	}
+	l.backdrop.fit = fit
+	l.backdrop.version++
+	l.markAllDirty()
+}
+
+// BackgroundImage returns the image set by SetBackgroundImage, or nil.
+func (l *Layer) BackgroundImage() image.Image {
+	return l.backdrop.image
+}
+
+func (l *Layer) BackgroundFit() BackgroundFit {
+	return l.backdrop.fit
+}
+
+// BackgroundVersion counts changes to the background image and its fit;
+// renderers re-upload the image when it changes.
+func (l *Layer) BackgroundVersion() uint64 {
+	return l.backdrop.version
+}
+
+func (l *Layer) markAllDirty() {
 	observer := l.observer
 	pane := l.pane
 	if observer != nil && pane != nil && pane.viewport != nil {
@@ -51,50 +145,321 @@ func (l *Layer) SetBackground(color color.Color) {
 	}
 }
 
+// SetStatic marks the layer as static. A static layer's instance buffers are
+// uploaded once and then kept: drawable changes still update the spatial index
+// but are not synced to the renderer until Invalidate is called. Viewport
+// moves keep rendering from the baked buffers.
+func (l *Layer) SetStatic(static bool) {
+	if l.static == static {
+		return
+	}
+	l.static = static
+	l.syncPending = true
+}
+
+func (l *Layer) Static() bool {
+	return l.static
+}
+
+// Invalidate schedules a static layer to resync its instance buffers on the
+// next frame and repaints the whole layer. On a dynamic layer it only
+// repaints.
+func (l *Layer) Invalidate() {
+	if l.static {
+		l.syncPending = true
+	}
+	l.markAllDirty()
+}
+
+// MarkDirty makes renderers rebuild the instances of every drawable in the
+// layer and repaints it, e.g. after changing a style shared through
+// Drawable.StyleRef or a Style in place. A static layer also resyncs.
+func (l *Layer) MarkDirty() {
+	l.styleVersion++
+	l.Invalidate()
+}
+
+// ModifyStyle changes the style of a drawable on the layer in place, e.g. to
+// recolor it, and makes renderers re-read that drawable's instance and those
+// of its proxies, without moving it in the spatial index. fn receives the
+// style of the proxy's source for a proxy. Changes to a style shared through
+// StyleRef need MarkDirty instead. It returns false if drawable is not on l.
+func (l *Layer) ModifyStyle(drawable *Drawable, fn func(style *SpatialStyle)) bool {
+	if drawable == nil || fn == nil || !l.containsDrawable(drawable) {
+		return false
+	}
+	source := drawable
+	for source.source != nil {
+		source = source.source
+	}
+	fn(&source.Style)
+	source.notifyStyleChanged()
+	for _, proxy := range source.proxies {
+		proxy.notifyStyleChanged()
+	}
+	return true
+}
+
+// StyleVersion counts MarkDirty calls; renderers rebuild cached instances
+// when it changes.
+func (l *Layer) StyleVersion() uint64 {
+	return l.styleVersion
+}
+
+// ConsumeBucketSync reports whether a FrameSource should hand the layer's
+// bucket deltas to the renderer this frame. Dynamic layers always sync; a
+// static layer syncs once after SetStatic or Invalidate.
+func (l *Layer) ConsumeBucketSync() bool {
+	if !l.static {
+		return true
+	}
+	pending := l.syncPending
+	l.syncPending = false
+	return pending
+}
+
+// SetColorBlendMode sets how the layer's drawables blend with each other and
+// the layer background in the instanced color pass, e.g. BlendAdditive for a
+// particle layer. The GPU renderers honor it; the software renderer always
+// draws with BlendAlpha.
+func (l *Layer) SetColorBlendMode(mode BlendMode) {
+	if l.colorBlend == mode {
+		return
+	}
+	l.colorBlend = mode
+	l.markAllDirty()
+}
+
+// ColorBlendMode returns the mode set by SetColorBlendMode; BlendAlpha by
+// default.
+func (l *Layer) ColorBlendMode() BlendMode {
+	return l.colorBlend
+}
+
+// SetParallax makes the layer scroll by a fraction of the viewport movement:
+// 1 is normal, 0 keeps the layer fixed (a HUD), 0.5 suits a distant
+// background.
+func (l *Layer) SetParallax(factorX, factorY float32) {
+	l.parallax = parallaxState{
+		factorX: factorX,
+		factorY: factorY,
+		enabled: factorX != 1 || factorY != 1,
+	}
+	l.markAllDirty()
+}
+
+// Parallax returns the factors set by SetParallax; 1, 1 by default.
+func (l *Layer) Parallax() (float32, float32) {
+	if !l.parallax.enabled {
+		return 1, 1
+	}
+	return l.parallax.factorX, l.parallax.factorY
+}
+
+// ParallaxViewRect returns the world rect the layer shows when the pane shows
+// viewRect. On a wrapping world the layer origin accumulates the scaled
+// viewport movement, taking the short way across the seam, so the layer does
+// not jump when the viewport wraps. Otherwise it is the viewport origin
+// scaled by the factors, clamped to the world. Renderers call it once per
+// frame.
+func (l *Layer) ParallaxViewRect(viewRect spatial.AABB, worldSize geom.Vec[uint32], wrap bool) spatial.AABB {
+	return l.ParallaxViewRectAxes(viewRect, worldSize, wrap, wrap)
+}
+
+// ParallaxViewRectAxes is ParallaxViewRect for a world wrapping on the given
+// axes; each axis accumulates or clamps on its own.
+func (l *Layer) ParallaxViewRectAxes(viewRect spatial.AABB, worldSize geom.Vec[uint32], wrapX, wrapY bool) spatial.AABB {
+	p := &l.parallax
+	if !p.enabled {
+		return viewRect
+	}
+	width := viewRect.BottomRight.X - viewRect.TopLeft.X
+	height := viewRect.BottomRight.Y - viewRect.TopLeft.Y
+	origin := viewRect.TopLeft
+	if !wrapX && !wrapY {
+		return geom.NewAABBAt(geom.NewVec(
+			scaleOrigin(origin.X, p.factorX, worldSize.X, width),
+			scaleOrigin(origin.Y, p.factorY, worldSize.Y, height),
+		), width, height)
+	}
+	if p.tracking {
+		p.originX += float64(wrapDelta(p.lastView.X, origin.X, worldSize.X)) * float64(p.factorX)
+		p.originY += float64(wrapDelta(p.lastView.Y, origin.Y, worldSize.Y)) * float64(p.factorY)
+	} else {
+		p.originX = float64(origin.X) * float64(p.factorX)
+		p.originY = float64(origin.Y) * float64(p.factorY)
+		p.tracking = true
+	}
+	p.lastView = origin
+	p.originX = wrapFloat(p.originX, worldSize.X)
+	p.originY = wrapFloat(p.originY, worldSize.Y)
+	x, y := uint32(p.originX), uint32(p.originY)
+	if !wrapX {
+		x = scaleOrigin(origin.X, p.factorX, worldSize.X, width)
+	}
+	if !wrapY {
+		y = scaleOrigin(origin.Y, p.factorY, worldSize.Y, height)
+	}
+	return geom.NewAABBAt(geom.NewVec(x, y), width, height)
+}
+
+func scaleOrigin(origin uint32, factor float32, world, size uint32) uint32 {
+	limit := float64(0)
+	if world > size {
+		limit = float64(world - size)
+	}
+	return uint32(min(max(float64(origin)*float64(factor), 0), limit))
+}
+
+// wrapDelta is the signed step from a to b on a ring of the given side,
+// taking the shorter way around.
+func wrapDelta(a, b, side uint32) int64 {
+	d := int64(b) - int64(a)
+	half := int64(side) / 2
+	if d > half {
+		d -= int64(side)
+	} else if d < -half {
+		d += int64(side)
+	}
+	return d
+}
+
+func wrapFloat(v float64, side uint32) float64 {
+	if side == 0 {
+		return v
+	}
+	v = math.Mod(v, float64(side))
+	if v < 0 {
+		v += float64(side)
+	}
+	if v >= float64(side) {
+		v = 0
+	}
+	return v
+}
+
+// AddDrawable attaches the drawable to the layer, moving it from its previous
+// layer if needed. A drawable with a zero ID gets one from NextDrawableID.
 func (l *Layer) AddDrawable(drawable *Drawable) {
 	if drawable == nil {
 		return
 	}
 	if drawable.layer != nil && drawable.layer != l {
-		drawable.layer.RemoveDrawable(drawable)
+		drawable.layer.removeDrawable(drawable)
 	}
 	if l.containsDrawable(drawable) {
 		return
 	}
-	l.drawables = append(l.drawables, drawable)
-	drawable.attach(l)
-	id := l.ensureDrawableIDLocked(drawable)
+	id := l.linkDrawable(drawable)
 	if l.observer != nil && id != 0 {
 		l.observer.OnDrawableAdded(l, drawable, id)
 	}
 }
 
+// RemoveDrawable detaches the drawable from the layer. Removing a source also
+// removes its proxies; a removed proxy no longer follows its source.
 func (l *Layer) RemoveDrawable(drawable *Drawable) {
+	if l.removeDrawable(drawable) {
+		drawable.releaseProxies()
+	}
+}
+
+func (l *Layer) removeDrawable(drawable *Drawable) bool {
 	if drawable == nil {
-		return
+		return false
 	}
 	if !l.containsDrawable(drawable) && l.idByDrawable[drawable] == 0 {
-		return
+		return false
+	}
+	id := l.unlinkDrawable(drawable)
+	if l.observer != nil && id != 0 {
+		l.observer.OnDrawableRemoved(l, drawable, id)
+	}
+	return true
+}
+
+// AddProxy adds a proxy of source to the layer, so one object shows in
+// several layers or panes, e.g. a unit on the main map and on a minimap. The
+// proxy is a drawable of its own, with its own ID and grid entry, that renders
+// with the style of source and shares its UserData. Moves applied through the
+// window's DrawableEventsApplier (gridbridge.Bridge) move the proxies of a
+// drawable with it. A proxy of a proxy mirrors the original source.
+func (l *Layer) AddProxy(source *Drawable) *Drawable {
+	if source == nil {
+		return nil
+	}
+	for source.source != nil {
+		source = source.source
+	}
+	proxy := &Drawable{AABB: source.AABB, UserData: source.UserData, source: source}
+	source.proxies = append(source.proxies, proxy)
+	l.AddDrawable(proxy)
+	return proxy
+}
+
+// MoveDrawableTo reparents drawable from l to dst, keeping its ID. When both
+// layers share an observer implementing DrawableMoveObserver it is notified
+// once; otherwise the move is reported as a removal plus an addition.
+// It returns false if drawable is not on l or dst is nil or l itself.
+func (l *Layer) MoveDrawableTo(drawable *Drawable, dst *Layer) bool {
+	if drawable == nil || dst == nil || dst == l || !l.containsDrawable(drawable) {
+		return false
+	}
+	id := l.unlinkDrawable(drawable)
+	dst.linkDrawable(drawable)
+
+	if mover, ok := l.observer.(DrawableMoveObserver); ok && l.observer == dst.observer {
+		mover.OnDrawableMoved(l, dst, drawable, id)
+		return true
+	}
+	if l.observer != nil && id != 0 {
+		l.observer.OnDrawableRemoved(l, drawable, id)
 	}
-	idx := -1
+	if dst.observer != nil && id != 0 {
+		dst.observer.OnDrawableAdded(dst, drawable, id)
+	}
+	return true
+}
+
+// linkDrawable appends drawable to the layer and registers its ID.
+func (l *Layer) linkDrawable(drawable *Drawable) uint64 {
+	l.drawables = append(l.drawables, drawable)
+	drawable.attach(l)
+	return l.ensureDrawableIDLocked(drawable)
+}
+
+// unlinkDrawable drops drawable from the layer and returns its former ID.
+func (l *Layer) unlinkDrawable(drawable *Drawable) uint64 {
 	for i, existing := range l.drawables {
 		if existing == drawable {
-			idx = i
+			l.drawables = append(l.drawables[:i], l.drawables[i+1:]...)
 			break
 		}
 	}
-	if idx >= 0 {
-		l.drawables = append(l.drawables[:idx], l.drawables[idx+1:]...)
-	}
 	if drawable.layer == l {
 		drawable.detach()
 	}
 	id := l.idByDrawable[drawable]
 	delete(l.idByDrawable, drawable)
 	delete(l.drawableByID, id)
-	if l.observer != nil && id != 0 {
-		l.observer.OnDrawableRemoved(l, drawable, id)
+	return id
+}
+
+// release detaches the drawables of a layer removed from its pane, then
+// reports the removal to the observer and unties the layer from the pane.
+// Renderers free a layer's GPU state once GetPane returns nil, so, like
+// Pane.Clear, it runs on the loop goroutine.
+func (l *Layer) release() {
+	for _, drawable := range l.Drawables() {
+		l.unlinkDrawable(drawable)
+		drawable.releaseProxies()
+	}
+	if observer, ok := l.observer.(LayerRemovedObserver); ok {
+		observer.OnLayerRemoved(l)
 	}
+	l.observer = nil
+	l.instances = nil
+	l.pane = nil
 }
 
 func (l *Layer) Drawables() []*Drawable {
@@ -112,6 +477,45 @@ func (l *Layer) containsDrawable(drawable *Drawable) bool {
 	return false
 }
 
+// SetInstanceVisitor registers the renderer whose instance data
+// ForEachInstance reports; nil falls back to the drawable list.
+func (l *Layer) SetInstanceVisitor(visitor InstanceVisitor) {
+	l.instances = visitor
+}
+
+// ForEachInstance calls fn for every instance of the layer as the renderer
+// last uploaded it, in world space and in no particular order, e.g. to drive
+// trails or debug overlays from exactly what is drawn. A drawable split across
+// the world seam is reported once per fragment. Without a GPU renderer the
+// layer's drawables and their wrap fragments are reported, which is what the
+// software renderer paints. Call it from the window loop, like the other
+// Layer methods.
+func (l *Layer) ForEachInstance(fn InstanceFunc) {
+	if fn == nil {
+		return
+	}
+	if l.instances != nil {
+		l.instances.VisitInstances(l, fn)
+		return
+	}
+	for _, drawable := range l.drawables {
+		id, _ := l.DrawableID(drawable)
+		style := drawable.EffectiveStyle()
+		fn(id, intAABB(drawable.AABB.AABB), style)
+		drawable.AABB.VisitFragments(func(_ plane.FragPosition, frag geom.AABB[uint32]) bool {
+			fn(id, intAABB(frag), style)
+			return true
+		})
+	}
+}
+
+func intAABB(aabb geom.AABB[uint32]) geom.AABB[int] {
+	return geom.NewAABB(
+		geom.NewVec(int(aabb.TopLeft.X), int(aabb.TopLeft.Y)),
+		geom.NewVec(int(aabb.BottomRight.X), int(aabb.BottomRight.Y)),
+	)
+}
+
 func (l *Layer) SetObserver(observer LayerObserver) {
 	l.observer = observer
 }
@@ -126,6 +530,12 @@ func (l *Layer) DrawableByID(id uint64) *Drawable {
 	return drawable
 }
 
+// DrawableByEntryID resolves a spatial index entry ID (drawable ID << 2 |
+// wrap fragment) to its drawable.
+func (l *Layer) DrawableByEntryID(entryID uint64) *Drawable {
+	return l.DrawableByID(entryID >> 2)
+}
+
 func (l *Layer) ensureDrawableIDLocked(drawable *Drawable) uint64 {
 	if drawable == nil {
 		return 0
@@ -0,0 +1,317 @@
+package gfx
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+)
+
+type addedObserver struct {
+	recordingObserver
+	added []uint64
+}
+
+func (o *addedObserver) OnDrawableAdded(_ *Layer, _ *Drawable, id uint64) {
+	o.added = append(o.added, id)
+}
+
+func TestLayer_AddDrawableAssignsMissingID(t *testing.T) {
+	pane := newTestPane(t, 1)
+	observer := &addedObserver{}
+	pane.SetLayerObserver(observer)
+	layer := pane.GetLayer(0)
+
+	drawable := &Drawable{}
+	layer.AddDrawable(drawable)
+
+	if drawable.ID == 0 {
+		t.Fatal("AddDrawable should assign an ID to a drawable without one")
+	}
+	if len(observer.added) != 1 || observer.added[0] != drawable.ID {
+		t.Errorf("observer should be notified with the assigned ID, got %v", observer.added)
+	}
+	if layer.DrawableByID(drawable.ID) != drawable {
+		t.Error("DrawableByID should resolve the assigned ID")
+	}
+}
+
+func TestLayer_DefaultBackgrounds(t *testing.T) {
+	pane := newTestPane(t, 3)
+
+	if _, _, _, a := pane.GetLayer(0).Background().RGBA(); a != 0xffff {
+		t.Errorf("base layer alpha = %#x, want opaque", a)
+	}
+	for _, num := range []int{1, 2} {
+		if _, _, _, a := pane.GetLayer(num).Background().RGBA(); a != 0 {
+			t.Errorf("layer %d alpha = %#x, want transparent", num, a)
+		}
+	}
+}
+
+type moveObserver struct {
+	addedObserver
+	removed []uint64
+	moved   []uint64
+}
+
+func (o *moveObserver) OnDrawableRemoved(_ *Layer, _ *Drawable, id uint64) {
+	o.removed = append(o.removed, id)
+}
+
+func (o *moveObserver) OnDrawableMoved(_, _ *Layer, _ *Drawable, id uint64) {
+	o.moved = append(o.moved, id)
+}
+
+func TestLayer_MoveDrawableToReportsSingleMove(t *testing.T) {
+	pane := newTestPane(t, 2)
+	observer := &moveObserver{}
+	pane.SetLayerObserver(observer)
+	src, dst := pane.GetLayer(0), pane.GetLayer(1)
+	drawable := &Drawable{ID: 7}
+	src.AddDrawable(drawable)
+	observer.added = nil
+
+	if !src.MoveDrawableTo(drawable, dst) {
+		t.Fatal("MoveDrawableTo failed")
+	}
+	if len(observer.moved) != 1 || observer.moved[0] != 7 {
+		t.Errorf("expected one move notification, got %v", observer.moved)
+	}
+	if len(observer.added) != 0 || len(observer.removed) != 0 {
+		t.Errorf("move should not report add/remove, got added=%v removed=%v", observer.added, observer.removed)
+	}
+	if src.DrawableByID(7) != nil || dst.DrawableByID(7) != drawable {
+		t.Error("drawable ID should be re-registered on the destination layer")
+	}
+	if len(src.Drawables()) != 0 || len(dst.Drawables()) != 1 {
+		t.Error("drawable lists not updated")
+	}
+	if src.MoveDrawableTo(drawable, dst) {
+		t.Error("moving a drawable not on the layer should fail")
+	}
+}
+
+type styleObserver struct {
+	recordingObserver
+	restyled []uint64
+}
+
+func (o *styleObserver) OnDrawableStyleChanged(_ *Layer, _ *Drawable, id uint64) {
+	o.restyled = append(o.restyled, id)
+}
+
+func TestLayer_ModifyStyleNotifiesDrawableAndProxies(t *testing.T) {
+	pane := newTestPane(t, 2)
+	observer := &styleObserver{}
+	pane.SetLayerObserver(observer)
+	main, minimap := pane.GetLayer(0), pane.GetLayer(1)
+	unit := &Drawable{ID: 3}
+	main.AddDrawable(unit)
+	proxy := minimap.AddProxy(unit)
+
+	red := color.RGBA{R: 255, A: 255}
+	if !minimap.ModifyStyle(proxy, func(style *SpatialStyle) { style.Fill = red }) {
+		t.Fatal("ModifyStyle failed")
+	}
+	if unit.Style.Fill != red || proxy.EffectiveStyle().Fill != red {
+		t.Errorf("style not applied to source: %v", unit.Style.Fill)
+	}
+	if len(observer.restyled) != 2 || observer.restyled[0] != 3 || observer.restyled[1] != proxy.ID {
+		t.Errorf("restyled = %v, want source then proxy", observer.restyled)
+	}
+	if main.ModifyStyle(proxy, func(*SpatialStyle) {}) {
+		t.Error("ModifyStyle should fail for a drawable on another layer")
+	}
+}
+
+func TestLayer_StaticSyncsOnlyWhenInvalidated(t *testing.T) {
+	pane := newTestPane(t, 1)
+	observer := &recordingObserver{}
+	pane.SetLayerObserver(observer)
+	layer := pane.GetLayer(0)
+
+	if !layer.ConsumeBucketSync() {
+		t.Fatal("dynamic layer should sync every frame")
+	}
+
+	layer.SetStatic(true)
+	if !layer.ConsumeBucketSync() {
+		t.Fatal("static layer should sync once after SetStatic")
+	}
+	if layer.ConsumeBucketSync() {
+		t.Fatal("static layer should not sync again until invalidated")
+	}
+
+	layer.Invalidate()
+	if len(observer.dirty) == 0 {
+		t.Error("Invalidate should mark the layer dirty")
+	}
+	if !layer.ConsumeBucketSync() {
+		t.Fatal("static layer should sync after Invalidate")
+	}
+	if layer.ConsumeBucketSync() {
+		t.Fatal("invalidation should be consumed by a single sync")
+	}
+
+	layer.SetStatic(false)
+	if !layer.ConsumeBucketSync() {
+		t.Fatal("layer should sync again once it is dynamic")
+	}
+}
+
+func TestLayer_SetColorBlendModeRepaints(t *testing.T) {
+	pane := newTestPane(t, 1)
+	observer := &recordingObserver{}
+	pane.SetLayerObserver(observer)
+	layer := pane.GetLayer(0)
+
+	if got := layer.ColorBlendMode(); got != BlendAlpha {
+		t.Fatalf("default blend mode = %v, want alpha", got)
+	}
+	layer.SetColorBlendMode(BlendAdditive)
+	if got := layer.ColorBlendMode(); got != BlendAdditive {
+		t.Fatalf("blend mode = %v, want additive", got)
+	}
+	if len(observer.dirty) != 1 {
+		t.Fatalf("dirty rects = %d, want 1", len(observer.dirty))
+	}
+	layer.SetColorBlendMode(BlendAdditive)
+	if len(observer.dirty) != 1 {
+		t.Errorf("setting the same mode should not repaint, got %d dirty rects", len(observer.dirty))
+	}
+}
+
+func TestLayer_SetBackgroundImageRepaintsAndBumpsVersion(t *testing.T) {
+	pane := newTestPane(t, 1)
+	observer := &recordingObserver{}
+	pane.SetLayerObserver(observer)
+	layer := pane.GetLayer(0)
+
+	layer.SetBackgroundImage(nil)
+	if layer.BackgroundVersion() != 0 || len(observer.dirty) != 0 {
+		t.Fatal("clearing a missing image should change nothing")
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	layer.SetBackgroundImage(img)
+	if layer.BackgroundImage() != img || layer.BackgroundVersion() != 1 || len(observer.dirty) != 1 {
+		t.Fatalf("image = %v, version = %d, dirty = %d", layer.BackgroundImage(), layer.BackgroundVersion(), len(observer.dirty))
+	}
+	layer.SetBackgroundFit(BackgroundTile)
+	if layer.BackgroundFit() != BackgroundTile || layer.BackgroundVersion() != 2 || len(observer.dirty) != 2 {
+		t.Fatal("changing the fit should bump the version and repaint")
+	}
+	layer.SetBackgroundFit(BackgroundTile)
+	if layer.BackgroundVersion() != 2 {
+		t.Error("setting the same fit should not bump the version")
+	}
+	layer.SetBackgroundImage(image.NewNRGBA(image.Rect(0, 0, 0, 4)))
+	if layer.BackgroundImage() != nil || layer.BackgroundVersion() != 3 {
+		t.Error("an empty image should remove the background image")
+	}
+}
+
+func TestLayer_MarkDirtyBumpsStyleVersion(t *testing.T) {
+	pane := newTestPane(t, 1)
+	observer := &recordingObserver{}
+	pane.SetLayerObserver(observer)
+	layer := pane.GetLayer(0)
+	layer.SetStatic(true)
+	layer.ConsumeBucketSync()
+
+	before := layer.StyleVersion()
+	layer.MarkDirty()
+	if layer.StyleVersion() == before {
+		t.Fatal("MarkDirty should bump the style version")
+	}
+	if len(observer.dirty) == 0 {
+		t.Error("MarkDirty should repaint the layer")
+	}
+	if !layer.ConsumeBucketSync() {
+		t.Error("MarkDirty should resync a static layer")
+	}
+}
+
+func TestLayer_ParallaxScalesAndClampsOnBoundedWorld(t *testing.T) {
+	layer := newTestPane(t, 1).GetLayer(0)
+	world := geom.NewVec[uint32](256, 256)
+	view := geom.NewAABBAt(geom.NewVec[uint32](100, 40), 64, 64)
+
+	if got := layer.ParallaxViewRect(view, world, false); got != view {
+		t.Fatalf("default parallax moved the view to %v", got)
+	}
+	layer.SetParallax(0.5, 0)
+	want := geom.NewAABBAt(geom.NewVec[uint32](50, 0), 64, 64)
+	if got := layer.ParallaxViewRect(view, world, false); got != want {
+		t.Fatalf("parallax view = %v, want %v", got, want)
+	}
+	if fx, fy := layer.Parallax(); fx != 0.5 || fy != 0 {
+		t.Fatalf("Parallax() = %v, %v", fx, fy)
+	}
+}
+
+func TestLayer_ParallaxIsContinuousAcrossSeam(t *testing.T) {
+	layer := newTestPane(t, 1).GetLayer(0)
+	layer.SetParallax(0.5, 0.5)
+	world := geom.NewVec[uint32](256, 256)
+
+	at := func(x uint32) uint32 {
+		view := geom.NewAABBAt(geom.NewVec(x, 0), 64, 64)
+		return layer.ParallaxViewRect(view, world, true).TopLeft.X
+	}
+	if got := at(250); got != 125 {
+		t.Fatalf("start = %d, want 125", got)
+	}
+	// Crossing the seam from 250 to 4 is a 10 unit step right: the layer
+	// moves 5, instead of jumping back to 4 * 0.5.
+	if got := at(4); got != 130 {
+		t.Fatalf("after the seam = %d, want 130", got)
+	}
+	if got := at(250); got != 125 {
+		t.Fatalf("back across the seam = %d, want 125", got)
+	}
+}
+
+type stubInstanceVisitor struct{ calls int }
+
+func (v *stubInstanceVisitor) VisitInstances(layer *Layer, fn InstanceFunc) {
+	v.calls++
+	fn(7, geom.NewAABB(geom.NewVec(1, 2), geom.NewVec(3, 4)), SpatialStyle{})
+}
+
+func TestLayer_ForEachInstanceWalksDrawableFragments(t *testing.T) {
+	space := plane.NewToroidal2D[uint32](64, 64)
+	layer := NewLayer(nil)
+	drawable := &Drawable{AABB: space.WrapAABB(geom.NewAABB(geom.NewVec[uint32](60, 10), geom.NewVec[uint32](70, 20)))}
+	layer.AddDrawable(drawable)
+
+	var got []geom.AABB[int]
+	layer.ForEachInstance(func(id uint64, aabb geom.AABB[int], _ SpatialStyle) {
+		if id != drawable.ID {
+			t.Fatalf("id = %d, want %d", id, drawable.ID)
+		}
+		got = append(got, aabb)
+	})
+	want := []geom.AABB[int]{
+		geom.NewAABB(geom.NewVec(60, 10), geom.NewVec(64, 20)),
+		geom.NewAABB(geom.NewVec(0, 10), geom.NewVec(6, 20)),
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("instances = %v, want %v", got, want)
+	}
+}
+
+func TestLayer_ForEachInstanceDelegatesToRenderer(t *testing.T) {
+	layer := NewLayer(nil)
+	layer.AddDrawable(&Drawable{})
+	visitor := &stubInstanceVisitor{}
+	layer.SetInstanceVisitor(visitor)
+
+	var ids []uint64
+	layer.ForEachInstance(func(id uint64, _ geom.AABB[int], _ SpatialStyle) { ids = append(ids, id) })
+	if visitor.calls != 1 || len(ids) != 1 || ids[0] != 7 {
+		t.Fatalf("visitor calls %d, ids %v; want the renderer's instances only", visitor.calls, ids)
+	}
+}
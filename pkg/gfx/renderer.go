@@ -1,8 +1,114 @@
 package gfx
 
+import (
+	"errors"
+	"image"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+)
+
 type Renderer interface {
 	Render(w *Window)
 	Close()
 }
 
 type RendererFactory func(w *Window) Renderer
+
+// RendererInitializer is implemented by renderers that set up GPU state
+// before the first frame. NewWindowE creates the window's GL context and
+// calls Init with it current, so a missing GPU (ErrNoGPU) or a shader that
+// fails to compile is reported as an error instead of a panic in the first
+// Render.
+type RendererInitializer interface {
+	Init(w *Window) error
+}
+
+// SoftwareRenderer is implemented by renderers that rasterize on the CPU and
+// present through Window.NewImageBlitter. The window skips GL frame setup
+// (BeginFrame/EndFrame) when Software returns true.
+type SoftwareRenderer interface {
+	Renderer
+	Software() bool
+}
+
+// Snapshotter is implemented by renderers that keep the last frame on the
+// CPU (the software renderer). Snapshot returns a copy of it, or nil before
+// the first frame.
+type Snapshotter interface {
+	Snapshot() *image.RGBA
+}
+
+// RectCapturer is implemented by renderers that can read back a region of the
+// window framebuffer. rect is in window coordinates (top-left origin) and
+// already clamped to the window; the result has its top-left corner at (0, 0).
+type RectCapturer interface {
+	CaptureRect(w *Window, rect image.Rectangle) (*image.RGBA, error)
+}
+
+// Texture is a GPU texture owned by the renderer. Handle is the GL texture
+// name with the desktop GL renderer; in the browser Value holds the
+// WebGLTexture (a js.Value) and Handle is 0.
+type Texture struct {
+	Handle uint32
+	Value  any
+	Width  int
+	Height int
+}
+
+// TextureRenderer is implemented by renderers that can redirect the final
+// composite into an offscreen texture of the given size.
+type TextureRenderer interface {
+	RenderToTexture(w *Window, width, height int) (Texture, error)
+}
+
+// TextureLoader is implemented by renderers that draw sprites. LoadTexture
+// copies img into a new texture and returns its ID.
+type TextureLoader interface {
+	LoadTexture(w *Window, img image.Image) (TextureID, error)
+}
+
+// InstanceFunc receives one drawn instance: the ID of its drawable, the world
+// rect of the fragment it covers and the style it was built with.
+type InstanceFunc func(id uint64, aabb geom.AABB[int], style SpatialStyle)
+
+// InstanceVisitor is implemented by renderers that cache per-layer instance
+// data. They register with Layer.SetInstanceVisitor so Layer.ForEachInstance
+// reports the instances they uploaded.
+type InstanceVisitor interface {
+	VisitInstances(layer *Layer, fn InstanceFunc)
+}
+
+var (
+	// ErrNoPlatformWindow is returned by NewWindowE when the platform window
+	// cannot be created, e.g. without a display.
+	ErrNoPlatformWindow = errors.New("gfx: platform window could not be created")
+	// ErrNoRenderer is returned by NewWindowE when the factory is nil or
+	// returns no renderer.
+	ErrNoRenderer = errors.New("gfx: renderer factory returned no renderer")
+	// ErrNoGPU is returned by NewWindowE for a GPU renderer implementing
+	// RendererInitializer when the platform cannot create a GL context.
+	ErrNoGPU = errors.New("gfx: no GL context available")
+	// ErrEmptyCapture is returned by Window.CaptureRect for a rect that does
+	// not overlap the window.
+	ErrEmptyCapture = errors.New("gfx: capture rect is empty")
+	// ErrCaptureUnsupported is returned by Window.CaptureRect when the
+	// renderer cannot read back the framebuffer.
+	ErrCaptureUnsupported = errors.New("gfx: renderer does not support capture")
+	// ErrTextureSize is returned by Window.RenderToTexture for a size that is
+	// not positive or exceeds Caps.MaxTextureSize, and by Window.LoadTexture
+	// for an empty image.
+	ErrTextureSize = errors.New("gfx: texture size must be positive")
+	// ErrTextureUnsupported is returned by Window.RenderToTexture when the
+	// renderer has no GPU textures.
+	ErrTextureUnsupported = errors.New("gfx: renderer does not support render to texture")
+	// ErrSpritesUnsupported is returned by Window.LoadTexture when the
+	// renderer cannot draw textures.
+	ErrSpritesUnsupported = errors.New("gfx: renderer does not support sprites")
+)
+
+// ImageBlitter presents a CPU image on the window.
+type ImageBlitter interface {
+	// Update copies rect of the image to the window.
+	Update(rect image.Rectangle)
+	Delete()
+}
@@ -0,0 +1,34 @@
+package gfx
+
+import (
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+)
+
+// TextureID identifies an image loaded with Window.LoadTexture. IDs start at
+// 1; the zero value means no texture.
+type TextureID uint32
+
+// Sprite is a bitmap drawable: the UV region of a loaded texture stretched
+// over its AABB, e.g. one frame of a unit's sprite sheet.
+//
+// GPU renderers bind a single texture per layer, so the sprites of one layer
+// must share a TextureID; pack several images into one texture and select
+// them with UV to mix them on a layer.
+type Sprite struct {
+	plane.AABB[uint32]
+	TextureID TextureID
+	// UV is the texture region in normalized coordinates, (0, 0) being the
+	// top-left of the image. The zero value is the whole texture.
+	UV geom.AABB[float32]
+}
+
+// Drawable returns a drawable rendering the sprite, ready for
+// Layer.AddDrawable. Move it like any other drawable; change the frame shown
+// through Layer.ModifyStyle.
+func (s Sprite) Drawable() *Drawable {
+	return &Drawable{
+		AABB:  s.AABB,
+		Style: SpatialStyle{Texture: s.TextureID, UV: s.UV},
+	}
+}
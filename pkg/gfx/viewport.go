@@ -6,20 +6,49 @@ import (
 	"github.com/kjkrol/gokg/pkg/geom"
 )
 
+// Anchor selects which point of the viewport stays fixed in world space when
+// the viewport is resized.
+type Anchor int
+
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorCenter
+)
+
 type Viewport struct {
 	mu      sync.RWMutex
 	origin  geom.Vec[uint32]
 	size    geom.Vec[uint32]
 	world   geom.Vec[uint32]
-	wrap    bool
+	wrapX   bool
+	wrapY   bool
 	version uint64
+	link    *viewportLink
+}
+
+// ViewportTransform derives a linked viewport's origin and size from its
+// source's origin and size.
+type ViewportTransform func(origin, size geom.Vec[uint32]) (geom.Vec[uint32], geom.Vec[uint32])
+
+type viewportLink struct {
+	source    *Viewport
+	transform ViewportTransform
+	version   uint64
+	synced    bool
 }
 
 func NewViewport(worldSize, viewSize geom.Vec[uint32], wrap bool) *Viewport {
+	return NewViewportAxes(worldSize, viewSize, wrap, wrap)
+}
+
+// NewViewportAxes is NewViewport with wrapping chosen per axis: the origin
+// wraps on a wrapping axis and is clamped to the world on the other.
+func NewViewportAxes(worldSize, viewSize geom.Vec[uint32], wrapX, wrapY bool) *Viewport {
 	v := &Viewport{
 		size:  viewSize,
 		world: worldSize,
-		wrap:  wrap,
+		wrapX: wrapX,
+		wrapY: wrapY,
 	}
 	v.setOriginLocked(geom.NewVec[uint32](0, 0))
 	return v
@@ -54,11 +83,18 @@ func (v *Viewport) WorldSize() geom.Vec[uint32] {
 	return world
 }
 
+// Wrap reports whether the world wraps on any axis; see WrapAxes.
 func (v *Viewport) Wrap() bool {
+	x, y := v.WrapAxes()
+	return x || y
+}
+
+// WrapAxes reports on which axes the world wraps.
+func (v *Viewport) WrapAxes() (x, y bool) {
 	v.mu.RLock()
-	wrap := v.wrap
+	x, y = v.wrapX, v.wrapY
 	v.mu.RUnlock()
-	return wrap
+	return x, y
 }
 
 func (v *Viewport) Version() uint64 {
@@ -80,6 +116,113 @@ func (v *Viewport) Move(dx, dy int32) {
 	v.mu.Unlock()
 }
 
+// Resize changes the viewport size. With AnchorCenter the world point at the
+// viewport center is kept in place; with AnchorTopLeft the origin is kept.
+// The resulting origin is wrapped or clamped like any other move.
+func (v *Viewport) Resize(newSize geom.Vec[uint32], anchor Anchor) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if newSize == v.size {
+		return
+	}
+	origin := v.origin
+	if anchor == AnchorCenter {
+		dx := int32(v.size.X/2) - int32(newSize.X/2)
+		dy := int32(v.size.Y/2) - int32(newSize.Y/2)
+		origin = origin.Add(geom.NewVec[uint32](uint32(dx), uint32(dy)))
+	}
+	v.size = newSize
+	v.version++
+	v.setOriginLocked(origin)
+}
+
+// SetWorldSize changes the world extent and re-clamps (or re-wraps) the
+// origin into it.
+func (v *Viewport) SetWorldSize(world geom.Vec[uint32]) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if world == v.world {
+		return
+	}
+	v.world = world
+	v.version++
+	v.setOriginLocked(v.origin)
+}
+
+// invalidate bumps the version without moving the view, so a window
+// rendering on demand redraws the pane.
+func (v *Viewport) invalidate() {
+	v.mu.Lock()
+	v.version++
+	v.mu.Unlock()
+}
+
+// Link makes v follow source: whenever source's Version changes, SyncLink
+// recomputes v's origin and size through transform (identity when nil). The
+// window syncs links of its panes before every frame. Passing a nil source
+// unlinks v. Link returns false, leaving v unchanged, if it would create a
+// cycle.
+func (v *Viewport) Link(source *Viewport, transform ViewportTransform) bool {
+	if source == nil {
+		v.mu.Lock()
+		v.link = nil
+		v.mu.Unlock()
+		return true
+	}
+	for s := source; s != nil; s = s.linkSource() {
+		if s == v {
+			return false
+		}
+	}
+	v.mu.Lock()
+	v.link = &viewportLink{source: source, transform: transform}
+	v.mu.Unlock()
+	return true
+}
+
+// SyncLink applies the link set by Link if the source changed since the last
+// sync, syncing the source's own link first. It reports whether v changed.
+func (v *Viewport) SyncLink() bool {
+	v.mu.RLock()
+	link := v.link
+	v.mu.RUnlock()
+	if link == nil {
+		return false
+	}
+	link.source.SyncLink()
+
+	link.source.mu.RLock()
+	origin, size, version := link.source.origin, link.source.size, link.source.version
+	link.source.mu.RUnlock()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.link != link || (link.synced && link.version == version) {
+		return false
+	}
+	link.version = version
+	link.synced = true
+	if link.transform != nil {
+		origin, size = link.transform(origin, size)
+	}
+	before := v.version
+	if size != v.size {
+		v.size = size
+		v.version++
+	}
+	v.setOriginLocked(origin)
+	return v.version != before
+}
+
+func (v *Viewport) linkSource() *Viewport {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.link == nil {
+		return nil
+	}
+	return v.link.source
+}
+
 func (v *Viewport) setOriginLocked(origin geom.Vec[uint32]) {
 	normalized := v.normalize(origin)
 	if normalized == v.origin {
@@ -90,29 +233,26 @@ func (v *Viewport) setOriginLocked(origin geom.Vec[uint32]) {
 }
 
 func (v *Viewport) normalize(origin geom.Vec[uint32]) geom.Vec[uint32] {
-	if v.wrap && v.world.X > 0 && v.world.Y > 0 {
-		math := geom.VectorMathByType[uint32]()
-		return math.Wrap(origin, v.world)
-	}
-	maxX := int64(0)
-	maxY := int64(0)
-	if v.world.X > v.size.X {
-		maxX = int64(v.world.X - v.size.X)
-	}
-	if v.world.Y > v.size.Y {
-		maxY = int64(v.world.Y - v.size.Y)
-	}
-	x := int64(int32(origin.X))
-	y := int64(int32(origin.Y))
-	if x < 0 {
-		x = 0
-	} else if x > maxX {
-		x = maxX
+	return geom.NewVec(
+		normalizeAxis(origin.X, v.size.X, v.world.X, v.wrapX),
+		normalizeAxis(origin.Y, v.size.Y, v.world.Y, v.wrapY),
+	)
+}
+
+// normalizeAxis reads one origin component as a signed value and wraps it
+// into the world, or clamps it so the view stays inside the world.
+func normalizeAxis(origin, size, world uint32, wrap bool) uint32 {
+	pos := int64(int32(origin))
+	if wrap && world > 0 {
+		pos %= int64(world)
+		if pos < 0 {
+			pos += int64(world)
+		}
+		return uint32(pos)
 	}
-	if y < 0 {
-		y = 0
-	} else if y > maxY {
-		y = maxY
+	limit := int64(0)
+	if world > size {
+		limit = int64(world - size)
 	}
-	return geom.NewVec(uint32(x), uint32(y))
+	return uint32(min(max(pos, 0), limit))
 }
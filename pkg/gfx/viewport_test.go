@@ -0,0 +1,98 @@
+package gfx
+
+import (
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+)
+
+func TestViewport_ResizeAnchors(t *testing.T) {
+	world := geom.NewVec[uint32](256, 256)
+
+	v := NewViewport(world, geom.NewVec[uint32](100, 100), false)
+	v.SetOrigin(50, 50)
+	v.Resize(geom.NewVec[uint32](60, 40), AnchorTopLeft)
+	if got := v.Origin(); got != geom.NewVec[uint32](50, 50) {
+		t.Errorf("top-left anchor moved origin to %v", got)
+	}
+
+	v = NewViewport(world, geom.NewVec[uint32](100, 100), false)
+	v.SetOrigin(50, 50)
+	version := v.Version()
+	v.Resize(geom.NewVec[uint32](60, 40), AnchorCenter)
+	if got := v.Origin(); got != geom.NewVec[uint32](70, 80) {
+		t.Errorf("center anchor origin = %v, want (70,80)", got)
+	}
+	if v.Version() == version {
+		t.Error("Resize did not bump the viewport version")
+	}
+
+	// Growing around a center near the world edge clamps without wrap...
+	v = NewViewport(world, geom.NewVec[uint32](20, 20), false)
+	v.Resize(geom.NewVec[uint32](100, 100), AnchorCenter)
+	if got := v.Origin(); got != geom.NewVec[uint32](0, 0) {
+		t.Errorf("clamped origin = %v, want (0,0)", got)
+	}
+
+	// ...and wraps around the seam with wrap enabled.
+	v = NewViewport(world, geom.NewVec[uint32](20, 20), true)
+	v.Resize(geom.NewVec[uint32](100, 100), AnchorCenter)
+	if got := v.Origin(); got != geom.NewVec[uint32](216, 216) {
+		t.Errorf("wrapped origin = %v, want (216,216)", got)
+	}
+}
+
+func TestViewport_LinkFollowsSource(t *testing.T) {
+	world := geom.NewVec[uint32](256, 256)
+	main := NewViewport(world, geom.NewVec[uint32](64, 64), true)
+	minimap := NewViewport(world, geom.NewVec[uint32](16, 16), true)
+	halve := func(origin, size geom.Vec[uint32]) (geom.Vec[uint32], geom.Vec[uint32]) {
+		return geom.NewVec(origin.X/2, origin.Y/2), geom.NewVec(size.X/2, size.Y/2)
+	}
+	if !minimap.Link(main, halve) {
+		t.Fatal("Link rejected a valid source")
+	}
+
+	if !minimap.SyncLink() {
+		t.Fatal("first sync should apply the link")
+	}
+	if minimap.SyncLink() {
+		t.Fatal("sync without a source change reported a change")
+	}
+
+	main.SetOrigin(40, 20)
+	if !minimap.SyncLink() {
+		t.Fatal("sync missed a source move")
+	}
+	if got := minimap.Origin(); got != geom.NewVec[uint32](20, 10) {
+		t.Errorf("linked origin = %v, want (20,10)", got)
+	}
+	if got := minimap.Size(); got != geom.NewVec[uint32](32, 32) {
+		t.Errorf("linked size = %v, want (32,32)", got)
+	}
+
+	if main.Link(minimap, nil) {
+		t.Error("Link accepted a cycle")
+	}
+	minimap.Link(nil, nil)
+	main.SetOrigin(0, 0)
+	if minimap.SyncLink() {
+		t.Error("unlinked viewport still follows its old source")
+	}
+}
+
+func TestViewport_WrapsPerAxis(t *testing.T) {
+	v := NewViewportAxes(geom.NewVec[uint32](256, 256), geom.NewVec[uint32](64, 64), true, false)
+	if !v.Wrap() {
+		t.Fatal("Wrap() = false for a world wrapping on X")
+	}
+
+	v.Move(-10, -10)
+	if got := v.Origin(); got != geom.NewVec[uint32](246, 0) {
+		t.Errorf("origin after moving past the top-left = %v, want (246,0)", got)
+	}
+	v.SetOrigin(300, 300)
+	if got := v.Origin(); got != geom.NewVec[uint32](44, 192) {
+		t.Errorf("origin past the bottom-right = %v, want (44,192)", got)
+	}
+}
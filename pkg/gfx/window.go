@@ -1,7 +1,13 @@
 package gfx
 
 import (
+	"cmp"
 	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/kjkrol/gokx/internal/platform"
@@ -16,24 +22,77 @@ type WindowConfig struct {
 	Title             string
 	World             WorldConfig
 	ChannelBufferSize int
+	// EventOverflowPolicy applies to the window queue (EmitEvent) and to
+	// backends that buffer input themselves (WASM); see DefaultOverflow for
+	// the zero value. EventBlockTimeout bounds the wait of the Block policy.
+	EventOverflowPolicy EventOverflowPolicy
+	EventBlockTimeout   time.Duration
+	// GL selects the GL context API and version (default OpenGL 3.3).
+	GL GLContextConfig
+}
+
+// GLAPI selects the client API of the window's GL context.
+type GLAPI int
+
+const (
+	// GLAPIOpenGL requests desktop OpenGL (default).
+	GLAPIOpenGL GLAPI = GLAPI(platform.GLAPIOpenGL)
+	// GLAPIOpenGLES requests OpenGL ES, e.g. on GLES-only embedded GPUs.
+	GLAPIOpenGLES GLAPI = GLAPI(platform.GLAPIOpenGLES)
+)
+
+// GLContextConfig is the GL context requested from the backend. Zero
+// Major/Minor select 3.3 for OpenGL and 3.0 for OpenGL ES, the minimum the
+// renderer needs. WASM always uses WebGL2 and ignores it.
+type GLContextConfig struct {
+	API          GLAPI
+	Major, Minor int
+}
+
+func (c GLContextConfig) convert() platform.GLContextConfig {
+	return platform.GLContextConfig{API: platform.GLAPI(c.API), Major: c.Major, Minor: c.Minor}
+}
+
+// Validate reports a context version the renderer cannot use: it needs
+// OpenGL 3.3 or OpenGL ES 3.0.
+func (c GLContextConfig) Validate() error {
+	return c.convert().Validate()
+}
+
+// ShaderHeader returns the GLSL #version line (plus default precisions for
+// ES) the renderer prepends to shader sources for this context.
+func (c GLContextConfig) ShaderHeader() string {
+	return c.convert().ShaderHeader()
+}
+
+// StripShaderVersion blanks a #version directive in source and reports one
+// that does not match ShaderHeader.
+func (c GLContextConfig) StripShaderVersion(source string) (string, error) {
+	return c.convert().StripShaderVersion(source)
 }
 
 func (w WindowConfig) convert() platform.WindowConfig {
 	return platform.WindowConfig{
-		PositionX:   w.PositionX,
-		PositionY:   w.PositionY,
-		Width:       w.Width,
-		Height:      w.Height,
-		BorderWidth: w.BorderWidth,
-		Title:       w.Title,
+		PositionX:         w.PositionX,
+		PositionY:         w.PositionY,
+		Width:             w.Width,
+		Height:            w.Height,
+		BorderWidth:       w.BorderWidth,
+		Title:             w.Title,
+		EventBufferSize:   w.ChannelBufferSize,
+		EventOverflow:     platform.OverflowPolicy(w.EventOverflowPolicy),
+		EventBlockTimeout: w.EventBlockTimeout,
+		GL:                w.GL.convert(),
 	}
 }
 
 type Window struct {
 	platformWinWrapper platform.PlatformWindowWrapper
 	renderer           Renderer
+	glConfig           GLContextConfig
 	defaultPane        *Pane
 	panes              map[string]*Pane
+	paneOrder          []*Pane
 
 	width  int
 	height int
@@ -45,18 +104,81 @@ type Window struct {
 	layerObserver   LayerObserver
 	nextPaneID      uint64
 	drawableApplier DrawableEventsApplier
+
+	closeRequestHandler func() bool
+	eventHandlers       eventHandlers
+	ecsEngine           ECSEngine
+	ecsUpdater          atomic.Pointer[ecsUpdater]
+	updateThreaded      atomic.Bool
+	threadedActive      atomic.Bool
+	handoff             drawableHandoff
+	handoffSpare        []Event
+	onFrame             func(time.Duration)
+	lastFrame           time.Time
+	// pointerX/pointerY is the last pointer position reported by an event,
+	// valid while pointerIn; see Pane.SetCursorDrawable.
+	pointerX, pointerY int
+	pointerIn          bool
+
+	renderOnDemand atomic.Bool
+	invalidated    atomic.Bool
+	paused         atomic.Bool
+	pauseRendering atomic.Bool
+	viewVersions   map[*Pane]uint64
 }
 
 func NewWindow(conf WindowConfig, factory RendererFactory) *Window {
-	platformConfig := conf.convert()
-	window := Window{
-		platformWinWrapper: platform.NewPlatformWindowWrapper(platformConfig),
+	window, err := newWindow(conf)
+	if err != nil {
+		panic(err.Error())
+	}
+	if factory != nil {
+		window.renderer = factory(window)
+	}
+	return window
+}
+
+// NewWindowE is NewWindow reporting failures as errors: the error of
+// GLContextConfig.Validate for an unusable conf.GL, ErrNoPlatformWindow
+// wrapping the platform's error, ErrNoRenderer for a nil factory or
+// renderer, ErrNoGPU when a GPU renderer implementing RendererInitializer
+// gets no GL context, and the error of its Init. Init runs here, with the GL
+// context current, rather than in the first frame. On error nothing is left
+// open, so an app can retry with another factory, e.g. the software
+// renderer.
+func NewWindowE(conf WindowConfig, factory RendererFactory) (*Window, error) {
+	window, err := newWindow(conf)
+	if err != nil {
+		return nil, err
+	}
+	if factory != nil {
+		window.renderer = factory(window)
+	}
+	if window.renderer == nil {
+		window.Close()
+		return nil, ErrNoRenderer
+	}
+	if err := window.initRenderer(); err != nil {
+		window.Close()
+		return nil, err
+	}
+	return window, nil
+}
+
+func newWindow(conf WindowConfig) (*Window, error) {
+	if err := conf.GL.Validate(); err != nil {
+		return nil, err
+	}
+	wrapper, err := platform.NewPlatformWindowWrapper(conf.convert())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoPlatformWindow, err)
+	}
+	window := &Window{
+		platformWinWrapper: wrapper,
 		panes:              make(map[string]*Pane),
 		width:              conf.Width,
 		height:             conf.Height,
-	}
-	if window.platformWinWrapper == nil {
-		panic("platform window wrapper is required")
+		glConfig:           conf.GL,
 	}
 	window.defaultPane = newPane(
 		&PaneConfig{
@@ -71,9 +193,6 @@ func NewWindow(conf WindowConfig, factory RendererFactory) *Window {
 	if window.layerObserver != nil {
 		window.defaultPane.SetLayerObserver(window.layerObserver)
 	}
-	if factory != nil {
-		window.renderer = factory(&window)
-	}
 
 	window.eventLoop = NewEventLoop(
 		conf.ChannelBufferSize,
@@ -84,9 +203,35 @@ func NewWindow(conf WindowConfig, factory RendererFactory) *Window {
 			}
 			return convert(platformEvent), true
 		})
+	window.eventLoop.SetOverflowPolicy(conf.EventOverflowPolicy, conf.EventBlockTimeout)
 
 	window.nextPaneID = 1
-	return &window
+	return window, nil
+}
+
+// initRenderer runs the renderer's Init, if any, with the GL context of a GPU
+// renderer made current. A platform without a GPU, or whose context creation
+// fails, yields ErrNoGPU instead of the panic BeginFrame would raise.
+func (w *Window) initRenderer() error {
+	initializer, ok := w.renderer.(RendererInitializer)
+	if !ok {
+		return nil
+	}
+	if w.softwareRendering() {
+		return initializer.Init(w)
+	}
+	if !w.GPUAvailable() {
+		return ErrNoGPU
+	}
+	if creator, ok := w.platformWinWrapper.(platform.GLContextCreator); ok {
+		if err := creator.CreateGLContext(); err != nil {
+			return fmt.Errorf("%w: %v", ErrNoGPU, err)
+		}
+		return initializer.Init(w)
+	}
+	w.platformWinWrapper.BeginFrame()
+	defer w.platformWinWrapper.EndFrame()
+	return initializer.Init(w)
 }
 
 func (w *Window) AddPane(name string, conf *PaneConfig) *Pane {
@@ -95,7 +240,11 @@ func (w *Window) AddPane(name string, conf *PaneConfig) *Pane {
 	if w.layerObserver != nil {
 		pane.SetLayerObserver(w.layerObserver)
 	}
+	if prev, ok := w.panes[name]; ok {
+		w.paneOrder = slices.DeleteFunc(w.paneOrder, func(p *Pane) bool { return p == prev })
+	}
 	w.panes[name] = pane
+	w.paneOrder = append(w.paneOrder, pane)
 	return pane
 }
 
@@ -107,10 +256,26 @@ func (w *Window) GetPaneByName(name string) *Pane {
 	return w.panes[name]
 }
 
+// Panes returns the window's panes in compositing order: ascending ZOrder,
+// ties broken by insertion order with the default pane first.
 func (w *Window) Panes() []*Pane {
 	return w.panesSnapshot()
 }
 
+// EachPane calls fn for every pane in the order of Panes, with the name it
+// was added under; the default pane has the empty name. Together with
+// Pane.IDValue, Pane.EachLayer and Layer.ID it lets an app walk the scene
+// graph, e.g. to save it.
+func (w *Window) EachPane(fn func(name string, p *Pane)) {
+	names := make(map[*Pane]string, len(w.panes))
+	for name, pane := range w.panes {
+		names[pane] = name
+	}
+	for _, pane := range w.panesSnapshot() {
+		fn(names[pane], pane)
+	}
+}
+
 func (w *Window) Size() (int, int) {
 	if w == nil {
 		return 0, 0
@@ -122,31 +287,324 @@ func (w *Window) Show() {
 	w.platformWinWrapper.Show()
 }
 
+// RefreshRate sets how many frames per second the render tick draws.
 func (w *Window) RefreshRate(fps int) {
 	w.rendererRefreshRate = time.Second / time.Duration(fps)
 }
 
+// ECSRefreshRate sets the fixed ECS update rate in steps per second. It
+// leaves the render rate alone; use RefreshRate for that.
 func (w *Window) ECSRefreshRate(rps int) {
-	w.rendererRefreshRate = time.Second / time.Duration(rps)
+	w.ecsRefreshRate = time.Second / time.Duration(rps)
+}
+
+// SetECSEngine drives engine.UpdateSystems from the window loop at the
+// ECSRefreshRate fixed step. Elapsed wall time is accumulated and consumed in
+// whole steps, so systems always receive the same dt and integrate velocities
+// deterministically (pos += vel * dt.Seconds()) whatever the render rate.
+// Close shuts the engine down.
+func (w *Window) SetECSEngine(engine ECSEngine) {
+	w.ecsEngine = engine
+}
+
+// SetUpdateThreaded moves the fixed-step ECS update off the loop thread onto
+// its own goroutine, so a heavy UpdateSystems no longer delays input
+// handling. It takes effect at the next ListenEvents. GL calls stay on the
+// loop thread: drawable events emitted with EmitEvent are double-buffered
+// and handed to it once per update step, and systems must not touch layers
+// or the renderer directly (nor call Pane.Clear).
+func (w *Window) SetUpdateThreaded(enabled bool) {
+	w.updateThreaded.Store(enabled)
 }
 
 func (w *Window) ListenEvents(dispather EventDispatcher) {
 	dispatch := func(event Event) {
-		w.applyDrawableEvent(event)
-		if dispather != nil {
-			dispather(event)
-		}
+		w.dispatchEvent(event, dispather)
 	}
 
+	threaded := w.updateThreaded.Load()
+	w.threadedActive.Store(threaded)
+	defer w.threadedActive.Store(false)
+
+	software := w.softwareRendering()
 	renderUpdater := newRenderUpdater(w.rendererRefreshRate, func() {
+		if w.paused.Load() && w.pauseRendering.Load() {
+			return
+		}
+		w.applyHandoff()
+		w.runOnFrame()
+		w.moveCursors()
+		w.syncViewportLinks()
+		w.updateViewStates()
+		w.flushTouched()
+		if !w.consumeRenderRequest() {
+			return
+		}
+		w.renderFrame(software)
+	})
+	ecsAdaptiveUpdater := newECSUpdater(w.ecsRefreshRate, func(d time.Duration) {
+		if w.ecsEngine != nil {
+			w.ecsEngine.UpdateSystems(d)
+		}
+		if threaded {
+			w.handoff.publish()
+		}
+	})
+	ecsAdaptiveUpdater.paused = w.paused.Load
+
+	w.ecsUpdater.Store(ecsAdaptiveUpdater)
+	w.lastFrame = time.Time{}
+	if threaded {
+		w.eventLoop.runUpdates(ecsAdaptiveUpdater)
+		w.eventLoop.Run(dispatch, renderUpdater, nil)
+		return
+	}
+	w.eventLoop.Run(dispatch, renderUpdater, ecsAdaptiveUpdater)
+}
+
+// dispatchEvent applies event to the window, then hands it to the handlers
+// added with AddEventHandler and, unless one consumed it, to dispatcher.
+func (w *Window) dispatchEvent(event Event, dispatcher EventDispatcher) {
+	w.applyDrawableEvent(event)
+	w.applyCloseRequest(event)
+	w.trackPointer(event)
+	if !w.eventHandlers.dispatch(event) && dispatcher != nil {
+		dispatcher(event)
+	}
+	// Handlers may mutate layers directly, so any dispatched event
+	// counts as a reason to redraw in on-demand mode.
+	w.invalidated.Store(true)
+}
+
+// OnFrame sets a variable-step callback for animation, interpolation and
+// camera work; fn receives the time since its previous call (zero on the
+// first frame). Each loop iteration dispatches pending events, then runs the
+// fixed-step ECS update (zero or more steps, see SetECSEngine), then, on a
+// render tick, calls fn before the grid is flushed and the frame drawn, so
+// changes made in fn show in that frame. It runs on the loop goroutine on
+// every render tick, also under render-on-demand, where viewport moves
+// redraw by themselves but other changes need Invalidate. It is not called
+// while rendering is paused. Pass nil to remove it.
+func (w *Window) OnFrame(fn func(dt time.Duration)) {
+	w.onFrame = fn
+}
+
+func (w *Window) runOnFrame() {
+	if w.onFrame == nil {
+		return
+	}
+	now := time.Now()
+	var dt time.Duration
+	if !w.lastFrame.IsZero() {
+		dt = now.Sub(w.lastFrame)
+	}
+	w.lastFrame = now
+	w.onFrame(dt)
+}
+
+// trackPointer records the pointer position carried by event.
+func (w *Window) trackPointer(event Event) {
+	if x, y, ok := pointerPosition(event); ok {
+		w.pointerX, w.pointerY, w.pointerIn = x, y, true
+		return
+	}
+	if _, ok := event.(LeaveNotify); ok {
+		w.pointerIn = false
+	}
+}
+
+// moveCursors centers the cursor drawable of every pane under the pointer on
+// it (see Pane.SetCursorDrawable).
+func (w *Window) moveCursors() {
+	mover, ok := w.drawableApplier.(DrawableMovedApplier)
+	if !w.pointerIn || !ok {
+		return
+	}
+	var moves []DrawableMove
+	for _, pane := range w.panesSnapshot() {
+		if move, ok := pane.cursorMove(w.pointerX, w.pointerY); ok {
+			moves = append(moves, move)
+		}
+	}
+	if len(moves) > 0 {
+		mover.ApplyMoved(moves)
+	}
+}
+
+// applyHandoff applies the drawable events published by the threaded update
+// goroutine since the last frame.
+func (w *Window) applyHandoff() {
+	events := w.handoff.take(w.handoffSpare)
+	for _, event := range events {
+		w.applyDrawableEvent(event)
+	}
+	if len(events) > 0 {
+		w.invalidated.Store(true)
+	}
+	w.handoffSpare = events
+}
+
+// RenderOnce synchronously renders and presents one frame without the event
+// loop, so scripts and golden tests can render and then Snapshot or
+// CaptureRect deterministically. Drawable events already queued with
+// EmitEvent are applied and the touched grid managers flushed first; other
+// queued events stay queued for ListenEvents. The frame is drawn even in
+// render-on-demand mode or while paused. Call it after Show, from the
+// goroutine that owns the window (and its GL context), never while
+// ListenEvents is running.
+func (w *Window) RenderOnce() {
+	if w.renderer == nil {
+		return
+	}
+	w.eventLoop.drainQueued(func(event Event) bool {
+		if !isDrawableEvent(event) {
+			return false
+		}
+		w.applyDrawableEvent(event)
+		return true
+	})
+	w.applyHandoff()
+	w.syncViewportLinks()
+	w.updateViewStates()
+	if w.drawableApplier != nil {
 		w.drawableApplier.FlushTouched()
+	}
+	w.consumeRenderRequest()
+	w.renderFrame(w.softwareRendering())
+}
+
+func (w *Window) softwareRendering() bool {
+	sr, ok := w.renderer.(SoftwareRenderer)
+	return ok && sr.Software()
+}
+
+// renderFrame renders one frame; GPU renderers are wrapped in the backend's
+// BeginFrame/EndFrame, which present it.
+func (w *Window) renderFrame(software bool) {
+	if !software {
 		w.platformWinWrapper.BeginFrame()
-		w.renderer.Render(w)
+	}
+	w.renderer.Render(w)
+	if !software {
 		w.platformWinWrapper.EndFrame()
-	})
-	ecsAdaptiveUpdater := newECSUpdater(w.ecsRefreshRate, func(d time.Duration) {})
+	}
+}
 
-	w.eventLoop.Run(dispatch, renderUpdater, ecsAdaptiveUpdater)
+// Snapshot returns a copy of the last frame rendered to the window, e.g. to
+// save it as a PNG or for pixel tests. ok is false when the renderer keeps no
+// CPU copy (GPU renderers) or nothing has been rendered yet.
+func (w *Window) Snapshot() (img *image.RGBA, ok bool) {
+	snapshotter, ok := w.renderer.(Snapshotter)
+	if !ok {
+		return nil, false
+	}
+	img = snapshotter.Snapshot()
+	return img, img != nil
+}
+
+// PaneSnapshot is Snapshot cropped to pane, with the pane's top-left corner
+// at (0, 0).
+func (w *Window) PaneSnapshot(pane *Pane) (*image.RGBA, bool) {
+	if pane == nil || pane.Config == nil {
+		return nil, false
+	}
+	frame, ok := w.Snapshot()
+	if !ok {
+		return nil, false
+	}
+	conf := pane.Config
+	rect := image.Rect(conf.OffsetX, conf.OffsetY, conf.OffsetX+conf.Width, conf.OffsetY+conf.Height).Intersect(frame.Bounds())
+	if rect.Empty() {
+		return nil, false
+	}
+	img := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(img, img.Bounds(), frame, rect.Min, draw.Src)
+	return img, true
+}
+
+// CaptureRect reads back the region r of the window (window coordinates,
+// clamped to the window size) without copying the whole frame, e.g. for a
+// thumbnail. The result has its top-left corner at (0, 0). GPU renderers
+// recomposite the last frame and read it with glReadPixels, so with them it
+// must be called from the window loop goroutine (an event handler).
+func (w *Window) CaptureRect(r image.Rectangle) (*image.RGBA, error) {
+	width, height := w.Size()
+	r = r.Intersect(image.Rect(0, 0, width, height))
+	if r.Empty() {
+		return nil, ErrEmptyCapture
+	}
+	capturer, ok := w.renderer.(RectCapturer)
+	if !ok {
+		return nil, ErrCaptureUnsupported
+	}
+	return capturer.CaptureRect(w, r)
+}
+
+// RenderToTexture runs the full pane and composite pipeline, post passes
+// included, into an offscreen texture of width x height pixels instead of the
+// window, e.g. to use the scene as a material in another GL program. The
+// window image is scaled to fit the texture, which is in GL orientation
+// (first row at the bottom).
+//
+// The texture belongs to the renderer: every call redraws and returns the
+// same texture (reallocated when the size changes) and Close deletes it. It
+// lives in the window's GL context, so RenderToTexture and any use of the
+// texture must happen on the window loop goroutine (an event handler) or in a
+// context sharing objects with it.
+func (w *Window) RenderToTexture(width, height int) (Texture, error) {
+	if width <= 0 || height <= 0 {
+		return Texture{}, ErrTextureSize
+	}
+	renderer, ok := w.renderer.(TextureRenderer)
+	if !ok {
+		return Texture{}, ErrTextureUnsupported
+	}
+	return renderer.RenderToTexture(w, width, height)
+}
+
+// LoadTexture copies img into a texture for sprites (see Sprite) and returns
+// its ID. Textures live until Close. An image larger than
+// Caps.MaxTextureSize is shrunk; sprites sample it by UV, so they draw it in
+// place at a lower resolution. GPU renderers upload to the window's GL
+// context, so with them LoadTexture must be called on the goroutine that
+// created the window, before ListenEvents, or from the window loop goroutine
+// (an event handler).
+func (w *Window) LoadTexture(img image.Image) (TextureID, error) {
+	if img == nil || img.Bounds().Empty() {
+		return 0, ErrTextureSize
+	}
+	loader, ok := w.renderer.(TextureLoader)
+	if !ok {
+		return 0, ErrSpritesUnsupported
+	}
+	if !w.softwareRendering() {
+		w.platformWinWrapper.BeginFrame()
+	}
+	return loader.LoadTexture(w, img)
+}
+
+// InterpolationAlpha returns how far, as a fraction in [0, 1), the window
+// loop is between the last ECS fixed step and the next one. Rendering
+// prev + (curr - prev) * alpha of the last two simulated states hides the
+// step-to-frame beat when the ECS and render rates differ. It is 0 before
+// ListenEvents starts.
+func (w *Window) InterpolationAlpha() float64 {
+	updater := w.ecsUpdater.Load()
+	if updater == nil {
+		return 0
+	}
+	return updater.alpha()
+}
+
+// Run is ListenEvents bound to ctx: cancelling ctx stops the loop just like
+// Stop. It still locks the OS thread for the duration of the loop and
+// returns ctx.Err() when the caller's context ended it, nil otherwise, so it
+// fits errgroup.Group.Go directly.
+func (w *Window) Run(ctx context.Context, dispatcher EventDispatcher) error {
+	stop := context.AfterFunc(ctx, w.Stop)
+	defer stop()
+	w.ListenEvents(dispatcher)
+	return ctx.Err()
 }
 
 func (w *Window) Stop() {
@@ -155,6 +613,10 @@ func (w *Window) Stop() {
 
 func (w *Window) Close() {
 	w.Stop()
+	if w.ecsEngine != nil {
+		w.ecsEngine.Shutdown()
+		w.ecsEngine = nil
+	}
 	if w.renderer != nil {
 		w.renderer.Close()
 		w.renderer = nil
@@ -165,10 +627,36 @@ func (w *Window) Close() {
 		pane.Close()
 	}
 	w.panes = nil
+	w.paneOrder = nil
 	w.platformWinWrapper.Close()
 
 }
 
+// NewImageBlitter returns a presenter that copies img to the window at the
+// given offset, or nil when the platform has no software blit path.
+func (w *Window) NewImageBlitter(img *image.RGBA, offsetX, offsetY int) ImageBlitter {
+	if w == nil || img == nil {
+		return nil
+	}
+	factory, ok := w.platformWinWrapper.(platform.ImageWrapperFactory)
+	if !ok {
+		return nil
+	}
+	return factory.NewPlatformImageWrapper(img, offsetX, offsetY)
+}
+
+// GPUAvailable reports whether the platform can create a GL context. Backends
+// that cannot probe are assumed to have one.
+func (w *Window) GPUAvailable() bool {
+	if w == nil {
+		return false
+	}
+	if prober, ok := w.platformWinWrapper.(platform.GPUProber); ok {
+		return prober.GPUAvailable()
+	}
+	return true
+}
+
 // TODO: sprawdzic czy uzywane; jesli nie usunac
 func (w *Window) GLContext() any {
 	if w == nil || w.platformWinWrapper == nil {
@@ -177,11 +665,84 @@ func (w *Window) GLContext() any {
 	return w.platformWinWrapper.GLContext()
 }
 
+// GLConfig returns the GL context configuration the window was created with.
+func (w *Window) GLConfig() GLContextConfig {
+	return w.glConfig
+}
+
+// SetCloseRequestHandler intercepts user close requests (window manager close
+// button, SDL_QUIT, browser beforeunload). Returning false vetoes the close;
+// with no handler the window stops immediately.
+func (w *Window) SetCloseRequestHandler(fn func() bool) {
+	w.closeRequestHandler = fn
+	if setter, ok := w.platformWinWrapper.(platform.CloseRequestHandlerSetter); ok {
+		setter.SetCloseRequestHandler(fn)
+	}
+}
+
+// Invalidate forces the next render tick to draw a frame even if nothing is
+// dirty. Safe to call from any goroutine.
+func (w *Window) Invalidate() {
+	w.invalidated.Store(true)
+}
+
+// SetRenderOnDemand toggles power-saving rendering. When enabled, a render
+// tick only draws if Invalidate was called, an event was dispatched, a pane
+// viewport changed or the drawable applier flushed changes since the last
+// frame. Other changes made outside the event loop must call Invalidate to
+// become visible.
+func (w *Window) SetRenderOnDemand(enabled bool) {
+	w.renderOnDemand.Store(enabled)
+	w.invalidated.Store(true)
+}
+
+// Pause stops the ECS updates while events keep being dispatched, e.g. for a
+// pause menu. Rendering continues unless SetPauseRendering(true) was called.
+// Safe to call from any goroutine.
+func (w *Window) Pause() {
+	w.paused.Store(true)
+}
+
+// Resume restarts the ECS updates stopped by Pause. The paused time is not
+// simulated.
+func (w *Window) Resume() {
+	w.paused.Store(false)
+	w.invalidated.Store(true)
+}
+
+func (w *Window) Paused() bool {
+	return w.paused.Load()
+}
+
+// SetPauseRendering controls whether a paused window also skips rendering.
+func (w *Window) SetPauseRendering(skip bool) {
+	w.pauseRendering.Store(skip)
+}
+
 // EmitEvent injects an event into the window loop (used by simulation).
+// While the update runs threaded, drawable events wait for the end of the
+// current update step instead; see SetUpdateThreaded.
 func (w *Window) EmitEvent(event Event) {
+	if w.threadedActive.Load() && isDrawableEvent(event) {
+		w.handoff.add(event)
+		return
+	}
 	w.eventLoop.EmitEvent(event)
 }
 
+// DroppedEvents returns how many events were discarded on queue overflow,
+// including those dropped by the platform backend.
+func (w *Window) DroppedEvents() uint64 {
+	if w == nil {
+		return 0
+	}
+	dropped := w.eventLoop.Dropped()
+	if counter, ok := w.platformWinWrapper.(platform.DroppedEventsCounter); ok {
+		dropped += counter.DroppedEvents()
+	}
+	return dropped
+}
+
 func (w *Window) Context() context.Context {
 	if w == nil {
 		return nil
@@ -201,16 +762,101 @@ func (w *Window) panesSnapshot() []*Pane {
 	if w == nil {
 		return nil
 	}
-	out := make([]*Pane, 0, len(w.panes)+1)
+	out := make([]*Pane, 0, len(w.paneOrder)+1)
 	if w.defaultPane != nil {
 		out = append(out, w.defaultPane)
 	}
-	for _, pane := range w.panes {
-		out = append(out, pane)
-	}
+	out = append(out, w.paneOrder...)
+	slices.SortStableFunc(out, func(a, b *Pane) int {
+		return cmp.Compare(a.ZOrder(), b.ZOrder())
+	})
 	return out
 }
 
+// syncViewportLinks applies the viewport links of all panes and requests a
+// frame when one moved or resized a view, so a linked minimap redraws in
+// render-on-demand mode as soon as its source changes.
+func (w *Window) syncViewportLinks() {
+	for _, pane := range w.panesSnapshot() {
+		if view := pane.Viewport(); view != nil && view.SyncLink() {
+			w.invalidated.Store(true)
+		}
+	}
+}
+
+// updateViewStates refreshes Pane.ViewDirty and runs the OnViewChange
+// callbacks of the panes whose view changed.
+func (w *Window) updateViewStates() {
+	for _, pane := range w.panesSnapshot() {
+		if fn := pane.updateViewDirty(); fn != nil {
+			fn()
+		}
+	}
+}
+
+func (w *Window) consumeRenderRequest() bool {
+	invalidated := w.invalidated.Swap(false)
+	if !w.renderOnDemand.Load() {
+		return true
+	}
+	if w.viewVersions == nil {
+		w.viewVersions = make(map[*Pane]uint64)
+	}
+	viewChanged := false
+	panes := w.panesSnapshot()
+	for _, pane := range panes {
+		view := pane.Viewport()
+		if view == nil {
+			delete(w.viewVersions, pane)
+			continue
+		}
+		version := view.Version()
+		if prev, ok := w.viewVersions[pane]; !ok || prev != version {
+			w.viewVersions[pane] = version
+			viewChanged = true
+		}
+	}
+	if len(w.viewVersions) > len(panes) {
+		// Forget panes replaced by AddPane.
+		for pane := range w.viewVersions {
+			if !slices.Contains(panes, pane) {
+				delete(w.viewVersions, pane)
+			}
+		}
+	}
+	return invalidated || viewChanged
+}
+
+func (w *Window) applyCloseRequest(event Event) {
+	if _, ok := event.(CloseRequest); !ok {
+		return
+	}
+	if w.closeRequestHandler == nil || w.closeRequestHandler() {
+		w.Stop()
+	}
+}
+
+func isDrawableEvent(event Event) bool {
+	switch event.(type) {
+	case DrawableSetAdded, DrawableSetRemoved, DrawableSetTranslated, DrawableSetMoved:
+		return true
+	}
+	return false
+}
+
+// flushTouched flushes the changes the drawable applier collected since the
+// last frame. Changes made from OnFrame, ECS systems or other goroutines
+// request a frame like an event would.
+func (w *Window) flushTouched() {
+	if w.drawableApplier == nil {
+		return
+	}
+	if reporter, ok := w.drawableApplier.(FlushTouchedReporter); ok && reporter.Touched() {
+		w.invalidated.Store(true)
+	}
+	w.drawableApplier.FlushTouched()
+}
+
 func (w *Window) applyDrawableEvent(event Event) {
 	applier := w.drawableApplier
 	if applier == nil {
@@ -227,6 +873,11 @@ func (w *Window) applyDrawableEvent(event Event) {
 	case DrawableSetTranslated:
 		applier.ApplyTranslated(e.Items)
 		flush = true
+	case DrawableSetMoved:
+		if mover, ok := applier.(DrawableMovedApplier); ok {
+			mover.ApplyMoved(e.Items)
+			flush = true
+		}
 	}
 
 	// TODO: ta logika wola o pomste do nieba
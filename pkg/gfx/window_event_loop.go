@@ -4,18 +4,41 @@ import (
 	"context"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/kjkrol/gokx/internal/platform"
 )
 
 type EventDispatcher func(Event)
 type EventPooler func(int) (Event, bool)
 
+// EventOverflowPolicy decides what EmitEvent does when the queue is full.
+type EventOverflowPolicy int
+
+const (
+	// DefaultOverflow, the zero value, drops the emitted event like
+	// DropNewest, while the WASM backend waits for room in its input queue
+	// without limit.
+	DefaultOverflow EventOverflowPolicy = EventOverflowPolicy(platform.DefaultOverflow)
+	// DropNewest discards the emitted event.
+	DropNewest EventOverflowPolicy = EventOverflowPolicy(platform.DropNewest)
+	// DropOldest evicts the oldest queued event to make room.
+	DropOldest EventOverflowPolicy = EventOverflowPolicy(platform.DropOldest)
+	// Block waits up to the block timeout (100ms by default) before dropping.
+	// Do not use it when emitting from the event loop goroutine itself.
+	Block EventOverflowPolicy = EventOverflowPolicy(platform.Block)
+)
+
 type EventBus struct {
 	wg                   sync.WaitGroup
 	ctx                  context.Context
 	cancel               context.CancelFunc
 	platformEventsPooler EventPooler
 	events               chan Event
+	overflow             EventOverflowPolicy
+	blockTimeout         time.Duration
+	dropped              atomic.Uint64
 }
 
 func NewEventLoop(bufferSize int, platformEventsPool EventPooler) *EventBus {
@@ -34,10 +57,43 @@ func (el *EventBus) EmitEvent(event Event) {
 	if el == nil || event == nil {
 		return
 	}
-	select {
-	case el.events <- event:
-	default:
-		// drop if buffer full to avoid blocking producer
+	if platform.Enqueue(el.events, event, platform.OverflowPolicy(el.overflow), el.blockTimeout) {
+		el.dropped.Add(1)
+	}
+}
+
+// SetOverflowPolicy configures how EmitEvent handles a full queue; timeout
+// only applies to Block.
+func (el *EventBus) SetOverflowPolicy(policy EventOverflowPolicy, timeout time.Duration) {
+	el.overflow = policy
+	el.blockTimeout = timeout
+}
+
+// Dropped returns the number of events discarded because the queue was full.
+func (el *EventBus) Dropped() uint64 {
+	return el.dropped.Load()
+}
+
+// drainQueued hands every queued event to handle without waiting and puts the
+// events handle did not consume back in their original order. It must not
+// run concurrently with Run.
+func (el *EventBus) drainQueued(handle func(Event) bool) {
+	if el == nil {
+		return
+	}
+	var kept []Event
+	for {
+		select {
+		case event := <-el.events:
+			if !handle(event) {
+				kept = append(kept, event)
+			}
+		default:
+			for _, event := range kept {
+				el.EmitEvent(event)
+			}
+			return
+		}
 	}
 }
 
@@ -60,13 +116,35 @@ func (el *EventBus) Run(
 			timeoutMs := eventPoolTimeout(renderUpdater.nextRenderTime, adaptiveDuration)
 			el.consumeEvents(dispatcher, timeoutMs)
 
-			actualWorkDuration := ecsUpdater.run()
+			var actualWorkDuration time.Duration
+			if ecsUpdater != nil {
+				actualWorkDuration = ecsUpdater.run()
+			}
 			adaptiveDuration = updateAdaptiveDuration(adaptiveDuration, actualWorkDuration)
 			renderUpdater.run()
 		}
 	}
 }
 
+// runUpdates drives ecsUpdater on its own goroutine, ticking once per fixed
+// step until the bus is cancelled. Run waits for it before returning.
+func (el *EventBus) runUpdates(ecsUpdater *ecsUpdater) {
+	el.wg.Add(1)
+	go func() {
+		defer el.wg.Done()
+		ticker := time.NewTicker(ecsUpdater.fixedTimeStep)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-el.ctx.Done():
+				return
+			case <-ticker.C:
+				ecsUpdater.run()
+			}
+		}
+	}()
+}
+
 func updateAdaptiveDuration(adaptiveDuration, lastWorkDuration time.Duration) time.Duration {
 	return time.Duration(
 		0.95*float64(adaptiveDuration) + 0.05*float64(lastWorkDuration),
@@ -0,0 +1,150 @@
+package gfx
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestEventBus(policy EventOverflowPolicy, timeout time.Duration) *EventBus {
+	bus := NewEventLoop(2, func(int) (Event, bool) { return nil, false })
+	bus.SetOverflowPolicy(policy, timeout)
+	return bus
+}
+
+func drain(bus *EventBus) []Event {
+	var out []Event
+	for {
+		select {
+		case e := <-bus.events:
+			out = append(out, e)
+		default:
+			return out
+		}
+	}
+}
+
+func TestEventBus_DropNewest(t *testing.T) {
+	// The zero value keeps the window queue's original drop-newest behaviour.
+	for _, policy := range []EventOverflowPolicy{DefaultOverflow, DropNewest} {
+		bus := newTestEventBus(policy, 0)
+		for i := range 3 {
+			bus.EmitEvent(MotionNotify{X: i})
+		}
+		got := drain(bus)
+		if len(got) != 2 || got[1] != (MotionNotify{X: 1}) {
+			t.Fatalf("policy %d kept %v", policy, got)
+		}
+		if bus.Dropped() != 1 {
+			t.Errorf("policy %d: Dropped = %d, want 1", policy, bus.Dropped())
+		}
+	}
+}
+
+func TestEventBus_DropOldest(t *testing.T) {
+	bus := newTestEventBus(DropOldest, 0)
+	for i := range 3 {
+		bus.EmitEvent(MotionNotify{X: i})
+	}
+	got := drain(bus)
+	if len(got) != 2 || got[0] != (MotionNotify{X: 1}) || got[1] != (MotionNotify{X: 2}) {
+		t.Fatalf("DropOldest kept %v", got)
+	}
+	if bus.Dropped() != 1 {
+		t.Errorf("Dropped = %d, want 1", bus.Dropped())
+	}
+}
+
+func TestEventBus_BlockWaitsForRoom(t *testing.T) {
+	bus := newTestEventBus(Block, time.Second)
+	bus.EmitEvent(MotionNotify{X: 0})
+	bus.EmitEvent(MotionNotify{X: 1})
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-bus.events
+	}()
+	bus.EmitEvent(ButtonRelease{Button: 1})
+
+	got := drain(bus)
+	if len(got) != 2 || got[1] != (ButtonRelease{Button: 1}) {
+		t.Fatalf("Block should deliver the event once room frees up, got %v", got)
+	}
+	if bus.Dropped() != 0 {
+		t.Errorf("Dropped = %d, want 0", bus.Dropped())
+	}
+}
+
+func TestEventBus_BlockTimesOut(t *testing.T) {
+	bus := newTestEventBus(Block, time.Millisecond)
+	for i := range 3 {
+		bus.EmitEvent(MotionNotify{X: i})
+	}
+	if bus.Dropped() != 1 {
+		t.Errorf("Dropped = %d, want 1", bus.Dropped())
+	}
+}
+
+func TestECSUpdater_FixedStepsAndAlpha(t *testing.T) {
+	var steps []time.Duration
+	u := newECSUpdater(10*time.Millisecond, func(d time.Duration) {
+		steps = append(steps, d)
+	})
+	u.lastTime = time.Now().Add(-25 * time.Millisecond)
+
+	u.run()
+
+	if len(steps) != 2 || steps[0] != 10*time.Millisecond || steps[1] != 10*time.Millisecond {
+		t.Fatalf("expected two 10ms steps, got %v", steps)
+	}
+	if alpha := u.alpha(); alpha < 0.5 || alpha >= 1 {
+		t.Fatalf("expected alpha of about 0.5, got %v", alpha)
+	}
+}
+
+func TestECSUpdater_PausedSkipsUpdatesWithoutCatchUp(t *testing.T) {
+	var steps int
+	var paused atomic.Bool
+	u := newECSUpdater(10*time.Millisecond, func(time.Duration) {
+		steps++
+	})
+	u.paused = paused.Load
+
+	paused.Store(true)
+	u.lastTime = time.Now().Add(-50 * time.Millisecond)
+	u.run()
+	if steps != 0 {
+		t.Fatalf("paused updater ran %d steps", steps)
+	}
+
+	paused.Store(false)
+	u.run()
+	if steps != 0 {
+		t.Fatalf("resumed updater replayed %d paused steps", steps)
+	}
+}
+
+func TestEventBus_RunUpdatesStepsOffTheCallerUntilCancelled(t *testing.T) {
+	bus := newTestEventBus(DropNewest, 0)
+	var steps atomic.Int32
+	u := newECSUpdater(time.Millisecond, func(time.Duration) {
+		steps.Add(1)
+	})
+
+	bus.runUpdates(u)
+	deadline := time.Now().Add(time.Second)
+	for steps.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	bus.cancel()
+	bus.wg.Wait()
+
+	if steps.Load() == 0 {
+		t.Fatal("threaded updater never stepped")
+	}
+	stopped := steps.Load()
+	time.Sleep(5 * time.Millisecond)
+	if steps.Load() != stopped {
+		t.Fatal("threaded updater kept stepping after cancel")
+	}
+}
@@ -0,0 +1,681 @@
+package gfx
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+	"github.com/kjkrol/gokx/internal/platform"
+)
+
+func TestWindow_RenderOnDemandSkipsIdleFrames(t *testing.T) {
+	w := &Window{defaultPane: newPane(&PaneConfig{
+		Width:  64,
+		Height: 64,
+		World:  WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 0)}
+	if !w.consumeRenderRequest() {
+		t.Fatal("continuous mode must always render")
+	}
+
+	w.SetRenderOnDemand(true)
+	if !w.consumeRenderRequest() {
+		t.Fatal("enabling on-demand mode should render once")
+	}
+	if w.consumeRenderRequest() {
+		t.Fatal("idle frame rendered in on-demand mode")
+	}
+
+	w.Invalidate()
+	if !w.consumeRenderRequest() {
+		t.Fatal("Invalidate did not force a frame")
+	}
+	if w.consumeRenderRequest() {
+		t.Fatal("Invalidate should be consumed by a single frame")
+	}
+
+	w.defaultPane.Viewport().Move(1, 0)
+	if !w.consumeRenderRequest() {
+		t.Fatal("viewport change did not trigger a frame")
+	}
+	if w.consumeRenderRequest() {
+		t.Fatal("unchanged viewport triggered a frame")
+	}
+}
+
+func TestWindow_ViewportLinkSyncRequestsFrame(t *testing.T) {
+	w := &Window{defaultPane: newPane(&PaneConfig{
+		Width: 64, Height: 64,
+		World: WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 0)}
+	source := NewViewport(geom.NewVec[uint32](256, 256), geom.NewVec[uint32](64, 64), false)
+	w.defaultPane.Viewport().Link(source, nil)
+	w.SetRenderOnDemand(true)
+	w.syncViewportLinks()
+	w.consumeRenderRequest()
+
+	w.syncViewportLinks()
+	if w.invalidated.Load() {
+		t.Fatal("unchanged link source requested a frame")
+	}
+	source.Move(8, 0)
+	w.syncViewportLinks()
+	if !w.invalidated.Load() {
+		t.Fatal("link sync that moved the view did not invalidate the window")
+	}
+	if !w.consumeRenderRequest() {
+		t.Fatal("link sync did not trigger a frame")
+	}
+}
+
+type stubSnapshotter struct {
+	img *image.RGBA
+}
+
+func (s stubSnapshotter) Render(*Window)        {}
+func (s stubSnapshotter) Close()                {}
+func (s stubSnapshotter) Snapshot() *image.RGBA { return s.img }
+
+func TestWindow_PaneSnapshotCropsToPane(t *testing.T) {
+	frame := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	frame.SetRGBA(10, 12, color.RGBA{G: 255, A: 255})
+	w := &Window{renderer: stubSnapshotter{img: frame}}
+	pane := newPane(&PaneConfig{Width: 8, Height: 8, OffsetX: 8, OffsetY: 8}, 1)
+
+	img, ok := w.PaneSnapshot(pane)
+	if !ok {
+		t.Fatal("expected a pane snapshot")
+	}
+	if img.Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	if got := img.RGBAAt(2, 4); got != (color.RGBA{G: 255, A: 255}) {
+		t.Fatalf("pixel (2,4) = %v, want the frame pixel at (10,12)", got)
+	}
+
+	if _, ok := (&Window{}).Snapshot(); ok {
+		t.Fatal("expected no snapshot without a snapshotting renderer")
+	}
+}
+
+func TestWindow_PanesFollowZOrderThenInsertion(t *testing.T) {
+	w := &Window{
+		defaultPane: newPane(&PaneConfig{Width: 64, Height: 64}, 0),
+		panes:       make(map[string]*Pane),
+		nextPaneID:  1,
+	}
+	names := []string{"hud", "map", "menu", "tooltip"}
+	for _, name := range names {
+		w.AddPane(name, &PaneConfig{Width: 32, Height: 32})
+	}
+
+	paneIDs := func() []uint64 {
+		out := make([]uint64, 0)
+		for _, pane := range w.Panes() {
+			out = append(out, pane.ID)
+		}
+		return out
+	}
+	for range 10 {
+		if got := paneIDs(); !slices.Equal(got, []uint64{0, 1, 2, 3, 4}) {
+			t.Fatalf("panes = %v, want insertion order", got)
+		}
+	}
+
+	w.GetPaneByName("hud").SetZOrder(10)
+	w.GetDefaultPane().SetZOrder(1)
+	w.GetPaneByName("menu").SetZOrder(-1)
+	if got := paneIDs(); !slices.Equal(got, []uint64{3, 2, 4, 0, 1}) {
+		t.Fatalf("panes = %v, want z-order then insertion order", got)
+	}
+
+	w.AddPane("map", &PaneConfig{Width: 32, Height: 32})
+	if got := paneIDs(); !slices.Equal(got, []uint64{3, 4, 5, 0, 1}) {
+		t.Fatalf("panes = %v, want replaced pane appended", got)
+	}
+}
+
+func TestWindow_RenderOnDemandRedrawsOnZOrderChange(t *testing.T) {
+	w := &Window{defaultPane: newPane(&PaneConfig{
+		Width: 64, Height: 64,
+		World: WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 0)}
+	w.SetRenderOnDemand(true)
+	w.consumeRenderRequest()
+
+	w.GetDefaultPane().SetZOrder(0)
+	if w.consumeRenderRequest() {
+		t.Fatal("unchanged z-order triggered a frame")
+	}
+	w.GetDefaultPane().SetZOrder(2)
+	if !w.consumeRenderRequest() {
+		t.Fatal("z-order change did not trigger a frame")
+	}
+}
+
+func TestWindow_EachPaneReportsNames(t *testing.T) {
+	w := &Window{
+		defaultPane: newPane(&PaneConfig{Width: 64, Height: 64}, 0),
+		panes:       make(map[string]*Pane),
+		nextPaneID:  1,
+	}
+	w.AddPane("map", &PaneConfig{Width: 32, Height: 32})
+	w.AddPane("hud", &PaneConfig{Width: 32, Height: 32})
+	w.GetPaneByName("map").SetZOrder(5)
+
+	var got []string
+	w.EachPane(func(name string, p *Pane) {
+		got = append(got, fmt.Sprintf("%s:%d", name, p.IDValue()))
+	})
+	if want := []string{":0", "hud:2", "map:1"}; !slices.Equal(got, want) {
+		t.Fatalf("EachPane = %v, want %v", got, want)
+	}
+}
+
+type stubCapturer struct {
+	stubSnapshotter
+	got image.Rectangle
+}
+
+func (s *stubCapturer) CaptureRect(_ *Window, rect image.Rectangle) (*image.RGBA, error) {
+	s.got = rect
+	return image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy())), nil
+}
+
+func TestWindow_CaptureRectClampsToWindow(t *testing.T) {
+	capturer := &stubCapturer{}
+	w := &Window{renderer: capturer, width: 64, height: 48}
+
+	img, err := w.CaptureRect(image.Rect(-10, 40, 20, 80))
+	if err != nil {
+		t.Fatalf("CaptureRect: %v", err)
+	}
+	if capturer.got != image.Rect(0, 40, 20, 48) {
+		t.Fatalf("renderer got %v, want the rect clamped to the window", capturer.got)
+	}
+	if img.Bounds() != image.Rect(0, 0, 20, 8) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+
+	if _, err := w.CaptureRect(image.Rect(70, 0, 80, 10)); !errors.Is(err, ErrEmptyCapture) {
+		t.Fatalf("expected ErrEmptyCapture, got %v", err)
+	}
+	w.renderer = stubSnapshotter{}
+	if _, err := w.CaptureRect(image.Rect(0, 0, 10, 10)); !errors.Is(err, ErrCaptureUnsupported) {
+		t.Fatalf("expected ErrCaptureUnsupported, got %v", err)
+	}
+}
+
+type stubTextureRenderer struct {
+	stubSnapshotter
+	calls int
+}
+
+func (s *stubTextureRenderer) RenderToTexture(_ *Window, width, height int) (Texture, error) {
+	s.calls++
+	return Texture{Handle: 7, Width: width, Height: height}, nil
+}
+
+func TestWindow_RenderToTexture(t *testing.T) {
+	renderer := &stubTextureRenderer{}
+	w := &Window{renderer: renderer, width: 64, height: 48}
+
+	tex, err := w.RenderToTexture(32, 24)
+	if err != nil {
+		t.Fatalf("RenderToTexture: %v", err)
+	}
+	if tex.Handle != 7 || tex.Width != 32 || tex.Height != 24 {
+		t.Fatalf("texture = %+v, want handle 7 at 32x24", tex)
+	}
+	if _, err := w.RenderToTexture(0, 24); !errors.Is(err, ErrTextureSize) {
+		t.Fatalf("expected ErrTextureSize, got %v", err)
+	}
+	if renderer.calls != 1 {
+		t.Fatalf("renderer called %d times, want 1", renderer.calls)
+	}
+	w.renderer = stubSnapshotter{}
+	if _, err := w.RenderToTexture(32, 24); !errors.Is(err, ErrTextureUnsupported) {
+		t.Fatalf("expected ErrTextureUnsupported, got %v", err)
+	}
+}
+
+type stubTextureLoader struct {
+	countingRenderer
+	loaded []image.Rectangle
+}
+
+func (r *stubTextureLoader) LoadTexture(_ *Window, img image.Image) (TextureID, error) {
+	r.loaded = append(r.loaded, img.Bounds())
+	return TextureID(len(r.loaded)), nil
+}
+
+func TestWindow_LoadTextureFeedsSprites(t *testing.T) {
+	renderer := &stubTextureLoader{}
+	w := &Window{renderer: renderer}
+
+	id, err := w.LoadTexture(image.NewNRGBA(image.Rect(0, 0, 8, 4)))
+	if err != nil || id != 1 {
+		t.Fatalf("LoadTexture = %d, %v; want 1", id, err)
+	}
+	if _, err := w.LoadTexture(image.NewNRGBA(image.Rect(0, 0, 0, 4))); !errors.Is(err, ErrTextureSize) {
+		t.Fatalf("empty image: expected ErrTextureSize, got %v", err)
+	}
+	if len(renderer.loaded) != 1 {
+		t.Fatalf("renderer loaded %v, want one image", renderer.loaded)
+	}
+
+	drawable := Sprite{TextureID: id}.Drawable()
+	uv, ok := drawable.EffectiveStyle().TextureRect()
+	if full := geom.NewAABB(geom.NewVec[float32](0, 0), geom.NewVec[float32](1, 1)); !ok || uv != full {
+		t.Fatalf("sprite texture rect = %v (ok=%v), want the whole texture", uv, ok)
+	}
+
+	w.renderer = &countingRenderer{}
+	if _, err := w.LoadTexture(image.NewNRGBA(image.Rect(0, 0, 8, 4))); !errors.Is(err, ErrSpritesUnsupported) {
+		t.Fatalf("expected ErrSpritesUnsupported, got %v", err)
+	}
+}
+
+type stubCapsReporter struct {
+	countingRenderer
+	calls int
+}
+
+func (r *stubCapsReporter) Capabilities(*Window) Caps {
+	r.calls++
+	return Caps{Kind: RendererGL, MaxTextureSize: 4096, Instancing: true}
+}
+
+func TestWindow_CapabilitiesFallsBackToRendererKind(t *testing.T) {
+	reporter := &stubCapsReporter{}
+	w := &Window{renderer: reporter}
+	if got := w.Capabilities(); got.Kind != RendererGL || got.MaxTextureSize != 4096 || reporter.calls != 1 {
+		t.Fatalf("caps = %+v after %d calls, want the reporter's", got, reporter.calls)
+	}
+
+	w.renderer = &countingRenderer{}
+	if got := w.Capabilities(); got != (Caps{Kind: RendererSoftware}) {
+		t.Fatalf("software renderer caps = %+v, want only its kind", got)
+	}
+	w.renderer = stubSnapshotter{}
+	if got := w.Capabilities(); got.Kind != RendererUnknown || got.Kind.String() != "unknown" {
+		t.Fatalf("caps = %+v, want an unknown renderer", got)
+	}
+}
+
+type countingRenderer struct {
+	renders int
+}
+
+func (r *countingRenderer) Render(*Window) { r.renders++ }
+func (r *countingRenderer) Close()         {}
+func (r *countingRenderer) Software() bool { return true }
+
+type countingApplier struct {
+	added   int
+	flushes int
+}
+
+func (a *countingApplier) ApplyAdded(items []DrawableAdd)      { a.added += len(items) }
+func (a *countingApplier) ApplyRemoved([]DrawableRemove)       {}
+func (a *countingApplier) ApplyTranslated([]DrawableTranslate) {}
+func (a *countingApplier) FlushTouched()                       { a.flushes++ }
+
+// touchingApplier reports the drawables added to the layers it observes as
+// pending work, like gridbridge.Bridge does.
+type touchingApplier struct {
+	countingApplier
+	touched bool
+}
+
+func (a *touchingApplier) OnDrawableAdded(*Layer, *Drawable, uint64)   { a.touched = true }
+func (a *touchingApplier) OnDrawableRemoved(*Layer, *Drawable, uint64) { a.touched = true }
+func (a *touchingApplier) OnLayerDirtyRect(*Layer, spatial.AABB)       { a.touched = true }
+
+func (a *touchingApplier) Touched() bool { return a.touched }
+
+func (a *touchingApplier) FlushTouched() {
+	a.flushes++
+	a.touched = false
+}
+
+func TestWindow_RenderOnDemandDrawsChangesMadeWithoutEvents(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width: 64, Height: 64,
+		World: WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 0)
+	applier := &touchingApplier{}
+	pane.SetLayerObserver(applier)
+	w := &Window{defaultPane: pane, drawableApplier: applier}
+	w.SetRenderOnDemand(true)
+	w.flushTouched()
+	w.consumeRenderRequest()
+
+	w.flushTouched()
+	if w.consumeRenderRequest() {
+		t.Fatal("idle frame rendered in on-demand mode")
+	}
+
+	// An OnFrame callback or ECS system adds a drawable: no event is
+	// dispatched, yet the next tick must draw it.
+	pane.GetLayer(0).AddDrawable(&Drawable{AABB: plane.NewEuclidean2D[uint32](256, 256).WrapAABB(
+		geom.NewAABBAt(geom.NewVec[uint32](8, 8), 4, 4),
+	)})
+	w.flushTouched()
+	if !w.consumeRenderRequest() {
+		t.Fatal("drawable added outside the event loop did not trigger a frame")
+	}
+	w.flushTouched()
+	if w.consumeRenderRequest() {
+		t.Fatal("flushed changes should be consumed by a single frame")
+	}
+}
+
+func TestWindow_RenderOnceAppliesQueuedDrawableEvents(t *testing.T) {
+	renderer := &countingRenderer{}
+	applier := &countingApplier{}
+	w := &Window{
+		renderer:        renderer,
+		drawableApplier: applier,
+		defaultPane:     newPane(&PaneConfig{Width: 64, Height: 64}, 0),
+		eventLoop:       NewEventLoop(4, func(int) (Event, bool) { return nil, false }),
+	}
+	w.SetRenderOnDemand(true)
+	w.EmitEvent(KeyPress{Code: 1})
+	w.EmitEvent(DrawableSetAdded{Items: []DrawableAdd{{}, {}}})
+
+	w.RenderOnce()
+	if renderer.renders != 1 {
+		t.Fatalf("renders = %d, want 1", renderer.renders)
+	}
+	if applier.added != 2 || applier.flushes == 0 {
+		t.Fatalf("applier added %d (flushes %d), want 2 added and a flush", applier.added, applier.flushes)
+	}
+	got := drain(w.eventLoop)
+	if len(got) != 1 || got[0] != (KeyPress{Code: 1}) {
+		t.Fatalf("queued events after RenderOnce = %v, want the key press kept", got)
+	}
+
+	w.RenderOnce()
+	if renderer.renders != 2 {
+		t.Errorf("RenderOnce should draw even without changes in on-demand mode")
+	}
+}
+
+func TestWindow_ThreadedUpdateHandsOffDrawableEventsPerStep(t *testing.T) {
+	applier := &countingApplier{}
+	w := &Window{
+		drawableApplier: applier,
+		eventLoop:       NewEventLoop(4, func(int) (Event, bool) { return nil, false }),
+	}
+	w.threadedActive.Store(true)
+
+	w.EmitEvent(DrawableSetAdded{Items: []DrawableAdd{{}}})
+	w.EmitEvent(KeyPress{Code: 1})
+	if got := drain(w.eventLoop); len(got) != 1 || got[0] != (KeyPress{Code: 1}) {
+		t.Fatalf("queued events = %v, want only the key press", got)
+	}
+
+	w.applyHandoff()
+	if applier.added != 0 {
+		t.Fatalf("events of an unfinished step were applied")
+	}
+	w.handoff.publish()
+	w.applyHandoff()
+	if applier.added != 1 || !w.invalidated.Load() {
+		t.Fatalf("added %d (invalidated %v), want the published step applied", applier.added, w.invalidated.Load())
+	}
+}
+
+func TestWindow_OnFrameReportsDeltaSincePreviousFrame(t *testing.T) {
+	w := &Window{}
+	w.runOnFrame() // no callback set
+
+	var deltas []time.Duration
+	w.OnFrame(func(dt time.Duration) { deltas = append(deltas, dt) })
+	w.runOnFrame()
+	time.Sleep(2 * time.Millisecond)
+	w.runOnFrame()
+
+	if len(deltas) != 2 {
+		t.Fatalf("OnFrame called %d times, want 2", len(deltas))
+	}
+	if deltas[0] != 0 {
+		t.Errorf("first delta = %v, want 0", deltas[0])
+	}
+	if deltas[1] < 2*time.Millisecond {
+		t.Errorf("second delta = %v, want at least the 2ms slept", deltas[1])
+	}
+}
+
+type recordingApplier struct {
+	countingApplier
+	moves []DrawableMove
+}
+
+func (a *recordingApplier) ApplyMoved(items []DrawableMove) { a.moves = append(a.moves, items...) }
+
+func TestWindow_CursorDrawableFollowsPointer(t *testing.T) {
+	pane := newPane(&PaneConfig{Width: 64, Height: 64, World: WorldConfig{
+		WorldResolution: spatial.Size256x256,
+		WorldWrap:       true,
+	}}, 0)
+	applier := &recordingApplier{}
+	w := &Window{defaultPane: pane, drawableApplier: applier}
+
+	cursor := &Drawable{AABB: plane.NewToroidal2D[uint32](256, 256).WrapAABB(
+		geom.NewAABBAt(geom.NewVec[uint32](0, 0), 4, 4),
+	)}
+	pane.GetLayer(0).AddDrawable(cursor)
+	w.trackPointer(MotionNotify{X: 10, Y: 20})
+	w.moveCursors()
+	if len(applier.moves) != 0 {
+		t.Fatalf("moved %v without a cursor drawable", applier.moves)
+	}
+
+	pane.SetCursorDrawable(cursor)
+	w.moveCursors()
+	id, _ := pane.GetLayer(0).DrawableID(cursor)
+	want := DrawableMove{PaneID: pane.ID, LayerID: pane.GetLayer(0).ID(), DrawableID: id, Delta: geom.NewVec(8, 18)}
+	if len(applier.moves) != 1 || applier.moves[0] != want {
+		t.Fatalf("moves = %v, want [%v]", applier.moves, want)
+	}
+
+	applier.moves = nil
+	w.trackPointer(MotionNotify{X: 100, Y: 20})
+	w.moveCursors()
+	w.trackPointer(LeaveNotify{X: 10, Y: 20})
+	w.moveCursors()
+	if len(applier.moves) != 0 {
+		t.Fatalf("moved %v with the pointer outside the pane", applier.moves)
+	}
+}
+
+func TestWindow_MovedEventsNeedDrawableMovedApplier(t *testing.T) {
+	applier := &countingApplier{}
+	w := &Window{drawableApplier: applier}
+	w.applyDrawableEvent(DrawableSetMoved{Items: []DrawableMove{{DrawableID: 1}}})
+	if applier.flushes != 0 {
+		t.Fatalf("flushed %d times for moves the applier cannot apply", applier.flushes)
+	}
+
+	recorder := &recordingApplier{}
+	w.drawableApplier = recorder
+	w.applyDrawableEvent(DrawableSetMoved{Items: []DrawableMove{{DrawableID: 1}}})
+	if len(recorder.moves) != 1 || recorder.flushes != 1 {
+		t.Fatalf("moves = %v, flushes = %d; want one move and one flush", recorder.moves, recorder.flushes)
+	}
+}
+
+type failingInitRenderer struct {
+	countingRenderer
+	err error
+}
+
+func (r *failingInitRenderer) Init(*Window) error { return r.err }
+
+func TestWindow_InitRendererReportsInitError(t *testing.T) {
+	initErr := errors.New("shader compile failed")
+	w := &Window{renderer: &failingInitRenderer{err: initErr}}
+	if err := w.initRenderer(); !errors.Is(err, initErr) {
+		t.Fatalf("expected init error, got %v", err)
+	}
+
+	w = &Window{renderer: &countingRenderer{}}
+	if err := w.initRenderer(); err != nil {
+		t.Fatalf("renderer without Init: got %v", err)
+	}
+}
+
+// stubPlatform is a platform window whose GL context creation can fail.
+type stubPlatform struct {
+	noGPU      bool
+	contextErr error
+	frames     int
+}
+
+func (p *stubPlatform) Show()                               {}
+func (p *stubPlatform) Close()                              {}
+func (p *stubPlatform) NextEventTimeout(int) platform.Event { return platform.TimeoutEvent{} }
+func (p *stubPlatform) BeginFrame()                         { panic("BeginFrame without a GL context") }
+func (p *stubPlatform) EndFrame()                           { p.frames++ }
+func (p *stubPlatform) GLContext() any                      { return nil }
+func (p *stubPlatform) GPUAvailable() bool                  { return !p.noGPU }
+func (p *stubPlatform) CreateGLContext() error              { return p.contextErr }
+
+type gpuInitRenderer struct {
+	inits int
+}
+
+func (r *gpuInitRenderer) Render(*Window)     {}
+func (r *gpuInitRenderer) Close()             {}
+func (r *gpuInitRenderer) Init(*Window) error { r.inits++; return nil }
+
+func TestWindow_InitRendererReportsMissingGPU(t *testing.T) {
+	renderer := &gpuInitRenderer{}
+	w := &Window{renderer: renderer, platformWinWrapper: &stubPlatform{noGPU: true}}
+	if err := w.initRenderer(); !errors.Is(err, ErrNoGPU) {
+		t.Fatalf("without a GPU: got %v, want ErrNoGPU", err)
+	}
+
+	contextErr := errors.New("SDL_GL_CreateContext error")
+	w.platformWinWrapper = &stubPlatform{contextErr: contextErr}
+	err := w.initRenderer()
+	if !errors.Is(err, ErrNoGPU) || !strings.Contains(err.Error(), contextErr.Error()) {
+		t.Fatalf("failed context creation: got %v, want ErrNoGPU wrapping it", err)
+	}
+	if renderer.inits != 0 {
+		t.Fatalf("Init ran %d times without a GL context", renderer.inits)
+	}
+
+	stub := &stubPlatform{}
+	w.platformWinWrapper = stub
+	if err := w.initRenderer(); err != nil {
+		t.Fatalf("with a GL context: got %v", err)
+	}
+	if renderer.inits != 1 || stub.frames != 0 {
+		t.Fatalf("inits=%d frames=%d, want 1 0", renderer.inits, stub.frames)
+	}
+}
+
+func TestWindow_RenderOnDemandForgetsReplacedAndClosedPanes(t *testing.T) {
+	w := &Window{
+		defaultPane: newPane(&PaneConfig{Width: 64, Height: 64}, 0),
+		panes:       make(map[string]*Pane),
+	}
+	w.SetRenderOnDemand(true)
+	first := w.AddPane("minimap", &PaneConfig{Width: 16, Height: 16})
+	w.consumeRenderRequest()
+	w.AddPane("minimap", &PaneConfig{Width: 16, Height: 16})
+	w.consumeRenderRequest()
+	if _, ok := w.viewVersions[first]; ok || len(w.viewVersions) != 2 {
+		t.Fatalf("view versions kept %d panes after AddPane replaced one, want 2", len(w.viewVersions))
+	}
+
+	w.GetPaneByName("minimap").Close()
+	w.consumeRenderRequest()
+	if len(w.viewVersions) != 1 {
+		t.Fatalf("view versions kept %d panes after Close, want 1", len(w.viewVersions))
+	}
+}
+
+func TestNewWindowE_RejectsUnusableGLConfig(t *testing.T) {
+	factory := func(*Window) Renderer { return &countingRenderer{} }
+	_, err := NewWindowE(WindowConfig{Width: 32, Height: 32, GL: GLContextConfig{Major: 2, Minor: 1}}, factory)
+	if err == nil || errors.Is(err, ErrNoPlatformWindow) {
+		t.Fatalf("OpenGL 2.1: got %v, want a validation error before opening a window", err)
+	}
+}
+
+func TestWindow_ViewDirtyFollowsViewportChanges(t *testing.T) {
+	pane := newPane(&PaneConfig{
+		Width:  64,
+		Height: 64,
+		World:  WorldConfig{WorldResolution: spatial.Size256x256},
+	}, 0)
+	w := &Window{defaultPane: pane}
+	calls := 0
+	pane.OnViewChange(func() { calls++ })
+
+	w.updateViewStates()
+	if !pane.ViewDirty() || calls != 1 {
+		t.Fatalf("first frame: dirty=%v calls=%d, want true 1", pane.ViewDirty(), calls)
+	}
+	w.updateViewStates()
+	if pane.ViewDirty() || calls != 1 {
+		t.Fatalf("idle frame: dirty=%v calls=%d, want false 1", pane.ViewDirty(), calls)
+	}
+	pane.Viewport().Move(8, 0)
+	w.updateViewStates()
+	if !pane.ViewDirty() || calls != 2 {
+		t.Fatalf("after move: dirty=%v calls=%d, want true 2", pane.ViewDirty(), calls)
+	}
+}
+
+func TestWindow_EventHandlersRunByPriorityAndStopPropagation(t *testing.T) {
+	w := &Window{}
+	var calls []string
+	handler := func(name string, consume func(Event) bool) EventHandler {
+		return func(event Event) bool {
+			calls = append(calls, name)
+			return consume(event)
+		}
+	}
+	never := func(Event) bool { return false }
+	w.AddEventHandler(0, handler("game", never))
+	w.AddEventHandler(10, handler("ui", func(event Event) bool {
+		_, ok := event.(KeyPress)
+		return ok
+	}))
+	removeDebug := w.AddEventHandler(10, handler("debug", never))
+	dispatcher := func(Event) { calls = append(calls, "dispatcher") }
+
+	w.dispatchEvent(Expose{}, dispatcher)
+	if want := []string{"ui", "debug", "game", "dispatcher"}; !slices.Equal(calls, want) {
+		t.Fatalf("unconsumed event visited %v, want %v", calls, want)
+	}
+
+	calls = nil
+	w.dispatchEvent(KeyPress{Key: KeyEscape}, dispatcher)
+	if want := []string{"ui"}; !slices.Equal(calls, want) {
+		t.Fatalf("consumed event visited %v, want %v", calls, want)
+	}
+	if !w.invalidated.Load() {
+		t.Error("a consumed event should still request a redraw")
+	}
+
+	removeDebug()
+	calls = nil
+	w.dispatchEvent(Expose{}, nil)
+	if want := []string{"ui", "game"}; !slices.Equal(calls, want) {
+		t.Fatalf("after removal visited %v, want %v", calls, want)
+	}
+}
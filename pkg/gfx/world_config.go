@@ -1,10 +1,30 @@
 package gfx
 
-import "github.com/kjkrol/gokg/pkg/spatial"
+import (
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
 
 type WorldConfig struct {
 	WorldResolution spatial.Resolution
-	WorldWrap       bool
+	// WorldWrap wraps the world on both axes, making it a torus.
+	WorldWrap bool
+	// WrapX and WrapY wrap the world on one axis only, e.g. a side-scroller
+	// looping horizontally while clamping vertically. Build the matching
+	// space with grid.NewWrapSpace. WorldWrap implies both.
+	WrapX, WrapY bool
+	// InitialOrigin is the viewport origin the pane starts at; it is wrapped
+	// or clamped to the world like any later move.
+	InitialOrigin geom.Vec[uint32]
+	// InitialZoom scales the starting view: 2 shows half as many world units,
+	// each twice as large. Zero means 1. It divides the pane's logical size
+	// (see PaneConfig.LogicalWidth), so LogicalScale includes it.
+	InitialZoom float32
+}
+
+// WrapAxes reports on which axes the world wraps.
+func (c WorldConfig) WrapAxes() (x, y bool) {
+	return c.WorldWrap || c.WrapX, c.WorldWrap || c.WrapY
 }
 
 func normalizeWorldConfig(conf WorldConfig, viewWidth, viewHeight int) WorldConfig {
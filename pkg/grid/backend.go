@@ -0,0 +1,412 @@
+package grid
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+	"github.com/kjkrol/gokq/pkg/qtree"
+)
+
+// Backend selects the spatial index behind a BucketGridManager.
+type Backend uint8
+
+const (
+	// BackendUniformGrid indexes entries in the uniform bucket grid (default).
+	BackendUniformGrid Backend = iota
+	// BackendQuadTree indexes entries in a quadtree, which culls range
+	// queries better when entries cluster unevenly.
+	BackendQuadTree
+)
+
+// SpatialBackend is the spatial index a BucketGridManager drives. Entries are
+// queued with their unwrapped union AABB and stored as up to four wrap
+// fragments with entry IDs id<<2|fragment, clamped to the world. Flush
+// applies the queue, reporting every touched fragment to onDirty. Bucket
+// planning stays in the manager: buckets are render tiles of
+// GridLevelConfig.BucketResolution whatever the backend, and
+// ConsumeBucketDeltas reports entry changes per such tile, so the bridge and
+// renderers consume the same plans and deltas for every backend.
+type SpatialBackend interface {
+	QueueInsert(id uint64, aabb spatial.AABB)
+	QueueRemove(id uint64)
+	QueueUpdate(id uint64, aabb spatial.AABB, markDirty bool)
+	Flush(onDirty func(spatial.AABB))
+	EntryAABB(entryID uint64) (spatial.AABB, bool)
+	QueryRange(aabb spatial.AABB, collector func(uint64)) int
+	VisitWrappedAABB(aabb spatial.AABB, visit func(spatial.AABB))
+	ConsumeBucketDeltas() []spatial.BucketDelta
+}
+
+var (
+	_ SpatialBackend = (*spatial.GridIndexManager)(nil)
+	_ SpatialBackend = (*quadTreeIndex)(nil)
+)
+
+// quadTreeIndex is the BackendQuadTree SpatialBackend. It mirrors the
+// fragment and bucket delta semantics of spatial.GridIndexManager.
+type quadTreeIndex struct {
+	space     plane.Space2D[uint32]
+	wrap      bool
+	tree      *qtree.QuadTree[uint32]
+	maxCoord  uint32
+	bucketRes spatial.Resolution
+	gridSide  uint32
+
+	opsMu sync.Mutex
+	ops   []indexOp
+
+	masks  map[uint64]uint8
+	items  map[uint64]*quadTreeItem
+	deltas map[spatial.AABB]*tileDelta
+}
+
+type indexOp struct {
+	id        uint64
+	aabb      spatial.AABB
+	remove    bool
+	update    bool
+	markDirty bool
+}
+
+// quadTreeItem is one stored fragment. The tree removes items by identity,
+// so each fragment keeps its pointer for its lifetime.
+type quadTreeItem struct {
+	entryID uint64
+	aabb    spatial.AABB
+}
+
+func (i *quadTreeItem) Bound() geom.AABB[uint32] { return i.aabb }
+
+func (i *quadTreeItem) SameID(other qtree.Item[uint32]) bool {
+	o, ok := other.(*quadTreeItem)
+	return ok && o.entryID == i.entryID
+}
+
+// queryProbe is the target of a range query; it matches no stored item.
+type queryProbe struct{ aabb spatial.AABB }
+
+func (p queryProbe) Bound() geom.AABB[uint32]       { return p.aabb }
+func (p queryProbe) SameID(qtree.Item[uint32]) bool { return false }
+
+// validateTiles applies the resolution checks of the uniform grid, whose
+// buckets the quadtree backend keeps as render tiles.
+func validateTiles(cfg GridLevelConfig) error {
+	if cfg.Resoltuion == 0 {
+		return fmt.Errorf("world resolution is required")
+	}
+	if cfg.BucketResolution == 0 {
+		return fmt.Errorf("bucket resolution is required")
+	}
+	if cfg.Resoltuion < cfg.BucketResolution {
+		return fmt.Errorf("bucket resolution must be <= world resolution")
+	}
+	return nil
+}
+
+func newQuadTreeIndex(space plane.Space2D[uint32], cfg GridLevelConfig) *quadTreeIndex {
+	dirty := newDirtyState(cfg)
+	return &quadTreeIndex{
+		space:     space,
+		wrap:      space.Name() == "Toroidal2D",
+		tree:      qtree.NewQuadTree(space),
+		maxCoord:  cfg.Resoltuion.MaxCoord(),
+		bucketRes: dirty.bucketResolution,
+		gridSide:  dirty.gridSide,
+		masks:     make(map[uint64]uint8),
+		items:     make(map[uint64]*quadTreeItem),
+		deltas:    make(map[spatial.AABB]*tileDelta),
+	}
+}
+
+func (q *quadTreeIndex) QueueInsert(id uint64, aabb spatial.AABB) {
+	q.queue(indexOp{id: id, aabb: aabb, markDirty: true})
+}
+
+func (q *quadTreeIndex) QueueRemove(id uint64) {
+	q.queue(indexOp{id: id, remove: true})
+}
+
+func (q *quadTreeIndex) QueueUpdate(id uint64, aabb spatial.AABB, markDirty bool) {
+	q.queue(indexOp{id: id, aabb: aabb, update: true, markDirty: markDirty})
+}
+
+func (q *quadTreeIndex) queue(op indexOp) {
+	q.opsMu.Lock()
+	q.ops = append(q.ops, op)
+	q.opsMu.Unlock()
+}
+
+func (q *quadTreeIndex) Flush(onDirty func(spatial.AABB)) {
+	q.opsMu.Lock()
+	ops := q.ops
+	q.ops = nil
+	q.opsMu.Unlock()
+	for _, op := range ops {
+		switch {
+		case op.remove:
+			q.applyRemove(op.id, onDirty)
+		case op.update:
+			q.applyUpdate(op.id, op.aabb, op.markDirty, onDirty)
+		default:
+			q.applyInsert(op.id, op.aabb, op.markDirty, onDirty)
+		}
+	}
+}
+
+func (q *quadTreeIndex) applyInsert(id uint64, shape spatial.AABB, markDirty bool, onDirty func(spatial.AABB)) {
+	mask, frags := q.fragments(shape)
+	if mask == 0 {
+		return
+	}
+	for idx, frag := range frags {
+		if mask&(1<<idx) == 0 {
+			continue
+		}
+		item := &quadTreeItem{entryID: id<<2 | uint64(idx), aabb: frag}
+		q.tree.Add(item)
+		q.items[item.entryID] = item
+		q.forEachTile(frag, func(tile spatial.AABB) { q.delta(tile).add(item.entryID) })
+		if markDirty && onDirty != nil {
+			onDirty(frag)
+		}
+	}
+	q.masks[id] = mask
+}
+
+func (q *quadTreeIndex) applyRemove(id uint64, onDirty func(spatial.AABB)) {
+	mask, ok := q.masks[id]
+	if !ok {
+		return
+	}
+	for idx := range 4 {
+		if mask&(1<<idx) == 0 {
+			continue
+		}
+		item := q.items[id<<2|uint64(idx)]
+		if item == nil {
+			continue
+		}
+		q.tree.Remove(item)
+		delete(q.items, item.entryID)
+		q.forEachTile(item.aabb, func(tile spatial.AABB) { q.delta(tile).remove(item.entryID) })
+		if onDirty != nil {
+			onDirty(item.aabb)
+		}
+	}
+	delete(q.masks, id)
+}
+
+func (q *quadTreeIndex) applyUpdate(id uint64, shape spatial.AABB, markDirty bool, onDirty func(spatial.AABB)) {
+	oldMask, ok := q.masks[id]
+	if !ok {
+		q.applyInsert(id, shape, markDirty, onDirty)
+		return
+	}
+	mask, frags := q.fragments(shape)
+	if mask == 0 {
+		q.applyRemove(id, onDirty)
+		return
+	}
+	if mask != oldMask {
+		q.applyRemove(id, onDirty)
+		q.applyInsert(id, shape, markDirty, onDirty)
+		return
+	}
+	for idx, frag := range frags {
+		if mask&(1<<idx) == 0 {
+			continue
+		}
+		item := q.items[id<<2|uint64(idx)]
+		if item == nil {
+			continue
+		}
+		old := item.aabb
+		q.tree.Remove(item)
+		item.aabb = frag
+		q.tree.Add(item)
+		q.recordMove(item.entryID, old, frag)
+		if markDirty && onDirty != nil {
+			onDirty(old)
+			onDirty(frag)
+		}
+	}
+}
+
+// recordMove reports entryID as updated in the tiles it stays in, added to
+// the tiles it enters and removed from the tiles it leaves.
+func (q *quadTreeIndex) recordMove(entryID uint64, old, new spatial.AABB) {
+	before := make(map[spatial.AABB]struct{})
+	q.forEachTile(old, func(tile spatial.AABB) { before[tile] = struct{}{} })
+	q.forEachTile(new, func(tile spatial.AABB) {
+		if _, ok := before[tile]; ok {
+			delete(before, tile)
+			q.delta(tile).update(entryID)
+			return
+		}
+		q.delta(tile).add(entryID)
+	})
+	for tile := range before {
+		q.delta(tile).remove(entryID)
+	}
+}
+
+// fragments splits shape like spatial.GridIndexManager: the base fragment at
+// index 0 and the wrapped pieces at their FragPosition + 1.
+func (q *quadTreeIndex) fragments(shape spatial.AABB) (uint8, [4]spatial.AABB) {
+	var frags [4]spatial.AABB
+	var mask uint8
+	add := func(idx int, aabb spatial.AABB) {
+		if clamped, ok := q.clamp(aabb); ok {
+			frags[idx] = clamped
+			mask |= 1 << idx
+		}
+	}
+	if !q.wrap {
+		add(0, shape)
+		return mask, frags
+	}
+	wrapped := q.space.WrapAABB(shape)
+	add(0, wrapped.AABB)
+	wrapped.VisitFragments(func(pos plane.FragPosition, frag geom.AABB[uint32]) bool {
+		add(int(pos)+1, frag)
+		return true
+	})
+	return mask, frags
+}
+
+func (q *quadTreeIndex) clamp(aabb spatial.AABB) (spatial.AABB, bool) {
+	minX, minY := min(aabb.TopLeft.X, q.maxCoord), min(aabb.TopLeft.Y, q.maxCoord)
+	maxX, maxY := min(aabb.BottomRight.X, q.maxCoord), min(aabb.BottomRight.Y, q.maxCoord)
+	if maxX < minX || maxY < minY {
+		return spatial.AABB{}, false
+	}
+	return geom.NewAABB(geom.NewVec(minX, minY), geom.NewVec(maxX, maxY)), true
+}
+
+// forEachTile calls fn with every bucket tile aabb touches, bottom-right
+// corner included, as the uniform grid assigns buckets.
+func (q *quadTreeIndex) forEachTile(aabb spatial.AABB, fn func(spatial.AABB)) {
+	last := q.gridSide - 1
+	size := q.bucketRes.Side()
+	x0, y0 := min(aabb.TopLeft.X>>q.bucketRes, last), min(aabb.TopLeft.Y>>q.bucketRes, last)
+	x1, y1 := min(aabb.BottomRight.X>>q.bucketRes, last), min(aabb.BottomRight.Y>>q.bucketRes, last)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			fn(geom.NewAABBAt(geom.NewVec(x*size, y*size), size, size))
+		}
+	}
+}
+
+func (q *quadTreeIndex) delta(tile spatial.AABB) *tileDelta {
+	delta := q.deltas[tile]
+	if delta == nil {
+		delta = &tileDelta{}
+		q.deltas[tile] = delta
+	}
+	return delta
+}
+
+func (q *quadTreeIndex) EntryAABB(entryID uint64) (spatial.AABB, bool) {
+	item := q.items[entryID]
+	if item == nil {
+		return spatial.AABB{}, false
+	}
+	return item.aabb, true
+}
+
+// QueryRange reports the fragment entry IDs touching aabb, edges included,
+// once each.
+func (q *quadTreeIndex) QueryRange(aabb spatial.AABB, collector func(uint64)) int {
+	seen := make(map[uint64]struct{})
+	q.VisitWrappedAABB(aabb, func(rect spatial.AABB) {
+		for _, found := range q.tree.FindNeighbors(queryProbe{aabb: rect}, 0) {
+			item := found.(*quadTreeItem)
+			if _, ok := seen[item.entryID]; ok || !rect.Intersects(item.aabb) {
+				continue
+			}
+			seen[item.entryID] = struct{}{}
+			collector(item.entryID)
+		}
+	})
+	return len(seen)
+}
+
+func (q *quadTreeIndex) VisitWrappedAABB(aabb spatial.AABB, visit func(spatial.AABB)) {
+	mask, frags := q.fragments(aabb)
+	for idx, frag := range frags {
+		if mask&(1<<idx) != 0 {
+			visit(frag)
+		}
+	}
+}
+
+func (q *quadTreeIndex) ConsumeBucketDeltas() []spatial.BucketDelta {
+	if len(q.deltas) == 0 {
+		return nil
+	}
+	out := make([]spatial.BucketDelta, 0, len(q.deltas))
+	for tile, delta := range q.deltas {
+		out = append(out, spatial.BucketDelta{
+			Bucket:  tile,
+			Added:   setKeys(delta.added),
+			Removed: setKeys(delta.removed),
+			Updated: setKeys(delta.updated),
+		})
+	}
+	clear(q.deltas)
+	return out
+}
+
+// tileDelta accumulates one tile's changes between ConsumeBucketDeltas calls.
+// An entry added and removed again cancels out; updates of entries added or
+// removed in the same window are folded into those.
+type tileDelta struct {
+	added, removed, updated map[uint64]struct{}
+}
+
+func (d *tileDelta) add(id uint64) {
+	delete(d.removed, id)
+	delete(d.updated, id)
+	d.added = addKey(d.added, id)
+}
+
+func (d *tileDelta) remove(id uint64) {
+	if _, ok := d.added[id]; ok {
+		delete(d.added, id)
+		return
+	}
+	delete(d.updated, id)
+	d.removed = addKey(d.removed, id)
+}
+
+func (d *tileDelta) update(id uint64) {
+	if _, ok := d.added[id]; ok {
+		return
+	}
+	if _, ok := d.removed[id]; ok {
+		return
+	}
+	d.updated = addKey(d.updated, id)
+}
+
+func addKey(set map[uint64]struct{}, id uint64) map[uint64]struct{} {
+	if set == nil {
+		set = make(map[uint64]struct{})
+	}
+	set[id] = struct{}{}
+	return set
+}
+
+func setKeys(set map[uint64]struct{}) []uint64 {
+	if len(set) == 0 {
+		return nil
+	}
+	out := make([]uint64, 0, len(set))
+	for id := range set {
+		out = append(out, id)
+	}
+	return out
+}
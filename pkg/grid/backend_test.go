@@ -0,0 +1,91 @@
+package grid
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
+
+func queryIDs(m *BucketGridManager, rect spatial.AABB) []uint64 {
+	var ids []uint64
+	m.QueryRange(rect, func(id uint64) { ids = append(ids, id) })
+	slices.Sort(ids)
+	return ids
+}
+
+// deltaSets flattens bucket deltas into sorted "bucket/kind/entry" keys so
+// two backends can be compared regardless of map order.
+func deltaSets(deltas []BucketDelta) []string {
+	var out []string
+	for _, d := range deltas {
+		for kind, ids := range map[string][]uint64{"+": d.Added, "-": d.Removed, "~": d.Updated} {
+			for _, id := range ids {
+				out = append(out, fmt.Sprintf("%v %s%d", d.Bucket, kind, id))
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestQuadTreeBackend_MatchesUniformGrid(t *testing.T) {
+	uniform, space := newTestManager(t)
+	tree, _ := newTestManager(t, func(cfg *GridLevelConfig) { cfg.Backend = BackendQuadTree })
+	shapes := map[uint64]geom.AABB[uint32]{
+		1: geom.NewAABB(geom.NewVec[uint32](10, 10), geom.NewVec[uint32](20, 20)),
+		2: geom.NewAABB(geom.NewVec[uint32](250, 100), geom.NewVec[uint32](262, 110)),
+		3: geom.NewAABB(geom.NewVec[uint32](250, 250), geom.NewVec[uint32](260, 260)),
+		4: geom.NewAABB(geom.NewVec[uint32](30, 30), geom.NewVec[uint32](34, 34)),
+	}
+	for _, m := range []*BucketGridManager{uniform, tree} {
+		for id, shape := range shapes {
+			m.QueueInsert(id, space.WrapAABB(shape))
+		}
+		m.Flush()
+	}
+	if got, want := deltaSets(tree.ConsumeBucketDeltas()), deltaSets(uniform.ConsumeBucketDeltas()); !slices.Equal(got, want) {
+		t.Fatalf("insert deltas differ:\nquadtree %v\nuniform  %v", got, want)
+	}
+
+	for _, m := range []*BucketGridManager{uniform, tree} {
+		m.QueueUpdate(4, space.WrapAABB(geom.NewAABB(geom.NewVec[uint32](31, 31), geom.NewVec[uint32](35, 35))), true)
+		m.QueueUpdate(1, space.WrapAABB(geom.NewAABB(geom.NewVec[uint32](11, 10), geom.NewVec[uint32](21, 20))), true)
+		m.QueueRemove(2)
+		m.Flush()
+	}
+	if got, want := deltaSets(tree.ConsumeBucketDeltas()), deltaSets(uniform.ConsumeBucketDeltas()); !slices.Equal(got, want) {
+		t.Fatalf("update deltas differ:\nquadtree %v\nuniform  %v", got, want)
+	}
+
+	for _, rect := range []spatial.AABB{
+		geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](40, 40)),
+		geom.NewAABB(geom.NewVec[uint32](240, 240), geom.NewVec[uint32](270, 270)),
+		geom.NewAABB(geom.NewVec[uint32](100, 100), geom.NewVec[uint32](120, 120)),
+	} {
+		if got, want := queryIDs(tree, rect), queryIDs(uniform, rect); !slices.Equal(got, want) {
+			t.Errorf("QueryRange(%v) = %v, uniform grid %v", rect, got, want)
+		}
+	}
+	for entryID := range uint64(16) {
+		got, okTree := tree.EntryAABB(entryID)
+		want, okUniform := uniform.EntryAABB(entryID)
+		if okTree != okUniform || got != want {
+			t.Errorf("EntryAABB(%d) = %v %v, uniform grid %v %v", entryID, got, okTree, want, okUniform)
+		}
+	}
+}
+
+func TestNewBucketGridManager_RejectsUnknownBackend(t *testing.T) {
+	_, err := NewBucketGridManager(plane.NewToroidal2D[uint32](256, 256), GridLevelConfig{
+		Resoltuion:       spatial.Size256x256,
+		BucketResolution: spatial.Size32x32,
+		Backend:          Backend(9),
+	})
+	if err == nil {
+		t.Fatal("expected an error for an unknown backend")
+	}
+}
@@ -2,6 +2,9 @@ package grid
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/kjkrol/gokg/pkg/geom"
 	"github.com/kjkrol/gokg/pkg/plane"
@@ -11,8 +14,29 @@ import (
 type GridLevelConfig struct {
 	Resoltuion       spatial.Resolution
 	BucketResolution spatial.Resolution
-	BucketCapacity   int
-	OpsBufferSize    int
+	// BucketCapacity is an allocation hint for the initial size of each
+	// bucket. Buckets grow on demand (amortized append), so inserts beyond the
+	// hint are never rejected or dropped. Values <= 0 fall back to 2.
+	BucketCapacity int
+	OpsBufferSize  int
+	// MarginBuckets is the prefetch margin (in buckets) around the view used
+	// by MultiBucketGridManager.BuildFrame. Zero uses the multi-manager's
+	// default.
+	MarginBuckets int
+	// SubpixelBits is the number of fractional bits of the fixed-point
+	// positions accepted by QueueInsertScaled and QueueUpdateScaled (8 gives
+	// 1/256 world unit). The index still buckets whole world units. Zero
+	// disables sub-pixel positions.
+	SubpixelBits uint8
+	// TrackMovedIDs makes the manager record which entries changed bucket
+	// membership, for ConsumeMovedIDs.
+	TrackMovedIDs bool
+	// Backend selects the spatial index; see SpatialBackend. Buckets keep
+	// BucketResolution as render tiles with either backend.
+	Backend Backend
+	// Profile makes the manager time Flush and Plan into a FrameProfile, see
+	// BucketGridManager.FrameProfile.
+	Profile bool
 }
 
 type BucketPlan struct {
@@ -23,10 +47,85 @@ type BucketPlan struct {
 
 type BucketDelta = spatial.BucketDelta
 
+// BulkItem is one shape passed to BulkInsert.
+type BulkItem struct {
+	ID   uint64
+	AABB plane.AABB[uint32]
+}
+
+// Hook observes the changes a BucketGridManager applies, for tracing and
+// metrics. Methods are called after the state changed, with the manager's
+// write lock held, so they must not call back into the manager.
+type Hook interface {
+	// OnInsert reports a new logical entry with its unwrapped union AABB.
+	OnInsert(id uint64, aabb spatial.AABB)
+	// OnRemove reports a removed logical entry.
+	OnRemove(id uint64)
+	// OnUpdate reports an entry moved or resized from old to new.
+	OnUpdate(id uint64, old, new spatial.AABB)
+	// OnDirty reports a world rect marked dirty; wrapped rects arrive as
+	// their in-world fragments.
+	OnDirty(rect spatial.AABB)
+}
+
+// managerSeq numbers managers for their lockOrder.
+var managerSeq atomic.Uint64
+
+// defaultOpsBufferSize mirrors the index default used when
+// GridLevelConfig.OpsBufferSize is zero.
+const defaultOpsBufferSize = 4096
+
+// BucketGridManager is safe for one writer (Queue*/Flush) running concurrently
+// with readers (QueryRange, EntryAABB, ForEachEntry) and the renderer (Plan,
+// MarkBucketsRendered). Flush and the dirty-state methods take the write lock;
+// queries take the read lock, so readers observe state as of the last Flush.
+// Collectors passed to queries must not call back into the manager's mutating
+// methods.
 type BucketGridManager struct {
-	index          *spatial.GridIndexManager
-	cacheWorldSide uint32
-	dirty          dirtyState
+	mu            sync.RWMutex
+	space         plane.Space2D[uint32]
+	cfg           GridLevelConfig
+	index         SpatialBackend
+	cacheWorld    geom.Vec[uint32]
+	marginBuckets int
+	dirty         dirtyState
+	entries       map[uint64]spatial.AABB
+	opsBufferSize int
+	hook          Hook
+	// subpixel holds the fixed-point placement of entries queued through
+	// QueueInsertScaled/QueueUpdateScaled, keyed by logical ID.
+	subpixel map[uint64]subpixelShape
+	// carriedDeltas are bucket deltas of a replaced index (see Rebucket) or
+	// of MarkEntryUpdated, returned ahead of the current index's deltas.
+	carriedDeltas []BucketDelta
+	// moved collects the IDs reported by ConsumeMovedIDs; nil unless
+	// GridLevelConfig.TrackMovedIDs is set.
+	moved map[uint64]struct{}
+	// profile accumulates the current frame and lastProfile holds the one
+	// completed by the last Plan; both stay zero unless cfg.Profile is set.
+	profile     FrameProfile
+	lastProfile FrameProfile
+	// lockOrder orders the write locks TransferTo takes on two managers.
+	lockOrder uint64
+
+	pendingMu sync.Mutex
+	pending   []entryOp
+	// queued holds the IDs that are live once the queue is flushed, so a
+	// repeated insert can be turned into an update.
+	queued map[uint64]struct{}
+	// queuedShapes holds the last shape queued for each ID since the last
+	// Flush, for QueuedEntry.
+	queuedShapes map[uint64]spatial.AABB
+}
+
+// entryOp mirrors a queued index operation so the manager can track logical
+// entries (original IDs, not fragment entry IDs) once the queue is flushed.
+type entryOp struct {
+	id       uint64
+	aabb     spatial.AABB
+	remove   bool
+	scaled   bool
+	subpixel subpixelShape
 }
 
 type dirtyState struct {
@@ -43,60 +142,268 @@ func NewBucketGridManager(space plane.Space2D[uint32], cfg GridLevelConfig) (*Bu
 	if space == nil {
 		return nil, fmt.Errorf("space is required")
 	}
-	index, err := spatial.NewGridIndexManager(space, spatial.GridIndexConfig{
+	if err := validateSubpixelBits(space, cfg.SubpixelBits); err != nil {
+		return nil, err
+	}
+	index, err := newGridIndex(space, cfg)
+	if err != nil {
+		return nil, err
+	}
+	manager := &BucketGridManager{
+		lockOrder:     managerSeq.Add(1),
+		space:         space,
+		cfg:           cfg,
+		index:         index,
+		marginBuckets: cfg.MarginBuckets,
+		entries:       make(map[uint64]spatial.AABB),
+		subpixel:      make(map[uint64]subpixelShape),
+		queued:        make(map[uint64]struct{}),
+		queuedShapes:  make(map[uint64]spatial.AABB),
+		opsBufferSize: cfg.OpsBufferSize,
+		dirty:         newDirtyState(cfg),
+	}
+	if manager.opsBufferSize <= 0 {
+		manager.opsBufferSize = defaultOpsBufferSize
+	}
+	if cfg.TrackMovedIDs {
+		manager.moved = make(map[uint64]struct{})
+	}
+	manager.cacheWorld = spaceWorld(space, cfg.Resoltuion.Side())
+	return manager, nil
+}
+
+func newGridIndex(space plane.Space2D[uint32], cfg GridLevelConfig) (SpatialBackend, error) {
+	if err := validateWorld(space, cfg); err != nil {
+		return nil, err
+	}
+	switch cfg.Backend {
+	case BackendUniformGrid:
+	case BackendQuadTree:
+		if err := validateTiles(cfg); err != nil {
+			return nil, err
+		}
+		return newQuadTreeIndex(space, cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown grid backend %d", cfg.Backend)
+	}
+	return spatial.NewGridIndexManager(space, spatial.GridIndexConfig{
 		Resolution:       cfg.Resoltuion,
 		BucketResolution: cfg.BucketResolution,
 		BucketCapacity:   cfg.BucketCapacity,
 		OpsBufferSize:    cfg.OpsBufferSize,
 	})
-	if err != nil {
-		return nil, err
+}
+
+// validateWorld checks that the buckets tile the world of space exactly.
+// Bucket indices are computed by shifting coordinates by the bucket
+// resolution, so a world side that is not a multiple of the bucket side would
+// lose its last partial row or column, and one larger than the grid would be
+// clamped into its last buckets. A wrapping axis must span the whole grid,
+// since the cache and view math wraps at the grid side. Zero resolutions are
+// left to the backends to reject.
+func validateWorld(space plane.Space2D[uint32], cfg GridLevelConfig) error {
+	if cfg.Resoltuion == 0 || cfg.BucketResolution == 0 || cfg.BucketResolution > cfg.Resoltuion {
+		return nil
 	}
-	manager := &BucketGridManager{index: index}
-	if space.Name() == "Toroidal2D" {
-		manager.cacheWorldSide = cfg.Resoltuion.Side()
+	gridSide, bucketSide := cfg.Resoltuion.Side(), cfg.BucketResolution.Side()
+	world := space.Viewport()
+	size := world.BottomRight.Sub(world.TopLeft)
+	wrapX, wrapY := SpaceWraps(space)
+	for _, axis := range []struct {
+		name string
+		side uint32
+		wrap bool
+	}{{"width", size.X, wrapX}, {"height", size.Y, wrapY}} {
+		switch {
+		case axis.side%bucketSide != 0:
+			return fmt.Errorf("world %s %d is not a multiple of the bucket size %d", axis.name, axis.side, bucketSide)
+		case axis.side > gridSide:
+			return fmt.Errorf("world %s %d exceeds the grid size %d", axis.name, axis.side, gridSide)
+		case axis.wrap && axis.side != gridSide:
+			return fmt.Errorf("wrapping world %s %d must equal the grid size %d", axis.name, axis.side, gridSide)
+		}
 	}
+	return nil
+}
+
+func newDirtyState(cfg GridLevelConfig) dirtyState {
 	bucketResolution := cfg.BucketResolution
 	if bucketResolution == 0 {
 		bucketResolution = spatial.NewResolution(6)
 	}
 	bucketSize := bucketResolution.Side()
-	gridSide := cfg.Resoltuion.Side() / bucketSize
-	manager.dirty = dirtyState{
+	return dirtyState{
 		bucketResolution: bucketResolution,
 		bucketSize:       bucketSize,
-		gridSide:         gridSide,
+		gridSide:         cfg.Resoltuion.Side() / bucketSize,
 		dirty:            make(map[uint32]struct{}),
 	}
-	return manager, nil
+}
+
+// Rebucket rebuilds the spatial index and the dirty grid with a new bucket
+// resolution, re-inserting every flushed entry. Pending queued ops are
+// flushed first. The cache rect is invalidated and every bucket marked dirty,
+// so the next Plan re-renders the whole view. Bucket deltas removing all
+// entries from the old buckets are returned by the next ConsumeBucketDeltas,
+// ahead of the deltas adding them to the new ones. Like Queue* and Flush it
+// must be called from the writer goroutine. On error the manager is left
+// unchanged. A Hook sees the dirty marks but no inserts: the logical entries
+// do not change.
+func (m *BucketGridManager) Rebucket(newRes spatial.Resolution) error {
+	if m.index == nil {
+		return nil
+	}
+	cfg := m.cfg
+	cfg.BucketResolution = newRes
+	index, err := newGridIndex(m.space, cfg)
+	if err != nil {
+		return err
+	}
+	m.Flush()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Drain the old index: its unconsumed deltas first, then the removal of
+	// every entry, so renderers drop the old buckets' instances in order.
+	carried := append(m.carriedDeltas, m.index.ConsumeBucketDeltas()...)
+	ignoreDirty := func(spatial.AABB) {}
+	queued := 0
+	for id := range m.entries {
+		m.index.QueueRemove(id)
+		if queued++; queued == m.opsBufferSize {
+			m.index.Flush(ignoreDirty)
+			queued = 0
+		}
+	}
+	m.index.Flush(ignoreDirty)
+	m.carriedDeltas = append(carried, m.index.ConsumeBucketDeltas()...)
+
+	m.cfg = cfg
+	m.index = index
+	m.dirty = newDirtyState(cfg)
+	queued = 0
+	for id, aabb := range m.entries {
+		m.index.QueueInsert(id, aabb)
+		if queued++; queued == m.opsBufferSize {
+			m.index.Flush(m.markDirty)
+			queued = 0
+		}
+	}
+	m.index.Flush(m.markDirty)
+	m.markAllDirtyLocked()
+	for id := range m.entries {
+		m.noteMoved(id)
+	}
+	return nil
 }
 
 func (m *BucketGridManager) ConsumeBucketDeltas() []BucketDelta {
 	if m.index == nil {
 		return nil
 	}
-	return m.index.ConsumeBucketDeltas()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deltas := m.index.ConsumeBucketDeltas()
+	if len(m.carriedDeltas) > 0 {
+		deltas = append(m.carriedDeltas, deltas...)
+		m.carriedDeltas = nil
+	}
+	return deltas
 }
 
 func (m *BucketGridManager) QueueInsert(id uint64, aabb plane.AABB[uint32]) {
 	if m.index == nil {
 		return
 	}
-	m.index.QueueInsert(id, planeAABBToSpatial(aabb))
+	shape := planeAABBToSpatial(aabb)
+	m.queueInsert(id, shape)
+	m.appendPending(entryOp{id: id, aabb: shape})
+}
+
+// queueInsert feeds an insert to the index. Inserting an ID that is already
+// live (e.g. a drawable added twice) would index it twice, so it is queued
+// as an update of the existing entry instead.
+func (m *BucketGridManager) queueInsert(id uint64, shape spatial.AABB) {
+	if m.setQueued(id, true) {
+		m.index.QueueUpdate(id, shape, true)
+		return
+	}
+	m.index.QueueInsert(id, shape)
 }
 
 func (m *BucketGridManager) QueueRemove(id uint64) {
 	if m.index == nil {
 		return
 	}
+	m.setQueued(id, false)
 	m.index.QueueRemove(id)
+	m.appendPending(entryOp{id: id, remove: true})
 }
 
 func (m *BucketGridManager) QueueUpdate(id uint64, aabb plane.AABB[uint32], markDirty bool) {
 	if m.index == nil {
 		return
 	}
-	m.index.QueueUpdate(id, planeAABBToSpatial(aabb), markDirty)
+	shape := planeAABBToSpatial(aabb)
+	m.setQueued(id, true)
+	m.index.QueueUpdate(id, shape, markDirty)
+	m.appendPending(entryOp{id: id, aabb: shape})
+}
+
+// appendPending records a queued op. The index queue is fed outside m.mu,
+// since Queue* blocks while the ops buffer is full and only Flush drains it.
+func (m *BucketGridManager) appendPending(op entryOp) {
+	m.pendingMu.Lock()
+	m.pending = append(m.pending, op)
+	if op.remove {
+		delete(m.queuedShapes, op.id)
+	} else {
+		m.queuedShapes[op.id] = op.aabb
+	}
+	m.pendingMu.Unlock()
+}
+
+// setQueued records whether id is live after the queued ops and reports
+// whether it was live before.
+func (m *BucketGridManager) setQueued(id uint64, live bool) bool {
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+	_, was := m.queued[id]
+	if live {
+		m.queued[id] = struct{}{}
+	} else {
+		delete(m.queued, id)
+	}
+	return was
+}
+
+// MarkEntryUpdated reports the flushed fragments of entry id as Updated in
+// the bucket deltas of every bucket they touch and repaints those buckets,
+// without moving the entry in the index. Renderers then re-read the entry's
+// instance data, e.g. after a style-only change.
+func (m *BucketGridManager) MarkEntryUpdated(id uint64) {
+	if m.index == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for frag := range uint64(4) {
+		entryID := id<<2 | frag
+		aabb, ok := m.index.EntryAABB(entryID)
+		if !ok {
+			continue
+		}
+		span := m.dirty.span(aabb)
+		for y := span.minY; y <= span.maxY; y++ {
+			for x := span.minX; x <= span.maxX; x++ {
+				m.carriedDeltas = append(m.carriedDeltas, BucketDelta{
+					Bucket:  m.bucketRect(y*m.dirty.gridSide + x),
+					Updated: []uint64{entryID},
+				})
+			}
+		}
+		m.markDirty(aabb)
+	}
 }
 
 func (m *BucketGridManager) QueueDirtyRect(rect spatial.AABB) {
@@ -104,61 +411,407 @@ func (m *BucketGridManager) QueueDirtyRect(rect spatial.AABB) {
 }
 
 func (m *BucketGridManager) Plan(viewRect spatial.AABB, marginBuckets int) BucketPlan {
-	worldSide := m.cacheWorldSide
-	if worldSide > 0 {
-		viewW := viewRect.BottomRight.X - viewRect.TopLeft.X
-		viewH := viewRect.BottomRight.Y - viewRect.TopLeft.Y
-		if viewW >= worldSide && viewH >= worldSide {
-			worldSide = 0
-		}
-	}
-	cacheRect := cacheRectForView(viewRect, m.dirty.bucketSize, marginBuckets, worldSide)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	start := m.profileStart()
+	world := viewWorld(m.cacheWorld, rectSize(viewRect))
+	cacheRect := cacheRectForView(viewRect, m.dirty.bucketSize, marginBuckets, world)
 	if !m.dirty.cacheValid || !rectEquals(cacheRect, m.dirty.cacheRect) {
 		if m.dirty.cacheValid {
 			for _, rect := range diffRects(m.dirty.cacheRect, cacheRect) {
-				m.MarkRectDirty(rect)
+				m.markRectDirtyLocked(rect)
 			}
 		} else {
-			m.MarkRectDirty(cacheRect)
+			m.markRectDirtyLocked(cacheRect)
 		}
 		m.dirty.cacheRect = cacheRect
 		m.dirty.cacheValid = true
 	}
+	collectStart := m.profileStart()
+	indices := m.collectDirtyBucketIndices(cacheRect)
+	if m.cfg.Profile {
+		m.profile.CollectDirtyBuckets = time.Since(collectStart)
+		m.profile.DirtyBuckets = len(indices)
+		m.profile.Plan = time.Since(start)
+		m.lastProfile, m.profile = m.profile, FrameProfile{}
+	}
 	return BucketPlan{
 		CacheRect:     cacheRect,
-		BucketIndices: m.collectDirtyBucketIndices(cacheRect),
-		BucketRect:    m.bucketRect,
+		BucketIndices: indices,
+		BucketRect:    m.dirty.layout().rect,
 	}
 }
 
+// CacheRect returns the padded region (view plus margin) computed by the last
+// Plan call; ok is false before the first Plan.
+func (m *BucketGridManager) CacheRect() (rect spatial.AABB, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.dirty.cacheRect, m.dirty.cacheValid
+}
+
+// MarginBuckets returns the prefetch margin configured for this layer.
+func (m *BucketGridManager) MarginBuckets() int {
+	return m.marginBuckets
+}
+
 func (m *BucketGridManager) Flush() {
 	if m.index == nil {
 		return
 	}
-	m.index.Flush(m.dirty.markDirtyAABB)
+	// Snapshot before draining the index so entries never get ahead of it;
+	// ops queued meanwhile are replayed by the next Flush.
+	m.pendingMu.Lock()
+	pending := m.pending
+	m.pending = nil
+	clear(m.queuedShapes)
+	m.pendingMu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	start := m.profileStart()
+	m.index.Flush(m.markDirty)
+	for _, op := range pending {
+		if op.remove {
+			m.removeEntry(op.id)
+			continue
+		}
+		m.setEntry(op.id, op.aabb)
+		if op.scaled {
+			m.subpixel[op.id] = op.subpixel
+		} else {
+			delete(m.subpixel, op.id)
+		}
+	}
+	if m.cfg.Profile {
+		m.profile.Flush += time.Since(start)
+		m.profile.Ops += len(pending)
+	}
+}
+
+// SetHook installs hook (nil removes it). Without a hook the manager does no
+// extra work.
+func (m *BucketGridManager) SetHook(hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hook = hook
+}
+
+func (m *BucketGridManager) setEntry(id uint64, aabb spatial.AABB) {
+	old, exists := m.entries[id]
+	m.entries[id] = aabb
+	if m.moved != nil && (!exists || m.entryBuckets(old) != m.entryBuckets(aabb)) {
+		m.noteMoved(id)
+	}
+	if m.hook == nil {
+		return
+	}
+	if exists {
+		m.hook.OnUpdate(id, old, aabb)
+	} else {
+		m.hook.OnInsert(id, aabb)
+	}
+}
+
+func (m *BucketGridManager) removeEntry(id uint64) {
+	if _, ok := m.entries[id]; !ok {
+		return
+	}
+	delete(m.entries, id)
+	delete(m.subpixel, id)
+	m.noteMoved(id)
+	if m.hook != nil {
+		m.hook.OnRemove(id)
+	}
+}
+
+func (m *BucketGridManager) markDirty(aabb spatial.AABB) {
+	m.dirty.markDirtyAABB(aabb)
+	if m.hook != nil {
+		m.hook.OnDirty(aabb)
+	}
+}
+
+// BulkInsert inserts items and applies them immediately, without a separate
+// Flush: bucket deltas and dirty buckets are recorded as for QueueInsert.
+// Pending queued ops are flushed first so ordering is preserved. Items are
+// fed to the index in chunks that fit its ops buffer under a single write
+// lock, so loading a scene never blocks on a full queue and skips the
+// per-item pending bookkeeping of QueueInsert. The index still fragments and
+// applies items one at a time: spatial.GridIndexManager exposes no bulk
+// entry point, so a single index.BulkInsert needs support in gokg first.
+func (m *BucketGridManager) BulkInsert(items []BulkItem) {
+	if m.index == nil || len(items) == 0 {
+		return
+	}
+	m.Flush()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	profileStart := m.profileStart()
+	for start := 0; start < len(items); start += m.opsBufferSize {
+		chunk := items[start:min(start+m.opsBufferSize, len(items))]
+		for _, item := range chunk {
+			shape := planeAABBToSpatial(item.AABB)
+			m.queueInsert(item.ID, shape)
+			m.setEntry(item.ID, shape)
+			delete(m.subpixel, item.ID)
+		}
+		m.index.Flush(m.markDirty)
+	}
+	if m.cfg.Profile {
+		m.profile.Flush += time.Since(profileStart)
+		m.profile.Ops += len(items)
+	}
+}
+
+// TransferTo moves entry id from m to dst, placed at aabb, e.g. when a
+// drawable changes layer. Pending queued ops of both managers are flushed
+// first; the move itself is applied at once with both managers' write locks
+// held, taken in a fixed order, so there is no moment at which a reader
+// finds the entry in neither manager or in both. Bucket deltas and dirty
+// buckets are recorded as for QueueRemove and QueueInsert. Like Queue* it
+// must be called from the writer goroutine.
+func (m *BucketGridManager) TransferTo(dst *BucketGridManager, id uint64, aabb plane.AABB[uint32]) {
+	if dst == nil || dst == m || m.index == nil || dst.index == nil {
+		return
+	}
+	m.Flush()
+	dst.Flush()
+
+	first, second := m, dst
+	if second.lockOrder < first.lockOrder {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	m.setQueued(id, false)
+	m.index.QueueRemove(id)
+	m.index.Flush(m.markDirty)
+	m.removeEntry(id)
+
+	shape := planeAABBToSpatial(aabb)
+	dst.queueInsert(id, shape)
+	dst.index.Flush(dst.markDirty)
+	dst.setEntry(id, shape)
+	delete(dst.subpixel, id)
+}
+
+// ForEachEntry visits every flushed logical entry once, with its original ID
+// and unwrapped union AABB, regardless of how many fragments it is split into.
+// The AABB is in uint32 world units like Entry and EntryAABB: a union that
+// crosses the seam extends past the world side, never below zero.
+func (m *BucketGridManager) ForEachEntry(fn func(id uint64, aabb spatial.AABB)) {
+	if fn == nil {
+		return
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for id, aabb := range m.entries {
+		fn(id, aabb)
+	}
 }
 
 func (m *BucketGridManager) EntryAABB(entryID uint64) (spatial.AABB, bool) {
 	if m.index == nil {
 		return spatial.AABB{}, false
 	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.index.EntryAABB(entryID)
 }
 
+// Entry returns the unwrapped union AABB of a flushed logical entry.
+func (m *BucketGridManager) Entry(id uint64) (spatial.AABB, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	aabb, ok := m.entries[id]
+	return aabb, ok
+}
+
+// QueuedEntry returns the unwrapped union AABB entry id will have once the
+// queue is flushed: its last queued insert or update, else its flushed entry.
+// ok is false when the entry is not live after the queue. Like Queue* it
+// must be called from the writer goroutine, so that a Flush cannot run
+// between the two lookups.
+func (m *BucketGridManager) QueuedEntry(id uint64) (spatial.AABB, bool) {
+	m.pendingMu.Lock()
+	_, live := m.queued[id]
+	aabb, queued := m.queuedShapes[id]
+	m.pendingMu.Unlock()
+	if !live {
+		return spatial.AABB{}, false
+	}
+	if queued {
+		return aabb, true
+	}
+	return m.Entry(id)
+}
+
+// Offset moves aabb, an unwrapped union AABB as returned by Entry, by delta
+// within the manager's space: the position wraps on a wrapping axis and is
+// clamped to the world on the others. The result is wrapped into the space, ready
+// for QueueUpdate.
+func (m *BucketGridManager) Offset(aabb spatial.AABB, delta geom.Vec[int]) plane.AABB[uint32] {
+	world := m.space.Viewport().BottomRight
+	wrapX, wrapY := SpaceWraps(m.space)
+	width := aabb.BottomRight.X - aabb.TopLeft.X
+	height := aabb.BottomRight.Y - aabb.TopLeft.Y
+	x := offsetAxis(aabb.TopLeft.X, width, world.X, delta.X, wrapX)
+	y := offsetAxis(aabb.TopLeft.Y, height, world.Y, delta.Y, wrapY)
+	return m.space.WrapAABB(geom.NewAABBAt(geom.NewVec(x, y), width, height))
+}
+
+func offsetAxis(pos, size, side uint32, delta int, wrap bool) uint32 {
+	moved := int64(pos) + int64(delta)
+	if wrap {
+		moved %= int64(side)
+		if moved < 0 {
+			moved += int64(side)
+		}
+		return uint32(moved)
+	}
+	upper := max(int64(side)-int64(size), 0)
+	return uint32(min(max(moved, 0), upper))
+}
+
+// WrapRect returns the pieces of rect, given in signed world coordinates, as
+// they lie in the space, e.g. to draw a selection marquee across the seams.
+// On a wrapping axis rect is shifted into the world and split at the seam,
+// each piece no larger than the world; on the other axes it is clipped to the
+// world. An empty rect, or one outside a bounded world, yields nil.
+func (m *BucketGridManager) WrapRect(rect geom.AABB[int]) []geom.AABB[int] {
+	world := m.space.Viewport().BottomRight
+	wrapX, wrapY := SpaceWraps(m.space)
+	x, width, okX := wrapRectAxis(rect.TopLeft.X, rect.BottomRight.X, world.X, wrapX)
+	y, height, okY := wrapRectAxis(rect.TopLeft.Y, rect.BottomRight.Y, world.Y, wrapY)
+	if !okX || !okY {
+		return nil
+	}
+	pieces := wrapViewRect(m.space, geom.NewAABBAt(geom.NewVec(x, y), width, height))
+	out := make([]geom.AABB[int], 0, len(pieces))
+	for _, piece := range pieces {
+		if rectEmpty(piece) {
+			continue
+		}
+		out = append(out, geom.NewAABB(
+			geom.NewVec(int(piece.TopLeft.X), int(piece.TopLeft.Y)),
+			geom.NewVec(int(piece.BottomRight.X), int(piece.BottomRight.Y)),
+		))
+	}
+	return out
+}
+
+// wrapRectAxis maps the span [lo, hi) into a world side, wrapping its start
+// or clipping it, and reports whether anything is left.
+func wrapRectAxis(lo, hi int, side uint32, wrap bool) (uint32, uint32, bool) {
+	if hi <= lo || side == 0 {
+		return 0, 0, false
+	}
+	if wrap {
+		start := int64(lo) % int64(side)
+		if start < 0 {
+			start += int64(side)
+		}
+		return uint32(start), uint32(min(int64(hi)-int64(lo), int64(side))), true
+	}
+	start, end := max(int64(lo), 0), min(int64(hi), int64(side))
+	if end <= start {
+		return 0, 0, false
+	}
+	return uint32(start), uint32(end - start), true
+}
+
 func (m *BucketGridManager) QueryRange(aabb spatial.AABB, collector func(uint64)) int {
 	if m.index == nil {
 		return 0
 	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.index.QueryRange(aabb, collector)
 }
 
+// QueryContained reports the logical ID of every entry lying entirely inside
+// aabb (box select), unlike QueryRange, which reports the fragment entry IDs
+// of entries merely touching it. An entry wrapped across the world seam must
+// have all its fragments inside the wrapped query rect. Each entry is
+// reported once; the count of reported entries is returned.
+func (m *BucketGridManager) QueryContained(aabb spatial.AABB, collector func(id uint64)) int {
+	if m.index == nil || collector == nil {
+		return 0
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pieces := wrapViewRect(m.space, aabb)
+	seen := make(map[uint64]struct{})
+	var contained []uint64
+	m.index.QueryRange(aabb, func(entryID uint64) {
+		id := entryID >> 2
+		if _, ok := seen[id]; ok {
+			return
+		}
+		seen[id] = struct{}{}
+		union, ok := m.entries[id]
+		if ok && containedInPieces(m.space.WrapAABB(union), pieces) {
+			contained = append(contained, id)
+		}
+	})
+	for _, id := range contained {
+		collector(id)
+	}
+	return len(contained)
+}
+
+func containedInPieces(shape plane.AABB[uint32], pieces []spatial.AABB) bool {
+	inside := func(frag spatial.AABB) bool {
+		for _, piece := range pieces {
+			if piece.Contains(frag) {
+				return true
+			}
+		}
+		return false
+	}
+	if !inside(shape.AABB) {
+		return false
+	}
+	ok := true
+	shape.VisitFragments(func(_ plane.FragPosition, frag geom.AABB[uint32]) bool {
+		ok = inside(frag)
+		return ok
+	})
+	return ok
+}
+
 func (m *BucketGridManager) MarkRectDirty(rect spatial.AABB) {
 	if m.index == nil {
 		return
 	}
-	m.index.VisitWrappedAABB(rect, func(aabb spatial.AABB) {
-		m.dirty.markDirtyAABB(aabb)
-	})
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.markRectDirtyLocked(rect)
+}
+
+func (m *BucketGridManager) markRectDirtyLocked(rect spatial.AABB) {
+	m.index.VisitWrappedAABB(rect, m.markDirty)
+}
+
+// MarkAllDirty marks every bucket of the grid dirty, forcing the next Plan to
+// re-render the whole cache rect (e.g. after a palette or theme change).
+func (m *BucketGridManager) MarkAllDirty() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.markAllDirtyLocked()
+}
+
+func (m *BucketGridManager) markAllDirtyLocked() {
+	m.dirty.markAll()
+	if m.hook != nil {
+		side := m.dirty.gridSide * m.dirty.bucketSize
+		m.hook.OnDirty(geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec(side, side)))
+	}
 }
 
 func (m *BucketGridManager) collectDirtyBucketIndices(cacheRect spatial.AABB) []uint32 {
@@ -188,6 +841,8 @@ func (m *BucketGridManager) MarkBucketsRendered(indices []uint32) {
 	if len(indices) == 0 {
 		return
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	for _, idx := range indices {
 		delete(m.dirty.dirty, idx)
 	}
@@ -204,12 +859,28 @@ func (m *BucketGridManager) MarkBucketsRendered(indices []uint32) {
 }
 
 func (m *BucketGridManager) bucketRect(idx uint32) geom.AABB[uint32] {
-	x := idx % m.dirty.gridSide
-	y := idx / m.dirty.gridSide
-	minX := x * m.dirty.bucketSize
-	minY := y * m.dirty.bucketSize
-	maxX := minX + m.dirty.bucketSize
-	maxY := minY + m.dirty.bucketSize
+	return m.dirty.layout().rect(idx)
+}
+
+// bucketLayout is the bucket geometry of a dirty grid. A BucketPlan keeps a
+// copy, so its BucketRect maps indices onto the grid it was planned on, even
+// after Rebucket replaced it, without reading the manager unlocked.
+type bucketLayout struct {
+	gridSide   uint32
+	bucketSize uint32
+}
+
+func (d *dirtyState) layout() bucketLayout {
+	return bucketLayout{gridSide: d.gridSide, bucketSize: d.bucketSize}
+}
+
+func (l bucketLayout) rect(idx uint32) geom.AABB[uint32] {
+	x := idx % l.gridSide
+	y := idx / l.gridSide
+	minX := x * l.bucketSize
+	minY := y * l.bucketSize
+	maxX := minX + l.bucketSize
+	maxY := minY + l.bucketSize
 	return geom.NewAABB(
 		geom.NewVec(minX, minY),
 		geom.NewVec(maxX, maxY),
@@ -248,6 +919,17 @@ func (d *dirtyState) markDirtyAABB(aabb spatial.AABB) {
 	}
 }
 
+func (d *dirtyState) markAll() {
+	total := d.gridSide * d.gridSide
+	for idx := uint32(0); idx < total; idx++ {
+		if _, ok := d.dirty[idx]; ok {
+			continue
+		}
+		d.dirty[idx] = struct{}{}
+		d.dirtyList = append(d.dirtyList, idx)
+	}
+}
+
 func planeAABBToSpatial(shape plane.AABB[uint32]) spatial.AABB {
 	base := shape.AABB
 	minX := base.TopLeft.X
@@ -0,0 +1,697 @@
+package grid
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
+
+// newTestManager returns a manager over a 256x256 torus with 32x32 buckets;
+// configure adjusts that config first, e.g. to enable TrackMovedIDs.
+func newTestManager(t *testing.T, configure ...func(*GridLevelConfig)) (*BucketGridManager, plane.Space2D[uint32]) {
+	t.Helper()
+	space := plane.NewToroidal2D[uint32](256, 256)
+	cfg := GridLevelConfig{
+		Resoltuion:       spatial.Size256x256,
+		BucketResolution: spatial.Size32x32,
+		BucketCapacity:   4,
+	}
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+	manager, err := NewBucketGridManager(space, cfg)
+	if err != nil {
+		t.Fatalf("NewBucketGridManager: %v", err)
+	}
+	return manager, space
+}
+
+func collectEntries(m *BucketGridManager) map[uint64]spatial.AABB {
+	out := make(map[uint64]spatial.AABB)
+	m.ForEachEntry(func(id uint64, aabb spatial.AABB) {
+		if _, ok := out[id]; ok {
+			panic("entry visited twice")
+		}
+		out[id] = aabb
+	})
+	return out
+}
+
+func TestBucketGridManager_ForEachEntryVisitsLogicalEntriesOnce(t *testing.T) {
+	manager, space := newTestManager(t)
+
+	manager.QueueInsert(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 5, 5)))
+	// Crosses both torus seams, so it is split into 4 fragments.
+	manager.QueueInsert(2, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](250, 250), 10, 10)))
+	if got := collectEntries(manager); len(got) != 0 {
+		t.Fatalf("entries should appear only after Flush, got %v", got)
+	}
+	manager.Flush()
+
+	got := collectEntries(manager)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 logical entries, got %d", len(got))
+	}
+	want := geom.NewAABB(geom.NewVec[uint32](250, 250), geom.NewVec[uint32](260, 260))
+	if got[2] != want {
+		t.Errorf("wrapped entry union = %v, want %v", got[2], want)
+	}
+
+	manager.QueueRemove(1)
+	manager.Flush()
+	got = collectEntries(manager)
+	if _, ok := got[1]; ok || len(got) != 1 {
+		t.Errorf("removed entry still visited: %v", got)
+	}
+}
+
+func TestBucketGridManager_BucketGrowsBeyondCapacityHint(t *testing.T) {
+	manager, space := newTestManager(t)
+
+	// All entries land in the first 32x32 bucket, far above the hint of 4.
+	const count = 200
+	for i := range uint32(count) {
+		pos := geom.NewVec(i%16, i/16)
+		manager.QueueInsert(uint64(i+1), space.WrapAABB(geom.NewAABBAt(pos, 1, 1)))
+	}
+	manager.Flush()
+
+	seen := make(map[uint64]struct{}, count)
+	bucket := geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](32, 32))
+	manager.QueryRange(bucket, func(entryID uint64) {
+		// QueryRange reports fragment entry IDs (id<<2 | fragment).
+		seen[entryID>>2] = struct{}{}
+	})
+	if len(seen) != count {
+		t.Fatalf("QueryRange returned %d entries, want %d", len(seen), count)
+	}
+	for i := range uint64(count) {
+		if _, ok := seen[i+1]; !ok {
+			t.Fatalf("entry %d missing from QueryRange", i+1)
+		}
+	}
+}
+
+// Run with -race: queries from another goroutine must not race with Flush.
+func TestBucketGridManager_ConcurrentQueriesDuringFlush(t *testing.T) {
+	manager, space := newTestManager(t)
+	const rounds = 200
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		world := geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](256, 256))
+		for range rounds {
+			manager.QueryRange(world, func(uint64) {})
+			manager.ForEachEntry(func(uint64, spatial.AABB) {})
+			manager.EntryAABB(1 << 2)
+		}
+	}()
+
+	for i := range uint32(rounds) {
+		pos := geom.NewVec(i%256, (i*7)%256)
+		manager.QueueInsert(uint64(i+1), space.WrapAABB(geom.NewAABBAt(pos, 4, 4)))
+		if i%3 == 0 {
+			manager.QueueRemove(uint64(i))
+		}
+		manager.Flush()
+	}
+	<-done
+
+	if got := len(collectEntries(manager)); got == 0 {
+		t.Fatal("expected entries after concurrent flushes")
+	}
+}
+
+func TestBucketGridManager_BulkInsert(t *testing.T) {
+	space := plane.NewToroidal2D[uint32](256, 256)
+	manager, err := NewBucketGridManager(space, GridLevelConfig{
+		Resoltuion:       spatial.Size256x256,
+		BucketResolution: spatial.Size32x32,
+		OpsBufferSize:    8,
+	})
+	if err != nil {
+		t.Fatalf("NewBucketGridManager: %v", err)
+	}
+	manager.QueueInsert(1000, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](0, 0), 2, 2)))
+
+	items := make([]BulkItem, 0, 100)
+	for i := 0; i < 100; i++ {
+		pos := geom.NewVec(uint32(i%10)*25, uint32(i/10)*25)
+		items = append(items, BulkItem{ID: uint64(i + 1), AABB: space.WrapAABB(geom.NewAABBAt(pos, 4, 4))})
+	}
+	manager.BulkInsert(items)
+
+	got := collectEntries(manager)
+	if len(got) != 101 {
+		t.Fatalf("entries = %d, want 101 (100 bulk + 1 queued)", len(got))
+	}
+	deltas := manager.ConsumeBucketDeltas()
+	if len(deltas) == 0 {
+		t.Fatal("BulkInsert recorded no bucket deltas")
+	}
+	hits := 0
+	manager.QueryRange(geom.NewAABBAt(geom.NewVec[uint32](0, 0), 256, 256), func(uint64) { hits++ })
+	if hits != 101 {
+		t.Errorf("QueryRange hits = %d, want 101", hits)
+	}
+}
+
+func benchmarkLoad(b *testing.B, bulk bool) {
+	space := plane.NewToroidal2D[uint32](4096, 4096)
+	items := make([]BulkItem, 100000)
+	for i := range items {
+		pos := geom.NewVec(uint32(i*37)%4096, uint32(i*91)%4096)
+		items[i] = BulkItem{ID: uint64(i + 1), AABB: space.WrapAABB(geom.NewAABBAt(pos, 8, 8))}
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		manager, err := NewBucketGridManager(space, GridLevelConfig{
+			Resoltuion:       spatial.NewResolution(12),
+			BucketResolution: spatial.Size64x64,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if bulk {
+			manager.BulkInsert(items)
+			continue
+		}
+		for i, item := range items {
+			manager.QueueInsert(item.ID, item.AABB)
+			if (i+1)%defaultOpsBufferSize == 0 {
+				manager.Flush()
+			}
+		}
+		manager.Flush()
+	}
+}
+
+func BenchmarkLoad_QueueInsert(b *testing.B) { benchmarkLoad(b, false) }
+func BenchmarkLoad_BulkInsert(b *testing.B)  { benchmarkLoad(b, true) }
+
+func queryLogicalIDs(m *BucketGridManager, rect spatial.AABB) map[uint64]struct{} {
+	out := make(map[uint64]struct{})
+	m.QueryRange(rect, func(entryID uint64) {
+		out[entryID>>2] = struct{}{}
+	})
+	return out
+}
+
+func TestBucketGridManager_TransferToMovesEntryAtOnce(t *testing.T) {
+	src, space := newTestManager(t)
+	dst, _ := newTestManager(t)
+	world := geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](256, 256))
+	src.QueueInsert(7, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4)))
+	src.Flush()
+	dst.QueueInsert(8, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](50, 50), 4, 4)))
+	src.ConsumeBucketDeltas()
+
+	src.TransferTo(dst, 7, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](100, 100), 4, 4)))
+	if got := queryLogicalIDs(src, world); len(got) != 0 {
+		t.Errorf("source still holds %v", got)
+	}
+	if got, want := queryLogicalIDs(dst, world), map[uint64]struct{}{7: {}, 8: {}}; !maps.Equal(got, want) {
+		t.Errorf("destination holds %v, want %v (queued entry 8 flushed first)", got, want)
+	}
+	if aabb, ok := dst.Entry(7); !ok || aabb != geom.NewAABBAt(geom.NewVec[uint32](100, 100), 4, 4) {
+		t.Errorf("destination entry = %v, %v", aabb, ok)
+	}
+	if removed := src.ConsumeBucketDeltas(); len(removed) != 1 || len(removed[0].Removed) != 1 {
+		t.Errorf("source deltas = %+v, want one removal", removed)
+	}
+
+	// Back again: the locks are taken in the same order either way.
+	dst.TransferTo(src, 7, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4)))
+	if _, ok := src.Entry(7); !ok {
+		t.Error("entry missing after transferring it back")
+	}
+	if _, ok := dst.QueuedEntry(7); ok {
+		t.Error("destination still reports the entry as queued")
+	}
+}
+
+func TestBucketGridManager_RebucketKeepsQueryResults(t *testing.T) {
+	manager, space := newTestManager(t)
+	for i := range uint32(40) {
+		pos := geom.NewVec((i*37)%256, (i*53)%256)
+		manager.QueueInsert(uint64(i+1), space.WrapAABB(geom.NewAABBAt(pos, 12, 9)))
+	}
+	manager.Flush()
+
+	rects := []spatial.AABB{
+		geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](64, 64)),
+		geom.NewAABB(geom.NewVec[uint32](100, 20), geom.NewVec[uint32](180, 200)),
+		geom.NewAABB(geom.NewVec[uint32](240, 240), geom.NewVec[uint32](256, 256)),
+	}
+	before := make([]map[uint64]struct{}, len(rects))
+	for i, rect := range rects {
+		before[i] = queryLogicalIDs(manager, rect)
+	}
+	manager.ConsumeBucketDeltas()
+
+	if err := manager.Rebucket(spatial.Size16x16); err != nil {
+		t.Fatalf("Rebucket: %v", err)
+	}
+	for i, rect := range rects {
+		if got := queryLogicalIDs(manager, rect); !maps.Equal(got, before[i]) {
+			t.Errorf("query %v after rebucket = %v, want %v", rect, got, before[i])
+		}
+	}
+	if got := len(collectEntries(manager)); got != 40 {
+		t.Errorf("expected 40 entries after rebucket, got %d", got)
+	}
+
+	var removed, added int
+	for _, delta := range manager.ConsumeBucketDeltas() {
+		size := delta.Bucket.BottomRight.X - delta.Bucket.TopLeft.X
+		switch {
+		case size == 32 && len(delta.Removed) > 0:
+			removed += len(delta.Removed)
+		case size == 16 && len(delta.Added) > 0:
+			added += len(delta.Added)
+		default:
+			t.Errorf("unexpected delta %+v", delta)
+		}
+	}
+	if removed == 0 || added == 0 {
+		t.Errorf("expected old buckets emptied and new ones filled, removed=%d added=%d", removed, added)
+	}
+
+	plan := manager.Plan(geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](64, 64)), 0)
+	if len(plan.BucketIndices) != 16 {
+		t.Errorf("expected all 16 view buckets dirty after rebucket, got %d", len(plan.BucketIndices))
+	}
+}
+
+func TestBucketGridManager_PlanBucketRectOutlivesRebucket(t *testing.T) {
+	manager, _ := newTestManager(t)
+	plan := manager.Plan(geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](64, 64)), 0)
+	if len(plan.BucketIndices) == 0 {
+		t.Fatal("first plan has no dirty buckets")
+	}
+	want := make([]geom.AABB[uint32], len(plan.BucketIndices))
+	for i, idx := range plan.BucketIndices {
+		want[i] = plan.BucketRect(idx)
+	}
+
+	// The renderer may still read an older plan while the writer rebuckets.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := manager.Rebucket(spatial.Size16x16); err != nil {
+			t.Errorf("Rebucket: %v", err)
+		}
+	}()
+	for range 100 {
+		for i, idx := range plan.BucketIndices {
+			if got := plan.BucketRect(idx); got != want[i] {
+				t.Fatalf("bucket %d rect = %v, want %v", idx, got, want[i])
+			}
+		}
+	}
+	<-done
+	for i, idx := range plan.BucketIndices {
+		if got := plan.BucketRect(idx); got != want[i] {
+			t.Fatalf("bucket %d rect after rebucket = %v, want %v", idx, got, want[i])
+		}
+	}
+}
+
+func TestBucketGridManager_RebucketRejectsInvalidResolution(t *testing.T) {
+	manager, space := newTestManager(t)
+	manager.QueueInsert(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 5, 5)))
+	manager.Flush()
+
+	if err := manager.Rebucket(spatial.Size512x512); err == nil {
+		t.Fatal("expected an error for buckets larger than the world")
+	}
+	if got := queryLogicalIDs(manager, geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](32, 32))); len(got) != 1 {
+		t.Errorf("manager changed after a failed rebucket: %v", got)
+	}
+}
+
+func TestNewBucketGridManager_ValidatesWorldAgainstBuckets(t *testing.T) {
+	cases := []struct {
+		name  string
+		space plane.Space2D[uint32]
+		ok    bool
+	}{
+		{"torus not a bucket multiple", plane.NewToroidal2D[uint32](1000, 1000), false},
+		{"plane not a bucket multiple", plane.NewEuclidean2D[uint32](1024, 1000), false},
+		{"plane larger than the grid", plane.NewEuclidean2D[uint32](2048, 2048), false},
+		{"torus smaller than the grid", plane.NewToroidal2D[uint32](512, 512), false},
+		{"plane smaller than the grid", plane.NewEuclidean2D[uint32](512, 512), true},
+		{"cylinder with a short bounded axis", NewWrapSpace(1024, 512, true, false), true},
+		{"cylinder with a short wrapping axis", NewWrapSpace(1024, 512, false, true), false},
+		{"torus matching the grid", plane.NewToroidal2D[uint32](1024, 1024), true},
+	}
+	for _, backend := range []Backend{BackendUniformGrid, BackendQuadTree} {
+		for _, tc := range cases {
+			_, err := NewBucketGridManager(tc.space, GridLevelConfig{
+				Resoltuion:       spatial.Size1024x1024,
+				BucketResolution: spatial.Size64x64,
+				BucketCapacity:   4,
+				Backend:          backend,
+			})
+			if (err == nil) != tc.ok {
+				t.Errorf("backend %d, %s: err = %v, want ok=%v", backend, tc.name, err, tc.ok)
+			}
+		}
+	}
+}
+
+func TestBucketGridManager_RebucketRejectsNonDividingBuckets(t *testing.T) {
+	space := plane.NewEuclidean2D[uint32](192, 192)
+	manager, err := NewBucketGridManager(space, GridLevelConfig{
+		Resoltuion:       spatial.Size256x256,
+		BucketResolution: spatial.Size32x32,
+		BucketCapacity:   4,
+	})
+	if err != nil {
+		t.Fatalf("NewBucketGridManager: %v", err)
+	}
+	if err := manager.Rebucket(spatial.Size128x128); err == nil {
+		t.Fatal("expected an error for buckets not dividing the world")
+	}
+	if err := manager.Rebucket(spatial.Size64x64); err != nil {
+		t.Fatalf("Rebucket: %v", err)
+	}
+}
+
+type recordingHook struct {
+	events []string
+	dirty  int
+}
+
+func (h *recordingHook) OnInsert(id uint64, _ spatial.AABB) {
+	h.events = append(h.events, fmt.Sprintf("insert %d", id))
+}
+
+func (h *recordingHook) OnRemove(id uint64) {
+	h.events = append(h.events, fmt.Sprintf("remove %d", id))
+}
+
+func (h *recordingHook) OnUpdate(id uint64, old, new spatial.AABB) {
+	h.events = append(h.events, fmt.Sprintf("update %d %v->%v", id, old.TopLeft, new.TopLeft))
+}
+
+func (h *recordingHook) OnDirty(spatial.AABB) {
+	h.dirty++
+}
+
+func TestBucketGridManager_HookReportsAppliedOps(t *testing.T) {
+	manager, space := newTestManager(t)
+	hook := &recordingHook{}
+	manager.SetHook(hook)
+
+	manager.QueueInsert(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 5, 5)))
+	if len(hook.events) != 0 {
+		t.Fatalf("hook called before Flush: %v", hook.events)
+	}
+	manager.Flush()
+	manager.QueueUpdate(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](40, 10), 5, 5)), true)
+	manager.QueueRemove(1)
+	manager.QueueRemove(99)
+	manager.Flush()
+
+	want := []string{"insert 1", "update 1 (10,10)->(40,10)", "remove 1"}
+	if !slices.Equal(hook.events, want) {
+		t.Fatalf("hook events = %v, want %v", hook.events, want)
+	}
+	if hook.dirty == 0 {
+		t.Error("expected OnDirty for the touched buckets")
+	}
+
+	manager.SetHook(nil)
+	manager.QueueInsert(2, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 5, 5)))
+	manager.Flush()
+	if len(hook.events) != len(want) {
+		t.Errorf("removed hook still called: %v", hook.events)
+	}
+}
+
+func TestBucketGridManager_OffsetWrapsOnTorusAndClampsOnPlane(t *testing.T) {
+	torus, _ := newTestManager(t)
+	aabb := geom.NewAABBAt(geom.NewVec[uint32](250, 4), 10, 10)
+	moved := torus.Offset(aabb, geom.NewVec(10, -6))
+	if want := geom.NewVec[uint32](4, 254); moved.TopLeft != want {
+		t.Errorf("torus offset top-left = %v, want %v", moved.TopLeft, want)
+	}
+
+	bounded, err := NewBucketGridManager(plane.NewEuclidean2D[uint32](256, 256), GridLevelConfig{
+		Resoltuion:       spatial.Size256x256,
+		BucketResolution: spatial.Size32x32,
+	})
+	if err != nil {
+		t.Fatalf("NewBucketGridManager: %v", err)
+	}
+	moved = bounded.Offset(aabb, geom.NewVec(10, -6))
+	if want := geom.NewVec[uint32](246, 0); moved.TopLeft != want {
+		t.Errorf("plane offset top-left = %v, want %v", moved.TopLeft, want)
+	}
+}
+
+func TestBucketGridManager_EntryReturnsFlushedUnion(t *testing.T) {
+	manager, space := newTestManager(t)
+	manager.QueueInsert(7, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4)))
+	if _, ok := manager.Entry(7); ok {
+		t.Fatal("entry visible before Flush")
+	}
+	manager.Flush()
+	aabb, ok := manager.Entry(7)
+	if want := geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4); !ok || aabb != want {
+		t.Fatalf("Entry = %v, %v; want %v", aabb, ok, want)
+	}
+}
+
+func TestBucketGridManager_QueuedEntryReturnsLastQueuedShape(t *testing.T) {
+	manager, space := newTestManager(t)
+	manager.QueueInsert(7, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4)))
+	manager.Flush()
+	manager.QueueUpdate(7, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](20, 10), 4, 4)), true)
+	manager.QueueUpdate(7, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](30, 10), 4, 4)), true)
+	aabb, ok := manager.QueuedEntry(7)
+	if want := geom.NewAABBAt(geom.NewVec[uint32](30, 10), 4, 4); !ok || aabb != want {
+		t.Fatalf("QueuedEntry = %v, %v; want %v", aabb, ok, want)
+	}
+	manager.Flush()
+	if flushed, ok := manager.QueuedEntry(7); !ok || flushed != aabb {
+		t.Fatalf("QueuedEntry after Flush = %v, %v; want %v", flushed, ok, aabb)
+	}
+	manager.QueueRemove(7)
+	if _, ok := manager.QueuedEntry(7); ok {
+		t.Fatal("QueuedEntry reports a removed entry")
+	}
+}
+
+func TestBucketGridManager_QueryContainedSkipsPartialOverlaps(t *testing.T) {
+	manager, space := newTestManager(t)
+	manager.QueueInsert(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 5, 5)))
+	manager.QueueInsert(2, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](28, 10), 5, 5)))
+	// Wrapped across the right seam into x in [0, 4).
+	manager.QueueInsert(3, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](250, 40), 10, 4)))
+	manager.Flush()
+
+	query := func(rect spatial.AABB) []uint64 {
+		var ids []uint64
+		manager.QueryContained(rect, func(id uint64) { ids = append(ids, id) })
+		slices.Sort(ids)
+		return ids
+	}
+	if got := query(geom.NewAABBAt(geom.NewVec[uint32](0, 0), 30, 30)); !slices.Equal(got, []uint64{1}) {
+		t.Errorf("contained = %v, want [1]", got)
+	}
+	// A rect crossing the seam holds both fragments of entry 3.
+	if got := query(geom.NewAABBAt(geom.NewVec[uint32](240, 30), 30, 20)); !slices.Equal(got, []uint64{3}) {
+		t.Errorf("contained across seam = %v, want [3]", got)
+	}
+	// Only the right half of entry 3 is inside.
+	if got := query(geom.NewAABBAt(geom.NewVec[uint32](0, 30), 20, 20)); len(got) != 0 {
+		t.Errorf("partially covered wrapped entry reported: %v", got)
+	}
+}
+
+func TestBucketGridManager_WrapRectSplitsAcrossSeams(t *testing.T) {
+	manager, _ := newTestManager(t)
+
+	got := manager.WrapRect(geom.NewAABB(geom.NewVec(-10, 250), geom.NewVec(20, 260)))
+	want := map[geom.AABB[int]]bool{
+		geom.NewAABB(geom.NewVec(246, 250), geom.NewVec(256, 256)): true,
+		geom.NewAABB(geom.NewVec(0, 250), geom.NewVec(20, 256)):    true,
+		geom.NewAABB(geom.NewVec(246, 0), geom.NewVec(256, 4)):     true,
+		geom.NewAABB(geom.NewVec(0, 0), geom.NewVec(20, 4)):        true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("fragments = %v, want %d pieces", got, len(want))
+	}
+	for _, frag := range got {
+		if !want[frag] {
+			t.Fatalf("unexpected fragment %v in %v", frag, got)
+		}
+	}
+
+	inside := manager.WrapRect(geom.NewAABB(geom.NewVec(10, 10), geom.NewVec(20, 30)))
+	if len(inside) != 1 || inside[0] != geom.NewAABB(geom.NewVec(10, 10), geom.NewVec(20, 30)) {
+		t.Fatalf("rect inside the world = %v", inside)
+	}
+	if empty := manager.WrapRect(geom.NewAABB(geom.NewVec(5, 5), geom.NewVec(5, 9))); empty != nil {
+		t.Fatalf("empty rect = %v, want nil", empty)
+	}
+}
+
+func TestBucketGridManager_WrapRectClipsOnBoundedPlane(t *testing.T) {
+	manager, err := NewBucketGridManager(plane.NewEuclidean2D[uint32](256, 256), GridLevelConfig{
+		Resoltuion:       spatial.Size256x256,
+		BucketResolution: spatial.Size32x32,
+		BucketCapacity:   4,
+	})
+	if err != nil {
+		t.Fatalf("NewBucketGridManager: %v", err)
+	}
+
+	got := manager.WrapRect(geom.NewAABB(geom.NewVec(-10, 250), geom.NewVec(20, 260)))
+	if len(got) != 1 || got[0] != geom.NewAABB(geom.NewVec(0, 250), geom.NewVec(20, 256)) {
+		t.Fatalf("clipped rect = %v", got)
+	}
+	if outside := manager.WrapRect(geom.NewAABB(geom.NewVec(300, 0), geom.NewVec(310, 5))); outside != nil {
+		t.Fatalf("rect outside the world = %v, want nil", outside)
+	}
+}
+
+func TestBucketGridManager_DuplicateInsertMovesEntry(t *testing.T) {
+	for _, backend := range []Backend{BackendUniformGrid, BackendQuadTree} {
+		space := plane.NewToroidal2D[uint32](256, 256)
+		manager, err := NewBucketGridManager(space, GridLevelConfig{
+			Resoltuion:       spatial.Size256x256,
+			BucketResolution: spatial.Size32x32,
+			BucketCapacity:   4,
+			Backend:          backend,
+		})
+		if err != nil {
+			t.Fatalf("backend %d: %v", backend, err)
+		}
+		oldRect := geom.NewAABBAt(geom.NewVec[uint32](10, 10), 5, 5)
+		newRect := geom.NewAABBAt(geom.NewVec[uint32](70, 70), 5, 5)
+
+		manager.QueueInsert(1, space.WrapAABB(oldRect))
+		manager.QueueInsert(1, space.WrapAABB(oldRect))
+		manager.Flush()
+		manager.QueueInsert(1, space.WrapAABB(newRect))
+		manager.Flush()
+
+		if got := collectEntries(manager); len(got) != 1 || got[1] != newRect {
+			t.Errorf("backend %d: entries = %v, want only 1 at %v", backend, got, newRect)
+		}
+		if got, ok := manager.EntryAABB(1 << 2); !ok || got != newRect {
+			t.Errorf("backend %d: EntryAABB = %v %v, want %v", backend, got, ok, newRect)
+		}
+		if got := queryIDs(manager, geom.NewAABBAt(geom.NewVec[uint32](0, 0), 32, 32)); len(got) != 0 {
+			t.Errorf("backend %d: old bucket still holds %v", backend, got)
+		}
+		if got := queryIDs(manager, geom.NewAABBAt(geom.NewVec[uint32](64, 64), 32, 32)); !slices.Equal(got, []uint64{1 << 2}) {
+			t.Errorf("backend %d: new bucket holds %v, want a single fragment", backend, got)
+		}
+
+		// After a remove, the ID can be inserted afresh.
+		manager.QueueRemove(1)
+		manager.QueueInsert(1, space.WrapAABB(oldRect))
+		manager.Flush()
+		if got := collectEntries(manager); len(got) != 1 || got[1] != oldRect {
+			t.Errorf("backend %d: entries after reinsert = %v", backend, got)
+		}
+	}
+}
+
+func TestBucketGridManager_MarkEntryUpdatedReportsUpdateInPlace(t *testing.T) {
+	for _, backend := range []Backend{BackendUniformGrid, BackendQuadTree} {
+		space := plane.NewToroidal2D[uint32](256, 256)
+		manager, err := NewBucketGridManager(space, GridLevelConfig{
+			Resoltuion:       spatial.Size256x256,
+			BucketResolution: spatial.Size32x32,
+			BucketCapacity:   4,
+			Backend:          backend,
+		})
+		if err != nil {
+			t.Fatalf("backend %d: %v", backend, err)
+		}
+		rect := geom.NewAABBAt(geom.NewVec[uint32](28, 10), 8, 4)
+		manager.QueueInsert(1, space.WrapAABB(rect))
+		manager.Flush()
+		manager.ConsumeBucketDeltas()
+		view := geom.NewAABBAt(geom.NewVec[uint32](0, 0), 64, 64)
+		manager.MarkBucketsRendered(manager.Plan(view, 0).BucketIndices)
+
+		manager.MarkEntryUpdated(1)
+		manager.MarkEntryUpdated(2)
+
+		var buckets []spatial.AABB
+		for _, delta := range manager.ConsumeBucketDeltas() {
+			if len(delta.Added) != 0 || len(delta.Removed) != 0 || !slices.Equal(delta.Updated, []uint64{1 << 2}) {
+				t.Errorf("backend %d: delta = %+v, want only an update of entry 1", backend, delta)
+			}
+			buckets = append(buckets, delta.Bucket)
+		}
+		want := []spatial.AABB{
+			geom.NewAABBAt(geom.NewVec[uint32](0, 0), 32, 32),
+			geom.NewAABBAt(geom.NewVec[uint32](32, 0), 32, 32),
+		}
+		if !slices.Equal(buckets, want) {
+			t.Errorf("backend %d: buckets = %v, want %v", backend, buckets, want)
+		}
+		if got, _ := manager.EntryAABB(1 << 2); got != rect {
+			t.Errorf("backend %d: entry moved to %v", backend, got)
+		}
+		dirty := manager.Plan(view, 0).BucketIndices
+		slices.Sort(dirty)
+		if !slices.Equal(dirty, []uint32{0, 1}) {
+			t.Errorf("backend %d: dirty buckets = %v, want [0 1]", backend, dirty)
+		}
+	}
+}
+
+func TestBucketGridManager_FrameProfileCoversFlushesSincePlan(t *testing.T) {
+	space := plane.NewToroidal2D[uint32](256, 256)
+	manager, err := NewBucketGridManager(space, GridLevelConfig{
+		Resoltuion:       spatial.Size256x256,
+		BucketResolution: spatial.Size32x32,
+		Profile:          true,
+	})
+	if err != nil {
+		t.Fatalf("NewBucketGridManager: %v", err)
+	}
+	view := geom.NewAABBAt(geom.NewVec[uint32](0, 0), 64, 64)
+
+	manager.QueueInsert(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4)))
+	manager.QueueInsert(2, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](40, 10), 4, 4)))
+	manager.Flush()
+	manager.BulkInsert([]BulkItem{{ID: 3, AABB: space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 40), 4, 4))}})
+	plan := manager.Plan(view, 1)
+
+	got := manager.FrameProfile()
+	if got.Ops != 3 || got.DirtyBuckets != len(plan.BucketIndices) || got.DirtyBuckets == 0 {
+		t.Fatalf("profile = %+v, want 3 ops and %d dirty buckets", got, len(plan.BucketIndices))
+	}
+	if got.Plan < got.CollectDirtyBuckets {
+		t.Errorf("plan time %v should include collect time %v", got.Plan, got.CollectDirtyBuckets)
+	}
+
+	manager.MarkBucketsRendered(plan.BucketIndices)
+	manager.Plan(view, 1)
+	if got := manager.FrameProfile(); got.Ops != 0 || got.DirtyBuckets != 0 || got.Flush != 0 {
+		t.Fatalf("idle frame profile = %+v, want no ops, buckets or flush time", got)
+	}
+
+	plain, _ := newTestManager(t)
+	plain.QueueInsert(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4)))
+	plain.Flush()
+	plain.Plan(view, 1)
+	if got := plain.FrameProfile(); got != (FrameProfile{}) {
+		t.Fatalf("unprofiled manager recorded %+v", got)
+	}
+}
@@ -0,0 +1,78 @@
+package grid
+
+import (
+	"slices"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
+
+// bucketSpan is the inclusive range of bucket cells one fragment covers,
+// computed like the index does (the bottom-right corner counts).
+type bucketSpan struct {
+	minX, minY, maxX, maxY uint32
+}
+
+// entryBuckets lists the bucket spans of an entry's fragments in visit order.
+// Two entries with equal entryBuckets sit in the same buckets.
+type entryBuckets struct {
+	spans [4]bucketSpan
+	n     int
+}
+
+func (m *BucketGridManager) entryBuckets(aabb spatial.AABB) entryBuckets {
+	var out entryBuckets
+	add := func(frag geom.AABB[uint32]) {
+		if out.n < len(out.spans) {
+			out.spans[out.n] = m.dirty.span(frag)
+			out.n++
+		}
+	}
+	wrapped := m.space.WrapAABB(aabb)
+	add(wrapped.AABB)
+	wrapped.VisitFragments(func(_ plane.FragPosition, frag geom.AABB[uint32]) bool {
+		add(frag)
+		return true
+	})
+	return out
+}
+
+func (d *dirtyState) span(aabb geom.AABB[uint32]) bucketSpan {
+	last := d.gridSide - 1
+	return bucketSpan{
+		minX: min(aabb.TopLeft.X>>d.bucketResolution, last),
+		minY: min(aabb.TopLeft.Y>>d.bucketResolution, last),
+		maxX: min(aabb.BottomRight.X>>d.bucketResolution, last),
+		maxY: min(aabb.BottomRight.Y>>d.bucketResolution, last),
+	}
+}
+
+// noteMoved records a bucket membership change of a logical entry when
+// GridLevelConfig.TrackMovedIDs is set.
+func (m *BucketGridManager) noteMoved(id uint64) {
+	if m.moved != nil {
+		m.moved[id] = struct{}{}
+	}
+}
+
+// ConsumeMovedIDs returns, sorted, the original IDs of the entries whose
+// bucket membership changed since the previous call: inserted, removed, or
+// updated into a different set of buckets. Updates within the same buckets
+// are not reported. It lets a collision pass re-check only the movers.
+// Tracking needs GridLevelConfig.TrackMovedIDs; without it the result is
+// always nil.
+func (m *BucketGridManager) ConsumeMovedIDs() []uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.moved) == 0 {
+		return nil
+	}
+	out := make([]uint64, 0, len(m.moved))
+	for id := range m.moved {
+		out = append(out, id)
+	}
+	clear(m.moved)
+	slices.Sort(out)
+	return out
+}
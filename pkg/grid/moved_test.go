@@ -0,0 +1,49 @@
+package grid
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+)
+
+func TestBucketGridManager_ConsumeMovedIDs(t *testing.T) {
+	manager, space := newTestManager(t, func(cfg *GridLevelConfig) { cfg.TrackMovedIDs = true })
+	rect := func(x, y uint32) plane.AABB[uint32] {
+		return space.WrapAABB(geom.NewAABBAt(geom.NewVec(x, y), 4, 4))
+	}
+	manager.QueueInsert(1, rect(2, 2))
+	manager.QueueInsert(2, rect(100, 100))
+	manager.QueueInsert(3, rect(250, 10))
+	manager.Flush()
+	if got := manager.ConsumeMovedIDs(); !slices.Equal(got, []uint64{1, 2, 3}) {
+		t.Fatalf("after insert = %v, want [1 2 3]", got)
+	}
+	if got := manager.ConsumeMovedIDs(); got != nil {
+		t.Fatalf("second consume = %v, want nil", got)
+	}
+
+	manager.QueueUpdate(1, rect(6, 6), true)     // same bucket
+	manager.QueueUpdate(2, rect(130, 100), true) // next bucket
+	manager.QueueUpdate(3, rect(254, 10), true)  // now also wraps to x=0
+	manager.Flush()
+	if got := manager.ConsumeMovedIDs(); !slices.Equal(got, []uint64{2, 3}) {
+		t.Fatalf("after update = %v, want [2 3]", got)
+	}
+
+	manager.QueueRemove(1)
+	manager.Flush()
+	if got := manager.ConsumeMovedIDs(); !slices.Equal(got, []uint64{1}) {
+		t.Fatalf("after remove = %v, want [1]", got)
+	}
+}
+
+func TestBucketGridManager_ConsumeMovedIDsNeedsTracking(t *testing.T) {
+	manager, space := newTestManager(t)
+	manager.QueueInsert(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](2, 2), 4, 4)))
+	manager.Flush()
+	if got := manager.ConsumeMovedIDs(); got != nil {
+		t.Errorf("untracked manager reported %v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package grid
 
 import (
+	"slices"
 	"sync"
 
 	"github.com/kjkrol/gokg/pkg/geom"
@@ -10,9 +11,18 @@ import (
 
 type GridLevelPlan struct {
 	Key uint64
+	// ViewRect is the part of the world the layer shows; it differs from the
+	// frame's ViewRect for parallax layers (see BuildFrameLayers).
+	ViewRect spatial.AABB
 	BucketPlan
 }
 
+// LayerView pairs a layer key with the world rect the layer shows this frame.
+type LayerView struct {
+	Key      uint64
+	ViewRect spatial.AABB
+}
+
 type FramePlan struct {
 	ViewRect       spatial.AABB
 	ViewChanged    bool
@@ -66,6 +76,9 @@ func (m *MultiBucketGridManager) Register(key uint64, cfg GridLevelConfig) (*Buc
 	if cfg.BucketCapacity == 0 {
 		cfg.BucketCapacity = m.defaultBucketCapacity
 	}
+	if cfg.MarginBuckets <= 0 {
+		cfg.MarginBuckets = m.marginBuckets
+	}
 	manager, err := NewBucketGridManager(m.space, cfg)
 	if err != nil {
 		return nil, err
@@ -76,6 +89,18 @@ func (m *MultiBucketGridManager) Register(key uint64, cfg GridLevelConfig) (*Buc
 	return manager, nil
 }
 
+// Unregister drops the manager of key, e.g. when its layer is removed, and
+// reports whether one was registered. Frames no longer plan the key.
+func (m *MultiBucketGridManager) Unregister(key uint64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.managers[key]; !ok {
+		return false
+	}
+	delete(m.managers, key)
+	return true
+}
+
 func (m *MultiBucketGridManager) Manager(key uint64) *BucketGridManager {
 	m.mu.RLock()
 	manager := m.managers[key]
@@ -83,53 +108,123 @@ func (m *MultiBucketGridManager) Manager(key uint64) *BucketGridManager {
 	return manager
 }
 
+// MarginBuckets returns the default margin applied to layers registered
+// without their own GridLevelConfig.MarginBuckets.
 func (m *MultiBucketGridManager) MarginBuckets() int {
 	return m.marginBuckets
 }
 
-func (m *MultiBucketGridManager) BuildFrame(viewRect spatial.AABB, viewChanged bool, keys []uint64) FramePlan {
-	gridLevels := make([]GridLevelPlan, 0, len(keys))
+// CacheRect returns the padded region last planned for the layer key.
+func (m *MultiBucketGridManager) CacheRect(key uint64) (spatial.AABB, bool) {
+	manager := m.Manager(key)
+	if manager == nil {
+		return spatial.AABB{}, false
+	}
+	return manager.CacheRect()
+}
+
+// QueryRangeAll runs QueryRange against every registered layer manager in
+// ascending key order and reports each matching entry ID with its layer key.
+// Entry IDs are reported per fragment, exactly as BucketGridManager.QueryRange.
+func (m *MultiBucketGridManager) QueryRangeAll(rect spatial.AABB, collector func(key uint64, entryID uint64)) {
+	if collector == nil {
+		return
+	}
+	m.mu.RLock()
+	keys := make([]uint64, 0, len(m.managers))
+	for key := range m.managers {
+		keys = append(keys, key)
+	}
+	m.mu.RUnlock()
+	slices.Sort(keys)
+
 	for _, key := range keys {
 		manager := m.Manager(key)
 		if manager == nil {
 			continue
 		}
-		plan := manager.Plan(viewRect, m.marginBuckets)
-		gridLevels = append(gridLevels, GridLevelPlan{Key: key, BucketPlan: plan})
+		manager.QueryRange(rect, func(entryID uint64) {
+			collector(key, entryID)
+		})
+	}
+}
+
+// MarkAllDirty marks every bucket of every registered layer manager dirty.
+func (m *MultiBucketGridManager) MarkAllDirty() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, manager := range m.managers {
+		manager.MarkAllDirty()
+	}
+}
+
+// FrameProfile sums the FrameProfile of every registered layer manager;
+// layers registered without GridLevelConfig.Profile contribute nothing.
+func (m *MultiBucketGridManager) FrameProfile() FrameProfile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var profile FrameProfile
+	for _, manager := range m.managers {
+		profile.Add(manager.FrameProfile())
+	}
+	return profile
+}
+
+func (m *MultiBucketGridManager) BuildFrame(viewRect spatial.AABB, viewChanged bool, keys []uint64) FramePlan {
+	layers := make([]LayerView, 0, len(keys))
+	for _, key := range keys {
+		layers = append(layers, LayerView{Key: key, ViewRect: viewRect})
+	}
+	return m.BuildFrameLayers(viewRect, viewChanged, layers)
+}
+
+// BuildFrameLayers is BuildFrame with a view rect per layer, e.g. for
+// parallax. Each layer is planned around its own rect and its dirty buckets
+// are mapped to composite rects relative to it; viewRect is the pane's view.
+// Every rect must have the size of viewRect.
+func (m *MultiBucketGridManager) BuildFrameLayers(viewRect spatial.AABB, viewChanged bool, layers []LayerView) FramePlan {
+	gridLevels := make([]GridLevelPlan, 0, len(layers))
+	for _, layer := range layers {
+		manager := m.Manager(layer.Key)
+		if manager == nil {
+			continue
+		}
+		plan := manager.Plan(layer.ViewRect, manager.MarginBuckets())
+		gridLevels = append(gridLevels, GridLevelPlan{Key: layer.Key, ViewRect: layer.ViewRect, BucketPlan: plan})
 	}
 
 	composite := make([]spatial.AABB, 0, 16)
-	if viewChanged {
-		viewSize := rectSize(viewRect)
-		if viewSize.X > 0 && viewSize.Y > 0 {
-			composite = append(composite, geom.NewAABBAt(geom.NewVec[uint32](0, 0), viewSize.X, viewSize.Y))
+	viewSize := rectSize(viewRect)
+	fullView := func() []spatial.AABB {
+		if viewSize.X == 0 || viewSize.Y == 0 {
+			return composite[:0]
 		}
+		return append(composite[:0], geom.NewAABBAt(geom.NewVec[uint32](0, 0), viewSize.X, viewSize.Y))
+	}
+	if viewChanged {
+		composite = fullView()
 	} else {
-		viewOrigin := viewRect.TopLeft
-		worldSide := m.worldSideForView()
-		if worldSide > 0 {
-			viewSize := rectSize(viewRect)
-			if viewSize.X >= worldSide && viewSize.Y >= worldSide {
-				worldSide = 0
-			}
-		}
+		world := viewWorld(m.worldForView(), viewSize)
 		for _, gridLevel := range gridLevels {
 			if gridLevel.BucketRect == nil {
 				continue
 			}
 			for _, idx := range gridLevel.BucketIndices {
 				bucket := gridLevel.BucketRect(idx)
-				clipped, ok := intersectWithView(m.space, bucket, viewRect)
+				clipped, ok := intersectWithView(m.space, bucket, gridLevel.ViewRect)
 				if !ok {
 					continue
 				}
-				viewRectLocal := toViewRect(clipped, viewOrigin, worldSide)
+				viewRectLocal := toViewRect(clipped, gridLevel.ViewRect.TopLeft, world)
 				if rectEmpty(viewRectLocal) {
 					continue
 				}
 				composite = append(composite, viewRectLocal)
 			}
 		}
+		if composite = coalesceRects(composite); len(composite) > maxCompositeRects {
+			composite = fullView()
+		}
 	}
 
 	return FramePlan{
@@ -144,14 +239,19 @@ func rectSize(rect spatial.AABB) geom.Vec[uint32] {
 	return geom.NewVec(rect.BottomRight.X-rect.TopLeft.X, rect.BottomRight.Y-rect.TopLeft.Y)
 }
 
-func (m *MultiBucketGridManager) worldSideForView() uint32 {
-	if m.space == nil {
-		return 0
-	}
-	if m.space.Name() != "Toroidal2D" {
-		return 0
+// worldForView returns the world size on the wrapping axes and zero on the
+// others.
+func (m *MultiBucketGridManager) worldForView() geom.Vec[uint32] {
+	return spaceWorld(m.space, m.resoltuion.Side())
+}
+
+// viewWorld drops the wrap when the view covers the world on every wrapping
+// axis; the view then maps without seams.
+func viewWorld(world, viewSize geom.Vec[uint32]) geom.Vec[uint32] {
+	if (world.X == 0 || viewSize.X >= world.X) && (world.Y == 0 || viewSize.Y >= world.Y) {
+		return geom.Vec[uint32]{}
 	}
-	return m.resoltuion.Side()
+	return world
 }
 
 func intersectWithView(space plane.Space2D[uint32], bucket, viewRect spatial.AABB) (spatial.AABB, bool) {
@@ -178,11 +278,11 @@ func wrapViewRect(space plane.Space2D[uint32], viewRect spatial.AABB) []spatial.
 	return out
 }
 
-func toViewRect(rect spatial.AABB, viewOrigin geom.Vec[uint32], worldSide uint32) spatial.AABB {
-	x0 := mapCoord(rect.TopLeft.X, viewOrigin.X, worldSide)
-	y0 := mapCoord(rect.TopLeft.Y, viewOrigin.Y, worldSide)
-	x1 := mapCoord(rect.BottomRight.X, viewOrigin.X, worldSide)
-	y1 := mapCoord(rect.BottomRight.Y, viewOrigin.Y, worldSide)
+func toViewRect(rect spatial.AABB, viewOrigin, world geom.Vec[uint32]) spatial.AABB {
+	x0 := mapCoord(rect.TopLeft.X, viewOrigin.X, world.X)
+	y0 := mapCoord(rect.TopLeft.Y, viewOrigin.Y, world.Y)
+	x1 := mapCoord(rect.BottomRight.X, viewOrigin.X, world.X)
+	y1 := mapCoord(rect.BottomRight.Y, viewOrigin.Y, world.Y)
 	return geom.NewAABB(geom.NewVec(x0, y0), geom.NewVec(x1, y1))
 }
 
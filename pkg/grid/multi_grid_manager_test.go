@@ -0,0 +1,222 @@
+package grid
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
+
+func TestMultiBucketGridManager_PerLayerMargin(t *testing.T) {
+	space := plane.NewEuclidean2D[uint32](256, 256)
+	multi := NewMultiBucketGridManager(space, spatial.Size256x256, 1, spatial.Size32x32, 4)
+	if _, err := multi.Register(0, GridLevelConfig{}); err != nil {
+		t.Fatalf("Register(0): %v", err)
+	}
+	if _, err := multi.Register(1, GridLevelConfig{MarginBuckets: 2}); err != nil {
+		t.Fatalf("Register(1): %v", err)
+	}
+	if _, ok := multi.CacheRect(0); ok {
+		t.Fatal("CacheRect should be unset before the first frame")
+	}
+
+	view := geom.NewAABBAt(geom.NewVec[uint32](96, 96), 64, 64)
+	multi.BuildFrame(view, true, []uint64{0, 1})
+
+	if got := multi.Manager(0).MarginBuckets(); got != 1 {
+		t.Errorf("default margin = %d, want 1", got)
+	}
+	want := map[uint64]spatial.AABB{
+		0: geom.NewAABB(geom.NewVec[uint32](64, 64), geom.NewVec[uint32](192, 192)),
+		1: geom.NewAABB(geom.NewVec[uint32](32, 32), geom.NewVec[uint32](224, 224)),
+	}
+	for key, rect := range want {
+		got, ok := multi.CacheRect(key)
+		if !ok || got != rect {
+			t.Errorf("layer %d cache rect = %v (ok=%v), want %v", key, got, ok, rect)
+		}
+	}
+}
+
+func TestMultiBucketGridManager_QueryRangeAll(t *testing.T) {
+	space := plane.NewEuclidean2D[uint32](256, 256)
+	multi := NewMultiBucketGridManager(space, spatial.Size256x256, 1, spatial.Size32x32, 4)
+	for key := uint64(1); key <= 3; key++ {
+		manager, err := multi.Register(key, GridLevelConfig{})
+		if err != nil {
+			t.Fatalf("Register(%d): %v", key, err)
+		}
+		manager.QueueInsert(key*10, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](uint32(key)*20, 20), 5, 5)))
+		manager.QueueInsert(key*10+1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](200, 200), 5, 5)))
+		manager.Flush()
+	}
+
+	type hit struct{ key, id uint64 }
+	var got []hit
+	rect := geom.NewAABBAt(geom.NewVec[uint32](0, 0), 50, 50)
+	multi.QueryRangeAll(rect, func(key uint64, entryID uint64) {
+		got = append(got, hit{key, entryID >> 2})
+	})
+
+	want := []hit{{1, 10}, {2, 20}}
+	if len(got) != len(want) {
+		t.Fatalf("hits = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("hit %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMultiBucketGridManager_BuildFrameLayersPlansEachLayerView(t *testing.T) {
+	space := plane.NewEuclidean2D[uint32](256, 256)
+	multi := NewMultiBucketGridManager(space, spatial.Size256x256, 1, spatial.Size32x32, 4)
+	for key := uint64(0); key <= 1; key++ {
+		if _, err := multi.Register(key, GridLevelConfig{MarginBuckets: 1}); err != nil {
+			t.Fatalf("Register(%d): %v", key, err)
+		}
+	}
+	view := geom.NewAABBAt(geom.NewVec[uint32](128, 128), 64, 64)
+	background := geom.NewAABBAt(geom.NewVec[uint32](64, 64), 64, 64)
+	first := multi.BuildFrameLayers(view, true, []LayerView{{Key: 0, ViewRect: view}, {Key: 1, ViewRect: background}})
+	for _, level := range first.GridLevels {
+		multi.Manager(level.Key).MarkBucketsRendered(level.BucketIndices)
+	}
+
+	// Dirty one bucket of the background layer: its composite rect is
+	// relative to the layer's own view, not the pane view.
+	multi.Manager(1).MarkRectDirty(geom.NewAABBAt(geom.NewVec[uint32](64, 64), 1, 1))
+	frame := multi.BuildFrameLayers(view, false, []LayerView{{Key: 0, ViewRect: view}, {Key: 1, ViewRect: background}})
+
+	if got := frame.GridLevels[1].ViewRect; got != background {
+		t.Fatalf("layer 1 view = %v, want %v", got, background)
+	}
+	want := geom.NewAABBAt(geom.NewVec[uint32](0, 0), 32, 32)
+	if len(frame.CompositeRects) != 1 || frame.CompositeRects[0] != want {
+		t.Fatalf("composite rects = %v, want [%v]", frame.CompositeRects, want)
+	}
+}
+
+func TestCoalesceRects_MergesOnlyExactUnions(t *testing.T) {
+	bucket := func(x, y uint32) spatial.AABB {
+		return geom.NewAABBAt(geom.NewVec(x*32, y*32), 32, 32)
+	}
+	block := coalesceRects([]spatial.AABB{bucket(1, 1), bucket(0, 0), bucket(1, 0), bucket(0, 1), bucket(0, 0)})
+	if want := geom.NewAABBAt(geom.NewVec[uint32](0, 0), 64, 64); len(block) != 1 || block[0] != want {
+		t.Fatalf("2x2 block coalesced to %v, want [%v]", block, want)
+	}
+
+	rects := []spatial.AABB{
+		bucket(1, 0), bucket(0, 0), bucket(0, 1), bucket(1, 1), // a 2x2 block
+		bucket(0, 0), // the same bucket dirty in another layer
+		bucket(6, 6), // a far-apart change
+		bucket(2, 1), // extends the lower row, so the rows no longer stack
+	}
+	got := coalesceRects(rects)
+	want := []spatial.AABB{
+		geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](64, 32)),
+		geom.NewAABB(geom.NewVec[uint32](0, 32), geom.NewVec[uint32](96, 64)),
+		bucket(6, 6),
+	}
+	slices.SortFunc(got, func(a, b spatial.AABB) int {
+		return cmp.Or(cmp.Compare(a.TopLeft.Y, b.TopLeft.Y), cmp.Compare(a.TopLeft.X, b.TopLeft.X))
+	})
+	if !slices.Equal(got, want) {
+		t.Fatalf("coalesced = %v, want %v", got, want)
+	}
+}
+
+func TestMultiBucketGridManager_ManyCompositeRectsFallBackToFullView(t *testing.T) {
+	space := plane.NewEuclidean2D[uint32](256, 256)
+	multi := NewMultiBucketGridManager(space, spatial.Size256x256, 1, spatial.Size8x8, 4)
+	if _, err := multi.Register(0, GridLevelConfig{}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	view := geom.NewAABBAt(geom.NewVec[uint32](0, 0), 256, 256)
+	layers := []LayerView{{Key: 0, ViewRect: view}}
+	first := multi.BuildFrameLayers(view, true, layers)
+	multi.Manager(0).MarkBucketsRendered(first.GridLevels[0].BucketIndices)
+
+	// A checkerboard of dirty buckets cannot be merged.
+	for y := uint32(0); y < 16; y += 2 {
+		for x := uint32(0); x < 16; x += 2 {
+			multi.Manager(0).MarkRectDirty(geom.NewAABBAt(geom.NewVec(x*8+1, y*8+1), 1, 1))
+		}
+	}
+	frame := multi.BuildFrameLayers(view, false, layers)
+	if len(frame.CompositeRects) != 1 || frame.CompositeRects[0] != view {
+		t.Fatalf("composite rects = %v, want the full view", frame.CompositeRects)
+	}
+}
+
+// Renderers clear and redraw only the planned bucket indices, so a static
+// overlay must plan nothing once its buckets have been rendered.
+func TestMultiBucketGridManager_StaticLayerPlansNoBucketRedraws(t *testing.T) {
+	space := plane.NewEuclidean2D[uint32](256, 256)
+	multi := NewMultiBucketGridManager(space, spatial.Size256x256, 1, spatial.Size32x32, 4)
+	overlay, err := multi.Register(0, GridLevelConfig{})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	overlay.QueueInsert(1, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](100, 100), 4, 4)))
+	overlay.Flush()
+
+	view := geom.NewAABBAt(geom.NewVec[uint32](98, 98), 48, 48)
+	first := multi.BuildFrameLayers(view, true, []LayerView{{Key: 0, ViewRect: view}})
+	if len(first.GridLevels[0].BucketIndices) == 0 {
+		t.Fatal("first frame should draw every bucket of the cache rect")
+	}
+	overlay.MarkBucketsRendered(first.GridLevels[0].BucketIndices)
+
+	idle := multi.BuildFrameLayers(view, false, []LayerView{{Key: 0, ViewRect: view}})
+	if got := idle.GridLevels[0].BucketIndices; len(got) != 0 {
+		t.Fatalf("unchanged frame redraws buckets %v", got)
+	}
+	if len(idle.CompositeRects) != 0 {
+		t.Fatalf("unchanged frame composites %v", idle.CompositeRects)
+	}
+
+	// Panning within the same buckets recomposites but redraws none.
+	panned := geom.NewAABBAt(geom.NewVec[uint32](104, 104), 48, 48)
+	moved := multi.BuildFrameLayers(panned, true, []LayerView{{Key: 0, ViewRect: panned}})
+	if got := moved.GridLevels[0].BucketIndices; len(got) != 0 {
+		t.Fatalf("panned frame redraws buckets %v", got)
+	}
+
+	overlay.QueueInsert(2, space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](130, 70), 2, 2)))
+	overlay.Flush()
+	changed := multi.BuildFrameLayers(panned, false, []LayerView{{Key: 0, ViewRect: panned}})
+	got := changed.GridLevels[0].BucketIndices
+	if len(got) != 1 || changed.GridLevels[0].BucketRect(got[0]) != geom.NewAABBAt(geom.NewVec[uint32](128, 64), 32, 32) {
+		t.Fatalf("insert redraws buckets %v, want only the bucket at (128,64)", got)
+	}
+}
+
+func TestMultiBucketGridManager_UnregisterDropsLayer(t *testing.T) {
+	space := plane.NewEuclidean2D[uint32](256, 256)
+	multi := NewMultiBucketGridManager(space, spatial.Size256x256, 1, spatial.Size32x32, 4)
+	for key := uint64(0); key <= 1; key++ {
+		if _, err := multi.Register(key, GridLevelConfig{}); err != nil {
+			t.Fatalf("Register(%d): %v", key, err)
+		}
+	}
+
+	if !multi.Unregister(1) {
+		t.Fatal("Unregister(1) should report the registered manager")
+	}
+	if multi.Unregister(1) {
+		t.Fatal("a second Unregister(1) should report nothing")
+	}
+	if multi.Manager(1) != nil {
+		t.Fatal("Manager(1) should be gone")
+	}
+	view := geom.NewAABBAt(geom.NewVec[uint32](0, 0), 64, 64)
+	frame := multi.BuildFrame(view, true, []uint64{0, 1})
+	if len(frame.GridLevels) != 1 || frame.GridLevels[0].Key != 0 {
+		t.Fatalf("planned levels = %v, want only layer 0", frame.GridLevels)
+	}
+}
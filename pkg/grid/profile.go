@@ -0,0 +1,46 @@
+package grid
+
+import "time"
+
+// FrameProfile holds the grid's share of one frame, recorded when
+// GridLevelConfig.Profile is set. A frame spans every Flush since the
+// previous Plan plus the Plan itself.
+type FrameProfile struct {
+	// Flush is the time Flush and BulkInsert spent applying ops to the index.
+	Flush time.Duration
+	// Plan is the time Plan took, CollectDirtyBuckets included.
+	Plan time.Duration
+	// CollectDirtyBuckets is the time Plan spent picking the dirty buckets
+	// inside the cache rect.
+	CollectDirtyBuckets time.Duration
+	// Ops is the number of queued or bulk-inserted ops applied.
+	Ops int
+	// DirtyBuckets is the number of bucket indices Plan returned.
+	DirtyBuckets int
+}
+
+// Add accumulates other into p, e.g. to sum the layers of a pane.
+func (p *FrameProfile) Add(other FrameProfile) {
+	p.Flush += other.Flush
+	p.Plan += other.Plan
+	p.CollectDirtyBuckets += other.CollectDirtyBuckets
+	p.Ops += other.Ops
+	p.DirtyBuckets += other.DirtyBuckets
+}
+
+// FrameProfile returns the profile of the frame completed by the last Plan.
+// It is zero unless GridLevelConfig.Profile is set.
+func (m *BucketGridManager) FrameProfile() FrameProfile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lastProfile
+}
+
+// profileStart reads the clock only when profiling is on, so a disabled
+// manager pays nothing for it.
+func (m *BucketGridManager) profileStart() time.Time {
+	if !m.cfg.Profile {
+		return time.Time{}
+	}
+	return time.Now()
+}
@@ -1,50 +1,37 @@
 package grid
 
 import (
+	"cmp"
+	"slices"
+
 	"github.com/kjkrol/gokg/pkg/geom"
 	"github.com/kjkrol/gokg/pkg/spatial"
 )
 
-func cacheRectForView(viewRect spatial.AABB, bucketSize uint32, marginBuckets int, worldSize uint32) spatial.AABB {
+// cacheRectForView aligns viewRect to buckets and pads it by marginBuckets.
+// world holds the world size on the wrapping axes and zero on the others: a
+// wrapping axis extends past the seam, the others stop at 0.
+func cacheRectForView(viewRect spatial.AABB, bucketSize uint32, marginBuckets int, world geom.Vec[uint32]) spatial.AABB {
 	if bucketSize == 0 {
 		return viewRect
 	}
 	margin := bucketSize * uint32(marginBuckets)
-	minX := alignDown(viewRect.TopLeft.X, bucketSize)
-	minY := alignDown(viewRect.TopLeft.Y, bucketSize)
-	maxX := alignUp(viewRect.BottomRight.X, bucketSize)
-	maxY := alignUp(viewRect.BottomRight.Y, bucketSize)
-	if margin != 0 {
-		if worldSize == 0 {
-			if minX > margin {
-				minX -= margin
-			} else {
-				minX = 0
-			}
-			if minY > margin {
-				minY -= margin
-			} else {
-				minY = 0
-			}
-		} else {
-			minX -= margin
-			minY -= margin
-		}
-		maxX += margin
-		maxY += margin
-	}
-	width := maxX - minX
-	height := maxY - minY
-	originX := minX
-	originY := minY
-	if worldSize > 0 {
-		originX = wrapUint(originX, worldSize)
-		originY = wrapUint(originY, worldSize)
+	minX, maxX := cacheSpan(viewRect.TopLeft.X, viewRect.BottomRight.X, bucketSize, margin, world.X)
+	minY, maxY := cacheSpan(viewRect.TopLeft.Y, viewRect.BottomRight.Y, bucketSize, margin, world.Y)
+	return geom.NewAABB(geom.NewVec(minX, minY), geom.NewVec(maxX, maxY))
+}
+
+// cacheSpan aligns and pads one axis of a view. With a world size the padded
+// span starts wrapped into the world; without one it stops at 0.
+func cacheSpan(lo, hi, bucketSize, margin, worldSize uint32) (uint32, uint32) {
+	lo = alignDown(lo, bucketSize)
+	hi = alignUp(hi, bucketSize) + margin
+	if worldSize == 0 {
+		return lo - min(lo, margin), hi
 	}
-	return geom.NewAABB(
-		geom.NewVec(originX, originY),
-		geom.NewVec(originX+width, originY+height),
-	)
+	lo -= margin
+	origin := wrapUint(lo, worldSize)
+	return origin, origin + hi - lo
 }
 
 func rectEquals(a, b spatial.AABB) bool {
@@ -115,3 +102,56 @@ func wrapUint(val, size uint32) uint32 {
 	}
 	return val % size
 }
+
+// maxCompositeRects caps the composite rects of a frame; past it the whole
+// view is recomposited, which beats many small passes.
+const maxCompositeRects = 32
+
+// coalesceRects merges rects whose union is itself a rect: first rects on the
+// same rows that touch or overlap horizontally, then the resulting strips in
+// the same columns that touch or overlap vertically. Duplicates, e.g. a
+// bucket dirty in several layers, collapse too. Rects that would only merge
+// into a larger bounding box, such as two far-apart changes, stay separate.
+// rects is reordered and reused.
+func coalesceRects(rects []spatial.AABB) []spatial.AABB {
+	rects = mergeRuns(rects, func(v geom.Vec[uint32]) (run, span uint32) { return v.X, v.Y })
+	return mergeRuns(rects, func(v geom.Vec[uint32]) (run, span uint32) { return v.Y, v.X })
+}
+
+// mergeRuns merges rects with equal spans on one axis whose extents on the
+// other, run, axis touch or overlap. axis splits a corner into its run and
+// span coordinates.
+func mergeRuns(rects []spatial.AABB, axis func(geom.Vec[uint32]) (run, span uint32)) []spatial.AABB {
+	slices.SortFunc(rects, func(a, b spatial.AABB) int {
+		aRun, aLo := axis(a.TopLeft)
+		_, aHi := axis(a.BottomRight)
+		bRun, bLo := axis(b.TopLeft)
+		_, bHi := axis(b.BottomRight)
+		return cmp.Or(cmp.Compare(aLo, bLo), cmp.Compare(aHi, bHi), cmp.Compare(aRun, bRun))
+	})
+	out := rects[:0]
+	for _, rect := range rects {
+		if n := len(out); n > 0 && sameSpan(out[n-1], rect, axis) {
+			last := &out[n-1]
+			lastEnd, _ := axis(last.BottomRight)
+			start, _ := axis(rect.TopLeft)
+			end, _ := axis(rect.BottomRight)
+			if start <= lastEnd {
+				if end > lastEnd {
+					last.BottomRight = rect.BottomRight
+				}
+				continue
+			}
+		}
+		out = append(out, rect)
+	}
+	return out
+}
+
+func sameSpan(a, b spatial.AABB, axis func(geom.Vec[uint32]) (run, span uint32)) bool {
+	_, aLo := axis(a.TopLeft)
+	_, aHi := axis(a.BottomRight)
+	_, bLo := axis(b.TopLeft)
+	_, bHi := axis(b.BottomRight)
+	return aLo == bLo && aHi == bHi
+}
@@ -0,0 +1,144 @@
+package grid
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
+
+// subpixelShape is the fixed-point placement of an entry: the fractional part
+// of its top-left corner and its full size, both in units of
+// 1/2^SubpixelBits world unit. The whole-unit part of the corner is the
+// entry's indexed AABB.
+type subpixelShape struct {
+	offset geom.Vec[uint32]
+	size   geom.Vec[uint32]
+}
+
+func validateSubpixelBits(space plane.Space2D[uint32], bits uint8) error {
+	if bits == 0 {
+		return nil
+	}
+	size := space.Viewport().BottomRight
+	side := uint64(max(size.X, size.Y))
+	if bits >= 32 || side<<bits > math.MaxUint32 {
+		return fmt.Errorf("subpixel bits %d overflow a %d world", bits, side)
+	}
+	return nil
+}
+
+// ToSubpixel converts a world coordinate to fixed-point units with bits
+// fractional bits, rounding to the nearest unit. Negative values clamp to 0.
+func ToSubpixel(v float64, bits uint8) uint32 {
+	scaled := math.Round(v * float64(uint64(1)<<bits))
+	return uint32(min(max(scaled, 0), math.MaxUint32))
+}
+
+// FromSubpixel converts fixed-point units back to world units.
+func FromSubpixel(v uint32, bits uint8) float64 {
+	return float64(v) / float64(uint64(1)<<bits)
+}
+
+// ScaledAABB returns the fixed-point AABB of a rect given in fractional world
+// units by its top-left corner and size.
+func ScaledAABB(x, y, w, h float64, bits uint8) spatial.AABB {
+	minX, minY := ToSubpixel(x, bits), ToSubpixel(y, bits)
+	return geom.NewAABB(
+		geom.NewVec(minX, minY),
+		geom.NewVec(minX+ToSubpixel(w, bits), minY+ToSubpixel(h, bits)),
+	)
+}
+
+// SubpixelBits returns the fractional bits of the scaled queue methods.
+func (m *BucketGridManager) SubpixelBits() uint8 {
+	return m.cfg.SubpixelBits
+}
+
+// QueueInsertScaled queues an insert of an AABB given in fixed-point units
+// (world units << SubpixelBits). The entry is indexed under the smallest
+// whole-unit AABB covering it, which is returned wrapped into the space so
+// callers can keep a Drawable's AABB in sync; EntrySubpixelAABB reports the
+// exact placement once flushed.
+func (m *BucketGridManager) QueueInsertScaled(id uint64, scaled spatial.AABB) plane.AABB[uint32] {
+	if m.index == nil {
+		return plane.AABB[uint32]{}
+	}
+	shape, sub := m.scaledShape(scaled)
+	aabb := planeAABBToSpatial(shape)
+	m.queueInsert(id, aabb)
+	m.appendPending(entryOp{id: id, aabb: aabb, scaled: true, subpixel: sub})
+	return shape
+}
+
+// QueueUpdateScaled is QueueUpdate for an AABB in fixed-point units; see
+// QueueInsertScaled. A later QueueUpdate or QueueInsert with a whole-unit
+// AABB drops the sub-pixel placement.
+func (m *BucketGridManager) QueueUpdateScaled(id uint64, scaled spatial.AABB, markDirty bool) plane.AABB[uint32] {
+	if m.index == nil {
+		return plane.AABB[uint32]{}
+	}
+	shape, sub := m.scaledShape(scaled)
+	aabb := planeAABBToSpatial(shape)
+	m.setQueued(id, true)
+	m.index.QueueUpdate(id, aabb, markDirty)
+	m.appendPending(entryOp{id: id, aabb: aabb, scaled: true, subpixel: sub})
+	return shape
+}
+
+func (m *BucketGridManager) scaledShape(scaled spatial.AABB) (plane.AABB[uint32], subpixelShape) {
+	bits := m.cfg.SubpixelBits
+	mask := uint32(1)<<bits - 1
+	world := geom.NewAABB(
+		geom.NewVec(scaled.TopLeft.X>>bits, scaled.TopLeft.Y>>bits),
+		geom.NewVec((scaled.BottomRight.X+mask)>>bits, (scaled.BottomRight.Y+mask)>>bits),
+	)
+	sub := subpixelShape{
+		offset: geom.NewVec(scaled.TopLeft.X&mask, scaled.TopLeft.Y&mask),
+		size: geom.NewVec(
+			scaled.BottomRight.X-scaled.TopLeft.X,
+			scaled.BottomRight.Y-scaled.TopLeft.Y,
+		),
+	}
+	return m.space.WrapAABB(world), sub
+}
+
+// EntrySubpixelAABB returns the fragment stored under entryID in fixed-point
+// units (world units << SubpixelBits). ok is false when the entry was placed
+// in whole world units; EntryAABB still describes it then.
+func (m *BucketGridManager) EntrySubpixelAABB(entryID uint64) (spatial.AABB, bool) {
+	if m.index == nil {
+		return spatial.AABB{}, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	id := entryID >> 2
+	sub, ok := m.subpixel[id]
+	if !ok {
+		return spatial.AABB{}, false
+	}
+	frag, ok := m.index.EntryAABB(entryID)
+	if !ok {
+		return spatial.AABB{}, false
+	}
+	union := m.entries[id]
+	world := m.space.Viewport().BottomRight
+	bits := m.cfg.SubpixelBits
+	minX, maxX := subpixelSpan(frag.TopLeft.X, union.TopLeft.X, sub.offset.X, sub.size.X, world.X, bits)
+	minY, maxY := subpixelSpan(frag.TopLeft.Y, union.TopLeft.Y, sub.offset.Y, sub.size.Y, world.Y, bits)
+	return geom.NewAABB(geom.NewVec(minX, minY), geom.NewVec(maxX, maxY)), true
+}
+
+// subpixelSpan maps one axis of a fragment to fixed-point units. A fragment
+// starting before the entry wrapped past the world edge and begins at 0.
+func subpixelSpan(fragMin, unionMin, offset, size, side uint32, bits uint8) (uint32, uint32) {
+	start := unionMin<<bits + offset
+	end := start + size
+	limit := side << bits
+	if fragMin < unionMin {
+		return 0, end - limit
+	}
+	return start, min(end, limit)
+}
@@ -0,0 +1,78 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
+
+func TestSubpixel_Conversions(t *testing.T) {
+	if got := ToSubpixel(10.3, 8); got != 2637 {
+		t.Errorf("ToSubpixel(10.3, 8) = %d, want 2637", got)
+	}
+	if got := ToSubpixel(-1, 8); got != 0 {
+		t.Errorf("ToSubpixel(-1, 8) = %d, want 0", got)
+	}
+	if got := FromSubpixel(2688, 8); got != 10.5 {
+		t.Errorf("FromSubpixel(2688, 8) = %v, want 10.5", got)
+	}
+}
+
+func TestBucketGridManager_RejectsOverflowingSubpixelBits(t *testing.T) {
+	_, err := NewBucketGridManager(plane.NewToroidal2D[uint32](256, 256), GridLevelConfig{
+		Resoltuion:   spatial.Size256x256,
+		SubpixelBits: 24,
+	})
+	if err == nil {
+		t.Fatal("expected an error for 24 subpixel bits on a 256 world")
+	}
+}
+
+func TestBucketGridManager_ScaledInsertKeepsSubpixelPlacement(t *testing.T) {
+	manager, _ := newTestManager(t, func(cfg *GridLevelConfig) { cfg.SubpixelBits = 8 })
+	scaled := ScaledAABB(10.5, 20.25, 4, 4, 8)
+
+	shape := manager.QueueInsertScaled(1, scaled)
+	want := geom.NewAABB(geom.NewVec[uint32](10, 20), geom.NewVec[uint32](15, 25))
+	if shape.AABB != want {
+		t.Fatalf("covering AABB = %v, want %v", shape.AABB, want)
+	}
+	manager.Flush()
+
+	if aabb, ok := manager.EntryAABB(1 << 2); !ok || aabb != want {
+		t.Fatalf("EntryAABB = %v, %v; want %v", aabb, ok, want)
+	}
+	if aabb, ok := manager.EntrySubpixelAABB(1 << 2); !ok || aabb != scaled {
+		t.Fatalf("EntrySubpixelAABB = %v, %v; want %v", aabb, ok, scaled)
+	}
+
+	manager.QueueUpdate(1, plane.NewToroidal2D[uint32](256, 256).WrapAABB(want), true)
+	manager.Flush()
+	if _, ok := manager.EntrySubpixelAABB(1 << 2); ok {
+		t.Fatal("a whole-unit update should drop the sub-pixel placement")
+	}
+}
+
+func TestBucketGridManager_ScaledFragmentsSplitAtWorldEdge(t *testing.T) {
+	manager, _ := newTestManager(t, func(cfg *GridLevelConfig) { cfg.SubpixelBits = 8 })
+	manager.QueueInsertScaled(1, ScaledAABB(254.5, 0, 3, 1, 8))
+	manager.Flush()
+
+	spans := make(map[uint32]spatial.AABB)
+	manager.QueryRange(geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](256, 256)), func(entryID uint64) {
+		frag, _ := manager.EntryAABB(entryID)
+		aabb, ok := manager.EntrySubpixelAABB(entryID)
+		if !ok {
+			t.Errorf("entry %d has no sub-pixel placement", entryID)
+		}
+		spans[frag.TopLeft.X] = aabb
+	})
+	if got := spans[254]; got.TopLeft.X != 254*256+128 || got.BottomRight.X != 256*256 {
+		t.Errorf("base fragment = %v, want x in [%d, %d]", got, 254*256+128, 256*256)
+	}
+	if got := spans[0]; got.TopLeft.X != 0 || got.BottomRight.X != 384 {
+		t.Errorf("wrapped fragment = %v, want x in [0, 384]", got)
+	}
+}
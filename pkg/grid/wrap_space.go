@@ -0,0 +1,173 @@
+package grid
+
+import (
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+)
+
+// toroidalName is the Name of the gokg torus. spatial.GridIndexManager only
+// splits entries through Space2D.WrapAABB for spaces reporting it.
+const toroidalName = "Toroidal2D"
+
+// NewWrapSpace returns the space of a sizeX x sizeY world that wraps on the
+// selected axes: a torus for both, a bounded plane for neither and a cylinder
+// otherwise. The cylinder wraps like the torus on its wrapping axis and clamps
+// to the world like the plane on the other.
+func NewWrapSpace(sizeX, sizeY uint32, wrapX, wrapY bool) plane.Space2D[uint32] {
+	switch {
+	case wrapX && wrapY:
+		return plane.NewToroidal2D(sizeX, sizeY)
+	case !wrapX && !wrapY:
+		return plane.NewEuclidean2D(sizeX, sizeY)
+	}
+	return &cylinder2d{
+		torus: plane.NewToroidal2D(sizeX, sizeY),
+		size:  geom.NewVec(sizeX, sizeY),
+		wrapX: wrapX,
+		wrapY: wrapY,
+	}
+}
+
+// SpaceWraps reports on which axes space wraps.
+func SpaceWraps(space plane.Space2D[uint32]) (x, y bool) {
+	if space == nil {
+		return false, false
+	}
+	if c, ok := space.(*cylinder2d); ok {
+		return c.wrapX, c.wrapY
+	}
+	wrap := space.Name() == toroidalName
+	return wrap, wrap
+}
+
+// spaceWorld returns the world size on the wrapping axes of space and zero on
+// the others, the form the cache and view mapping math expects.
+func spaceWorld(space plane.Space2D[uint32], side uint32) geom.Vec[uint32] {
+	var world geom.Vec[uint32]
+	wrapX, wrapY := SpaceWraps(space)
+	if wrapX {
+		world.X = side
+	}
+	if wrapY {
+		world.Y = side
+	}
+	return world
+}
+
+// cylinder2d wraps on one axis only. It clamps the other axis into the world
+// before delegating to a torus, so wrapped fragments only ever appear across
+// the wrapping seam.
+type cylinder2d struct {
+	torus        plane.Space2D[uint32]
+	size         geom.Vec[uint32]
+	wrapX, wrapY bool
+}
+
+// Name reports the torus name so spatial.GridIndexManager fragments entries
+// through WrapAABB; use SpaceWraps to tell the axes apart.
+func (s *cylinder2d) Name() string { return toroidalName }
+
+func (s *cylinder2d) Viewport() geom.AABB[uint32] { return s.torus.Viewport() }
+
+func (s *cylinder2d) WrapAABB(aabb geom.AABB[uint32]) plane.AABB[uint32] {
+	if !s.wrapX {
+		aabb.TopLeft.X, aabb.BottomRight.X = clampSpan(aabb.TopLeft.X, aabb.BottomRight.X, s.size.X)
+	}
+	if !s.wrapY {
+		aabb.TopLeft.Y, aabb.BottomRight.Y = clampSpan(aabb.TopLeft.Y, aabb.BottomRight.Y, s.size.Y)
+	}
+	return s.torus.WrapAABB(aabb)
+}
+
+func (s *cylinder2d) WrapVec(vec geom.Vec[uint32]) plane.AABB[uint32] {
+	return s.WrapAABB(geom.NewAABBAt(vec, 0, 0))
+}
+
+func (s *cylinder2d) Normalize(aabb geom.AABB[uint32]) geom.AABB[uint32] {
+	return s.WrapAABB(aabb).AABB
+}
+
+func (s *cylinder2d) Expand(aabb *plane.AABB[uint32], margin uint32) {
+	full := unwrappedAABB(aabb)
+	x, w := expandAxis(full.TopLeft.X, full.BottomRight.X, margin, s.size.X, s.wrapX)
+	y, h := expandAxis(full.TopLeft.Y, full.BottomRight.Y, margin, s.size.Y, s.wrapY)
+	*aabb = s.WrapAABB(geom.NewAABBAt(geom.NewVec(x, y), w, h))
+}
+
+// Translate moves aabb by delta, whose components are two's complement
+// offsets as with the gokg spaces. On the clamped axis the box stops at the
+// world edges, as with BucketGridManager.Offset.
+func (s *cylinder2d) Translate(aabb *plane.AABB[uint32], delta geom.Vec[uint32]) {
+	full := unwrappedAABB(aabb)
+	w := full.BottomRight.X - full.TopLeft.X
+	h := full.BottomRight.Y - full.TopLeft.Y
+	x := offsetAxis(full.TopLeft.X, w, s.size.X, int(int32(delta.X)), s.wrapX)
+	y := offsetAxis(full.TopLeft.Y, h, s.size.Y, int(int32(delta.Y)), s.wrapY)
+	*aabb = s.WrapAABB(geom.NewAABBAt(geom.NewVec(x, y), w, h))
+}
+
+// AABBDistance measures gaps the short way around the wrapping axis.
+func (s *cylinder2d) AABBDistance() plane.AABBDistance[uint32] {
+	math := geom.VectorMathByType[uint32]()
+	return func(a, b geom.AABB[uint32]) uint32 {
+		if a.Intersects(b) {
+			return 0
+		}
+		dx, dy := a.AxisDistanceX(b), a.AxisDistanceY(b)
+		if s.wrapX {
+			dx = wrappedGap(dx, s.size.X)
+		}
+		if s.wrapY {
+			dy = wrappedGap(dy, s.size.Y)
+		}
+		return math.Length(geom.NewVec(dx, dy))
+	}
+}
+
+// clampSpan clamps [lo, hi] into a world side. A span starting at or past
+// the far edge keeps its last unit, like the index clamp to MaxCoord, since
+// the torus would otherwise wrap it to 0.
+func clampSpan(lo, hi, side uint32) (uint32, uint32) {
+	if side == 0 {
+		return 0, 0
+	}
+	hi = min(hi, side)
+	lo = min(lo, side-1)
+	return lo, max(lo, hi)
+}
+
+// expandAxis grows [lo, hi] by margin on both sides and returns the new start
+// and extent. WrapAABB clamps the far side of a non-wrapping axis.
+func expandAxis(lo, hi, margin, side uint32, wrap bool) (uint32, uint32) {
+	if wrap {
+		return offsetAxis(lo, 0, side, -int(margin), true), hi - lo + 2*margin
+	}
+	start := lo - min(lo, margin)
+	return start, hi + margin - start
+}
+
+func wrappedGap(d, side uint32) uint32 {
+	if side == 0 {
+		return d
+	}
+	d %= side
+	return min(d, side-d)
+}
+
+// unwrappedAABB returns the base AABB of aabb extended by its fragments
+// wrapped past the right and bottom edges.
+func unwrappedAABB(aabb *plane.AABB[uint32]) geom.AABB[uint32] {
+	full := aabb.AABB
+	aabb.VisitFragments(func(pos plane.FragPosition, frag geom.AABB[uint32]) bool {
+		switch pos {
+		case plane.FRAG_RIGHT, plane.FRAG_BOTTOM_RIGHT:
+			full.BottomRight.X = max(full.BottomRight.X, aabb.BottomRight.X+frag.BottomRight.X-frag.TopLeft.X)
+		}
+		switch pos {
+		case plane.FRAG_BOTTOM, plane.FRAG_BOTTOM_RIGHT:
+			full.BottomRight.Y = max(full.BottomRight.Y, aabb.BottomRight.Y+frag.BottomRight.Y-frag.TopLeft.Y)
+		}
+		return true
+	})
+	return full
+}
@@ -0,0 +1,87 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+)
+
+func fragmentsOf(aabb plane.AABB[uint32]) []geom.AABB[uint32] {
+	var out []geom.AABB[uint32]
+	aabb.VisitFragments(func(_ plane.FragPosition, frag geom.AABB[uint32]) bool {
+		out = append(out, frag)
+		return true
+	})
+	return out
+}
+
+func TestNewWrapSpace_PicksSpaceByAxes(t *testing.T) {
+	cases := []struct {
+		wrapX, wrapY bool
+	}{{true, true}, {false, false}, {true, false}, {false, true}}
+	for _, tc := range cases {
+		x, y := SpaceWraps(NewWrapSpace(256, 256, tc.wrapX, tc.wrapY))
+		if x != tc.wrapX || y != tc.wrapY {
+			t.Errorf("SpaceWraps(%v, %v) = %v, %v", tc.wrapX, tc.wrapY, x, y)
+		}
+	}
+}
+
+func TestCylinder_WrapAABBSplitsOnlyAcrossWrappingAxis(t *testing.T) {
+	space := NewWrapSpace(256, 256, true, false)
+
+	wrapped := space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](250, 250), 10, 10))
+	want := geom.NewAABB(geom.NewVec[uint32](250, 250), geom.NewVec[uint32](256, 256))
+	if wrapped.AABB != want {
+		t.Fatalf("base = %v, want %v", wrapped.AABB, want)
+	}
+	frags := fragmentsOf(wrapped)
+	wantFrag := geom.NewAABB(geom.NewVec[uint32](0, 250), geom.NewVec[uint32](4, 256))
+	if len(frags) != 1 || frags[0] != wantFrag {
+		t.Fatalf("fragments = %v, want [%v]", frags, wantFrag)
+	}
+
+	moved := space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 240), 10, 10))
+	space.Translate(&moved, geom.NewVec(^uint32(19), uint32(30)))
+	want = geom.NewAABB(geom.NewVec[uint32](246, 246), geom.NewVec[uint32](256, 256))
+	if moved.AABB != want {
+		t.Fatalf("translated base = %v, want %v", moved.AABB, want)
+	}
+	if frags := fragmentsOf(moved); len(frags) != 0 {
+		t.Fatalf("translated fragments = %v, want none", frags)
+	}
+}
+
+func TestBucketGridManager_CylinderWrapsOneAxis(t *testing.T) {
+	space := NewWrapSpace(256, 256, false, true)
+	manager, err := NewBucketGridManager(space, GridLevelConfig{
+		Resoltuion:       spatial.Size256x256,
+		BucketResolution: spatial.Size32x32,
+		BucketCapacity:   4,
+	})
+	if err != nil {
+		t.Fatalf("NewBucketGridManager: %v", err)
+	}
+
+	plan := manager.Plan(geom.NewAABB(geom.NewVec[uint32](0, 0), geom.NewVec[uint32](64, 64)), 1)
+	want := geom.NewAABB(geom.NewVec[uint32](0, 224), geom.NewVec[uint32](96, 352))
+	if plan.CacheRect != want {
+		t.Fatalf("cache rect = %v, want %v", plan.CacheRect, want)
+	}
+
+	got := manager.WrapRect(geom.NewAABB(geom.NewVec(-10, 250), geom.NewVec(20, 260)))
+	wantRects := []geom.AABB[int]{
+		geom.NewAABB(geom.NewVec(0, 250), geom.NewVec(20, 256)),
+		geom.NewAABB(geom.NewVec(0, 0), geom.NewVec(20, 4)),
+	}
+	if len(got) != len(wantRects) || got[0] != wantRects[0] || got[1] != wantRects[1] {
+		t.Fatalf("wrapped rect = %v, want %v", got, wantRects)
+	}
+
+	moved := manager.Offset(geom.NewAABBAt(geom.NewVec[uint32](250, 250), 4, 4), geom.NewVec(10, 10))
+	if moved.TopLeft != geom.NewVec[uint32](252, 4) {
+		t.Fatalf("offset = %v, want clamped X and wrapped Y", moved.AABB)
+	}
+}
@@ -2,19 +2,30 @@ package gridbridge
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
+	"github.com/kjkrol/gokg/pkg/geom"
 	"github.com/kjkrol/gokg/pkg/plane"
 	"github.com/kjkrol/gokg/pkg/spatial"
 	"github.com/kjkrol/gokx/pkg/gfx"
 	"github.com/kjkrol/gokx/pkg/grid"
 )
 
+var (
+	_ gfx.DrawableMovedApplier = (*Bridge)(nil)
+	_ gfx.FlushTouchedReporter = (*Bridge)(nil)
+)
+
 type Bridge struct {
 	paneManagers map[*gfx.Pane]*grid.MultiBucketGridManager
 	panesByID    map[uint64]*gfx.Pane
 	managerByPID map[uint64]*grid.MultiBucketGridManager
 	layerConfigs map[*gfx.Layer]grid.GridLevelConfig
 	touched      map[*grid.BucketGridManager]struct{}
+	// backlogs holds the coalesced bucket deltas of static layers waiting
+	// for their next sync.
+	backlogs map[*gfx.Layer]*deltaBacklog
 }
 
 func NewBridge() *Bridge {
@@ -24,6 +35,7 @@ func NewBridge() *Bridge {
 		managerByPID: make(map[uint64]*grid.MultiBucketGridManager),
 		layerConfigs: make(map[*gfx.Layer]grid.GridLevelConfig),
 		touched:      make(map[*grid.BucketGridManager]struct{}),
+		backlogs:     make(map[*gfx.Layer]*deltaBacklog),
 	}
 }
 
@@ -74,6 +86,50 @@ func (b *Bridge) OnDrawableRemoved(layer *gfx.Layer, _ *gfx.Drawable, id uint64)
 	b.markTouched(manager)
 }
 
+// OnDrawableMoved transfers the drawable's entry between the layers' grid
+// managers at once (see grid.BucketGridManager.TransferTo), so queries never
+// miss it between the two layers.
+func (b *Bridge) OnDrawableMoved(src, dst *gfx.Layer, drawable *gfx.Drawable, id uint64) {
+	if drawable == nil || id == 0 {
+		return
+	}
+	from, to := b.layerManager(src), b.layerManager(dst)
+	switch {
+	case from != nil && to != nil:
+		from.TransferTo(to, id, drawable.AABB)
+	case from != nil:
+		from.QueueRemove(id)
+	case to != nil:
+		to.QueueInsert(id, drawable.AABB)
+	}
+	b.markTouched(from)
+	b.markTouched(to)
+}
+
+// OnDrawableStyleChanged reports the drawable's entry as updated in its
+// buckets, so renderers re-read its style without a spatial move.
+func (b *Bridge) OnDrawableStyleChanged(layer *gfx.Layer, _ *gfx.Drawable, id uint64) {
+	manager := b.layerManager(layer)
+	if manager == nil || id == 0 {
+		return
+	}
+	manager.MarkEntryUpdated(id)
+	b.markTouched(manager)
+}
+
+// OnLayerRemoved drops the grid manager and config of a layer removed by
+// gfx.Pane.Clear.
+func (b *Bridge) OnLayerRemoved(layer *gfx.Layer) {
+	delete(b.layerConfigs, layer)
+	delete(b.backlogs, layer)
+	manager := b.layerManager(layer)
+	if manager == nil {
+		return
+	}
+	delete(b.touched, manager)
+	b.paneManager(layer.GetPane()).Unregister(layer.ID())
+}
+
 func (b *Bridge) OnLayerDirtyRect(layer *gfx.Layer, rect spatial.AABB) {
 	manager := b.layerManager(layer)
 	if manager == nil {
@@ -100,14 +156,23 @@ func (b *Bridge) BuildFrame(pane *gfx.Pane, viewRect spatial.AABB, viewChanged b
 		key := layer.ID()
 		keyToLayer[key] = layer
 	}
-	keys := make([]uint64, 0, len(layers))
+	var worldSize geom.Vec[uint32]
+	var wrapX, wrapY bool
+	if view := pane.Viewport(); view != nil {
+		worldSize = view.WorldSize()
+		wrapX, wrapY = view.WrapAxes()
+	}
+	views := make([]grid.LayerView, 0, len(layers))
 	for _, layer := range layers {
 		if layer == nil {
 			continue
 		}
-		keys = append(keys, layer.ID())
+		views = append(views, grid.LayerView{
+			Key:      layer.ID(),
+			ViewRect: layer.ParallaxViewRectAxes(viewRect, worldSize, wrapX, wrapY),
+		})
 	}
-	frame := manager.BuildFrame(viewRect, viewChanged, keys)
+	frame := manager.BuildFrameLayers(viewRect, viewChanged, views)
 	out.ViewRect = frame.ViewRect
 	out.ViewChanged = frame.ViewChanged
 	if len(frame.CompositeRects) > 0 {
@@ -128,6 +193,7 @@ func (b *Bridge) BuildFrame(pane *gfx.Pane, viewRect spatial.AABB, viewChanged b
 		}
 		out.Layers = append(out.Layers, gfx.LayerPlan{
 			Layer:         layer,
+			ViewRect:      gridLevelPlan.ViewRect,
 			CacheRect:     gridLevelPlan.CacheRect,
 			BucketIndices: indices,
 			BucketRect:    gridLevelPlan.BucketRect,
@@ -136,12 +202,32 @@ func (b *Bridge) BuildFrame(pane *gfx.Pane, viewRect spatial.AABB, viewChanged b
 	return out
 }
 
+// ConsumeBucketDeltas drains the layer's bucket deltas on every call. A
+// static layer between syncs gets none: its deltas are coalesced and handed
+// over on its next sync, so they neither pile up in the manager nor get
+// lost.
 func (b *Bridge) ConsumeBucketDeltas(layer *gfx.Layer) []gfx.BucketDelta {
 	manager := b.layerManager(layer)
 	if manager == nil {
 		return nil
 	}
 	deltas := manager.ConsumeBucketDeltas()
+	backlog := b.backlogs[layer]
+	if !layer.ConsumeBucketSync() {
+		if len(deltas) > 0 {
+			if backlog == nil {
+				backlog = newDeltaBacklog()
+				b.backlogs[layer] = backlog
+			}
+			backlog.add(deltas)
+		}
+		return nil
+	}
+	if backlog != nil {
+		delete(b.backlogs, layer)
+		backlog.add(deltas)
+		deltas = backlog.deltas()
+	}
 	if len(deltas) == 0 {
 		return nil
 	}
@@ -157,6 +243,72 @@ func (b *Bridge) ConsumeBucketDeltas(layer *gfx.Layer) []gfx.BucketDelta {
 	return out
 }
 
+// deltaBacklog coalesces bucket deltas into the net change of each entry in
+// each bucket, so it grows with the entries touched, not with the edits.
+type deltaBacklog struct {
+	buckets map[spatial.AABB]map[uint64]entryChange
+	order   []spatial.AABB
+}
+
+// entryChange records whether the renderer held an entry in a bucket before
+// the backlog started and whether it holds it after.
+type entryChange struct {
+	before, after bool
+}
+
+func newDeltaBacklog() *deltaBacklog {
+	return &deltaBacklog{buckets: make(map[spatial.AABB]map[uint64]entryChange)}
+}
+
+// add folds deltas in, reading each delta's Removed, Added and Updated in
+// the order renderers apply them.
+func (d *deltaBacklog) add(deltas []grid.BucketDelta) {
+	for _, delta := range deltas {
+		entries := d.buckets[delta.Bucket]
+		if entries == nil {
+			entries = make(map[uint64]entryChange)
+			d.buckets[delta.Bucket] = entries
+			d.order = append(d.order, delta.Bucket)
+		}
+		note := func(ids []uint64, before, after bool) {
+			for _, id := range ids {
+				change, ok := entries[id]
+				if !ok {
+					change.before = before
+				}
+				change.after = after
+				entries[id] = change
+			}
+		}
+		note(delta.Removed, true, false)
+		note(delta.Added, false, true)
+		note(delta.Updated, true, true)
+	}
+}
+
+// deltas returns the net changes, one delta per bucket that has any.
+func (d *deltaBacklog) deltas() []grid.BucketDelta {
+	out := make([]grid.BucketDelta, 0, len(d.order))
+	for _, bucket := range d.order {
+		entries := d.buckets[bucket]
+		delta := grid.BucketDelta{Bucket: bucket}
+		for _, id := range slices.Sorted(maps.Keys(entries)) {
+			switch change := entries[id]; {
+			case change.before && change.after:
+				delta.Updated = append(delta.Updated, id)
+			case change.before:
+				delta.Removed = append(delta.Removed, id)
+			case change.after:
+				delta.Added = append(delta.Added, id)
+			}
+		}
+		if len(delta.Added)+len(delta.Removed)+len(delta.Updated) > 0 {
+			out = append(out, delta)
+		}
+	}
+	return out
+}
+
 func (b *Bridge) EntryAABB(layer *gfx.Layer, entryID uint64) (spatial.AABB, bool) {
 	manager := b.layerManager(layer)
 	if manager == nil {
@@ -165,6 +317,98 @@ func (b *Bridge) EntryAABB(layer *gfx.Layer, entryID uint64) (spatial.AABB, bool
 	return manager.EntryAABB(entryID)
 }
 
+func (b *Bridge) EntrySubpixelAABB(layer *gfx.Layer, entryID uint64) (spatial.AABB, uint8, bool) {
+	manager := b.layerManager(layer)
+	if manager == nil {
+		return spatial.AABB{}, 0, false
+	}
+	aabb, ok := manager.EntrySubpixelAABB(entryID)
+	return aabb, manager.SubpixelBits(), ok
+}
+
+// MoveDrawableScaled places the drawable at an AABB in fixed-point units
+// (world units << the layer's GridLevelConfig.SubpixelBits) for smooth
+// sub-pixel motion. The drawable's AABB is set to the covering whole-unit
+// box the grid indexes it under.
+func (b *Bridge) MoveDrawableScaled(layer *gfx.Layer, drawable *gfx.Drawable, scaled spatial.AABB) {
+	manager := b.layerManager(layer)
+	if manager == nil || drawable == nil {
+		return
+	}
+	id, ok := layer.DrawableID(drawable)
+	if !ok {
+		return
+	}
+	queueOldDirty(manager, drawable.AABB)
+	drawable.AABB = manager.QueueUpdateScaled(id, scaled, true)
+	b.markTouched(manager)
+	b.syncProxies(drawable, drawable.AABB)
+}
+
+func (b *Bridge) QueryRange(layer *gfx.Layer, rect spatial.AABB, collector func(entryID uint64)) {
+	manager := b.layerManager(layer)
+	if manager == nil || collector == nil {
+		return
+	}
+	manager.QueryRange(rect, collector)
+}
+
+// QueryContained reports the drawables of the layer lying entirely inside
+// rect, e.g. for marquee selection; see grid.BucketGridManager.QueryContained.
+func (b *Bridge) QueryContained(layer *gfx.Layer, rect spatial.AABB, collector func(drawable *gfx.Drawable)) {
+	manager := b.layerManager(layer)
+	if manager == nil || collector == nil {
+		return
+	}
+	manager.QueryContained(rect, func(id uint64) {
+		if drawable := layer.DrawableByID(id); drawable != nil {
+			collector(drawable)
+		}
+	})
+}
+
+// LayerHit is a drawable matched by QueryRectAllLayers together with the
+// layer that holds it.
+type LayerHit struct {
+	Layer    *gfx.Layer
+	Drawable *gfx.Drawable
+}
+
+// QueryRectAllLayers returns the drawables of every layer of the pane that
+// intersect rect, bottom layer first. A drawable split across the world seam
+// is reported once.
+func (b *Bridge) QueryRectAllLayers(paneID uint64, rect spatial.AABB) []LayerHit {
+	pane := b.panesByID[paneID]
+	manager := b.PaneManagerByID(paneID)
+	if pane == nil || manager == nil {
+		return nil
+	}
+	layers := make(map[uint64]*gfx.Layer)
+	for _, layer := range pane.Layers() {
+		if layer != nil {
+			layers[layer.ID()] = layer
+		}
+	}
+	var out []LayerHit
+	seen := make(map[*gfx.Drawable]struct{})
+	manager.QueryRangeAll(rect, func(key uint64, entryID uint64) {
+		layer := layers[key]
+		if layer == nil {
+			return
+		}
+		drawable := layer.DrawableByEntryID(entryID)
+		if drawable == nil {
+			return
+		}
+		if _, dup := seen[drawable]; dup {
+			return
+		}
+		seen[drawable] = struct{}{}
+		out = append(out, LayerHit{Layer: layer, Drawable: drawable})
+	})
+	return out
+}
+
 func (b *Bridge) AcknowledgeRendered(layer *gfx.Layer, bucketIndices []uint32) {
 	manager := b.layerManager(layer)
 	if manager == nil {
@@ -189,6 +433,59 @@ func (b *Bridge) LayerManagerByID(paneID, layerID uint64) *grid.BucketGridManage
 	return manager.Manager(layerID)
 }
 
+// InvalidatePane forces a full repaint of every layer attached to the pane,
+// e.g. after a theme change or when the window is restored.
+func (b *Bridge) InvalidatePane(paneID uint64) {
+	manager := b.PaneManagerByID(paneID)
+	if manager == nil {
+		return
+	}
+	manager.MarkAllDirty()
+}
+
+// MarkViewDirty repaints what the pane shows at viewRect, given in world
+// units relative to the top-left of its viewport, e.g. after an overlay
+// redraw. Each layer offsets viewRect by its own (parallax) view origin; the
+// result is split at the seams of wrapping axes and clipped to a bounded
+// world.
+func (b *Bridge) MarkViewDirty(paneID uint64, viewRect geom.AABB[int]) {
+	pane, manager := b.panesByID[paneID], b.PaneManagerByID(paneID)
+	if pane == nil || manager == nil {
+		return
+	}
+	view := pane.Viewport()
+	if view == nil {
+		return
+	}
+	rect, world := view.Rect(), view.WorldSize()
+	wrapX, wrapY := view.WrapAxes()
+	for _, layer := range pane.Layers() {
+		gridMgr := manager.Manager(layer.ID())
+		if gridMgr == nil {
+			continue
+		}
+		origin := layer.ParallaxViewRectAxes(rect, world, wrapX, wrapY).TopLeft
+		offset := geom.NewVec(int(origin.X), int(origin.Y))
+		shifted := geom.NewAABB(viewRect.TopLeft.Add(offset), viewRect.BottomRight.Add(offset))
+		for _, piece := range gridMgr.WrapRect(shifted) {
+			gridMgr.MarkRectDirty(geom.NewAABB(
+				geom.NewVec(uint32(piece.TopLeft.X), uint32(piece.TopLeft.Y)),
+				geom.NewVec(uint32(piece.BottomRight.X), uint32(piece.BottomRight.Y)),
+			))
+		}
+	}
+}
+
+// FrameProfile sums the grid FrameProfile of every profiled layer attached
+// to the pane, for telling grid time apart from renderer time in a frame.
+func (b *Bridge) FrameProfile(paneID uint64) grid.FrameProfile {
+	manager := b.PaneManagerByID(paneID)
+	if manager == nil {
+		return grid.FrameProfile{}
+	}
+	return manager.FrameProfile()
+}
+
 func (b *Bridge) registerLayer(pane *gfx.Pane, layer *gfx.Layer) error {
 	if pane == nil || layer == nil {
 		return nil
@@ -280,24 +577,92 @@ func (b *Bridge) ApplyTranslated(items []gfx.DrawableTranslate) {
 			continue
 		}
 		manager.QueueUpdate(item.DrawableID, item.New, true)
-		old := item.Old
-		base := old.AABB
-		if base.TopLeft != base.BottomRight {
-			manager.QueueDirtyRect(base)
-			old.VisitFragments(func(_ plane.FragPosition, frag spatial.AABB) bool {
-				manager.QueueDirtyRect(frag)
-				return true
-			})
+		queueOldDirty(manager, item.Old)
+		b.markTouched(manager)
+		if drawable := b.drawableByID(item.PaneID, item.LayerID, item.DrawableID); drawable != nil {
+			b.syncProxies(drawable, item.New)
 		}
+	}
+}
+
+// ApplyMoved offsets each drawable's grid entry by its delta. Moves start
+// from the entry's queued position, so they add up with earlier moves and
+// translations not flushed yet.
+func (b *Bridge) ApplyMoved(items []gfx.DrawableMove) {
+	for _, item := range items {
+		manager := b.LayerManagerByID(item.PaneID, item.LayerID)
+		if manager == nil || item.DrawableID == 0 {
+			continue
+		}
+		current, ok := manager.QueuedEntry(item.DrawableID)
+		if !ok {
+			continue
+		}
+		old := manager.Offset(current, geom.Vec[int]{})
+		next := manager.Offset(current, item.Delta)
+		manager.QueueUpdate(item.DrawableID, next, true)
+		queueOldDirty(manager, old)
+		if drawable := b.drawableByID(item.PaneID, item.LayerID, item.DrawableID); drawable != nil {
+			drawable.AABB = next
+			b.syncProxies(drawable, next)
+		}
+		b.markTouched(manager)
+	}
+}
+
+func (b *Bridge) drawableByID(paneID, layerID, drawableID uint64) *gfx.Drawable {
+	pane := b.panesByID[paneID]
+	if pane == nil {
+		return nil
+	}
+	for _, layer := range pane.Layers() {
+		if layer.ID() == layerID {
+			return layer.DrawableByID(drawableID)
+		}
+	}
+	return nil
+}
+
+// syncProxies moves the proxies of drawable (see gfx.Layer.AddProxy) to aabb
+// in their own layers' managers.
+func (b *Bridge) syncProxies(drawable *gfx.Drawable, aabb plane.AABB[uint32]) {
+	for _, proxy := range drawable.Proxies() {
+		layer := proxy.Layer()
+		manager := b.layerManager(layer)
+		if manager == nil {
+			continue
+		}
+		id, ok := layer.DrawableID(proxy)
+		if !ok {
+			continue
+		}
+		manager.QueueUpdate(id, aabb, true)
+		queueOldDirty(manager, proxy.AABB)
+		proxy.AABB = aabb
 		b.markTouched(manager)
 	}
 }
 
+// queueOldDirty marks the area a drawable is leaving dirty.
+func queueOldDirty(manager *grid.BucketGridManager, old plane.AABB[uint32]) {
+	base := old.AABB
+	if base.TopLeft == base.BottomRight {
+		return
+	}
+	manager.QueueDirtyRect(base)
+	old.VisitFragments(func(_ plane.FragPosition, frag spatial.AABB) bool {
+		manager.QueueDirtyRect(frag)
+		return true
+	})
+}
+
+// FlushTouched flushes every grid manager changed since the last call.
 func (b *Bridge) FlushTouched() {
 	touched := b.touched
-	if len(touched) > 0 {
-		b.touched = make(map[*grid.BucketGridManager]struct{}, len(touched))
+	if len(touched) == 0 {
+		return
 	}
+	b.touched = make(map[*grid.BucketGridManager]struct{}, len(touched))
 	for manager := range touched {
 		if manager != nil {
 			manager.Flush()
@@ -305,6 +670,12 @@ func (b *Bridge) FlushTouched() {
 	}
 }
 
+// Touched reports whether a grid manager changed since the last
+// FlushTouched.
+func (b *Bridge) Touched() bool {
+	return len(b.touched) > 0
+}
+
 func (b *Bridge) markTouched(manager *grid.BucketGridManager) {
 	if manager == nil {
 		return
@@ -0,0 +1,221 @@
+package gridbridge
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kjkrol/gokg/pkg/geom"
+	"github.com/kjkrol/gokg/pkg/plane"
+	"github.com/kjkrol/gokg/pkg/spatial"
+	"github.com/kjkrol/gokx/pkg/gfx"
+	"github.com/kjkrol/gokx/pkg/grid"
+)
+
+var testWorld = geom.NewAABBAt(geom.NewVec[uint32](0, 0), 256, 256)
+
+// newTestBridge attaches a 64x64 pane over a 256x256 world split into 32x32
+// buckets, with the given number of layers.
+func newTestBridge(t *testing.T, world gfx.WorldConfig, layers int) (*Bridge, *gfx.Pane) {
+	t.Helper()
+	world.WorldResolution = spatial.Size256x256
+	pane := gfx.NewPane(&gfx.PaneConfig{Width: 64, Height: 64, World: world}, 1)
+	var space plane.Space2D[uint32] = plane.NewEuclidean2D[uint32](256, 256)
+	if world.WorldWrap {
+		space = plane.NewToroidal2D[uint32](256, 256)
+	}
+	bridge := NewBridge()
+	bridge.AttachPane(pane, grid.NewMultiBucketGridManager(space, spatial.Size256x256, 1, spatial.Size32x32, 4))
+	for i := 1; i < layers; i++ {
+		if !pane.AddLayer(i) {
+			t.Fatalf("AddLayer(%d) failed", i)
+		}
+	}
+	for _, layer := range pane.Layers() {
+		dirtyBuckets(t, bridge, pane, layer)
+	}
+	return bridge, pane
+}
+
+// dirtyBuckets returns the top-left corners of the layer's dirty buckets,
+// row by row, and marks them rendered.
+func dirtyBuckets(t *testing.T, bridge *Bridge, pane *gfx.Pane, layer *gfx.Layer) []geom.Vec[uint32] {
+	t.Helper()
+	manager := bridge.LayerManagerByID(pane.ID, layer.ID())
+	if manager == nil {
+		t.Fatalf("layer %d has no grid manager", layer.ID())
+	}
+	plan := manager.Plan(testWorld, 0)
+	manager.MarkBucketsRendered(plan.BucketIndices)
+	out := make([]geom.Vec[uint32], 0, len(plan.BucketIndices))
+	for _, idx := range plan.BucketIndices {
+		out = append(out, plan.BucketRect(idx).TopLeft)
+	}
+	slices.SortFunc(out, func(a, b geom.Vec[uint32]) int {
+		if a.Y != b.Y {
+			return int(a.Y) - int(b.Y)
+		}
+		return int(a.X) - int(b.X)
+	})
+	return out
+}
+
+func buckets(corners ...uint32) []geom.Vec[uint32] {
+	out := make([]geom.Vec[uint32], 0, len(corners)/2)
+	for i := 0; i+1 < len(corners); i += 2 {
+		out = append(out, geom.NewVec(corners[i], corners[i+1]))
+	}
+	return out
+}
+
+func TestBridge_MarkViewDirtyOffsetsByViewportOrigin(t *testing.T) {
+	bridge, pane := newTestBridge(t, gfx.WorldConfig{}, 1)
+	pane.Viewport().SetOrigin(96, 32)
+
+	// View (10,10)-(40,20) is world (106,42)-(136,52).
+	bridge.MarkViewDirty(pane.ID, geom.NewAABB(geom.NewVec(10, 10), geom.NewVec(40, 20)))
+	got := dirtyBuckets(t, bridge, pane, pane.GetLayer(0))
+	if want := buckets(96, 32, 128, 32); !slices.Equal(got, want) {
+		t.Fatalf("dirty buckets = %v, want %v", got, want)
+	}
+}
+
+func TestBridge_MarkViewDirtySplitsAtWrapSeam(t *testing.T) {
+	bridge, pane := newTestBridge(t, gfx.WorldConfig{WorldWrap: true}, 1)
+	pane.Viewport().SetOrigin(240, 0)
+
+	// View (4,4)-(24,8) is world (244,4)-(264,8), which wraps to x 0..8.
+	bridge.MarkViewDirty(pane.ID, geom.NewAABB(geom.NewVec(4, 4), geom.NewVec(24, 8)))
+	got := dirtyBuckets(t, bridge, pane, pane.GetLayer(0))
+	if want := buckets(0, 0, 224, 0); !slices.Equal(got, want) {
+		t.Fatalf("dirty buckets = %v, want %v", got, want)
+	}
+}
+
+func TestBridge_MarkViewDirtyFollowsLayerParallax(t *testing.T) {
+	bridge, pane := newTestBridge(t, gfx.WorldConfig{}, 2)
+	background := pane.GetLayer(1)
+	background.SetParallax(0.5, 0.5)
+	dirtyBuckets(t, bridge, pane, background)
+	pane.Viewport().SetOrigin(128, 128)
+
+	// The background scrolls at half speed, so it shows (64,64) where the
+	// base layer shows (128,128).
+	bridge.MarkViewDirty(pane.ID, geom.NewAABB(geom.NewVec(4, 4), geom.NewVec(8, 8)))
+	if got, want := dirtyBuckets(t, bridge, pane, pane.GetLayer(0)), buckets(128, 128); !slices.Equal(got, want) {
+		t.Errorf("base layer dirty buckets = %v, want %v", got, want)
+	}
+	if got, want := dirtyBuckets(t, bridge, pane, background), buckets(64, 64); !slices.Equal(got, want) {
+		t.Errorf("parallax layer dirty buckets = %v, want %v", got, want)
+	}
+}
+
+func TestBridge_ApplyMovedAddsUpBeforeFlush(t *testing.T) {
+	bridge, pane := newTestBridge(t, gfx.WorldConfig{}, 1)
+	layer := pane.GetLayer(0)
+	space := plane.NewEuclidean2D[uint32](256, 256)
+	drawable := &gfx.Drawable{AABB: space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4))}
+	layer.AddDrawable(drawable)
+	bridge.FlushTouched()
+	id, _ := layer.DrawableID(drawable)
+	manager := bridge.LayerManagerByID(pane.ID, layer.ID())
+	move := func(dx, dy int) {
+		bridge.ApplyMoved([]gfx.DrawableMove{{PaneID: pane.ID, LayerID: layer.ID(), DrawableID: id, Delta: geom.NewVec(dx, dy)}})
+	}
+
+	move(5, 0)
+	move(0, 7)
+	bridge.FlushTouched()
+	if got, want := mustEntry(t, manager, id), geom.NewAABBAt(geom.NewVec[uint32](15, 17), 4, 4); got != want {
+		t.Fatalf("after two moves entry = %v, want %v", got, want)
+	}
+
+	moved := space.WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](100, 100), 4, 4))
+	bridge.ApplyTranslated([]gfx.DrawableTranslate{{PaneID: pane.ID, LayerID: layer.ID(), DrawableID: id, Old: drawable.AABB, New: moved}})
+	move(-3, 2)
+	bridge.FlushTouched()
+	if got, want := mustEntry(t, manager, id), geom.NewAABBAt(geom.NewVec[uint32](97, 102), 4, 4); got != want {
+		t.Fatalf("after translate and move entry = %v, want %v", got, want)
+	}
+	if got, want := drawable.AABB.AABB, geom.NewAABBAt(geom.NewVec[uint32](97, 102), 4, 4); got != want {
+		t.Fatalf("drawable AABB = %v, want %v", got, want)
+	}
+}
+
+func mustEntry(t *testing.T, manager *grid.BucketGridManager, id uint64) spatial.AABB {
+	t.Helper()
+	aabb, ok := manager.Entry(id)
+	if !ok {
+		t.Fatalf("entry %d missing", id)
+	}
+	return aabb
+}
+
+func TestBridge_StaticLayerCoalescesDeltasUntilSync(t *testing.T) {
+	bridge, pane := newTestBridge(t, gfx.WorldConfig{}, 1)
+	layer := pane.GetLayer(0)
+	drawable := &gfx.Drawable{AABB: plane.NewEuclidean2D[uint32](256, 256).WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4))}
+	layer.AddDrawable(drawable)
+	bridge.FlushTouched()
+	layer.SetStatic(true)
+	if deltas := bridge.ConsumeBucketDeltas(layer); len(deltas) != 1 || len(deltas[0].Added) != 1 {
+		t.Fatalf("first sync = %+v, want the drawable added to one bucket", deltas)
+	}
+
+	// Bounce the drawable between buckets (0,0) and (32,0) 101 times: it
+	// ends up in (32,0).
+	id, _ := layer.DrawableID(drawable)
+	for i := range 101 {
+		dx := 30
+		if i%2 == 1 {
+			dx = -30
+		}
+		bridge.ApplyMoved([]gfx.DrawableMove{{PaneID: pane.ID, LayerID: layer.ID(), DrawableID: id, Delta: geom.NewVec(dx, 0)}})
+		bridge.FlushTouched()
+		if deltas := bridge.ConsumeBucketDeltas(layer); deltas != nil {
+			t.Fatalf("edit %d: static layer synced %+v", i, deltas)
+		}
+	}
+	manager := bridge.LayerManagerByID(pane.ID, layer.ID())
+	if queued := manager.ConsumeBucketDeltas(); len(queued) != 0 {
+		t.Fatalf("manager still queues %d deltas", len(queued))
+	}
+
+	layer.Invalidate()
+	deltas := bridge.ConsumeBucketDeltas(layer)
+	if len(deltas) != 2 {
+		t.Fatalf("sync after invalidate = %+v, want 2 coalesced deltas", deltas)
+	}
+	for _, delta := range deltas {
+		switch delta.Bucket.TopLeft {
+		case geom.NewVec[uint32](0, 0):
+			if len(delta.Removed) != 1 || len(delta.Added)+len(delta.Updated) != 0 {
+				t.Errorf("bucket (0,0) delta = %+v, want one removal", delta)
+			}
+		case geom.NewVec[uint32](32, 0):
+			if len(delta.Added) != 1 || len(delta.Removed)+len(delta.Updated) != 0 {
+				t.Errorf("bucket (32,0) delta = %+v, want one addition", delta)
+			}
+		default:
+			t.Errorf("unexpected delta %+v", delta)
+		}
+	}
+}
+
+func TestBridge_MoveDrawableToTransfersWithoutFlush(t *testing.T) {
+	bridge, pane := newTestBridge(t, gfx.WorldConfig{}, 2)
+	src, dst := pane.GetLayer(0), pane.GetLayer(1)
+	drawable := &gfx.Drawable{AABB: plane.NewEuclidean2D[uint32](256, 256).WrapAABB(geom.NewAABBAt(geom.NewVec[uint32](10, 10), 4, 4))}
+	src.AddDrawable(drawable)
+	bridge.FlushTouched()
+	id, _ := src.DrawableID(drawable)
+
+	if !src.MoveDrawableTo(drawable, dst) {
+		t.Fatal("MoveDrawableTo failed")
+	}
+	if _, ok := bridge.LayerManagerByID(pane.ID, src.ID()).Entry(id); ok {
+		t.Error("source layer still indexes the drawable")
+	}
+	if _, ok := bridge.LayerManagerByID(pane.ID, dst.ID()).Entry(id); !ok {
+		t.Error("destination layer does not index the drawable before FlushTouched")
+	}
+}